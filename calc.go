@@ -1,9 +1,9 @@
-
 package excelize
 
 import (
 	"bytes"
 	"container/list"
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -11,6 +11,7 @@ import (
 	"math/cmplx"
 	"math/rand"
 	"net/url"
+	"path"
 	"reflect"
 	"regexp"
 	"sort"
@@ -25,6 +26,7 @@ import (
 	"github.com/xuri/efp"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
+	"golang.org/x/text/width"
 )
 
 const (
@@ -192,6 +194,234 @@ var (
 		regexp.MustCompile(`^<(.*)$`),
 		regexp.MustCompile(`^>(.*)$`),
 	}
+	// impureFormulaFuncs defined the volatile formula functions which are not
+	// allowed in the pure calculation
+	impureFormulaFuncs = map[string]bool{
+		"CELL":        true,
+		"INFO":        true,
+		"NOW":         true,
+		"OFFSET":      true,
+		"RAND":        true,
+		"RANDBETWEEN": true,
+		"TODAY":       true,
+	}
+	// blankIgnoredFormulaFuncs defined the aggregation functions which ignore
+	// the blank cells, the whole column or row references in the arguments of
+	// these functions will be limited to the used range of the worksheet
+	blankIgnoredFormulaFuncs = map[string]bool{
+		"AVERAGE": true,
+		"COUNT":   true,
+		"COUNTA":  true,
+		"MAX":     true,
+		"MIN":     true,
+		"PRODUCT": true,
+		"SUM":     true,
+	}
+	// spillReference defined the spilled range reference operator (#) which
+	// suffixed to the anchor cell reference, such as A1# or Sheet1!$A$1#
+	spillReference = regexp.MustCompile(`(^|[^\w.$!'\]])((?:'[^']+'|[\w.]+)!)?(\$?[A-Za-z]{1,3}\$?\d+)#`)
+	// formulaFuncsArity defined the minimum and maximum number of arguments
+	// of the formula functions, the maximum -1 means unlimited arguments. The
+	// table is partial, the functions which are not listed here still check
+	// the number of arguments by themselves
+	formulaFuncsArity = map[string][2]int{
+		"ACCRINT":        {6, 8},
+		"ACCRINTM":       {4, 5},
+		"AMORDEGRC":      {6, 7},
+		"AMORLINC":       {6, 7},
+		"ANCHORARRAY":    {1, 1},
+		"ASC":            {1, 1},
+		"AVEDEV":         {1, -1},
+		"AVERAGEIF":      {2, 3},
+		"AVERAGEIFS":     {3, -1},
+		"BINOM.DIST":     {4, 4},
+		"BINOMDIST":      {4, 4},
+		"CEILING.MATH":   {1, 3},
+		"CHAR":           {1, 1},
+		"CHISQ.DIST":     {3, 3},
+		"CHISQ.TEST":     {2, 2},
+		"CHITEST":        {2, 2},
+		"CLEAN":          {1, 1},
+		"COLUMNS":        {1, 1},
+		"CONFIDENCE.T":   {3, 3},
+		"CONVERT":        {3, 3},
+		"CORREL":         {2, 2},
+		"COUNTBLANK":     {1, 1},
+		"COUNTIF":        {2, 2},
+		"COUNTIFS":       {2, -1},
+		"DATEVALUE":      {1, 1},
+		"DAYS":           {2, 2},
+		"DBCS":           {1, 1},
+		"DGET":           {3, 3},
+		"EDATE":          {2, 2},
+		"EFFECT":         {2, 2},
+		"ENCODEURL":      {1, 1},
+		"EOMONTH":        {2, 2},
+		"ERF.PRECISE":    {1, 1},
+		"ERROR.TYPE":     {1, 1},
+		"EUROCONVERT":    {3, 5},
+		"EXACT":          {2, 2},
+		"EXPON.DIST":     {3, 3},
+		"EXPONDIST":      {3, 3},
+		"F.DIST":         {4, 4},
+		"F.DIST.RT":      {3, 3},
+		"F.TEST":         {2, 2},
+		"FDIST":          {3, 3},
+		"FIXED":          {1, 3},
+		"FLOOR.MATH":     {1, 3},
+		"FORMULATEXT":    {1, 1},
+		"FREQUENCY":      {2, 2},
+		"FTEST":          {2, 2},
+		"FV":             {3, 5},
+		"FVSCHEDULE":     {2, 2},
+		"GAMMA.DIST":     {4, 4},
+		"GAMMA.INV":      {3, 3},
+		"GAMMADIST":      {4, 4},
+		"GAMMAINV":       {3, 3},
+		"GCD":            {1, -1},
+		"HARMEAN":        {1, -1},
+		"IFERROR":        {2, 2},
+		"IFNA":           {2, 2},
+		"IFS":            {2, -1},
+		"IMABS":          {1, 1},
+		"IMAGINARY":      {1, 1},
+		"IMARGUMENT":     {1, 1},
+		"IMCONJUGATE":    {1, 1},
+		"IMCOS":          {1, 1},
+		"IMCOSH":         {1, 1},
+		"IMCOT":          {1, 1},
+		"IMCSC":          {1, 1},
+		"IMCSCH":         {1, 1},
+		"IMDIV":          {2, 2},
+		"IMEXP":          {1, 1},
+		"IMLN":           {1, 1},
+		"IMLOG10":        {1, 1},
+		"IMLOG2":         {1, 1},
+		"IMPOWER":        {2, 2},
+		"IMREAL":         {1, 1},
+		"IMSEC":          {1, 1},
+		"IMSECH":         {1, 1},
+		"IMSIN":          {1, 1},
+		"IMSINH":         {1, 1},
+		"IMSQRT":         {1, 1},
+		"IMSUB":          {2, 2},
+		"IMSUM":          {1, -1},
+		"IMTAN":          {1, 1},
+		"INDEX":          {2, 3},
+		"INDIRECT":       {1, 2},
+		"ISBLANK":        {1, 1},
+		"ISERR":          {1, 1},
+		"ISERROR":        {1, 1},
+		"ISEVEN":         {1, 1},
+		"ISFORMULA":      {1, 1},
+		"ISLOGICAL":      {1, 1},
+		"ISNA":           {1, 1},
+		"ISNONTEXT":      {1, 1},
+		"ISNUMBER":       {1, 1},
+		"ISODD":          {1, 1},
+		"ISOWEEKNUM":     {1, 1},
+		"ISPMT":          {4, 4},
+		"ISREF":          {1, 1},
+		"ISTEXT":         {1, 1},
+		"KURT":           {1, -1},
+		"LCM":            {1, -1},
+		"LINEST":         {1, 4},
+		"LOGEST":         {1, 4},
+		"LOGINV":         {3, 3},
+		"LOGNORM.DIST":   {4, 4},
+		"LOGNORM.INV":    {3, 3},
+		"LOGNORMDIST":    {3, 3},
+		"LOWER":          {1, 1},
+		"MAX":            {1, -1},
+		"MAXA":           {1, -1},
+		"MAXIFS":         {3, -1},
+		"MDETERM":        {1, 1},
+		"MEDIAN":         {1, -1},
+		"MIN":            {1, -1},
+		"MINA":           {1, -1},
+		"MINIFS":         {3, -1},
+		"MINVERSE":       {1, 1},
+		"MIRR":           {3, 3},
+		"MODE":           {1, -1},
+		"MODE.MULT":      {1, -1},
+		"MODE.SNGL":      {1, -1},
+		"N":              {1, 1},
+		"NEGBINOM.DIST":  {4, 4},
+		"NEGBINOMDIST":   {3, 3},
+		"NOMINAL":        {2, 2},
+		"NORM.DIST":      {4, 4},
+		"NORM.INV":       {3, 3},
+		"NORMDIST":       {4, 4},
+		"NORMINV":        {3, 3},
+		"NOT":            {1, 1},
+		"NPER":           {3, 5},
+		"NPV":            {2, -1},
+		"ODDFPRICE":      {8, 9},
+		"ODDFYIELD":      {8, 9},
+		"PDURATION":      {3, 3},
+		"PERCENTILE":     {2, 2},
+		"PERCENTILE.EXC": {2, 2},
+		"PERCENTILE.INC": {2, 2},
+		"PHI":            {1, 1},
+		"PHONETIC":       {1, 1},
+		"PMT":            {3, 5},
+		"POISSON":        {3, 3},
+		"POISSON.DIST":   {3, 3},
+		"PRICEDISC":      {4, 5},
+		"PRICEMAT":       {5, 6},
+		"PROPER":         {1, 1},
+		"PV":             {3, 5},
+		"QUARTILE":       {2, 2},
+		"QUARTILE.EXC":   {2, 2},
+		"QUARTILE.INC":   {2, 2},
+		"RATE":           {3, 6},
+		"REPT":           {2, 2},
+		"ROWS":           {1, 1},
+		"RRI":            {3, 3},
+		"SERIESSUM":      {4, 4},
+		"SLN":            {3, 3},
+		"STANDARDIZE":    {3, 3},
+		"STDEV":          {1, -1},
+		"STDEV.S":        {1, -1},
+		"STDEVA":         {1, -1},
+		"STEYX":          {2, 2},
+		"SUBSTITUTE":     {3, 4},
+		"SUBTOTAL":       {2, -1},
+		"SUMIF":          {2, 3},
+		"SUMIFS":         {3, -1},
+		"SUMPRODUCT":     {1, -1},
+		"SWITCH":         {3, -1},
+		"SYD":            {4, 4},
+		"T":              {1, 1},
+		"T.DIST":         {3, 3},
+		"T.DIST.2T":      {2, 2},
+		"T.DIST.RT":      {2, 2},
+		"T.INV":          {2, 2},
+		"T.INV.2T":       {2, 2},
+		"T.TEST":         {4, 4},
+		"TBILLEQ":        {3, 3},
+		"TBILLPRICE":     {3, 3},
+		"TBILLYIELD":     {3, 3},
+		"TDIST":          {3, 3},
+		"TEXT":           {2, 2},
+		"TINV":           {2, 2},
+		"TRANSPOSE":      {1, 1},
+		"TRIM":           {1, 1},
+		"TRIMMEAN":       {2, 2},
+		"TRUNC":          {1, 2},
+		"TTEST":          {4, 4},
+		"TYPE":           {1, 1},
+		"UNICHAR":        {1, 1},
+		"UPPER":          {1, 1},
+		"VALUE":          {1, 1},
+		"WEIBULL":        {4, 4},
+		"WEIBULL.DIST":   {4, 4},
+		"XIRR":           {2, 3},
+		"XNPV":           {3, 3},
+		"XOR":            {1, -1},
+		"YIELDDISC":      {4, 5},
+		"YIELDMAT":       {5, 6},
+	}
 	formulaCriterias = []byte{
 		criteriaEq,
 		criteriaEq,
@@ -205,11 +435,32 @@ var (
 
 // calcContext defines the formula execution context.
 type calcContext struct {
-	mu                sync.Mutex
-	entry             string
-	maxCalcIterations uint
-	iterations        map[string]uint
-	iterationsCache   map[string]formulaArg
+	mu                       sync.Mutex
+	done                     context.Context
+	entry                    string
+	maxCalcIterations        uint
+	gregorianDates           bool
+	pureCalc                 bool
+	unsupportedFunctionError bool
+	bindings                 map[string]formulaArg
+	iterations               map[string]uint
+	iterationsCache          map[string]formulaArg
+	sheetList                []string
+	spills                   map[string]bool
+	usedRanges               map[string][]int
+	workbooks                map[*File]*calcContext
+}
+
+// ErrUnsupportedFunction defined the error message on calculating the formula
+// which contains the unsupported formula function.
+type ErrUnsupportedFunction struct {
+	FuncName string
+}
+
+// Error returns the error message on calculating the formula which contains
+// the unsupported formula function.
+func (err ErrUnsupportedFunction) Error() string {
+	return fmt.Sprintf("unsupported formula function %s", err.FuncName)
 }
 
 // cellRef defines the structure of a cell reference.
@@ -271,6 +522,10 @@ func (fa formulaArg) Value() (value string) {
 		return fmt.Sprintf("%g", fa.Number)
 	case ArgString:
 		return fa.String
+	case ArgMatrix:
+		if args := fa.ToList(); len(args) > 0 {
+			return args[0].Value()
+		}
 	case ArgError:
 		return fa.Error
 	}
@@ -289,6 +544,10 @@ func (fa formulaArg) ToNumber() formulaArg {
 		}
 	case ArgNumber:
 		n = fa.Number
+	case ArgMatrix:
+		if args := fa.ToList(); len(args) > 0 {
+			return args[0].ToNumber()
+		}
 	}
 	return newNumberFormulaArg(n)
 }
@@ -335,10 +594,42 @@ type formulaFuncs struct {
 	sheet, cell string
 }
 
+// FormulaArgument is the argument of a custom formula function which
+// registered by the RegisterFunction. The logical value will be the ArgNumber
+// type argument with the Boolean field set to true, the same as the
+// FormulaResult. For the ArgError type argument, the String field will be the
+// formula error, such as #VALUE!, and for the ArgMatrix type argument, the
+// values of the range or array will be stored in the Matrix field.
+type FormulaArgument struct {
+	Type    ArgType
+	Number  float64
+	String  string
+	Boolean bool
+	Matrix  [][]FormulaArgument
+}
+
+// FormulaResult is the result of a custom formula function which registered
+// by the RegisterFunction, or the bound cell value used by the
+// CalcCellValueWithBindings. Set the Type field to ArgNumber, ArgString or
+// ArgError to return a number, string or formula error, and set the Boolean
+// field with the ArgNumber type to return a logical value. For the ArgError
+// type result, the String field should be the formula error, such as #N/A.
+type FormulaResult struct {
+	Type    ArgType
+	Number  float64
+	String  string
+	Boolean bool
+}
+
 // CalcCellValue provides a function to get calculated cell value. This feature
 // is currently in working processing. Iterative calculation, implicit
 // intersection, explicit intersection, array formula, table formula and some
-// other formulas are not supported currently.
+// other formulas are not supported currently. The ErrUnsupportedFunction
+// error with the function name will be returned for the formula which
+// contains the unsupported function if the UnsupportedFunctionError option is
+// enabled. The volatile functions, such as NOW, RAND and OFFSET, and the
+// INDIRECT function which references other worksheets will be rejected with
+// an error if the PureCalc option is enabled.
 //
 // Supported formula functions:
 //
@@ -353,9 +644,11 @@ type formulaFuncs struct {
 //	AGGREGATE
 //	AMORDEGRC
 //	AMORLINC
+//	ANCHORARRAY
 //	AND
 //	ARABIC
 //	ARRAYTOTEXT
+//	ASC
 //	ASIN
 //	ASINH
 //	ATAN
@@ -445,6 +738,7 @@ type formulaFuncs struct {
 //	DAYS
 //	DAYS360
 //	DB
+//	DBCS
 //	DCOUNT
 //	DCOUNTA
 //	DDB
@@ -587,9 +881,11 @@ type formulaFuncs struct {
 //	LEFTB
 //	LEN
 //	LENB
+//	LINEST
 //	LN
 //	LOG
 //	LOG10
+//	LOGEST
 //	LOGINV
 //	LOGNORM.DIST
 //	LOGNORM.INV
@@ -659,6 +955,7 @@ type formulaFuncs struct {
 //	PERMUT
 //	PERMUTATIONA
 //	PHI
+//	PHONETIC
 //	PI
 //	PMT
 //	POISSON
@@ -690,6 +987,7 @@ type formulaFuncs struct {
 //	RIGHTB
 //	ROMAN
 //	ROUND
+//	ROUND.EVEN
 //	ROUNDDOWN
 //	ROUNDUP
 //	ROW
@@ -794,16 +1092,67 @@ type formulaFuncs struct {
 //	Z.TEST
 //	ZTEST
 func (f *File) CalcCellValue(sheet, cell string, opts ...Options) (result string, err error) {
+	return f.calcCellValueWithBindings(context.Background(), sheet, cell, nil, opts...)
+}
+
+// CalcCellValueContext provides a function to get calculated cell value with
+// the given context. The calculation will be stopped and the context error
+// will be returned once the context is canceled or its deadline is exceeded,
+// which avoids the long calculations hanging. For example, get the value of
+// the cell B1 on Sheet1 in 5 seconds at most:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	result, err := f.CalcCellValueContext(ctx, "Sheet1", "B1")
+func (f *File) CalcCellValueContext(ctx context.Context, sheet, cell string, opts ...Options) (result string, err error) {
+	return f.calcCellValueWithBindings(ctx, sheet, cell, nil, opts...)
+}
+
+// CalcCellValueWithBindings provides a function to get calculated cell value
+// with the hypothetical values of the given cells, the workbook will not be
+// changed. The bindings are keyed by the cell reference, the cell reference
+// without worksheet name refers to the cell on the given worksheet. The bound
+// cells will be treated as the given values instead of their values or
+// formulas when they are referenced by the formulas. For example, get the
+// value of the cell B1 on Sheet1 with the formula =A1*2 when the value of the
+// cell A1 is 10:
+//
+//	result, err := f.CalcCellValueWithBindings("Sheet1", "B1", map[string]excelize.FormulaResult{
+//	    "A1": {Type: excelize.ArgNumber, Number: 10},
+//	})
+func (f *File) CalcCellValueWithBindings(sheet, cell string, bindings map[string]FormulaResult, opts ...Options) (result string, err error) {
+	return f.calcCellValueWithBindings(context.Background(), sheet, cell, bindings, opts...)
+}
+
+// calcCellValueWithBindings get calculated cell value by given context,
+// worksheet name, cell reference and the bound cell values.
+func (f *File) calcCellValueWithBindings(done context.Context, sheet, cell string, bindings map[string]FormulaResult, opts ...Options) (result string, err error) {
+	var args map[string]formulaArg
+	if args, err = prepareCalcBindings(sheet, bindings); err != nil {
+		return
+	}
+	result, _, err = f.calcCellValueWithArgs(done, sheet, cell, args, opts...)
+	return
+}
+
+// calcCellValueWithArgs get calculated cell value and the formula result by
+// given context, worksheet name, cell reference and the bound cell values
+// keyed by the cell reference with worksheet name.
+func (f *File) calcCellValueWithArgs(done context.Context, sheet, cell string, args map[string]formulaArg, opts ...Options) (result string, token formulaArg, err error) {
 	var (
 		rawCellValue = getOptions(opts...).RawCellValue
 		styleIdx     int
-		token        formulaArg
 	)
 	if token, err = f.calcCellValue(&calcContext{
-		entry:             fmt.Sprintf("%s!%s", sheet, cell),
-		maxCalcIterations: getOptions(opts...).MaxCalcIterations,
-		iterations:        make(map[string]uint),
-		iterationsCache:   make(map[string]formulaArg),
+		done:                     done,
+		entry:                    fmt.Sprintf("%s!%s", sheet, cell),
+		maxCalcIterations:        getOptions(opts...).MaxCalcIterations,
+		gregorianDates:           getOptions(opts...).GregorianDates,
+		pureCalc:                 getOptions(opts...).PureCalc,
+		unsupportedFunctionError: getOptions(opts...).UnsupportedFunctionError,
+		bindings:                 args,
+		iterations:               make(map[string]uint),
+		iterationsCache:          make(map[string]formulaArg),
 	}, sheet, cell); err != nil {
 		result = token.String
 		return
@@ -824,6 +1173,213 @@ func (f *File) CalcCellValue(sheet, cell string, opts ...Options) (result string
 	return
 }
 
+// prepareCalcBindings converts the bound cell values into the formula
+// arguments keyed by the cell reference with worksheet name.
+func prepareCalcBindings(sheet string, bindings map[string]FormulaResult) (map[string]formulaArg, error) {
+	args := make(map[string]formulaArg, len(bindings))
+	for ref, value := range bindings {
+		sheetName, cell := sheet, strings.ReplaceAll(ref, "$", "")
+		if idx := strings.LastIndex(cell, "!"); idx != -1 {
+			sheetName, cell = strings.Trim(cell[:idx], "'"), cell[idx+1:]
+		}
+		col, row, err := CellNameToCoordinates(cell)
+		if err != nil {
+			return nil, err
+		}
+		if cell, err = CoordinatesToCellName(col, row); err != nil {
+			return nil, err
+		}
+		args[fmt.Sprintf("%s!%s", sheetName, cell)] = value.toFormulaArg()
+	}
+	return args, nil
+}
+
+// canceled returns the error of the context if the calculation has been
+// canceled or its deadline has been exceeded.
+func (ctx *calcContext) canceled() error {
+	if ctx == nil || ctx.done == nil {
+		return nil
+	}
+	return ctx.done.Err()
+}
+
+// formulaTokensCacheSize defined the maximum number of the formulas which
+// parsed tokens are memoized in each workbook.
+const formulaTokensCacheSize = 4096
+
+// formulaTokensCache directly maps the formula text to the parsed tokens of
+// the formula, the least recently stored formula will be evicted when the
+// cache is full.
+type formulaTokensCache struct {
+	mu     sync.Mutex
+	order  *list.List
+	tokens map[string][]efp.Token
+}
+
+// load provides a function to get the memoized parsed tokens by given formula
+// text.
+func (c *formulaTokensCache) load(formula string) ([]efp.Token, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tokens, ok := c.tokens[formula]
+	return tokens, ok
+}
+
+// store provides a function to memoize the parsed tokens of the formula, and
+// evict the least recently stored formula when the cache is full.
+func (c *formulaTokensCache) store(formula string, tokens []efp.Token) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens == nil {
+		c.order, c.tokens = list.New(), make(map[string][]efp.Token)
+	}
+	if _, ok := c.tokens[formula]; ok {
+		return
+	}
+	if c.order.Len() >= formulaTokensCacheSize {
+		delete(c.tokens, c.order.Remove(c.order.Front()).(string))
+	}
+	c.order.PushBack(formula)
+	c.tokens[formula] = tokens
+}
+
+// len provides a function to get the number of the memoized formulas.
+func (c *formulaTokensCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.tokens)
+}
+
+// clear provides a function to drop all the memoized parsed tokens.
+func (c *formulaTokensCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order, c.tokens = nil, nil
+}
+
+// ClearCalcCache provides a function to drop the memoized parsed tokens of the
+// formulas in the workbook to release the memory. The parsed tokens are keyed
+// by the formula text and never go stale, the cell values are read on each
+// calculation, so it's not required to call this function after the cell
+// edits. The iteration caches of the circular references and the used ranges
+// of the worksheets are kept in each calculation context, which are dropped
+// when the calculation finished.
+func (f *File) ClearCalcCache() {
+	f.calcTokens.clear()
+}
+
+// parseFormulaTokens parse the formula into tokens, the parsed tokens will be
+// memoized for the formulas with the same text in the workbook.
+func (f *File) parseFormulaTokens(formula string) []efp.Token {
+	if tokens, ok := f.calcTokens.load(formula); ok {
+		return tokens
+	}
+	ps := efp.ExcelParser()
+	tokens := ps.Parse(replaceSpillReferences(formula))
+	f.calcTokens.store(formula, tokens)
+	return tokens
+}
+
+// replaceSpillReferences replace the spilled range reference operator (#) in
+// the formula with the ANCHORARRAY function, which is the form of the spilled
+// range reference stored in the workbook. The string literals in the formula
+// will be kept.
+func replaceSpillReferences(formula string) string {
+	if !strings.Contains(formula, "#") {
+		return formula
+	}
+	parts := strings.Split(formula, "\"")
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = spillReference.ReplaceAllString(parts[i], "${1}_xlfn.ANCHORARRAY(${2}${3})")
+	}
+	return strings.Join(parts, "\"")
+}
+
+// ValidateFormula provides a function to check the formula structure by given
+// worksheet name and formula text without evaluating the references. It
+// returns an error if the parentheses are mismatched, the formula contains an
+// unknown function, or a function is called with the wrong number of
+// arguments. The number of arguments is only checked for the functions listed
+// in the arity table, the other functions such as ABS and VLOOKUP are checked
+// by the function name only, and the wrong number of arguments of them will
+// be reported on calculation. The functions registered by the
+// RegisterFunction are treated as known functions. For example, check the
+// formula before writing it into the cell:
+//
+//	if err := f.ValidateFormula("Sheet1", "=SUM(A1:A3)"); err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	err := f.SetCellFormula("Sheet1", "A4", "=SUM(A1:A3)")
+func (f *File) ValidateFormula(sheet, formula string) error {
+	idx, err := f.GetSheetIndex(sheet)
+	if err != nil {
+		return err
+	}
+	if idx == -1 {
+		return ErrSheetNotExist{sheet}
+	}
+	type frame struct {
+		name  string
+		args  int
+		empty bool
+	}
+	var frames []*frame
+	for _, token := range f.parseFormulaTokens(formula) {
+		if token.TType == efp.TokenTypeWhitespace {
+			continue
+		}
+		if token.TSubType == efp.TokenSubTypeStop {
+			if len(frames) == 0 {
+				return errors.New("formula has mismatched parentheses")
+			}
+			top := frames[len(frames)-1]
+			frames = frames[:len(frames)-1]
+			if _, ok := formulaFuncsArity[top.name]; !ok {
+				continue
+			}
+			argsList := list.New()
+			if !top.empty || top.args > 0 {
+				for i := 0; i <= top.args; i++ {
+					argsList.PushBack(newEmptyFormulaArg())
+				}
+			}
+			if argsCount := checkFormulaArgsCount(top.name, argsList); argsCount.Type == ArgError {
+				return errors.New(argsCount.Error)
+			}
+			continue
+		}
+		if len(frames) > 0 {
+			top := frames[len(frames)-1]
+			if token.TType == efp.TokenTypeArgument {
+				top.args++
+				continue
+			}
+			top.empty = false
+		}
+		if token.TSubType != efp.TokenSubTypeStart {
+			continue
+		}
+		if token.TType != efp.TokenTypeFunction {
+			frames = append(frames, &frame{empty: true})
+			continue
+		}
+		name := strings.ToUpper(strings.TrimPrefix(token.TValue, "_xlfn."))
+		if name == "ARRAY" || name == "ARRAYROW" {
+			frames = append(frames, &frame{empty: true})
+			continue
+		}
+		if !f.isFormulaFuncSupported(name) {
+			return ErrUnsupportedFunction{FuncName: name}
+		}
+		frames = append(frames, &frame{name: name, empty: true})
+	}
+	if len(frames) != 0 {
+		return errors.New("formula has mismatched parentheses")
+	}
+	return nil
+}
+
 // calcCellValue calculate cell value by given context, worksheet name and cell
 // reference.
 func (f *File) calcCellValue(ctx *calcContext, sheet, cell string) (result formulaArg, err error) {
@@ -831,8 +1387,7 @@ func (f *File) calcCellValue(ctx *calcContext, sheet, cell string) (result formu
 	if formula, err = f.GetCellFormula(sheet, cell); err != nil {
 		return
 	}
-	ps := efp.ExcelParser()
-	tokens := ps.Parse(formula)
+	tokens := f.parseFormulaTokens(formula)
 	if tokens == nil {
 		return
 	}
@@ -840,6 +1395,405 @@ func (f *File) calcCellValue(ctx *calcContext, sheet, cell string) (result formu
 	return
 }
 
+// checkSpillRange checks if the spill range of the dynamic array result is
+// empty on the worksheet. The spill range begins with the given anchor cell
+// and has the same size as the result matrix. The #SPILL! formula error will
+// be returned if any cell in the spill range except the anchor cell has a
+// value or formula, or the spill range is out of the worksheet.
+func (f *File) checkSpillRange(sheet, cell string, result formulaArg) (formulaArg, error) {
+	if result.Type != ArgMatrix {
+		return result, nil
+	}
+	col, row, err := CellNameToCoordinates(cell)
+	if err != nil {
+		return newErrorFormulaArg(formulaErrorREF, formulaErrorREF), err
+	}
+	for r, cells := range result.Matrix {
+		for c := range cells {
+			if r == 0 && c == 0 {
+				continue
+			}
+			if row+r > TotalRows || col+c > MaxColumns {
+				return newErrorFormulaArg(formulaErrorSPILL, formulaErrorSPILL), nil
+			}
+			ref, _ := CoordinatesToCellName(col+c, row+r)
+			value, err := f.GetCellValue(sheet, ref, Options{RawCellValue: true})
+			if err != nil {
+				return newErrorFormulaArg(formulaErrorREF, formulaErrorREF), err
+			}
+			formula, err := f.GetCellFormula(sheet, ref)
+			if err != nil {
+				return newErrorFormulaArg(formulaErrorREF, formulaErrorREF), err
+			}
+			if value != "" || formula != "" {
+				return newErrorFormulaArg(formulaErrorSPILL, formulaErrorSPILL), nil
+			}
+		}
+	}
+	return result, nil
+}
+
+// EvalFormula provides a function to evaluate the given formula on the
+// worksheet without writing it into any cell. The formula is treated as
+// authored in cell A1 and copied to the given origin cell, so the relative
+// references will be shifted by the distance between A1 and the origin cell,
+// and the absolute references keep unchanged. For example, evaluate the
+// formula =A1+B1 with the origin cell C3 will reference the cells C3 and D3:
+//
+//	result, err := f.EvalFormula("Sheet1", "C3", "=A1+B1")
+func (f *File) EvalFormula(sheet, origin, formula string, opts ...Options) (result string, err error) {
+	var (
+		col, row int
+		token    formulaArg
+	)
+	if col, row, err = CellNameToCoordinates(origin); err != nil {
+		return
+	}
+	orig := []byte(strings.TrimPrefix(formula, "="))
+	shifted, start := parseSharedFormula(col-1, row-1, orig)
+	if start < len(orig) {
+		shifted += string(orig[start:])
+	}
+	ps := efp.ExcelParser()
+	tokens := ps.Parse(replaceSpillReferences(shifted))
+	if tokens == nil {
+		return
+	}
+	if token, err = f.evalInfixExp(&calcContext{
+		entry:                    fmt.Sprintf("%s!%s", sheet, origin),
+		maxCalcIterations:        getOptions(opts...).MaxCalcIterations,
+		gregorianDates:           getOptions(opts...).GregorianDates,
+		pureCalc:                 getOptions(opts...).PureCalc,
+		unsupportedFunctionError: getOptions(opts...).UnsupportedFunctionError,
+		iterations:               make(map[string]uint),
+		iterationsCache:          make(map[string]formulaArg),
+	}, sheet, origin, tokens); err != nil {
+		result = token.String
+		return
+	}
+	result = token.Value()
+	return
+}
+
+// CalcSheetValues provides a function to calculate all formula cells on the
+// worksheet by given worksheet name, and returns the calculated values keyed
+// by the cell reference. The formula cells will be grouped by their
+// dependencies, including the dependencies through the formula cells on the
+// other worksheets, each group only depends on the previous groups, and the
+// cells in each group will be calculated concurrently by the given number of
+// workers. The calculated values of the previous groups will be reused by
+// the later groups instead of being recalculated. The cells which reference
+// the cells dynamically by the INDIRECT or OFFSET function will be
+// calculated after the other cells. The cells will be calculated one by one
+// if the number of workers less than 2. The formula errors such as #DIV/0!
+// will be returned as the cell values, and an error will be returned if a
+// circular reference was found and the MaxCalcIterations option is not set.
+// For example, calculate the formula cells on Sheet1 with 4 workers:
+//
+//	values, err := f.CalcSheetValues("Sheet1", 4)
+func (f *File) CalcSheetValues(sheet string, workers int, opts ...Options) (map[string]string, error) {
+	cells, err := f.getFormulaCells(sheet)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := f.groupFormulaCells(sheet, cells)
+	if err != nil && getOptions(opts...).MaxCalcIterations == 0 {
+		return nil, err
+	}
+	f.mu.Lock()
+	if _, err = f.stylesReader(); err == nil {
+		_, err = f.workbookReader()
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	var (
+		mu      sync.Mutex
+		calcErr error
+		values  = make(map[string]string, len(cells))
+		// the calculated cells of the previous groups, which are only read by
+		// the workers of the current group
+		bindings = make(map[string]formulaArg, len(cells))
+	)
+	for _, group := range groups {
+		var wg sync.WaitGroup
+		queue, tokens := make(chan string), make(map[string]formulaArg, len(group))
+		for i := 0; i < workers && i < len(group); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for cell := range queue {
+					result, token, err := f.calcCellValueWithArgs(context.Background(), sheet, cell, bindings, opts...)
+					mu.Lock()
+					if err != nil && result == "" && calcErr == nil {
+						calcErr = err
+					}
+					values[cell], tokens[cell] = result, token
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, cell := range group {
+			queue <- cell
+		}
+		close(queue)
+		wg.Wait()
+		if calcErr != nil {
+			return values, calcErr
+		}
+		for cell, token := range tokens {
+			bindings[fmt.Sprintf("%s!%s", sheet, cell)] = token
+		}
+	}
+	return values, nil
+}
+
+// getFormulaCells returns the references of the cells which contain formula
+// on the worksheet by given worksheet name.
+func (f *File) getFormulaCells(sheet string) ([]string, error) {
+	f.mu.Lock()
+	ws, err := f.workSheetReader(sheet)
+	if err != nil {
+		f.mu.Unlock()
+		return nil, err
+	}
+	f.mu.Unlock()
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	var cells []string
+	for _, row := range ws.SheetData.Row {
+		for _, c := range row.C {
+			if c.F != nil {
+				cells = append(cells, c.R)
+			}
+		}
+	}
+	return cells, nil
+}
+
+// formulaCellIndex indexes the formula cells on a worksheet by their column
+// and row numbers, which used to find the formula cells in a range without
+// checking each formula cell on the worksheet.
+type formulaCellIndex struct {
+	cols  []int
+	rows  map[int][]int
+	cells map[int]map[int]string
+}
+
+// newFormulaCellIndex creates the index of the given formula cells.
+func newFormulaCellIndex(cells []string) (*formulaCellIndex, error) {
+	idx := &formulaCellIndex{rows: make(map[int][]int), cells: make(map[int]map[int]string)}
+	for _, cell := range cells {
+		col, row, err := CellNameToCoordinates(cell)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := idx.cells[col]; !ok {
+			idx.cols = append(idx.cols, col)
+			idx.cells[col] = make(map[int]string)
+		}
+		idx.rows[col] = append(idx.rows[col], row)
+		idx.cells[col][row] = cell
+	}
+	sort.Ints(idx.cols)
+	for _, rows := range idx.rows {
+		sort.Ints(rows)
+	}
+	return idx, nil
+}
+
+// find returns the formula cells in the given cell range.
+func (idx *formulaCellIndex) find(cr cellRange) []string {
+	var cells []string
+	for i := sort.SearchInts(idx.cols, cr.From.Col); i < len(idx.cols) && idx.cols[i] <= cr.To.Col; i++ {
+		col := idx.cols[i]
+		rows := idx.rows[col]
+		for j := sort.SearchInts(rows, cr.From.Row); j < len(rows) && rows[j] <= cr.To.Row; j++ {
+			cells = append(cells, idx.cells[col][rows[j]])
+		}
+	}
+	return cells
+}
+
+// formulaPrecedents resolves the formula cells on a worksheet which are
+// referenced by the formula cells on the same worksheet, directly or through
+// the formula cells on the other worksheets.
+type formulaPrecedents struct {
+	f       *File
+	sheet   string
+	indexes map[string]*formulaCellIndex
+}
+
+// getIndex returns the index of the formula cells on the given worksheet,
+// the index will be created on the first access.
+func (fp *formulaPrecedents) getIndex(sheet string) *formulaCellIndex {
+	if idx, ok := fp.indexes[sheet]; ok {
+		return idx
+	}
+	idx := &formulaCellIndex{}
+	if cells, err := fp.f.getFormulaCells(sheet); err == nil {
+		if idx, err = newFormulaCellIndex(cells); err != nil {
+			idx = &formulaCellIndex{}
+		}
+	}
+	fp.indexes[sheet] = idx
+	return idx
+}
+
+// get returns the formula cells on the worksheet which are referenced by the
+// given formula on the given worksheet, including the cells referenced by
+// the defined names, and whether the formula references the cells
+// dynamically by the INDIRECT or OFFSET function. The visited keeps the
+// formula cells on the other worksheets which have been resolved.
+func (fp *formulaPrecedents) get(sheet, formula string, visited map[string]bool) (precedents []string, dynamic bool) {
+	ps := efp.ExcelParser()
+	for _, token := range ps.Parse(replaceSpillReferences(formula)) {
+		if isFunctionStartToken(token) {
+			switch strings.ToUpper(strings.TrimPrefix(token.TValue, "_xlfn.")) {
+			case "INDIRECT", "OFFSET":
+				dynamic = true
+			}
+			continue
+		}
+		if token.TSubType != efp.TokenSubTypeRange {
+			continue
+		}
+		reference := token.TValue
+		if refTo := fp.f.getDefinedNameRefTo(reference, sheet); refTo != "" {
+			reference = refTo
+		}
+		cr, err := fp.f.parseCellRange(sheet, reference)
+		if err != nil {
+			continue
+		}
+		refSheet := strings.Trim(cr.From.Sheet, "'")
+		for _, cell := range fp.getIndex(refSheet).find(cr) {
+			if refSheet == fp.sheet {
+				precedents = append(precedents, cell)
+				continue
+			}
+			ref := fmt.Sprintf("%s!%s", refSheet, cell)
+			if visited[ref] {
+				continue
+			}
+			visited[ref] = true
+			formula, err := fp.f.GetCellFormula(refSheet, cell)
+			if err != nil {
+				continue
+			}
+			cells, isDynamic := fp.get(refSheet, formula, visited)
+			precedents, dynamic = append(precedents, cells...), dynamic || isDynamic
+		}
+	}
+	return
+}
+
+// groupFormulaCells groups the formula cells on the worksheet by their
+// dependencies, the cells in each group only depend on the cells in the
+// previous groups. The cells which reference the cells dynamically, and the
+// cells which depend on them will be put in the groups after the other cells.
+// The cells in the circular references will be put in the last group, and
+// the circular reference error will be returned.
+func (f *File) groupFormulaCells(sheet string, cells []string) ([][]string, error) {
+	idx, err := newFormulaCellIndex(cells)
+	if err != nil {
+		return nil, err
+	}
+	fp := &formulaPrecedents{f: f, sheet: sheet, indexes: map[string]*formulaCellIndex{sheet: idx}}
+	precedents, dependents := make(map[string]int, len(cells)), make(map[string][]string, len(cells))
+	var deferred []string
+	for _, cell := range cells {
+		formula, err := f.GetCellFormula(sheet, cell)
+		if err != nil {
+			return nil, err
+		}
+		cellPrecedents, dynamic := fp.get(sheet, formula, map[string]bool{})
+		if dynamic {
+			deferred = append(deferred, cell)
+		}
+		referenced := make(map[string]bool, len(cellPrecedents))
+		for _, precedent := range cellPrecedents {
+			if referenced[precedent] {
+				continue
+			}
+			referenced[precedent] = true
+			precedents[cell]++
+			dependents[precedent] = append(dependents[precedent], cell)
+		}
+	}
+	var groups [][]string
+	var group []string
+	for _, cell := range cells {
+		if precedents[cell] == 0 {
+			group = append(group, cell)
+		}
+	}
+	for len(group) > 0 {
+		groups = append(groups, group)
+		var next []string
+		for _, cell := range group {
+			for _, dependent := range dependents[cell] {
+				if precedents[dependent]--; precedents[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		group = next
+	}
+	groups = deferFormulaCells(groups, deferred, dependents)
+	var circular []string
+	for _, cell := range cells {
+		if precedents[cell] > 0 {
+			circular = append(circular, cell)
+		}
+	}
+	if len(circular) > 0 {
+		return append(groups, circular), errors.New("circular reference")
+	}
+	return groups, nil
+}
+
+// deferFormulaCells moves the given formula cells and the cells which depend
+// on them to the groups after the other cells, the order of the moved cells
+// is kept, so each group still only depends on the previous groups.
+func deferFormulaCells(groups [][]string, cells []string, dependents map[string][]string) [][]string {
+	if len(cells) == 0 {
+		return groups
+	}
+	deferred := make(map[string]bool, len(cells))
+	for len(cells) > 0 {
+		cell := cells[len(cells)-1]
+		cells = cells[:len(cells)-1]
+		if deferred[cell] {
+			continue
+		}
+		deferred[cell] = true
+		cells = append(cells, dependents[cell]...)
+	}
+	var head, tail [][]string
+	for _, group := range groups {
+		var cur, next []string
+		for _, cell := range group {
+			if deferred[cell] {
+				next = append(next, cell)
+				continue
+			}
+			cur = append(cur, cell)
+		}
+		if len(cur) > 0 {
+			head = append(head, cur)
+		}
+		if len(next) > 0 {
+			tail = append(tail, next)
+		}
+	}
+	return append(head, tail...)
+}
+
 // getPriority calculate arithmetic operator priority.
 func getPriority(token efp.Token) (pri int) {
 	pri = tokenPriority[token.TValue]
@@ -860,6 +1814,15 @@ func newNumberFormulaArg(n float64) formulaArg {
 	return formulaArg{Type: ArgNumber, Number: n}
 }
 
+// newFiniteNumberFormulaArg constructs a number formula argument, or a #NUM!
+// error when the number overflows the range of double-precision values.
+func newFiniteNumberFormulaArg(n float64) formulaArg {
+	if math.IsInf(n, 0) {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	}
+	return newNumberFormulaArg(n)
+}
+
 // newStringFormulaArg constructs a string formula argument.
 func newStringFormulaArg(s string) formulaArg {
 	return formulaArg{Type: ArgString, String: s}
@@ -895,6 +1858,37 @@ func newEmptyFormulaArg() formulaArg {
 	return formulaArg{Type: ArgEmpty}
 }
 
+// checkFormulaArgsCount checking the number of arguments of the formula
+// function by given function name and arguments list, and returns #VALUE!
+// error with the uniform message if the number of arguments is out of the
+// range defined in the formulaFuncsArity.
+func checkFormulaArgsCount(name string, argsList *list.List) formulaArg {
+	arity, ok := formulaFuncsArity[name]
+	if !ok {
+		return newEmptyFormulaArg()
+	}
+	argsLen, minArgs, maxArgs := argsList.Len(), arity[0], arity[1]
+	argument := func(n int) string {
+		if n == 1 {
+			return "argument"
+		}
+		return "arguments"
+	}
+	if minArgs == maxArgs && argsLen != minArgs {
+		return newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("%s requires %d %s", name, minArgs, argument(minArgs)))
+	}
+	if maxArgs == minArgs+1 && (argsLen < minArgs || argsLen > maxArgs) {
+		return newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("%s requires %d or %d arguments", name, minArgs, maxArgs))
+	}
+	if argsLen < minArgs {
+		return newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("%s requires at least %d %s", name, minArgs, argument(minArgs)))
+	}
+	if maxArgs != -1 && argsLen > maxArgs {
+		return newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("%s allows at most %d arguments", name, maxArgs))
+	}
+	return newEmptyFormulaArg()
+}
+
 // evalInfixExp evaluate syntax analysis by given infix expression after
 // lexical analysis. Evaluate an infix expression containing formulas by
 // stacks:
@@ -906,12 +1900,19 @@ func newEmptyFormulaArg() formulaArg {
 //	opft - Operator of the operation formula
 //	args - Arguments list of the operation formula
 //
-// TODO: handle subtypes: Nothing, Text, Logical, Error, Concatenation, Intersection, Union
+// TODO: handle subtypes: Nothing, Text, Logical, Concatenation, Intersection, Union
 func (f *File) evalInfixExp(ctx *calcContext, sheet, cell string, tokens []efp.Token) (formulaArg, error) {
 	var err error
 	opdStack, optStack, opfStack, opfdStack, opftStack, argsStack := NewStack(), NewStack(), NewStack(), NewStack(), NewStack(), NewStack()
-	var inArray, inArrayRow bool
+	var (
+		inArray, inArrayRow bool
+		formulaArray        [][]formulaArg
+		formulaArrayRow     []formulaArg
+	)
 	for i := 0; i < len(tokens); i++ {
+		if err = ctx.canceled(); err != nil {
+			return newEmptyFormulaArg(), err
+		}
 		token := tokens[i]
 
 		// out of function stack
@@ -924,13 +1925,18 @@ func (f *File) evalInfixExp(ctx *calcContext, sheet, cell string, tokens []efp.T
 		// function start
 		if isFunctionStartToken(token) {
 			if token.TValue == "ARRAY" {
-				inArray = true
+				inArray, formulaArray = true, [][]formulaArg{}
 				continue
 			}
 			if token.TValue == "ARRAYROW" {
-				inArrayRow = true
+				inArrayRow, formulaArrayRow = true, []formulaArg{}
 				continue
 			}
+			if ctx.unsupportedFunctionError {
+				if name := strings.TrimPrefix(token.TValue, "_xlfn."); !f.isFormulaFuncSupported(name) {
+					return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE), ErrUnsupportedFunction{FuncName: name}
+				}
+			}
 			opfStack.Push(token)
 			argsStack.Push(list.New().Init())
 			opftStack.Push(token) // to know which operators belong to a function use the function as a separator
@@ -965,13 +1971,18 @@ func (f *File) evalInfixExp(ctx *calcContext, sheet, cell string, tokens []efp.T
 					if refTo != "" {
 						token.TValue = refTo
 					}
-					result, err := f.parseReference(ctx, sheet, token.TValue)
+					// the whole column or row reference will be limited to the used
+					// range only if it's the argument of the aggregation functions
+					// which ignore the blank cells
+					operand := nextToken.TType == efp.TokenTypeArgument && !opfdStack.Empty()
+					result, err := f.parseRangeReference(ctx, sheet, token.TValue,
+						!operand && blankIgnoredFormulaFuncs[strings.ToUpper(opfStack.Peek().(efp.Token).TValue)])
 					if err != nil {
 						return result, err
 					}
 					// when current token is range, next token is argument and opfdStack not empty,
 					// should push value to opfdStack and continue
-					if nextToken.TType == efp.TokenTypeArgument && !opfdStack.Empty() {
+					if operand {
 						opfdStack.Push(result)
 						continue
 					}
@@ -994,6 +2005,11 @@ func (f *File) evalInfixExp(ctx *calcContext, sheet, cell string, tokens []efp.T
 
 			// current token is arg
 			if token.TType == efp.TokenTypeArgument {
+				// the omitted argument, such as the second one in the
+				// LINEST(A1:A4,,TRUE), will be passed as an empty argument
+				if !inArrayRow && (isFunctionStartToken(tokens[i-1]) || tokens[i-1].TType == efp.TokenTypeArgument) {
+					argsStack.Peek().(*list.List).PushBack(newEmptyFormulaArg())
+				}
 				for opftStack.Peek().(efp.Token) != opfStack.Peek().(efp.Token) {
 					// calculate trigger
 					topOpt := opftStack.Peek().(efp.Token)
@@ -1005,18 +2021,29 @@ func (f *File) evalInfixExp(ctx *calcContext, sheet, cell string, tokens []efp.T
 				if !opfdStack.Empty() {
 					argsStack.Peek().(*list.List).PushBack(opfdStack.Pop().(formulaArg))
 				}
+				if !inArrayRow && isFunctionStopToken(nextToken) {
+					argsStack.Peek().(*list.List).PushBack(newEmptyFormulaArg())
+				}
 				continue
 			}
 
 			if inArrayRow && isOperand(token) {
+				// negate the array element, such as -1 in the {-1,2}
+				for opftStack.Peek().(efp.Token).TType == efp.TokenTypeOperatorPrefix {
+					if err = calculate(opfdStack, opftStack.Pop().(efp.Token)); err != nil {
+						return newEmptyFormulaArg(), err
+					}
+				}
+				formulaArrayRow = append(formulaArrayRow, opfdStack.Pop().(formulaArg))
 				continue
 			}
 			if inArrayRow && isFunctionStopToken(token) {
+				formulaArray = append(formulaArray, formulaArrayRow)
 				inArrayRow = false
 				continue
 			}
 			if inArray && isFunctionStopToken(token) {
-				argsStack.Peek().(*list.List).PushBack(opfdStack.Pop())
+				argsStack.Peek().(*list.List).PushBack(newMatrixFormulaArg(formulaArray))
 				inArray = false
 				continue
 			}
@@ -1035,6 +2062,9 @@ func (f *File) evalInfixExp(ctx *calcContext, sheet, cell string, tokens []efp.T
 	if opdStack.Len() == 0 {
 		return newEmptyFormulaArg(), ErrInvalidFormula
 	}
+	if result := opdStack.Peek().(formulaArg); result.Type == ArgError {
+		return result, errors.New(result.Error)
+	}
 	return opdStack.Peek().(formulaArg), err
 }
 
@@ -1055,7 +2085,8 @@ func (f *File) evalInfixExpFunc(ctx *calcContext, sheet, cell string, token, nex
 	opftStack.Pop() // remove current function separator
 	opfStack.Pop()
 	if opfStack.Len() > 0 { // still in function stack
-		if nextToken.TType == efp.TokenTypeOperatorInfix || (opftStack.Len() > 1 && opfdStack.Len() > 0) {
+		if nextToken.TType == efp.TokenTypeOperatorInfix || nextToken.TType == efp.TokenTypeOperatorPostfix ||
+			(opftStack.Len() > 1 && opfdStack.Len() > 0) {
 			// mathematics calculate in formula function
 			opfdStack.Push(arg)
 			return newEmptyFormulaArg()
@@ -1063,7 +2094,7 @@ func (f *File) evalInfixExpFunc(ctx *calcContext, sheet, cell string, token, nex
 		argsStack.Peek().(*list.List).PushBack(arg)
 		return newEmptyFormulaArg()
 	}
-	if arg.Type == ArgMatrix && len(arg.Matrix) > 0 && len(arg.Matrix[0]) > 0 {
+	if arg.Type == ArgMatrix && len(arg.Matrix) > 0 && len(arg.Matrix[0]) > 0 && !ctx.isSpilling(sheet, cell) {
 		opdStack.Push(arg.Matrix[0][0])
 		return newEmptyFormulaArg()
 	}
@@ -1099,13 +2130,26 @@ func prepareEvalInfixExp(opfStack, opftStack, opfdStack, argsStack *Stack) {
 	}
 }
 
+// operandToNumber converts the operand of the arithmetic operations to a
+// number, the date or time text will be converted to the date-time serial
+// number, such as "2020-01-01"+1.
+func operandToNumber(opd formulaArg) formulaArg {
+	num := opd.ToNumber()
+	if num.Type == ArgError && opd.Type == ArgString {
+		if serial, ok := strToDateTimeSerial(opd.String); ok {
+			return newNumberFormulaArg(serial)
+		}
+	}
+	return num
+}
+
 // calcPow evaluate exponentiation arithmetic operations.
 func calcPow(rOpd, lOpd formulaArg, opdStack *Stack) error {
-	lOpdVal := lOpd.ToNumber()
+	lOpdVal := operandToNumber(lOpd)
 	if lOpdVal.Type != ArgNumber {
 		return errors.New(lOpdVal.Value())
 	}
-	rOpdVal := rOpd.ToNumber()
+	rOpdVal := operandToNumber(rOpd)
 	if rOpdVal.Type != ArgNumber {
 		return errors.New(rOpdVal.Value())
 	}
@@ -1113,20 +2157,42 @@ func calcPow(rOpd, lOpd formulaArg, opdStack *Stack) error {
 	return nil
 }
 
+// prepareCompareOperands converts the empty operand of the comparison
+// operations to the empty string, FALSE or zero by the data type of the other
+// operand.
+func prepareCompareOperands(rOpd, lOpd formulaArg) (formulaArg, formulaArg) {
+	emptyAs := func(opd, other formulaArg) formulaArg {
+		if opd.Type != ArgEmpty {
+			return opd
+		}
+		if other.Type == ArgString {
+			return newStringFormulaArg("")
+		}
+		if other.Type == ArgNumber && other.Boolean {
+			return newBoolFormulaArg(false)
+		}
+		return newNumberFormulaArg(0)
+	}
+	return emptyAs(rOpd, lOpd), emptyAs(lOpd, rOpd)
+}
+
 // calcEq evaluate equal arithmetic operations.
 func calcEq(rOpd, lOpd formulaArg, opdStack *Stack) error {
+	rOpd, lOpd = prepareCompareOperands(rOpd, lOpd)
 	opdStack.Push(newBoolFormulaArg(rOpd.Value() == lOpd.Value()))
 	return nil
 }
 
 // calcNEq evaluate not equal arithmetic operations.
 func calcNEq(rOpd, lOpd formulaArg, opdStack *Stack) error {
+	rOpd, lOpd = prepareCompareOperands(rOpd, lOpd)
 	opdStack.Push(newBoolFormulaArg(rOpd.Value() != lOpd.Value()))
 	return nil
 }
 
 // calcL evaluate less than arithmetic operations.
 func calcL(rOpd, lOpd formulaArg, opdStack *Stack) error {
+	rOpd, lOpd = prepareCompareOperands(rOpd, lOpd)
 	if rOpd.Type == ArgNumber && lOpd.Type == ArgNumber {
 		opdStack.Push(newBoolFormulaArg(lOpd.Number < rOpd.Number))
 	}
@@ -1144,6 +2210,7 @@ func calcL(rOpd, lOpd formulaArg, opdStack *Stack) error {
 
 // calcLe evaluate less than or equal arithmetic operations.
 func calcLe(rOpd, lOpd formulaArg, opdStack *Stack) error {
+	rOpd, lOpd = prepareCompareOperands(rOpd, lOpd)
 	if rOpd.Type == ArgNumber && lOpd.Type == ArgNumber {
 		opdStack.Push(newBoolFormulaArg(lOpd.Number <= rOpd.Number))
 	}
@@ -1161,6 +2228,7 @@ func calcLe(rOpd, lOpd formulaArg, opdStack *Stack) error {
 
 // calcG evaluate greater than arithmetic operations.
 func calcG(rOpd, lOpd formulaArg, opdStack *Stack) error {
+	rOpd, lOpd = prepareCompareOperands(rOpd, lOpd)
 	if rOpd.Type == ArgNumber && lOpd.Type == ArgNumber {
 		opdStack.Push(newBoolFormulaArg(lOpd.Number > rOpd.Number))
 	}
@@ -1178,6 +2246,7 @@ func calcG(rOpd, lOpd formulaArg, opdStack *Stack) error {
 
 // calcGe evaluate greater than or equal arithmetic operations.
 func calcGe(rOpd, lOpd formulaArg, opdStack *Stack) error {
+	rOpd, lOpd = prepareCompareOperands(rOpd, lOpd)
 	if rOpd.Type == ArgNumber && lOpd.Type == ArgNumber {
 		opdStack.Push(newBoolFormulaArg(lOpd.Number >= rOpd.Number))
 	}
@@ -1193,19 +2262,21 @@ func calcGe(rOpd, lOpd formulaArg, opdStack *Stack) error {
 	return nil
 }
 
-// calcSplice evaluate splice '&' operations.
+// calcSplice evaluate splice '&' operations, the empty operand will be
+// treated as an empty string.
 func calcSplice(rOpd, lOpd formulaArg, opdStack *Stack) error {
 	opdStack.Push(newStringFormulaArg(lOpd.Value() + rOpd.Value()))
 	return nil
 }
 
-// calcAdd evaluate addition arithmetic operations.
+// calcAdd evaluate addition arithmetic operations, the empty operand will be
+// treated as zero.
 func calcAdd(rOpd, lOpd formulaArg, opdStack *Stack) error {
-	lOpdVal := lOpd.ToNumber()
+	lOpdVal := operandToNumber(lOpd)
 	if lOpdVal.Type != ArgNumber {
 		return errors.New(lOpdVal.Value())
 	}
-	rOpdVal := rOpd.ToNumber()
+	rOpdVal := operandToNumber(rOpd)
 	if rOpdVal.Type != ArgNumber {
 		return errors.New(rOpdVal.Value())
 	}
@@ -1215,11 +2286,11 @@ func calcAdd(rOpd, lOpd formulaArg, opdStack *Stack) error {
 
 // calcSubtract evaluate subtraction arithmetic operations.
 func calcSubtract(rOpd, lOpd formulaArg, opdStack *Stack) error {
-	lOpdVal := lOpd.ToNumber()
+	lOpdVal := operandToNumber(lOpd)
 	if lOpdVal.Type != ArgNumber {
 		return errors.New(lOpdVal.Value())
 	}
-	rOpdVal := rOpd.ToNumber()
+	rOpdVal := operandToNumber(rOpd)
 	if rOpdVal.Type != ArgNumber {
 		return errors.New(rOpdVal.Value())
 	}
@@ -1229,11 +2300,11 @@ func calcSubtract(rOpd, lOpd formulaArg, opdStack *Stack) error {
 
 // calcMultiply evaluate multiplication arithmetic operations.
 func calcMultiply(rOpd, lOpd formulaArg, opdStack *Stack) error {
-	lOpdVal := lOpd.ToNumber()
+	lOpdVal := operandToNumber(lOpd)
 	if lOpdVal.Type != ArgNumber {
 		return errors.New(lOpdVal.Value())
 	}
-	rOpdVal := rOpd.ToNumber()
+	rOpdVal := operandToNumber(rOpd)
 	if rOpdVal.Type != ArgNumber {
 		return errors.New(rOpdVal.Value())
 	}
@@ -1243,19 +2314,108 @@ func calcMultiply(rOpd, lOpd formulaArg, opdStack *Stack) error {
 
 // calcDiv evaluate division arithmetic operations.
 func calcDiv(rOpd, lOpd formulaArg, opdStack *Stack) error {
-	lOpdVal := lOpd.ToNumber()
+	lOpdVal := operandToNumber(lOpd)
 	if lOpdVal.Type != ArgNumber {
 		return errors.New(lOpdVal.Value())
 	}
-	rOpdVal := rOpd.ToNumber()
+	rOpdVal := operandToNumber(rOpd)
 	if rOpdVal.Type != ArgNumber {
 		return errors.New(rOpdVal.Value())
 	}
 	if rOpdVal.Number == 0 {
-		return errors.New(formulaErrorDIV)
+		opdStack.Push(newErrorFormulaArg(formulaErrorDIV, formulaErrorDIV))
+		return nil
+	}
+	opdStack.Push(newNumberFormulaArg(lOpdVal.Number / rOpdVal.Number))
+	return nil
+}
+
+// matrixOperandElement returns the element of the operand at the given row
+// and column index for the element-wise matrix operations. A scalar operand
+// or a single row or column matrix will be broadcast.
+func matrixOperandElement(opd formulaArg, row, col int) formulaArg {
+	if opd.Type != ArgMatrix {
+		return opd
+	}
+	if len(opd.Matrix) == 1 {
+		row = 0
+	}
+	if row < len(opd.Matrix) && len(opd.Matrix[row]) == 1 {
+		col = 0
+	}
+	if row < len(opd.Matrix) && col < len(opd.Matrix[row]) {
+		return opd.Matrix[row][col]
+	}
+	return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
+}
+
+// calcMatrix evaluate arithmetic and comparison operations element-wise when
+// any of the operands is a matrix, for example: (A1:A3>2)*1.
+func calcMatrix(fn func(rOpd, lOpd formulaArg, opdStack *Stack) error, rOpd, lOpd formulaArg, opdStack *Stack) error {
+	var rows, cols int
+	for _, opd := range []formulaArg{lOpd, rOpd} {
+		if opd.Type != ArgMatrix {
+			continue
+		}
+		if len(opd.Matrix) > rows {
+			rows = len(opd.Matrix)
+		}
+		for _, row := range opd.Matrix {
+			if len(row) > cols {
+				cols = len(row)
+			}
+		}
+	}
+	matrix := make([][]formulaArg, rows)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			lArg, rArg := matrixOperandElement(lOpd, r, c), matrixOperandElement(rOpd, r, c)
+			if lArg.Type == ArgError {
+				matrix[r] = append(matrix[r], lArg)
+				continue
+			}
+			if rArg.Type == ArgError {
+				matrix[r] = append(matrix[r], rArg)
+				continue
+			}
+			s := NewStack()
+			if err := fn(rArg, lArg, s); err != nil {
+				matrix[r] = append(matrix[r], newErrorFormulaArg(formulaErrorVALUE, err.Error()))
+				continue
+			}
+			if s.Len() == 0 {
+				matrix[r] = append(matrix[r], newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE))
+				continue
+			}
+			matrix[r] = append(matrix[r], s.Pop().(formulaArg))
+		}
+	}
+	opdStack.Push(newMatrixFormulaArg(matrix))
+	return nil
+}
+
+// firstErrorOperand returns the first error operand of the binary operation
+// in evaluation order, the error should be propagated unchanged as the
+// result of the operation.
+func firstErrorOperand(lOpd, rOpd formulaArg) formulaArg {
+	for _, opd := range []formulaArg{lOpd, rOpd} {
+		if opd.Type == ArgError {
+			return opd
+		}
+	}
+	return newEmptyFormulaArg()
+}
+
+// firstErrorArg returns the first error value in the given list of formula
+// arguments, the error in an array argument should be propagated unchanged as
+// the result of the function.
+func firstErrorArg(args []formulaArg) formulaArg {
+	for _, arg := range args {
+		if arg.Type == ArgError {
+			return arg
+		}
 	}
-	opdStack.Push(newNumberFormulaArg(lOpdVal.Number / rOpdVal.Number))
-	return nil
+	return newEmptyFormulaArg()
 }
 
 // calculate evaluate basic arithmetic operations.
@@ -1265,7 +2425,20 @@ func calculate(opdStack *Stack, opt efp.Token) error {
 			return ErrInvalidFormula
 		}
 		opd := opdStack.Pop().(formulaArg)
-		opdStack.Push(newNumberFormulaArg(0 - opd.ToNumber().Number))
+		if opd.Type == ArgError {
+			opdStack.Push(opd)
+			return nil
+		}
+		if opd.Type == ArgMatrix {
+			// negates each element, the double negation coerces booleans to numbers
+			return calcMatrix(calcSubtract, opd, newNumberFormulaArg(0), opdStack)
+		}
+		num := operandToNumber(opd)
+		if num.Type != ArgNumber {
+			opdStack.Push(newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE))
+			return nil
+		}
+		opdStack.Push(newNumberFormulaArg(0 - num.Number))
 	}
 	if opt.TValue == "-" && opt.TType == efp.TokenTypeOperatorInfix {
 		if opdStack.Len() < 2 {
@@ -1273,6 +2446,13 @@ func calculate(opdStack *Stack, opt efp.Token) error {
 		}
 		rOpd := opdStack.Pop().(formulaArg)
 		lOpd := opdStack.Pop().(formulaArg)
+		if errArg := firstErrorOperand(lOpd, rOpd); errArg.Type == ArgError {
+			opdStack.Push(errArg)
+			return nil
+		}
+		if rOpd.Type == ArgMatrix || lOpd.Type == ArgMatrix {
+			return calcMatrix(calcSubtract, rOpd, lOpd, opdStack)
+		}
 		if err := calcSubtract(rOpd, lOpd, opdStack); err != nil {
 			return err
 		}
@@ -1297,11 +2477,12 @@ func calculate(opdStack *Stack, opt efp.Token) error {
 		}
 		rOpd := opdStack.Pop().(formulaArg)
 		lOpd := opdStack.Pop().(formulaArg)
-		if rOpd.Type == ArgError {
-			return errors.New(rOpd.Value())
+		if errArg := firstErrorOperand(lOpd, rOpd); errArg.Type == ArgError {
+			opdStack.Push(errArg)
+			return nil
 		}
-		if lOpd.Type == ArgError {
-			return errors.New(lOpd.Value())
+		if rOpd.Type == ArgMatrix || lOpd.Type == ArgMatrix {
+			return calcMatrix(fn, rOpd, lOpd, opdStack)
 		}
 		if err := fn(rOpd, lOpd, opdStack); err != nil {
 			return err
@@ -1310,9 +2491,14 @@ func calculate(opdStack *Stack, opt efp.Token) error {
 	return nil
 }
 
-// parseOperatorPrefixToken parse operator prefix token.
+// parseOperatorPrefixToken parse operator prefix token. The operators with
+// the same priority will be evaluated from left to right, so 2^3^2 will be
+// evaluated as (2^3)^2 like Excel, and the negation has higher priority than
+// the exponentiation, so -2^2 will be evaluated as (-2)^2. The negation has no
+// left operand, so consecutive negations such as --A1 are applied from right to
+// left.
 func (f *File) parseOperatorPrefixToken(optStack, opdStack *Stack, token efp.Token) (err error) {
-	if optStack.Len() == 0 {
+	if optStack.Len() == 0 || token.TType == efp.TokenTypeOperatorPrefix {
 		optStack.Push(token)
 		return
 	}
@@ -1408,8 +2594,18 @@ func (f *File) parseToken(ctx *calcContext, sheet string, token efp.Token, opdSt
 		}
 		result, err := f.parseReference(ctx, sheet, token.TValue)
 		if err != nil {
+			if err = ctx.canceled(); err != nil {
+				return err
+			}
 			return errors.New(formulaErrorNAME)
 		}
+		if result.Type == ArgEmpty || result.Type == ArgError {
+			// the blank cell will be treated as zero in numeric context and
+			// empty string in text context by the operations, and the error
+			// will be propagated by the operations
+			opdStack.Push(result)
+			return nil
+		}
 		token = formulaArgToToken(result)
 	}
 	if isOperatorPrefixToken(token) {
@@ -1432,6 +2628,12 @@ func (f *File) parseToken(ctx *calcContext, sheet string, token efp.Token, opdSt
 	}
 	if token.TType == efp.TokenTypeOperatorPostfix && !opdStack.Empty() {
 		topOpd := opdStack.Pop().(formulaArg)
+		if topOpd.Type == ArgError {
+			return errors.New(topOpd.Value())
+		}
+		if topOpd = topOpd.ToNumber(); topOpd.Type != ArgNumber {
+			return errors.New(topOpd.Value())
+		}
 		opdStack.Push(newNumberFormulaArg(topOpd.Number / 100))
 	}
 	// opd
@@ -1464,6 +2666,35 @@ func (f *File) parseRef(ref string) (cellRef, bool, bool, error) {
 	return cr, false, false, err
 }
 
+// parseCellRange parse the cell or range reference to a cell range without
+// reading the cell values by given default worksheet name.
+func (f *File) parseCellRange(sheet, reference string) (cellRange, error) {
+	var cr cellRange
+	for i, ref := range strings.Split(strings.ReplaceAll(reference, "$", ""), ":") {
+		cellRef, isCol, isRow, err := f.parseRef(ref)
+		if err != nil {
+			return cellRange{}, err
+		}
+		if i == 0 {
+			if isCol {
+				cellRef.Row = 1
+			}
+			if isRow {
+				cellRef.Col = 1
+			}
+			if cellRef.Sheet == "" {
+				cellRef.Sheet = sheet
+			}
+			cr.From, cr.To = cellRef, cellRef
+			continue
+		}
+		if err = cr.prepareCellRange(isCol, isRow, cellRef); err != nil {
+			return cellRange{}, err
+		}
+	}
+	return cr, nil
+}
+
 // prepareCellRange checking and convert cell reference to a cell range.
 func (cr *cellRange) prepareCellRange(col, row bool, cellRef cellRef) error {
 	if col {
@@ -1493,10 +2724,203 @@ func (cr *cellRange) prepareCellRange(col, row bool, cellRef cellRef) error {
 	return nil
 }
 
+// parse3DReference parse the 3-D reference which spans multiple worksheets,
+// for example: Sheet1:Sheet3!A1 or Sheet1:Sheet3!A1:B2. The values of the
+// reference on each worksheet between the start and end worksheet will be
+// combined into a matrix by rows. The boolean result indicates whether the
+// given reference is a 3-D reference, which starts with an existing worksheet.
+func (f *File) parse3DReference(ctx *calcContext, reference string) (formulaArg, bool, error) {
+	idx := strings.LastIndex(reference, "!")
+	if idx == -1 {
+		return newEmptyFormulaArg(), false, nil
+	}
+	sheets := strings.Split(strings.Trim(reference[:idx], "'"), ":")
+	if len(sheets) != 2 || strings.Contains(sheets[0], "!") {
+		return newEmptyFormulaArg(), false, nil
+	}
+	sheetList := f.getCalcSheetList(ctx)
+	from, to := inStrSlice(sheetList, sheets[0], false), inStrSlice(sheetList, sheets[1], false)
+	if from == -1 {
+		return newEmptyFormulaArg(), false, nil
+	}
+	if to == -1 {
+		return newErrorFormulaArg(formulaErrorREF, "invalid reference"), true, errors.New("invalid reference")
+	}
+	if from > to {
+		from, to = to, from
+	}
+	var matrix [][]formulaArg
+	for _, sheet := range sheetList[from : to+1] {
+		arg, err := f.parseReference(ctx, sheet, reference[idx+1:])
+		if err != nil {
+			return arg, true, err
+		}
+		if arg.Type == ArgMatrix {
+			matrix = append(matrix, arg.Matrix...)
+			continue
+		}
+		matrix = append(matrix, []formulaArg{arg})
+	}
+	return newMatrixFormulaArg(matrix), true, nil
+}
+
+// getCalcSheetList returns the worksheet names in the workbook, which will be
+// resolved once for each formula execution context.
+func (f *File) getCalcSheetList(ctx *calcContext) []string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.sheetList == nil {
+		ctx.sheetList = f.GetSheetList()
+	}
+	return ctx.sheetList
+}
+
+// SetExternalWorkbook provides a function to register an external workbook by
+// given name, which will be used to resolve the external references in the
+// formulas, such as ='[Budget.xlsx]Sheet1'!A1. The name is case-insensitive
+// and should be the workbook name enclosed in the square brackets of the
+// external references. The external references to the workbooks that haven't
+// been registered will be calculated as the #REF! error. The external
+// workbooks are read-only in the calculation, set the ext to nil to unregister
+// the workbook. For example, register the workbook Budget.xlsx:
+//
+//	budget, err := excelize.OpenFile("Budget.xlsx")
+//	if err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	f.SetExternalWorkbook("Budget.xlsx", budget)
+func (f *File) SetExternalWorkbook(name string, ext *File) {
+	if ext == nil {
+		f.externalWorkbooks.Delete(strings.ToLower(name))
+		return
+	}
+	f.externalWorkbooks.Store(strings.ToLower(name), ext)
+}
+
+// getExternalWorkbookName provides a function to get the file name of the
+// external workbook by given 1-based index of the external references in the
+// workbook, such as the index 1 in the reference [1]Sheet1!A1. The file name
+// is the target of the external link part which referenced by the workbook.
+func (f *File) getExternalWorkbookName(idx int) (string, error) {
+	wb, err := f.workbookReader()
+	if err != nil || wb.ExternalReferences == nil || idx < 1 ||
+		idx > len(wb.ExternalReferences.ExternalReference) {
+		return "", err
+	}
+	rels, err := f.relsReader(f.getWorkbookRelsPath())
+	if err != nil || rels == nil {
+		return "", err
+	}
+	var target string
+	rels.mu.Lock()
+	for _, rel := range rels.Relationships {
+		if rel.ID == wb.ExternalReferences.ExternalReference[idx-1].RID {
+			target = rel.Target
+		}
+	}
+	rels.mu.Unlock()
+	if target == "" {
+		return "", err
+	}
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else {
+		target = path.Join(path.Dir(f.getWorkbookPath()), target)
+	}
+	if rels, err = f.relsReader(path.Join(path.Dir(target), "_rels", path.Base(target)+".rels")); err != nil || rels == nil {
+		return "", err
+	}
+	rels.mu.Lock()
+	defer rels.mu.Unlock()
+	for _, rel := range rels.Relationships {
+		if rel.TargetMode == "External" {
+			name, err := url.PathUnescape(strings.ReplaceAll(rel.Target, "\\", "/"))
+			return path.Base(name), err
+		}
+	}
+	return "", err
+}
+
+// externalContext returns the calculation context for the cells in the given
+// external workbook, which derived from ctx and shared by all the workbooks
+// in the same calculation, so the iterations guard is kept across the
+// references between the workbooks, and the cells, sheet list and used ranges
+// of each workbook are tracked separately.
+func (ctx *calcContext) externalContext(f, book *File) *calcContext {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.workbooks == nil {
+		ctx.workbooks = map[*File]*calcContext{f: ctx}
+	}
+	if extCtx, ok := ctx.workbooks[book]; ok {
+		return extCtx
+	}
+	extCtx := &calcContext{
+		done:                     ctx.done,
+		maxCalcIterations:        ctx.maxCalcIterations,
+		gregorianDates:           ctx.gregorianDates,
+		pureCalc:                 ctx.pureCalc,
+		unsupportedFunctionError: ctx.unsupportedFunctionError,
+		iterations:               make(map[string]uint),
+		iterationsCache:          make(map[string]formulaArg),
+		workbooks:                ctx.workbooks,
+	}
+	ctx.workbooks[book] = extCtx
+	return extCtx
+}
+
+// parseExternalReference parse the external reference which references the
+// cells in an external workbook registered by the SetExternalWorkbook, for
+// example: [Budget.xlsx]Sheet1!A1 or '[Budget.xlsx]Sheet 1'!A1:B2, and the
+// index form [1]Sheet1!A1 which saved in the workbook is resolved by the
+// external link parts. The boolean result indicates whether the given
+// reference is an external reference.
+func (f *File) parseExternalReference(ctx *calcContext, reference string) (formulaArg, bool, error) {
+	idx := strings.LastIndex(reference, "!")
+	if idx == -1 {
+		return newEmptyFormulaArg(), false, nil
+	}
+	prefix := strings.Trim(reference[:idx], "'")
+	end := strings.Index(prefix, "]")
+	if !strings.HasPrefix(prefix, "[") || end == -1 {
+		return newEmptyFormulaArg(), false, nil
+	}
+	name, sheet := prefix[1:end], prefix[end+1:]
+	if n, err := strconv.Atoi(name); err == nil {
+		if name, err = f.getExternalWorkbookName(n); err != nil {
+			return newErrorFormulaArg(formulaErrorREF, formulaErrorREF), true, err
+		}
+	}
+	ext, ok := f.externalWorkbooks.Load(strings.ToLower(name))
+	if !ok {
+		return newErrorFormulaArg(formulaErrorREF, formulaErrorREF), true, nil
+	}
+	book := ext.(*File)
+	if sheetIdx, _ := book.GetSheetIndex(sheet); sheetIdx == -1 {
+		return newErrorFormulaArg(formulaErrorREF, formulaErrorREF), true, nil
+	}
+	arg, err := book.parseReference(ctx.externalContext(f, book), sheet, reference[idx+1:])
+	return arg, true, err
+}
+
 // parseReference parse reference and extract values by given reference
 // characters and default sheet name.
 func (f *File) parseReference(ctx *calcContext, sheet, reference string) (formulaArg, error) {
+	return f.parseRangeReference(ctx, sheet, reference, false)
+}
+
+// parseRangeReference parse reference and extract values by given reference
+// characters and default sheet name, the whole column or row reference will
+// be limited to the used range of the worksheet if trim is true.
+func (f *File) parseRangeReference(ctx *calcContext, sheet, reference string, trim bool) (formulaArg, error) {
 	reference = strings.ReplaceAll(reference, "$", "")
+	if arg, ok, err := f.parseExternalReference(ctx, reference); ok {
+		return arg, err
+	}
+	if arg, ok, err := f.parse3DReference(ctx, reference); ok {
+		return arg, err
+	}
 	ranges, cellRanges, cellRefs := strings.Split(reference, ":"), list.New(), list.New()
 	if len(ranges) > 1 {
 		var cr cellRange
@@ -1523,7 +2947,7 @@ func (f *File) parseReference(ctx *calcContext, sheet, reference string) (formul
 			}
 		}
 		cellRanges.PushBack(cr)
-		return f.rangeResolver(ctx, cellRefs, cellRanges)
+		return f.rangeResolver(ctx, cellRefs, cellRanges, trim)
 	}
 	cellRef, _, _, err := f.parseRef(reference)
 	if err != nil {
@@ -1533,7 +2957,7 @@ func (f *File) parseReference(ctx *calcContext, sheet, reference string) (formul
 		cellRef.Sheet = sheet
 	}
 	cellRefs.PushBack(cellRef)
-	return f.rangeResolver(ctx, cellRefs, cellRanges)
+	return f.rangeResolver(ctx, cellRefs, cellRanges, false)
 }
 
 // prepareValueRange prepare value range.
@@ -1575,7 +2999,13 @@ func (f *File) cellResolver(ctx *calcContext, sheet, cell string) (formulaArg, e
 		value string
 		err   error
 	)
+	if err = ctx.canceled(); err != nil {
+		return newEmptyFormulaArg(), err
+	}
 	ref := fmt.Sprintf("%s!%s", sheet, cell)
+	if arg, ok := ctx.bindings[ref]; ok {
+		return arg, nil
+	}
 	if formula, _ := f.GetCellFormula(sheet, cell); len(formula) != 0 {
 		ctx.mu.Lock()
 		if ctx.entry != ref {
@@ -1583,6 +3013,9 @@ func (f *File) cellResolver(ctx *calcContext, sheet, cell string) (formulaArg, e
 				ctx.iterations[ref]++
 				ctx.mu.Unlock()
 				arg, _ = f.calcCellValue(ctx, sheet, cell)
+				if err = ctx.canceled(); err != nil {
+					return newEmptyFormulaArg(), err
+				}
 				ctx.iterationsCache[ref] = arg
 				return arg, nil
 			}
@@ -1611,10 +3044,43 @@ func (f *File) cellResolver(ctx *calcContext, sheet, cell string) (formulaArg, e
 	}
 }
 
+// spillResolver calculates the dynamic array result of the formula in the
+// given spill anchor cell. Unlike the cell resolver, the array result will be
+// kept rather than reduced to the value of the anchor cell, and the #REF!
+// error will be returned for the circular spilled range references.
+func (f *File) spillResolver(ctx *calcContext, sheet, cell string) (formulaArg, error) {
+	ref := fmt.Sprintf("%s!%s", sheet, cell)
+	ctx.mu.Lock()
+	if ctx.spills == nil {
+		ctx.spills = make(map[string]bool)
+	}
+	if ctx.spills[ref] {
+		ctx.mu.Unlock()
+		return newErrorFormulaArg(formulaErrorREF, formulaErrorREF), nil
+	}
+	ctx.spills[ref] = true
+	ctx.mu.Unlock()
+	defer func() {
+		ctx.mu.Lock()
+		delete(ctx.spills, ref)
+		ctx.mu.Unlock()
+	}()
+	return f.calcCellValue(ctx, sheet, cell)
+}
+
+// isSpilling determine if the formula in the given cell is being calculated
+// by the spill resolver.
+func (ctx *calcContext) isSpilling(sheet, cell string) bool {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.spills[fmt.Sprintf("%s!%s", sheet, cell)]
+}
+
 // rangeResolver extract value as string from given reference and range list.
 // This function will not ignore the empty cell. For example, A1:A2:A2:B3 will
-// be reference A1:B3.
-func (f *File) rangeResolver(ctx *calcContext, cellRefs, cellRanges *list.List) (arg formulaArg, err error) {
+// be reference A1:B3. The whole column or row reference will be limited to
+// the used range of the worksheet if trim is true.
+func (f *File) rangeResolver(ctx *calcContext, cellRefs, cellRanges *list.List, trim bool) (arg formulaArg, err error) {
 	arg.cellRefs, arg.cellRanges = cellRefs, cellRanges
 	// value range order: from row, to row, from column, to column
 	valueRange := []int{0, 0, 0, 0}
@@ -1640,6 +3106,11 @@ func (f *File) rangeResolver(ctx *calcContext, cellRefs, cellRanges *list.List)
 	// extract value from ranges
 	if cellRanges.Len() > 0 {
 		arg.Type = ArgMatrix
+		if trim && (valueRange[1] == TotalRows || valueRange[3] == MaxColumns) {
+			if err = f.trimValueRange(ctx, sheet, valueRange); err != nil {
+				return
+			}
+		}
 		for row := valueRange[0]; row <= valueRange[1]; row++ {
 			var matrixRow []formulaArg
 			for col := valueRange[2]; col <= valueRange[3]; col++ {
@@ -1672,9 +3143,248 @@ func (f *File) rangeResolver(ctx *calcContext, cellRefs, cellRanges *list.List)
 	return
 }
 
+// trimValueRange limits the value range of the whole column or row reference
+// to the used range of the worksheet, the cells beyond the last row or column
+// containing data are empty and will not be resolved. The trimmed value range
+// keeps at least one row and column of the reference.
+func (f *File) trimValueRange(ctx *calcContext, sheet string, valueRange []int) error {
+	maxCol, maxRow, err := f.getUsedRange(ctx, sheet)
+	if err != nil {
+		return err
+	}
+	if valueRange[1] == TotalRows {
+		if valueRange[1] = maxRow; valueRange[1] < valueRange[0] {
+			valueRange[1] = valueRange[0]
+		}
+	}
+	if valueRange[3] == MaxColumns {
+		if valueRange[3] = maxCol; valueRange[3] < valueRange[2] {
+			valueRange[3] = valueRange[2]
+		}
+	}
+	return nil
+}
+
+// getUsedRange returns the last column and row number which contains cell on
+// the worksheet by given worksheet name, including the cells provided by the
+// calculation bindings. The used range is read from the dimension of the
+// worksheet, and extended by the last cell of each row for the edited cells
+// which are not recorded in the dimension yet. The used range will be cached
+// in the calculation context.
+func (f *File) getUsedRange(ctx *calcContext, sheet string) (int, int, error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if usedRange, ok := ctx.usedRanges[sheet]; ok {
+		return usedRange[0], usedRange[1], nil
+	}
+	f.mu.Lock()
+	ws, err := f.workSheetReader(sheet)
+	if err != nil {
+		f.mu.Unlock()
+		return 0, 0, err
+	}
+	f.mu.Unlock()
+	var maxCol, maxRow int
+	ws.mu.Lock()
+	if ws.Dimension != nil {
+		if coordinates, err := rangeRefToCoordinates(ws.Dimension.Ref); err == nil {
+			_ = sortCoordinates(coordinates)
+			maxCol, maxRow = coordinates[2], coordinates[3]
+		}
+	}
+	for r, row := range ws.SheetData.Row {
+		if len(row.C) == 0 {
+			continue
+		}
+		if row.R != 0 {
+			r = row.R - 1
+		}
+		if r+1 > maxRow {
+			maxRow = r + 1
+		}
+		c := len(row.C)
+		if col, _, err := CellNameToCoordinates(row.C[c-1].R); err == nil && col > c {
+			c = col
+		}
+		if c > maxCol {
+			maxCol = c
+		}
+	}
+	ws.mu.Unlock()
+	for ref := range ctx.bindings {
+		if !strings.HasPrefix(ref, sheet+"!") {
+			continue
+		}
+		if col, row, err := CellNameToCoordinates(strings.TrimPrefix(ref, sheet+"!")); err == nil {
+			if col > maxCol {
+				maxCol = col
+			}
+			if row > maxRow {
+				maxRow = row
+			}
+		}
+	}
+	if ctx.usedRanges == nil {
+		ctx.usedRanges = make(map[string][]int)
+	}
+	ctx.usedRanges[sheet] = []int{maxCol, maxRow}
+	return maxCol, maxRow, nil
+}
+
+// RegisterFunction provides a function to register a custom formula function
+// by given function name, the function name is case-insensitive and will be
+// converted to uppercase, it must begin with a letter and only contain
+// letters, digits and underscores. The built-in functions can't be
+// overridden. For example, register a custom function MYADD which returns the
+// sum of the numeric arguments:
+//
+//	err := f.RegisterFunction("MYADD", func(args []excelize.FormulaArgument) (excelize.FormulaResult, error) {
+//	    var sum float64
+//	    for _, arg := range args {
+//	        sum += arg.Number
+//	    }
+//	    return excelize.FormulaResult{Type: excelize.ArgNumber, Number: sum}, nil
+//	})
+//
+// Then use it in the formula like =MYADD(A1,B1). The error returned by the
+// custom function will be treated as a #VALUE! formula error.
+func (f *File) RegisterFunction(name string, fn func(args []FormulaArgument) (FormulaResult, error)) error {
+	if fn == nil {
+		return ErrParameterInvalid
+	}
+	name = strings.ToUpper(name)
+	if len(name) == 0 || len(name) > MaxFieldLength || name[0] < 'A' || name[0] > 'Z' ||
+		strings.IndexFunc(name, func(r rune) bool {
+			return r != '_' && (r < 'A' || r > 'Z') && (r < '0' || r > '9')
+		}) != -1 {
+		return fmt.Errorf("invalid function name %s", name)
+	}
+	if reflect.ValueOf(&formulaFuncs{}).MethodByName(name).IsValid() {
+		return fmt.Errorf("built-in function %s can not be overridden", name)
+	}
+	f.functions.Store(name, fn)
+	return nil
+}
+
+// newFormulaArgument converts the formula argument to the argument of the
+// custom formula function.
+func newFormulaArgument(arg formulaArg) FormulaArgument {
+	fa := FormulaArgument{Type: arg.Type, Number: arg.Number, String: arg.String, Boolean: arg.Boolean}
+	if arg.Type == ArgList {
+		fa.Type, arg.Matrix = ArgMatrix, [][]formulaArg{arg.List}
+	}
+	for _, row := range arg.Matrix {
+		var cells []FormulaArgument
+		for _, cell := range row {
+			cells = append(cells, newFormulaArgument(cell))
+		}
+		fa.Matrix = append(fa.Matrix, cells)
+	}
+	return fa
+}
+
+// callCustomFunc calls the custom formula function registered by the
+// RegisterFunction with given arguments list.
+func callCustomFunc(fn func(args []FormulaArgument) (FormulaResult, error), argsList *list.List) formulaArg {
+	var args []FormulaArgument
+	for arg := argsList.Front(); arg != nil; arg = arg.Next() {
+		args = append(args, newFormulaArgument(arg.Value.(formulaArg)))
+	}
+	result, err := fn(args)
+	if err != nil {
+		return newErrorFormulaArg(formulaErrorVALUE, err.Error())
+	}
+	return result.toFormulaArg()
+}
+
+// toFormulaArg converts the formula result to the formula argument.
+func (fr FormulaResult) toFormulaArg() formulaArg {
+	switch fr.Type {
+	case ArgNumber:
+		if fr.Boolean {
+			return newBoolFormulaArg(fr.Number != 0)
+		}
+		return newNumberFormulaArg(fr.Number)
+	case ArgString:
+		return newStringFormulaArg(fr.String)
+	case ArgError:
+		return newErrorFormulaArg(fr.String, fr.String)
+	default:
+		return newEmptyFormulaArg()
+	}
+}
+
+// checkPureCalc checking if the formula function by given name and arguments
+// is allowed in the pure calculation, the volatile functions and the INDIRECT
+// function which references other worksheets are not allowed.
+func (fn *formulaFuncs) checkPureCalc(name string, argsList *list.List) formulaArg {
+	name = strings.ToUpper(name)
+	if impureFormulaFuncs[name] {
+		return newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("%s function is not allowed in pure calculation", name))
+	}
+	if name == "INDIRECT" && argsList.Len() > 0 {
+		for _, ref := range strings.Split(argsList.Front().Value.(formulaArg).Value(), ":") {
+			if tokens := strings.Split(ref, "!"); len(tokens) == 2 && strings.Trim(tokens[0], "'") != fn.sheet {
+				return newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("%s function is not allowed in pure calculation", name))
+			}
+		}
+	}
+	return newEmptyFormulaArg()
+}
+
+// formulaFuncsTable is the dispatch table of the built-in formula functions
+// by the method name, which is built once to avoid the reflection lookup on
+// each formula function call.
+var formulaFuncsTable = newFormulaFuncsTable()
+
+// newFormulaFuncsTable build the dispatch table of the built-in formula
+// functions with the methods of the formulaFuncs.
+func newFormulaFuncsTable() map[string]func(*formulaFuncs, *list.List) formulaArg {
+	table := make(map[string]func(*formulaFuncs, *list.List) formulaArg)
+	typ := reflect.TypeOf(&formulaFuncs{})
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+		if fn, ok := method.Func.Interface().(func(*formulaFuncs, *list.List) formulaArg); ok {
+			table[method.Name] = fn
+		}
+	}
+	return table
+}
+
+// isFormulaFuncSupported returns if the formula function by given name is a
+// built-in formula function or a custom formula function registered by the
+// RegisterFunction.
+func (f *File) isFormulaFuncSupported(name string) bool {
+	if _, ok := f.functions.Load(strings.ToUpper(name)); ok {
+		return true
+	}
+	_, ok := formulaFuncsTable[strings.ReplaceAll(name, ".", "dot")]
+	return ok
+}
+
 // callFuncByName calls the no error or only error return function with
-// reflect by given receiver, name and parameters.
+// reflect by given receiver, name and parameters. The built-in formula
+// functions will be called by the dispatch table without reflection. The
+// custom formula functions registered by the RegisterFunction take
+// precedence over the built-in functions.
 func callFuncByName(receiver interface{}, name string, params []reflect.Value) (arg formulaArg) {
+	if fn, ok := receiver.(*formulaFuncs); ok && fn.ctx != nil && fn.ctx.pureCalc && len(params) == 1 {
+		if arg = fn.checkPureCalc(name, params[0].Interface().(*list.List)); arg.Type == ArgError {
+			return
+		}
+	}
+	if fn, ok := receiver.(*formulaFuncs); ok && fn.f != nil && len(params) == 1 {
+		if custom, ok := fn.f.functions.Load(strings.ToUpper(name)); ok {
+			return callCustomFunc(custom.(func(args []FormulaArgument) (FormulaResult, error)), params[0].Interface().(*list.List))
+		}
+	}
+	if fn, ok := receiver.(*formulaFuncs); ok && len(params) == 1 {
+		if argsList, ok := params[0].Interface().(*list.List); ok {
+			if function, ok := formulaFuncsTable[name]; ok {
+				return function(fn, argsList)
+			}
+		}
+	}
 	function := reflect.ValueOf(receiver).MethodByName(name)
 	if function.IsValid() {
 		rt := function.Call(params)
@@ -1696,6 +3406,9 @@ func formulaCriteriaParser(exp formulaArg) *formulaCriteria {
 			percentile /= 100
 		}
 		if expected, err = strconv.ParseFloat(cond, 64); err != nil {
+			if serial, ok := criteriaDateSerial(cond); ok {
+				return serial, nil
+			}
 			return
 		}
 		expected *= percentile
@@ -1724,10 +3437,23 @@ func formulaCriteriaParser(exp formulaArg) *formulaCriteria {
 	fc.Type, fc.Condition = criteriaRegexp, newStringFormulaArg(val)
 	if num := fc.Condition.ToNumber(); num.Type == ArgNumber {
 		fc.Condition = num
+	} else if serial, ok := criteriaDateSerial(val); ok {
+		fc.Condition = newNumberFormulaArg(serial)
 	}
 	return fc
 }
 
+// criteriaDateSerial converts the date formatted criteria string to the serial
+// number, such as 2023-01-01 or 1/1/2023, which will be compared against the
+// numeric cell values. The boolean result indicates whether the given string
+// is date formatted.
+func criteriaDateSerial(cond string) (float64, bool) {
+	if _, _, _, _, err := strToDate(strings.ToLower(cond)); err.Type == ArgError {
+		return 0, false
+	}
+	return strToDateTimeSerial(cond)
+}
+
 // formulaCriteriaEval evaluate formula criteria expression.
 func formulaCriteriaEval(val formulaArg, criteria *formulaCriteria) (result bool, err error) {
 	s := NewStack()
@@ -2288,8 +4014,10 @@ var conversionUnits = map[string]conversionUnit{
 	// temperature
 	"C":    {group: categoryTemperature, allowPrefix: false},
 	"cel":  {group: categoryTemperature, allowPrefix: false},
+	"°C":   {group: categoryTemperature, allowPrefix: false},
 	"F":    {group: categoryTemperature, allowPrefix: false},
 	"fah":  {group: categoryTemperature, allowPrefix: false},
+	"°F":   {group: categoryTemperature, allowPrefix: false},
 	"K":    {group: categoryTemperature, allowPrefix: false},
 	"kel":  {group: categoryTemperature, allowPrefix: false},
 	"Rank": {group: categoryTemperature, allowPrefix: false},
@@ -2370,6 +4098,7 @@ var conversionUnits = map[string]conversionUnit{
 	"m/h":   {group: categorySpeed, allowPrefix: true},
 	"m/hr":  {group: categorySpeed, allowPrefix: true},
 	"mph":   {group: categorySpeed, allowPrefix: false},
+	"kph":   {group: categorySpeed, allowPrefix: false},
 	"admkn": {group: categorySpeed, allowPrefix: false},
 	"kn":    {group: categorySpeed, allowPrefix: false},
 }
@@ -2555,6 +4284,7 @@ var unitConversions = map[byte]map[string]float64{
 		"m/h":   3.60e+03,
 		"m/hr":  3.60e+03,
 		"mph":   2.23693629205440e+00,
+		"kph":   3.60e+00,
 		"admkn": 1.94260256941567e+00,
 		"kn":    1.94384449244060e+00,
 	},
@@ -2616,7 +4346,8 @@ func getUnitDetails(uom string) (unit string, catgory byte, res float64, ok bool
 		unitCategory := conversionUnit.group
 		return uom, unitCategory, multiplier, true
 	}
-	// 2 character standard and binary metric multiplier prefixes
+	// 2 character standard and binary metric multiplier prefixes, the binary
+	// prefixes can only be used with the information units
 	if len(uom) > 0 {
 		multiplierType += uom[:1]
 		uom = uom[1:]
@@ -2624,7 +4355,7 @@ func getUnitDetails(uom string) (unit string, catgory byte, res float64, ok bool
 	conversionUnit, ok1 = conversionUnits[uom]
 	multiplier, ok2 = conversionMultipliers[multiplierType]
 	if ok1 && ok2 {
-		if !conversionUnit.allowPrefix {
+		if !conversionUnit.allowPrefix || (strings.HasSuffix(multiplierType, "i") && conversionUnit.group != categoryInformation) {
 			ok = false
 			return
 		}
@@ -2639,9 +4370,9 @@ func getUnitDetails(uom string) (unit string, catgory byte, res float64, ok bool
 // temperature synonyms.
 func resolveTemperatureSynonyms(uom string) string {
 	switch uom {
-	case "fah":
+	case "fah", "°F":
 		return "F"
-	case "cel":
+	case "cel", "°C":
 		return "C"
 	case "kel":
 		return "K"
@@ -2686,8 +4417,8 @@ func convertTemperature(fromUOM, toUOM string, value float64) float64 {
 //
 //	CONVERT(number,from_unit,to_unit)
 func (fn *formulaFuncs) CONVERT(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "CONVERT requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("CONVERT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	num := argsList.Front().Value.(formulaArg).ToNumber()
 	if num.Type != ArgNumber {
@@ -2861,8 +4592,8 @@ func (fn *formulaFuncs) ERF(argsList *list.List) formulaArg {
 //
 //	ERF.PRECISE(x)
 func (fn *formulaFuncs) ERFdotPRECISE(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ERF.PRECISE requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ERF.PRECISE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	x := argsList.Front().Value.(formulaArg).ToNumber()
 	if x.Type != ArgNumber {
@@ -3002,8 +4733,8 @@ func (fn *formulaFuncs) hex2dec(number string) formulaArg {
 //
 //	IMABS(inumber)
 func (fn *formulaFuncs) IMABS(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMABS requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMABS", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3018,8 +4749,8 @@ func (fn *formulaFuncs) IMABS(argsList *list.List) formulaArg {
 //
 //	IMAGINARY(inumber)
 func (fn *formulaFuncs) IMAGINARY(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMAGINARY requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMAGINARY", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3034,8 +4765,8 @@ func (fn *formulaFuncs) IMAGINARY(argsList *list.List) formulaArg {
 //
 //	IMARGUMENT(inumber)
 func (fn *formulaFuncs) IMARGUMENT(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMARGUMENT requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMARGUMENT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3050,8 +4781,8 @@ func (fn *formulaFuncs) IMARGUMENT(argsList *list.List) formulaArg {
 //
 //	IMCONJUGATE(inumber)
 func (fn *formulaFuncs) IMCONJUGATE(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMCONJUGATE requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMCONJUGATE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3066,8 +4797,8 @@ func (fn *formulaFuncs) IMCONJUGATE(argsList *list.List) formulaArg {
 //
 //	IMCOS(inumber)
 func (fn *formulaFuncs) IMCOS(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMCOS requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMCOS", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3082,8 +4813,8 @@ func (fn *formulaFuncs) IMCOS(argsList *list.List) formulaArg {
 //
 //	IMCOSH(inumber)
 func (fn *formulaFuncs) IMCOSH(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMCOSH requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMCOSH", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3098,8 +4829,8 @@ func (fn *formulaFuncs) IMCOSH(argsList *list.List) formulaArg {
 //
 //	IMCOT(inumber)
 func (fn *formulaFuncs) IMCOT(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMCOT requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMCOT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3114,8 +4845,8 @@ func (fn *formulaFuncs) IMCOT(argsList *list.List) formulaArg {
 //
 //	IMCSC(inumber)
 func (fn *formulaFuncs) IMCSC(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMCSC requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMCSC", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3134,8 +4865,8 @@ func (fn *formulaFuncs) IMCSC(argsList *list.List) formulaArg {
 //
 //	IMCSCH(inumber)
 func (fn *formulaFuncs) IMCSCH(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMCSCH requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMCSCH", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3154,8 +4885,8 @@ func (fn *formulaFuncs) IMCSCH(argsList *list.List) formulaArg {
 //
 //	IMDIV(inumber1,inumber2)
 func (fn *formulaFuncs) IMDIV(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMDIV requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("IMDIV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber1, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3178,8 +4909,8 @@ func (fn *formulaFuncs) IMDIV(argsList *list.List) formulaArg {
 //
 //	IMEXP(inumber)
 func (fn *formulaFuncs) IMEXP(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMEXP requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMEXP", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3194,8 +4925,8 @@ func (fn *formulaFuncs) IMEXP(argsList *list.List) formulaArg {
 //
 //	IMLN(inumber)
 func (fn *formulaFuncs) IMLN(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMLN requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMLN", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3214,8 +4945,8 @@ func (fn *formulaFuncs) IMLN(argsList *list.List) formulaArg {
 //
 //	IMLOG10(inumber)
 func (fn *formulaFuncs) IMLOG10(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMLOG10 requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMLOG10", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3234,8 +4965,8 @@ func (fn *formulaFuncs) IMLOG10(argsList *list.List) formulaArg {
 //
 //	IMLOG2(inumber)
 func (fn *formulaFuncs) IMLOG2(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMLOG2 requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMLOG2", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3254,8 +4985,8 @@ func (fn *formulaFuncs) IMLOG2(argsList *list.List) formulaArg {
 //
 //	IMPOWER(inumber,number)
 func (fn *formulaFuncs) IMPOWER(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMPOWER requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("IMPOWER", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3319,8 +5050,8 @@ func (fn *formulaFuncs) IMPRODUCT(argsList *list.List) formulaArg {
 //
 //	IMREAL(inumber)
 func (fn *formulaFuncs) IMREAL(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMREAL requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMREAL", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3335,8 +5066,8 @@ func (fn *formulaFuncs) IMREAL(argsList *list.List) formulaArg {
 //
 //	IMSEC(inumber)
 func (fn *formulaFuncs) IMSEC(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMSEC requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMSEC", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3351,8 +5082,8 @@ func (fn *formulaFuncs) IMSEC(argsList *list.List) formulaArg {
 //
 //	IMSECH(inumber)
 func (fn *formulaFuncs) IMSECH(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMSECH requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMSECH", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3367,8 +5098,8 @@ func (fn *formulaFuncs) IMSECH(argsList *list.List) formulaArg {
 //
 //	IMSIN(inumber)
 func (fn *formulaFuncs) IMSIN(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMSIN requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMSIN", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3383,8 +5114,8 @@ func (fn *formulaFuncs) IMSIN(argsList *list.List) formulaArg {
 //
 //	IMSINH(inumber)
 func (fn *formulaFuncs) IMSINH(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMSINH requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMSINH", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3399,8 +5130,8 @@ func (fn *formulaFuncs) IMSINH(argsList *list.List) formulaArg {
 //
 //	IMSQRT(inumber)
 func (fn *formulaFuncs) IMSQRT(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMSQRT requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMSQRT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3416,8 +5147,8 @@ func (fn *formulaFuncs) IMSQRT(argsList *list.List) formulaArg {
 //
 //	IMSUB(inumber1,inumber2)
 func (fn *formulaFuncs) IMSUB(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMSUB requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("IMSUB", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	i1, err := strconv.ParseComplex(str2cmplx(argsList.Front().Value.(formulaArg).Value()), 128)
 	if err != nil {
@@ -3435,8 +5166,8 @@ func (fn *formulaFuncs) IMSUB(argsList *list.List) formulaArg {
 //
 //	IMSUM(inumber1,inumber2,...)
 func (fn *formulaFuncs) IMSUM(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMSUM requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("IMSUM", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var result complex128
 	for arg := argsList.Front(); arg != nil; arg = arg.Next() {
@@ -3455,8 +5186,8 @@ func (fn *formulaFuncs) IMSUM(argsList *list.List) formulaArg {
 //
 //	IMTAN(inumber)
 func (fn *formulaFuncs) IMTAN(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IMTAN requires 1 argument")
+	if argsCount := checkFormulaArgsCount("IMTAN", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg).Value()
 	inumber, err := strconv.ParseComplex(str2cmplx(value), 128)
@@ -3668,13 +5399,19 @@ func (fn *formulaFuncs) AGGREGATE(argsList *list.List) formulaArg {
 	}
 	subArgList := list.New().Init()
 	for arg := argsList.Front().Next().Next(); arg != nil; arg = arg.Next() {
-		subArgList.PushBack(arg.Value.(formulaArg))
+		token := arg.Value.(formulaArg)
+		// the options 0 to 3 ignore the nested SUBTOTAL and AGGREGATE functions
+		if int(opts.Number) <= 3 {
+			token = fn.excludeSubtotals(token)
+		}
+		subArgList.PushBack(token)
 	}
 	return subFn(subArgList)
 }
 
-// ARABIC function converts a Roman numeral into an Arabic numeral. The syntax
-// of the function is:
+// ARABIC function converts a Roman numeral into an Arabic numeral. The text
+// which is not a valid Roman numeral in any form of the ROMAN function will
+// return #VALUE! error. The syntax of the function is:
 //
 //	ARABIC(text)
 func (fn *formulaFuncs) ARABIC(argsList *list.List) formulaArg {
@@ -3699,6 +5436,12 @@ func (fn *formulaFuncs) ARABIC(argsList *list.List) formulaArg {
 		actualStart++
 	}
 	charMap := map[rune]int{'I': 1, 'V': 5, 'X': 10, 'L': 50, 'C': 100, 'D': 500, 'M': 1000}
+	numeral := text[actualStart : index+1]
+	for _, c := range numeral {
+		if _, ok := charMap[c]; !ok {
+			return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+		}
+	}
 	for index >= actualStart {
 		startIndex = index
 		startChar := text[startIndex]
@@ -3719,12 +5462,29 @@ func (fn *formulaFuncs) ARABIC(argsList *list.List) formulaArg {
 	if subtractNumber != 0 {
 		number -= subtractNumber
 	}
+	if numeral != "" && !isRomanNumeral(numeral, number) {
+		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+	}
 	if isNegative {
 		number = -number
 	}
 	return newNumberFormulaArg(float64(number))
 }
 
+// isRomanNumeral returns if the given text is the Roman numeral of the number
+// in any form of the ROMAN function.
+func isRomanNumeral(text string, number int) bool {
+	if number < 1 || number > 3999 {
+		return false
+	}
+	for form := range romanTable {
+		if romanNumeral(float64(number), form) == text {
+			return true
+		}
+	}
+	return false
+}
+
 // ASIN function calculates the arcsine (i.e. the inverse sine) of a given
 // number, and returns an angle, in radians, between -π/2 and π/2. The syntax
 // of the function is:
@@ -3808,7 +5568,9 @@ func (fn *formulaFuncs) ATAN2(argsList *list.List) formulaArg {
 }
 
 // BASE function converts a number into a supplied base (radix), and returns a
-// text representation of the calculated value. The syntax of the function is:
+// text representation of the calculated value with uppercase letters. The
+// number must be a non-negative integer less than 2^53. The syntax of the
+// function is:
 //
 //	BASE(number,radix,[min_length])
 func (fn *formulaFuncs) BASE(argsList *list.List) formulaArg {
@@ -3836,6 +5598,9 @@ func (fn *formulaFuncs) BASE(argsList *list.List) formulaArg {
 			return newErrorFormulaArg(formulaErrorVALUE, err.Error())
 		}
 	}
+	if number.Number < 0 || number.Number >= 1<<53 || minLength < 0 || minLength > 255 {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	}
 	result := strconv.FormatInt(int64(number.Number), int(radix.Number))
 	if len(result) < minLength {
 		result = strings.Repeat("0", minLength-len(result)) + result
@@ -3884,31 +5649,27 @@ func (fn *formulaFuncs) CEILING(argsList *list.List) formulaArg {
 }
 
 // CEILINGdotMATH function rounds a supplied number up to a supplied multiple
-// of significance. The syntax of the function is:
+// of significance. The sign of the significance is ignored, the negative
+// numbers are rounded toward zero by default, and rounded away from zero if
+// the mode is nonzero. The syntax of the function is:
 //
 //	CEILING.MATH(number,[significance],[mode])
 func (fn *formulaFuncs) CEILINGdotMATH(argsList *list.List) formulaArg {
-	if argsList.Len() == 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "CEILING.MATH requires at least 1 argument")
-	}
-	if argsList.Len() > 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "CEILING.MATH allows at most 3 arguments")
+	if argsCount := checkFormulaArgsCount("CEILING.MATH", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
-	number, significance, mode := 0.0, 1.0, 1.0
+	significance, mode := 1.0, 0.0
 	n := argsList.Front().Value.(formulaArg).ToNumber()
 	if n.Type == ArgError {
 		return n
 	}
-	number = n.Number
-	if number < 0 {
-		significance = -1
-	}
+	number := n.Number
 	if argsList.Len() > 1 {
 		s := argsList.Front().Next().Value.(formulaArg).ToNumber()
 		if s.Type == ArgError {
 			return s
 		}
-		significance = s.Number
+		significance = math.Abs(s.Number)
 	}
 	if argsList.Len() == 1 {
 		return newNumberFormulaArg(math.Ceil(number))
@@ -3920,11 +5681,14 @@ func (fn *formulaFuncs) CEILINGdotMATH(argsList *list.List) formulaArg {
 		}
 		mode = m.Number
 	}
+	if significance == 0 {
+		return newNumberFormulaArg(0)
+	}
 	val, res := math.Modf(number / significance)
 	if res != 0 {
 		if number > 0 {
 			val++
-		} else if mode < 0 {
+		} else if mode != 0 {
 			val--
 		}
 	}
@@ -4004,7 +5768,7 @@ func (fn *formulaFuncs) COMBIN(argsList *list.List) formulaArg {
 	for c := float64(1); c <= chosen; c++ {
 		val *= (number + 1 - c) / c
 	}
-	return newNumberFormulaArg(math.Ceil(val))
+	return newFiniteNumberFormulaArg(math.Ceil(val))
 }
 
 // COMBINA function calculates the number of combinations, with repetitions,
@@ -4148,7 +5912,9 @@ func (fn *formulaFuncs) CSCH(argsList *list.List) formulaArg {
 }
 
 // DECIMAL function converts a text representation of a number in a specified
-// base, into a decimal value. The syntax of the function is:
+// base, into a decimal value. The letters of the text are case-insensitive,
+// and the digits out of range for the radix will return #NUM! error. The
+// syntax of the function is:
 //
 //	DECIMAL(text,radix)
 func (fn *formulaFuncs) DECIMAL(argsList *list.List) formulaArg {
@@ -4161,12 +5927,20 @@ func (fn *formulaFuncs) DECIMAL(argsList *list.List) formulaArg {
 	if radix.Type != ArgNumber {
 		return radix
 	}
+	if int(radix.Number) < 2 || int(radix.Number) > 36 {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	}
 	if len(text) > 2 && (strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X")) {
 		text = text[2:]
 	}
+	for _, c := range strings.ToUpper(text) {
+		if digit := strings.IndexRune("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ", c); digit == -1 || digit >= int(radix.Number) {
+			return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+		}
+	}
 	val, err := strconv.ParseInt(text, int(radix.Number), 64)
 	if err != nil {
-		return newErrorFormulaArg(formulaErrorVALUE, err.Error())
+		return newErrorFormulaArg(formulaErrorNUM, err.Error())
 	}
 	return newNumberFormulaArg(float64(val))
 }
@@ -4254,7 +6028,7 @@ func (fn *formulaFuncs) FACT(argsList *list.List) formulaArg {
 	if number.Number < 0 {
 		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	return newNumberFormulaArg(fact(number.Number))
+	return newFiniteNumberFormulaArg(fact(number.Number))
 }
 
 // FACTDOUBLE function returns the double factorial of a supplied number. The
@@ -4309,30 +6083,26 @@ func (fn *formulaFuncs) FLOOR(argsList *list.List) formulaArg {
 }
 
 // FLOORdotMATH function rounds a supplied number down to a supplied multiple
-// of significance. The syntax of the function is:
+// of significance. The sign of the significance is ignored, the negative
+// numbers are rounded away from zero by default, and rounded toward zero if
+// the mode is nonzero. The syntax of the function is:
 //
 //	FLOOR.MATH(number,[significance],[mode])
 func (fn *formulaFuncs) FLOORdotMATH(argsList *list.List) formulaArg {
-	if argsList.Len() == 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "FLOOR.MATH requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("FLOOR.MATH", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
-	if argsList.Len() > 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "FLOOR.MATH allows at most 3 arguments")
-	}
-	significance, mode := 1.0, 1.0
+	significance, mode := 1.0, 0.0
 	number := argsList.Front().Value.(formulaArg).ToNumber()
 	if number.Type == ArgError {
 		return number
 	}
-	if number.Number < 0 {
-		significance = -1
-	}
 	if argsList.Len() > 1 {
 		s := argsList.Front().Next().Value.(formulaArg).ToNumber()
 		if s.Type == ArgError {
 			return s
 		}
-		significance = s.Number
+		significance = math.Abs(s.Number)
 	}
 	if argsList.Len() == 1 {
 		return newNumberFormulaArg(math.Floor(number.Number))
@@ -4344,8 +6114,11 @@ func (fn *formulaFuncs) FLOORdotMATH(argsList *list.List) formulaArg {
 		}
 		mode = m.Number
 	}
+	if significance == 0 {
+		return newNumberFormulaArg(0)
+	}
 	val, res := math.Modf(number.Number / significance)
-	if res != 0 && number.Number < 0 && mode > 0 {
+	if res != 0 && number.Number < 0 && mode == 0 {
 		val--
 	}
 	return newNumberFormulaArg(val * significance)
@@ -4417,8 +6190,8 @@ func gcd(x, y float64) float64 {
 //
 //	GCD(number1,[number2],...)
 func (fn *formulaFuncs) GCD(argsList *list.List) formulaArg {
-	if argsList.Len() == 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "GCD requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("GCD", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var (
 		val  float64
@@ -4454,8 +6227,10 @@ func (fn *formulaFuncs) GCD(argsList *list.List) formulaArg {
 	return newNumberFormulaArg(cd)
 }
 
-// INT function truncates a supplied number down to the closest integer. The
-// syntax of the function is:
+// INT function truncates a supplied number down to the closest integer. Unlike
+// the TRUNC function, the negative numbers are rounded toward negative
+// infinity, and the integers are returned unchanged. The syntax of the
+// function is:
 //
 //	INT(number)
 func (fn *formulaFuncs) INT(argsList *list.List) formulaArg {
@@ -4466,11 +6241,10 @@ func (fn *formulaFuncs) INT(argsList *list.List) formulaArg {
 	if number.Type == ArgError {
 		return number
 	}
-	val, frac := math.Modf(number.Number)
-	if frac < 0 {
-		val--
+	if val := math.Floor(number.Number); val != 0 {
+		return newNumberFormulaArg(val)
 	}
-	return newNumberFormulaArg(val)
+	return newNumberFormulaArg(0)
 }
 
 // ISOdotCEILING function rounds a supplied number up (regardless of the
@@ -4531,8 +6305,8 @@ func lcm(a, b float64) float64 {
 //
 //	LCM(number1,[number2],...)
 func (fn *formulaFuncs) LCM(argsList *list.List) formulaArg {
-	if argsList.Len() == 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "LCM requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("LCM", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var (
 		val  float64
@@ -4657,6 +6431,9 @@ func minor(sqMtx [][]float64, idx int) [][]float64 {
 
 // det determinant of the 2x2 matrix.
 func det(sqMtx [][]float64) float64 {
+	if len(sqMtx) == 1 {
+		return sqMtx[0][0]
+	}
 	if len(sqMtx) == 2 {
 		m00 := sqMtx[0][0]
 		m01 := sqMtx[0][1]
@@ -4709,8 +6486,8 @@ func newFormulaArgMatrix(numMtx [][]float64) (arg [][]formulaArg) {
 //
 //	MDETERM(array)
 func (fn *formulaFuncs) MDETERM(argsList *list.List) (result formulaArg) {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MDETERM requires 1 argument")
+	if argsCount := checkFormulaArgsCount("MDETERM", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	numMtx, errArg := newNumberMatrix(argsList.Front().Value.(formulaArg), true)
 	if errArg.Type == ArgError {
@@ -4765,25 +6542,41 @@ func adjugateMatrix(A [][]float64) (adjA [][]float64) {
 	return
 }
 
+// inverseMatrix returns the inverse of the square matrix with the adjugate
+// matrix, it returns nil if the matrix is singular.
+func inverseMatrix(numMtx [][]float64) [][]float64 {
+	if len(numMtx) == 1 {
+		if numMtx[0][0] == 0 {
+			return nil
+		}
+		return [][]float64{{1 / numMtx[0][0]}}
+	}
+	detM := det(numMtx)
+	if detM == 0 {
+		return nil
+	}
+	datM, invertM := 1/detM, adjugateMatrix(numMtx)
+	for i := 0; i < len(invertM); i++ {
+		for j := 0; j < len(invertM[i]); j++ {
+			invertM[i][j] *= datM
+		}
+	}
+	return invertM
+}
+
 // MINVERSE function calculates the inverse of a square matrix. The syntax of
 // the function is:
 //
 //	MINVERSE(array)
 func (fn *formulaFuncs) MINVERSE(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MINVERSE requires 1 argument")
+	if argsCount := checkFormulaArgsCount("MINVERSE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	numMtx, errArg := newNumberMatrix(argsList.Front().Value.(formulaArg), true)
 	if errArg.Type == ArgError {
 		return errArg
 	}
-	if detM := det(numMtx); detM != 0 {
-		datM, invertM := 1/detM, adjugateMatrix(numMtx)
-		for i := 0; i < len(invertM); i++ {
-			for j := 0; j < len(invertM[i]); j++ {
-				invertM[i][j] *= datM
-			}
-		}
+	if invertM := inverseMatrix(numMtx); invertM != nil {
 		return newMatrixFormulaArg(newFormulaArgMatrix(invertM))
 	}
 	return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
@@ -4855,15 +6648,21 @@ func (fn *formulaFuncs) MOD(argsList *list.List) formulaArg {
 	if divisor.Number == 0 {
 		return newErrorFormulaArg(formulaErrorDIV, "MOD divide by zero")
 	}
-	trunc, rem := math.Modf(number.Number / divisor.Number)
-	if rem < 0 {
-		trunc--
+	// the floating-point remainder is exact for large operands, and has the
+	// same sign as the divisor as in Excel
+	result := math.Mod(number.Number, divisor.Number)
+	if result == 0 {
+		return newNumberFormulaArg(0)
+	}
+	if (result < 0) != (divisor.Number < 0) {
+		result += divisor.Number
 	}
-	return newNumberFormulaArg(number.Number - divisor.Number*trunc)
+	return newNumberFormulaArg(result)
 }
 
 // MROUND function rounds a supplied number up or down to the nearest multiple
-// of a given number. The syntax of the function is:
+// of a given number, the halves are rounded away from zero. The syntax of the
+// function is:
 //
 //	MROUND(number,multiple)
 func (fn *formulaFuncs) MROUND(argsList *list.List) formulaArg {
@@ -4885,9 +6684,9 @@ func (fn *formulaFuncs) MROUND(argsList *list.List) formulaArg {
 		multiple.Number > 0 && n.Number < 0 {
 		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	number, res := math.Modf(n.Number / multiple.Number)
-	if math.Trunc(res+0.5) > 0 {
-		number++
+	number := fn.round(n.Number/multiple.Number, 0, closest)
+	if number == 0 {
+		return newNumberFormulaArg(0)
 	}
 	return newNumberFormulaArg(number * multiple.Number)
 }
@@ -4898,7 +6697,7 @@ func (fn *formulaFuncs) MROUND(argsList *list.List) formulaArg {
 //
 //	MULTINOMIAL(number1,[number2],...)
 func (fn *formulaFuncs) MULTINOMIAL(argsList *list.List) formulaArg {
-	val, num, denom := 0.0, 0.0, 1.0
+	val, num, result := 0.0, 0.0, 1.0
 	var err error
 	for arg := argsList.Front(); arg != nil; arg = arg.Next() {
 		token := arg.Value.(formulaArg)
@@ -4913,10 +6712,17 @@ func (fn *formulaFuncs) MULTINOMIAL(argsList *list.List) formulaArg {
 		case ArgNumber:
 			val = token.Number
 		}
+		// multiply the binomial coefficients of the running sum to avoid
+		// dividing overflowed factorials, the coefficient C(n,k) is equal to
+		// C(n,n-k), so the smaller one will be iterated
+		val = math.Trunc(val)
 		num += val
-		denom *= fact(val)
+		k := math.Min(val, num-val)
+		for c := float64(1); c <= k && !math.IsInf(result, 0); c++ {
+			result *= (num - k + c) / c
+		}
 	}
-	return newNumberFormulaArg(fact(num) / denom)
+	return newFiniteNumberFormulaArg(math.Round(result))
 }
 
 // MUNIT function returns the unit matrix for a specified dimension. The
@@ -5027,11 +6833,13 @@ func (fn *formulaFuncs) PRODUCT(argsList *list.List) formulaArg {
 			}
 			product = product * num.Number
 		case ArgNumber:
-			product = product * token.Number
+			if !isLogicalReference(token) {
+				product = product * token.Number
+			}
 		case ArgMatrix:
 			for _, row := range token.Matrix {
 				for _, cell := range row {
-					if cell.Type == ArgNumber {
+					if cell.Type == ArgNumber && !cell.Boolean {
 						product *= cell.Number
 					}
 				}
@@ -5226,8 +7034,8 @@ var romanTable = [][]romanNumerals{
 }
 
 // ROMAN function converts an arabic number to Roman. I.e. for a supplied
-// integer, the function returns a text string depicting the roman numeral
-// form of the number. The syntax of the function is:
+// integer between 0 and 3999, the function returns a text string depicting
+// the roman numeral form of the number. The syntax of the function is:
 //
 //	ROMAN(number,[form])
 func (fn *formulaFuncs) ROMAN(argsList *list.List) formulaArg {
@@ -5254,26 +7062,24 @@ func (fn *formulaFuncs) ROMAN(argsList *list.List) formulaArg {
 			form = 4
 		}
 	}
-	decimalTable := romanTable[0]
-	switch form {
-	case 1:
-		decimalTable = romanTable[1]
-	case 2:
-		decimalTable = romanTable[2]
-	case 3:
-		decimalTable = romanTable[3]
-	case 4:
-		decimalTable = romanTable[4]
-	}
 	val := math.Trunc(number.Number)
+	if val < 0 || val > 3999 {
+		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+	}
+	return newStringFormulaArg(romanNumeral(val, form))
+}
+
+// romanNumeral converts the given non-negative integer to the Roman numeral
+// by given form of the ROMAN function.
+func romanNumeral(val float64, form int) string {
 	buf := bytes.Buffer{}
-	for _, r := range decimalTable {
+	for _, r := range romanTable[form] {
 		for val >= r.n {
 			buf.WriteString(r.s)
 			val -= r.n
 		}
 	}
-	return newStringFormulaArg(buf.String())
+	return buf.String()
 }
 
 type roundMode byte
@@ -5282,34 +7088,51 @@ const (
 	closest roundMode = iota
 	down
 	up
+	halfEven
 )
 
-// round rounds a supplied number up or down.
+// round rounds a supplied number up or down. The number will be rounded to 15
+// significant digits as Excel at first, and then be rounded by the decimal
+// digits of the integer mantissa, to avoid the binary floating-point artifacts
+// such as 2.675 which is stored slightly less than 2.675.
 func (fn *formulaFuncs) round(number, digits float64, mode roundMode) float64 {
-	var significance float64
-	if digits > 0 {
-		significance = math.Pow(1/10.0, digits)
-	} else {
-		significance = math.Pow(10.0, -digits)
+	if number == 0 || math.IsInf(number, 0) || math.IsNaN(number) {
+		return number
 	}
-	val, res := math.Modf(number / significance)
+	digits = math.Trunc(digits)
+	parts := strings.Split(strconv.FormatFloat(math.Abs(number), 'e', 14, 64), "e")
+	mantissa, _ := strconv.ParseInt(strings.Replace(parts[0], ".", "", 1), 10, 64)
+	exp, _ := strconv.Atoi(parts[1])
+	// the scaled number by the digits is mantissa * 10^shift
+	shift := exp - 14 + int(digits)
+	if shift >= 0 {
+		return number
+	}
+	if shift < -16 {
+		shift = -16
+	}
+	unit := int64(math.Pow10(-shift))
+	val, rem := mantissa/unit, mantissa%unit
 	switch mode {
 	case closest:
-		const eps = 0.499999999
-		if res >= eps {
+		if rem*2 >= unit {
 			val++
-		} else if res <= -eps {
-			val--
 		}
 	case down:
 	case up:
-		if res > 0 {
+		if rem > 0 {
+			val++
+		}
+	case halfEven:
+		if rem*2 > unit || (rem*2 == unit && val%2 == 1) {
 			val++
-		} else if res < 0 {
-			val--
 		}
 	}
-	return val * significance
+	if val == 0 {
+		return 0
+	}
+	result, _ := strconv.ParseFloat(fmt.Sprintf("%de%d", val, -int(digits)), 64)
+	return math.Copysign(result, number)
 }
 
 // ROUND function rounds a supplied number up or down, to a specified number
@@ -5331,6 +7154,27 @@ func (fn *formulaFuncs) ROUND(argsList *list.List) formulaArg {
 	return newNumberFormulaArg(fn.round(number.Number, digits.Number, closest))
 }
 
+// ROUNDdotEVEN function rounds a supplied number to a specified number of
+// decimal places, the number exactly halfway between two values will be
+// rounded to the nearest even value (banker's rounding), for example, 2.5 is
+// rounded to 2 and 3.5 is rounded to 4. The syntax of the function is:
+//
+//	ROUND.EVEN(number,num_digits)
+func (fn *formulaFuncs) ROUNDdotEVEN(argsList *list.List) formulaArg {
+	if argsList.Len() != 2 {
+		return newErrorFormulaArg(formulaErrorVALUE, "ROUND.EVEN requires 2 numeric arguments")
+	}
+	number := argsList.Front().Value.(formulaArg).ToNumber()
+	if number.Type == ArgError {
+		return number
+	}
+	digits := argsList.Back().Value.(formulaArg).ToNumber()
+	if digits.Type == ArgError {
+		return digits
+	}
+	return newNumberFormulaArg(fn.round(number.Number, digits.Number, halfEven))
+}
+
 // ROUNDDOWN function rounds a supplied number down towards zero, to a
 // specified number of decimal places. The syntax of the function is:
 //
@@ -5404,8 +7248,8 @@ func (fn *formulaFuncs) SECH(argsList *list.List) formulaArg {
 //
 //	SERIESSUM(x,n,m,coefficients)
 func (fn *formulaFuncs) SERIESSUM(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "SERIESSUM requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("SERIESSUM", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, n, m formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -5418,7 +7262,11 @@ func (fn *formulaFuncs) SERIESSUM(argsList *list.List) formulaArg {
 		return m
 	}
 	var result, i float64
-	for _, coefficient := range argsList.Back().Value.(formulaArg).ToList() {
+	coefficients := argsList.Back().Value.(formulaArg).ToList()
+	if errArg := firstErrorArg(coefficients); errArg.Type == ArgError {
+		return errArg
+	}
+	for _, coefficient := range coefficients {
 		if coefficient.Value() == "" {
 			continue
 		}
@@ -5523,8 +7371,8 @@ func (fn *formulaFuncs) SQRTPI(argsList *list.List) formulaArg {
 //
 //	STDEV(number1,[number2],...)
 func (fn *formulaFuncs) STDEV(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "STDEV requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("STDEV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.stdev(false, argsList)
 }
@@ -5534,8 +7382,8 @@ func (fn *formulaFuncs) STDEV(argsList *list.List) formulaArg {
 //
 //	STDEV.S(number1,[number2],...)
 func (fn *formulaFuncs) STDEVdotS(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "STDEV.S requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("STDEV.S", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.stdev(false, argsList)
 }
@@ -5546,8 +7394,8 @@ func (fn *formulaFuncs) STDEVdotS(argsList *list.List) formulaArg {
 //
 //	STDEVA(number1,[number2],...)
 func (fn *formulaFuncs) STDEVA(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "STDEVA requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("STDEVA", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.stdev(true, argsList)
 }
@@ -5629,8 +7477,8 @@ func (fn *formulaFuncs) stdev(stdeva bool, argsList *list.List) formulaArg {
 //
 //	POISSON.DIST(x,mean,cumulative)
 func (fn *formulaFuncs) POISSONdotDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "POISSON.DIST requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("POISSON.DIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.POISSON(argsList)
 }
@@ -5641,8 +7489,8 @@ func (fn *formulaFuncs) POISSONdotDIST(argsList *list.List) formulaArg {
 //
 //	POISSON(x,mean,cumulative)
 func (fn *formulaFuncs) POISSON(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "POISSON requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("POISSON", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, mean, cumulative formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -5738,13 +7586,86 @@ func (fn *formulaFuncs) PROB(argsList *list.List) formulaArg {
 	return newNumberFormulaArg(res)
 }
 
+// isSubtotalCell determine if the formula of the given cell contains the
+// SUBTOTAL or AGGREGATE function.
+func (fn *formulaFuncs) isSubtotalCell(sheet string, col, row int) bool {
+	cell, err := CoordinatesToCellName(col, row)
+	if err != nil {
+		return false
+	}
+	formula, err := fn.f.GetCellFormula(sheet, cell)
+	if err != nil || formula == "" {
+		return false
+	}
+	ps := efp.ExcelParser()
+	for _, token := range ps.Parse(formula) {
+		if !isFunctionStartToken(token) {
+			continue
+		}
+		if name := strings.ToUpper(strings.TrimPrefix(token.TValue, "_xlfn.")); name == "SUBTOTAL" || name == "AGGREGATE" {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeSubtotals replace the values of the referenced cells which contain
+// the SUBTOTAL or AGGREGATE function with empty values, so that the nested
+// subtotals will be ignored to avoid double counting.
+func (fn *formulaFuncs) excludeSubtotals(arg formulaArg) formulaArg {
+	if fn.f == nil {
+		return arg
+	}
+	if arg.Type == ArgMatrix && arg.cellRanges != nil && arg.cellRanges.Len() > 0 {
+		sheet, col, row := fn.sheet, 0, 0
+		for temp := arg.cellRanges.Front(); temp != nil; temp = temp.Next() {
+			cr := temp.Value.(cellRange)
+			rng := []int{cr.From.Col, cr.From.Row, cr.To.Col, cr.To.Row}
+			_ = sortCoordinates(rng)
+			if col == 0 || rng[0] < col {
+				col = rng[0]
+			}
+			if row == 0 || rng[1] < row {
+				row = rng[1]
+			}
+			if cr.From.Sheet != "" {
+				sheet = cr.From.Sheet
+			}
+		}
+		mtx := make([][]formulaArg, len(arg.Matrix))
+		for r, cells := range arg.Matrix {
+			mtx[r] = make([]formulaArg, len(cells))
+			for c, cell := range cells {
+				if mtx[r][c] = cell; fn.isSubtotalCell(sheet, col+c, row+r) {
+					mtx[r][c] = newEmptyFormulaArg()
+				}
+			}
+		}
+		arg.Matrix = mtx
+		return arg
+	}
+	if arg.Type != ArgMatrix && arg.cellRefs != nil && arg.cellRefs.Len() == 1 {
+		ref := arg.cellRefs.Front().Value.(cellRef)
+		sheet := ref.Sheet
+		if sheet == "" {
+			sheet = fn.sheet
+		}
+		if fn.isSubtotalCell(sheet, ref.Col, ref.Row) {
+			return newEmptyFormulaArg()
+		}
+	}
+	return arg
+}
+
 // SUBTOTAL function performs a specified calculation (e.g. the sum, product,
-// average, etc.) for a supplied set of values. The syntax of the function is:
+// average, etc.) for a supplied set of values, the cells contain other
+// SUBTOTAL or AGGREGATE functions in the references are ignored. The syntax
+// of the function is:
 //
 //	SUBTOTAL(function_num,ref1,[ref2],...)
 func (fn *formulaFuncs) SUBTOTAL(argsList *list.List) formulaArg {
-	if argsList.Len() < 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "SUBTOTAL requires at least 2 arguments")
+	if argsCount := checkFormulaArgsCount("SUBTOTAL", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var fnNum formulaArg
 	if fnNum = argsList.Front().Value.(formulaArg).ToNumber(); fnNum.Type != ArgNumber {
@@ -5768,13 +7689,21 @@ func (fn *formulaFuncs) SUBTOTAL(argsList *list.List) formulaArg {
 	}
 	subArgList := list.New().Init()
 	for arg := argsList.Front().Next(); arg != nil; arg = arg.Next() {
-		subArgList.PushBack(arg.Value.(formulaArg))
+		subArgList.PushBack(fn.excludeSubtotals(arg.Value.(formulaArg)))
 	}
 	return subFn(subArgList)
 }
 
+// isLogicalReference determine if the formula argument is a logical value
+// which comes from a cell reference rather than typed directly as argument.
+func isLogicalReference(arg formulaArg) bool {
+	return arg.Type == ArgNumber && arg.Boolean && arg.cellRefs != nil && arg.cellRefs.Len() > 0
+}
+
 // SUM function adds together a supplied set of numbers and returns the sum of
-// these values. The syntax of the function is:
+// these values. The logical values typed directly as arguments are counted,
+// but the logical values in references are ignored. The syntax of the
+// function is:
 //
 //	SUM(number1,[number2],...)
 func (fn *formulaFuncs) SUM(argsList *list.List) formulaArg {
@@ -5789,10 +7718,15 @@ func (fn *formulaFuncs) SUM(argsList *list.List) formulaArg {
 				sum += num.Number
 			}
 		case ArgNumber:
-			sum += token.Number
+			if !isLogicalReference(token) {
+				sum += token.Number
+			}
 		case ArgMatrix:
 			for _, row := range token.Matrix {
 				for _, value := range row {
+					if value.Type == ArgNumber && value.Boolean {
+						continue
+					}
 					if num := value.ToNumber(); num.Type == ArgNumber {
 						sum += num.Number
 					}
@@ -5803,20 +7737,63 @@ func (fn *formulaFuncs) SUM(argsList *list.List) formulaArg {
 	return newNumberFormulaArg(sum)
 }
 
+// prepareSumRange returns the cells of the sum range which has the same size
+// as the criteria range and begins with the top-left cell of the sum range.
+// The sum range will be extended from the worksheet or trimmed when it has a
+// different shape with the criteria range.
+func (fn *formulaFuncs) prepareSumRange(rangeMtx [][]formulaArg, sumRange formulaArg) [][]formulaArg {
+	rows, cols := len(rangeMtx), 0
+	for _, row := range rangeMtx {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+	mtx := sumRange.Matrix
+	if sumRange.Type != ArgMatrix {
+		mtx = [][]formulaArg{{sumRange}}
+	}
+	extend := len(mtx) < rows
+	for _, row := range mtx {
+		extend = extend || len(row) < cols
+	}
+	if !extend || fn.f == nil {
+		return mtx
+	}
+	var topLeft cellRef
+	if sumRange.cellRanges != nil && sumRange.cellRanges.Len() > 0 {
+		cr := sumRange.cellRanges.Front().Value.(cellRange)
+		rng := []int{cr.From.Col, cr.From.Row, cr.To.Col, cr.To.Row}
+		_ = sortCoordinates(rng)
+		topLeft = cellRef{Sheet: cr.From.Sheet, Col: rng[0], Row: rng[1]}
+	} else if sumRange.cellRefs != nil && sumRange.cellRefs.Len() > 0 {
+		topLeft = sumRange.cellRefs.Front().Value.(cellRef)
+	} else {
+		return mtx
+	}
+	cellRanges := list.New()
+	cellRanges.PushBack(cellRange{From: topLeft, To: cellRef{Sheet: topLeft.Sheet, Col: topLeft.Col + cols - 1, Row: topLeft.Row + rows - 1}})
+	arg, err := fn.f.rangeResolver(fn.ctx, list.New(), cellRanges, false)
+	if err != nil {
+		return mtx
+	}
+	return arg.Matrix
+}
+
 // SUMIF function finds the values in a supplied array, that satisfy a given
 // criteria, and returns the sum of the corresponding values in a second
-// supplied array. The syntax of the function is:
+// supplied array. The sum_range begins with its top-left cell and has the
+// same size as the range, as in Excel. The syntax of the function is:
 //
 //	SUMIF(range,criteria,[sum_range])
 func (fn *formulaFuncs) SUMIF(argsList *list.List) formulaArg {
-	if argsList.Len() < 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "SUMIF requires at least 2 arguments")
+	if argsCount := checkFormulaArgsCount("SUMIF", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	criteria := formulaCriteriaParser(argsList.Front().Next().Value.(formulaArg))
 	rangeMtx := argsList.Front().Value.(formulaArg).Matrix
 	var sumRange [][]formulaArg
 	if argsList.Len() == 3 {
-		sumRange = argsList.Back().Value.(formulaArg).Matrix
+		sumRange = fn.prepareSumRange(rangeMtx, argsList.Back().Value.(formulaArg))
 	}
 	var sum float64
 	var arg formulaArg
@@ -5828,6 +7805,7 @@ func (fn *formulaFuncs) SUMIF(argsList *list.List) formulaArg {
 			}
 			if ok, _ := formulaCriteriaEval(arg, criteria); ok {
 				if argsList.Len() == 3 {
+					arg = newEmptyFormulaArg()
 					if len(sumRange) > rowIdx && len(sumRange[rowIdx]) > colIdx {
 						arg = sumRange[rowIdx][colIdx]
 					}
@@ -5847,8 +7825,8 @@ func (fn *formulaFuncs) SUMIF(argsList *list.List) formulaArg {
 //
 //	SUMIFS(sum_range,criteria_range1,criteria1,[criteria_range2,criteria2],...)
 func (fn *formulaFuncs) SUMIFS(argsList *list.List) formulaArg {
-	if argsList.Len() < 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "SUMIFS requires at least 3 arguments")
+	if argsCount := checkFormulaArgsCount("SUMIFS", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	if argsList.Len()%2 != 1 {
 		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
@@ -5869,6 +7847,57 @@ func (fn *formulaFuncs) SUMIFS(argsList *list.List) formulaArg {
 	return newNumberFormulaArg(sum)
 }
 
+// sumproductBroadcast calculates the sum of the products of the single-column
+// and single-row arrays with different lengths, the column arrays will be
+// broadcast against the row arrays to form a matrix. The second result
+// indicates whether the arrays can be broadcast.
+func sumproductBroadcast(argsList *list.List) (formulaArg, bool) {
+	var cols, rows []float64
+	for arg := argsList.Front(); arg != nil; arg = arg.Next() {
+		token := arg.Value.(formulaArg)
+		if token.Type != ArgMatrix || len(token.Matrix) == 0 {
+			return newEmptyFormulaArg(), false
+		}
+		vector := &rows
+		if len(token.Matrix[0]) == 1 && len(token.Matrix) > 1 {
+			vector = &cols
+		} else if len(token.Matrix) != 1 || len(token.Matrix[0]) < 2 {
+			return newEmptyFormulaArg(), false
+		}
+		args := token.ToList()
+		if errArg := firstErrorArg(args); errArg.Type == ArgError {
+			return errArg, true
+		}
+		if *vector == nil {
+			*vector = make([]float64, len(args))
+			for i := range *vector {
+				(*vector)[i] = 1
+			}
+		}
+		if len(args) != len(*vector) {
+			return newEmptyFormulaArg(), false
+		}
+		for i, value := range args {
+			num := value.ToNumber()
+			if num.Type != ArgNumber && value.Value() != "" {
+				return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE), true
+			}
+			(*vector)[i] *= num.Number
+		}
+	}
+	if cols == nil || rows == nil || len(cols) == len(rows) {
+		return newEmptyFormulaArg(), false
+	}
+	var colSum, rowSum float64
+	for _, num := range cols {
+		colSum += num
+	}
+	for _, num := range rows {
+		rowSum += num
+	}
+	return newNumberFormulaArg(colSum * rowSum), true
+}
+
 // sumproduct is an implementation of the formula function SUMPRODUCT.
 func (fn *formulaFuncs) sumproduct(argsList *list.List) formulaArg {
 	var (
@@ -5877,6 +7906,9 @@ func (fn *formulaFuncs) sumproduct(argsList *list.List) formulaArg {
 		res     []float64
 		sum     float64
 	)
+	if result, ok := sumproductBroadcast(argsList); ok {
+		return result
+	}
 	for arg := argsList.Front(); arg != nil; arg = arg.Next() {
 		token := arg.Value.(formulaArg)
 		if argType == ArgUnknown {
@@ -5894,6 +7926,9 @@ func (fn *formulaFuncs) sumproduct(argsList *list.List) formulaArg {
 			return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 		case ArgMatrix:
 			args := token.ToList()
+			if errArg := firstErrorArg(args); errArg.Type == ArgError {
+				return errArg
+			}
 			if res == nil {
 				n = len(args)
 				res = make([]float64, n)
@@ -5920,12 +7955,14 @@ func (fn *formulaFuncs) sumproduct(argsList *list.List) formulaArg {
 }
 
 // SUMPRODUCT function returns the sum of the products of the corresponding
-// values in a set of supplied arrays. The syntax of the function is:
+// values in a set of supplied arrays. The single-column arrays will be
+// broadcast against the single-row arrays when they have different lengths.
+// The syntax of the function is:
 //
 //	SUMPRODUCT(array1,[array2],[array3],...)
 func (fn *formulaFuncs) SUMPRODUCT(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "SUMPRODUCT requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("SUMPRODUCT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	for arg := argsList.Front(); arg != nil; arg = arg.Next() {
 		if token := arg.Value.(formulaArg); token.Type == ArgError {
@@ -5955,9 +7992,14 @@ func (fn *formulaFuncs) SUMSQ(argsList *list.List) formulaArg {
 			sq += val * val
 		case ArgNumber:
 			sq += token.Number * token.Number
+		case ArgError:
+			return token
 		case ArgMatrix:
 			for _, row := range token.Matrix {
 				for _, value := range row {
+					if value.Type == ArgError {
+						return value
+					}
 					if value.Value() == "" {
 						continue
 					}
@@ -5981,6 +8023,11 @@ func (fn *formulaFuncs) sumx(name string, argsList *list.List) formulaArg {
 	array1 := argsList.Front().Value.(formulaArg)
 	array2 := argsList.Back().Value.(formulaArg)
 	left, right := array1.ToList(), array2.ToList()
+	for _, args := range [][]formulaArg{left, right} {
+		if errArg := firstErrorArg(args); errArg.Type == ArgError {
+			return errArg
+		}
+	}
 	n := len(left)
 	if n != len(right) {
 		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
@@ -6057,15 +8104,15 @@ func (fn *formulaFuncs) TANH(argsList *list.List) formulaArg {
 }
 
 // TRUNC function truncates a supplied number to a specified number of decimal
-// places. The syntax of the function is:
+// places. The negative number of digits truncates the number to the left of
+// the decimal point. The syntax of the function is:
 //
 //	TRUNC(number,[number_digits])
 func (fn *formulaFuncs) TRUNC(argsList *list.List) formulaArg {
-	if argsList.Len() == 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TRUNC requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("TRUNC", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
-	var digits, adjust, rtrim float64
-	var err error
+	var digits float64
 	number := argsList.Front().Value.(formulaArg).ToNumber()
 	if number.Type == ArgError {
 		return number
@@ -6076,19 +8123,8 @@ func (fn *formulaFuncs) TRUNC(argsList *list.List) formulaArg {
 			return d
 		}
 		digits = d.Number
-		digits = math.Floor(digits)
-	}
-	adjust = math.Pow(10, digits)
-	x := int((math.Abs(number.Number) - math.Abs(float64(int(number.Number)))) * adjust)
-	if x != 0 {
-		if rtrim, err = strconv.ParseFloat(strings.TrimRight(strconv.Itoa(x), "0"), 64); err != nil {
-			return newErrorFormulaArg(formulaErrorVALUE, err.Error())
-		}
 	}
-	if (digits > 0) && (rtrim < adjust/10) {
-		return newNumberFormulaArg(number.Number)
-	}
-	return newNumberFormulaArg(float64(int(number.Number*adjust)) / adjust)
+	return newNumberFormulaArg(fn.round(number.Number, digits, down))
 }
 
 // Statistical Functions
@@ -6098,8 +8134,8 @@ func (fn *formulaFuncs) TRUNC(argsList *list.List) formulaArg {
 //
 //	AVEDEV(number1,[number2],...)
 func (fn *formulaFuncs) AVEDEV(argsList *list.List) formulaArg {
-	if argsList.Len() == 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "AVEDEV requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("AVEDEV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	average := fn.AVERAGE(argsList)
 	if average.Type != ArgNumber {
@@ -6151,13 +8187,14 @@ func (fn *formulaFuncs) AVERAGEA(argsList *list.List) formulaArg {
 
 // AVERAGEIF function finds the values in a supplied array that satisfy a
 // specified criteria, and returns the average (i.e. the statistical mean) of
-// the corresponding values in a second supplied array. The syntax of the
-// function is:
+// the corresponding values in a second supplied array. The average_range
+// begins with its top-left cell and has the same size as the range, as in
+// Excel. The syntax of the function is:
 //
 //	AVERAGEIF(range,criteria,[average_range])
 func (fn *formulaFuncs) AVERAGEIF(argsList *list.List) formulaArg {
-	if argsList.Len() < 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "AVERAGEIF requires at least 2 arguments")
+	if argsCount := checkFormulaArgsCount("AVERAGEIF", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var (
 		criteria  = formulaCriteriaParser(argsList.Front().Next().Value.(formulaArg))
@@ -6169,7 +8206,7 @@ func (fn *formulaFuncs) AVERAGEIF(argsList *list.List) formulaArg {
 		ok        bool
 	)
 	if argsList.Len() == 3 {
-		cellRange = argsList.Back().Value.(formulaArg).Matrix
+		cellRange = fn.prepareSumRange(rangeMtx, argsList.Back().Value.(formulaArg))
 	}
 	for rowIdx, row := range rangeMtx {
 		for colIdx, col := range row {
@@ -6183,6 +8220,7 @@ func (fn *formulaFuncs) AVERAGEIF(argsList *list.List) formulaArg {
 			ok, _ = formulaCriteriaEval(col, criteria)
 			if ok {
 				if argsList.Len() == 3 {
+					fromVal = ""
 					if len(cellRange) > rowIdx && len(cellRange[rowIdx]) > colIdx {
 						fromVal = cellRange[rowIdx][colIdx].Value()
 					}
@@ -6208,8 +8246,8 @@ func (fn *formulaFuncs) AVERAGEIF(argsList *list.List) formulaArg {
 //
 //	AVERAGEIFS(average_range,criteria_range1,criteria1,[criteria_range2,criteria2],...)
 func (fn *formulaFuncs) AVERAGEIFS(argsList *list.List) formulaArg {
-	if argsList.Len() < 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "AVERAGEIFS requires at least 3 arguments")
+	if argsCount := checkFormulaArgsCount("AVERAGEIFS", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	if argsList.Len()%2 != 1 {
 		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
@@ -6947,8 +8985,8 @@ func binomdist(x, n, p float64) float64 {
 //
 //	BINOM.DIST(number_s,trials,probability_s,cumulative)
 func (fn *formulaFuncs) BINOMdotDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "BINOM.DIST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("BINOM.DIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.BINOMDIST(argsList)
 }
@@ -6959,8 +8997,8 @@ func (fn *formulaFuncs) BINOMdotDIST(argsList *list.List) formulaArg {
 //
 //	BINOMDIST(number_s,trials,probability_s,cumulative)
 func (fn *formulaFuncs) BINOMDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "BINOMDIST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("BINOMDIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var s, trials, probability, cumulative formulaArg
 	if s = argsList.Front().Value.(formulaArg).ToNumber(); s.Type != ArgNumber {
@@ -7187,8 +9225,8 @@ func (fn *formulaFuncs) CHIINV(argsList *list.List) formulaArg {
 //
 //	CHITEST(actual_range,expected_range)
 func (fn *formulaFuncs) CHITEST(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "CHITEST requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("CHITEST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	actual, expected := argsList.Front().Value.(formulaArg), argsList.Back().Value.(formulaArg)
 	actualList, expectedList := actual.ToList(), expected.ToList()
@@ -7332,6 +9370,21 @@ func getLogGamma(fZ float64) float64 {
 	return getLogGammaHelper(fZ+2) - math.Log(fZ+1) - math.Log(fZ)
 }
 
+// getGamma calculates the gamma function, the negative arguments less than
+// -0.5 are calculated by the reflection formula.
+func getGamma(fZ float64) float64 {
+	if fZ >= 1.0 {
+		return getGammaHelper(fZ)
+	}
+	if fZ >= 0.5 {
+		return getGammaHelper(fZ+1) / fZ
+	}
+	if fZ >= -0.5 {
+		return getGammaHelper(fZ+2) / (fZ + 1) / fZ
+	}
+	return math.Pi / (math.Sin(math.Pi*fZ) * getGamma(1-fZ))
+}
+
 // getLowRegIGamma returns lower regularized incomplete gamma function.
 func getLowRegIGamma(fA, fX float64) float64 {
 	lnFactor := fA*math.Log(fX) - fX - getLogGamma(fA)
@@ -7382,8 +9435,8 @@ func getChiSqDistPDF(fX, fDF float64) float64 {
 //
 //	CHISQ.DIST(x,degrees_freedom,cumulative)
 func (fn *formulaFuncs) CHISQdotDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "CHISQ.DIST requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("CHISQ.DIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, degrees, cumulative formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -7426,8 +9479,8 @@ func (fn *formulaFuncs) CHISQdotDISTdotRT(argsList *list.List) formulaArg {
 //
 //	CHISQ.TEST(actual_range,expected_range)
 func (fn *formulaFuncs) CHISQdotTEST(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "CHISQ.TEST requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("CHISQ.TEST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.CHITEST(argsList)
 }
@@ -7623,8 +9676,8 @@ func (fn *formulaFuncs) CONFIDENCEdotNORM(argsList *list.List) formulaArg {
 //
 //	CONFIDENCE.T(alpha,standard_dev,size)
 func (fn *formulaFuncs) CONFIDENCEdotT(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "CONFIDENCE.T requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("CONFIDENCE.T", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var alpha, standardDev, size formulaArg
 	if alpha = argsList.Front().Value.(formulaArg).ToNumber(); alpha.Type != ArgNumber {
@@ -7719,12 +9772,14 @@ func calcStringCountSum(countText bool, count, sum float64, num, arg formulaArg)
 	return count, sum
 }
 
-// countSum get count and sum for a formula arguments array.
+// countSum get count and sum for a formula arguments array. The logical
+// values typed directly as arguments will be counted, but the logical values
+// in the references, ranges or arrays are ignored unless count the text.
 func (fn *formulaFuncs) countSum(countText bool, args []formulaArg) (count, sum float64) {
 	for _, arg := range args {
 		switch arg.Type {
 		case ArgNumber:
-			if countText || !arg.Boolean {
+			if countText || !arg.Boolean || !isLogicalReference(arg) {
 				sum += arg.Number
 				count++
 			}
@@ -7742,7 +9797,13 @@ func (fn *formulaFuncs) countSum(countText bool, args []formulaArg) (count, sum
 			num := arg.ToNumber()
 			count, sum = calcStringCountSum(countText, count, sum, num, arg)
 		case ArgList, ArgMatrix:
-			cnt, summary := fn.countSum(countText, arg.ToList())
+			var values []formulaArg
+			for _, value := range arg.ToList() {
+				if countText || value.Type != ArgNumber || !value.Boolean {
+					values = append(values, value)
+				}
+			}
+			cnt, summary := fn.countSum(countText, values)
 			sum += summary
 			count += cnt
 		}
@@ -7755,8 +9816,8 @@ func (fn *formulaFuncs) countSum(countText bool, args []formulaArg) (count, sum
 //
 //	CORREL(array1,array2)
 func (fn *formulaFuncs) CORREL(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "CORREL requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("CORREL", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	array1 := argsList.Front().Value.(formulaArg)
 	array2 := argsList.Back().Value.(formulaArg)
@@ -7853,8 +9914,8 @@ func (fn *formulaFuncs) COUNTA(argsList *list.List) formulaArg {
 //
 //	COUNTBLANK(range)
 func (fn *formulaFuncs) COUNTBLANK(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "COUNTBLANK requires 1 argument")
+	if argsCount := checkFormulaArgsCount("COUNTBLANK", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var count float64
 	for _, cell := range argsList.Front().Value.(formulaArg).ToList() {
@@ -7870,8 +9931,8 @@ func (fn *formulaFuncs) COUNTBLANK(argsList *list.List) formulaArg {
 //
 //	COUNTIF(range,criteria)
 func (fn *formulaFuncs) COUNTIF(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "COUNTIF requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("COUNTIF", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var (
 		criteria = formulaCriteriaParser(argsList.Front().Next().Value.(formulaArg))
@@ -7904,6 +9965,9 @@ func formulaIfsMatch(args []formulaArg) (cellRefs []cellRef) {
 		} else {
 			match = []cellRef{}
 			for _, ref := range cellRefs {
+				if ref.Row >= len(matrix) || ref.Col >= len(matrix[ref.Row]) {
+					continue
+				}
 				value := matrix[ref.Row][ref.Col]
 				if ok, _ := formulaCriteriaEval(value, criteria); ok {
 					match = append(match, ref)
@@ -7920,8 +9984,8 @@ func formulaIfsMatch(args []formulaArg) (cellRefs []cellRef) {
 //
 //	COUNTIFS(criteria_range1,criteria1,[criteria_range2,criteria2],...)
 func (fn *formulaFuncs) COUNTIFS(argsList *list.List) formulaArg {
-	if argsList.Len() < 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "COUNTIFS requires at least 2 arguments")
+	if argsCount := checkFormulaArgsCount("COUNTIFS", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	if argsList.Len()%2 != 0 {
 		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
@@ -8039,9 +10103,9 @@ func (fn *formulaFuncs) FORECASTdotLINEAR(argsList *list.List) formulaArg {
 	return fn.pearsonProduct("FORECAST.LINEAR", 3, argsList)
 }
 
-// maritxToSortedColumnList convert matrix formula arguments to a ascending
-// order list by column.
-func maritxToSortedColumnList(arg formulaArg) formulaArg {
+// maritxToColumnList convert the numbers in matrix formula arguments to a
+// list by column.
+func maritxToColumnList(arg formulaArg) formulaArg {
 	mtx, cols := []formulaArg{}, len(arg.Matrix[0])
 	for colIdx := 0; colIdx < cols; colIdx++ {
 		for _, row := range arg.Matrix {
@@ -8054,7 +10118,16 @@ func maritxToSortedColumnList(arg formulaArg) formulaArg {
 			}
 		}
 	}
-	argsList := newListFormulaArg(mtx)
+	return newListFormulaArg(mtx)
+}
+
+// maritxToSortedColumnList convert matrix formula arguments to a ascending
+// order list by column.
+func maritxToSortedColumnList(arg formulaArg) formulaArg {
+	argsList := maritxToColumnList(arg)
+	if argsList.Type != ArgList {
+		return argsList
+	}
 	sort.Slice(argsList.List, func(i, j int) bool {
 		return argsList.List[i].Number < argsList.List[j].Number
 	})
@@ -8062,12 +10135,15 @@ func maritxToSortedColumnList(arg formulaArg) formulaArg {
 }
 
 // FREQUENCY function to count how many children fall into different age
-// ranges. The syntax of the function is:
+// ranges. The function returns a vertical array with one more element than
+// the bins, each element counts the values less than or equal to the bin and
+// greater than the previous bin, the last element counts the values greater
+// than the highest bin. The syntax of the function is:
 //
 //	FREQUENCY(data_array,bins_array)
 func (fn *formulaFuncs) FREQUENCY(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "FREQUENCY requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("FREQUENCY", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	data, bins := argsList.Front().Value.(formulaArg), argsList.Back().Value.(formulaArg)
 	if len(data.Matrix) == 0 {
@@ -8079,22 +10155,27 @@ func (fn *formulaFuncs) FREQUENCY(argsList *list.List) formulaArg {
 	var (
 		dataMtx, binsMtx formulaArg
 		c                [][]formulaArg
-		i, j             int
+		i                int
 	)
 	if dataMtx = maritxToSortedColumnList(data); dataMtx.Type != ArgList {
 		return dataMtx
 	}
-	if binsMtx = maritxToSortedColumnList(bins); binsMtx.Type != ArgList {
+	if binsMtx = maritxToColumnList(bins); binsMtx.Type != ArgList {
 		return binsMtx
 	}
 	for row := 0; row < len(binsMtx.List)+1; row++ {
-		rows := []formulaArg{}
-		for col := 0; col < 1; col++ {
-			rows = append(rows, newNumberFormulaArg(0))
-		}
-		c = append(c, rows)
+		c = append(c, []formulaArg{newNumberFormulaArg(0)})
+	}
+	// count by the ascending bins and place the counts in the original order
+	// of the bins, the duplicate bins after the first one count nothing
+	order := make([]int, len(binsMtx.List))
+	for j := range order {
+		order[j] = j
 	}
-	for j = 0; j < len(binsMtx.List); j++ {
+	sort.SliceStable(order, func(a, b int) bool {
+		return binsMtx.List[order[a]].Number < binsMtx.List[order[b]].Number
+	})
+	for _, j := range order {
 		n := 0.0
 		for i < len(dataMtx.List) && dataMtx.List[i].Number <= binsMtx.List[j].Number {
 			n++
@@ -8102,10 +10183,7 @@ func (fn *formulaFuncs) FREQUENCY(argsList *list.List) formulaArg {
 		}
 		c[j] = []formulaArg{newNumberFormulaArg(n)}
 	}
-	c[j] = []formulaArg{newNumberFormulaArg(float64(len(dataMtx.List) - i))}
-	if len(c) > 2 {
-		c[1], c[2] = c[2], c[1]
-	}
+	c[len(binsMtx.List)] = []formulaArg{newNumberFormulaArg(float64(len(dataMtx.List) - i))}
 	return newMatrixFormulaArg(c)
 }
 
@@ -8121,10 +10199,15 @@ func (fn *formulaFuncs) GAMMA(argsList *list.List) formulaArg {
 	if number.Type != ArgNumber {
 		return newErrorFormulaArg(formulaErrorVALUE, "GAMMA requires 1 numeric argument")
 	}
-	if number.Number <= 0 {
-		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
+	// the gamma function has poles at zero and the negative integers
+	if number.Number <= 0 && number.Number == math.Trunc(number.Number) {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	}
+	result := getGamma(number.Number)
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	return newNumberFormulaArg(math.Gamma(number.Number))
+	return newNumberFormulaArg(result)
 }
 
 // GAMMAdotDIST function returns the Gamma Distribution, which is frequently
@@ -8133,8 +10216,8 @@ func (fn *formulaFuncs) GAMMA(argsList *list.List) formulaArg {
 //
 //	GAMMA.DIST(x,alpha,beta,cumulative)
 func (fn *formulaFuncs) GAMMAdotDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "GAMMA.DIST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("GAMMA.DIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.GAMMADIST(argsList)
 }
@@ -8145,8 +10228,8 @@ func (fn *formulaFuncs) GAMMAdotDIST(argsList *list.List) formulaArg {
 //
 //	GAMMADIST(x,alpha,beta,cumulative)
 func (fn *formulaFuncs) GAMMADIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "GAMMADIST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("GAMMADIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, alpha, beta, cumulative formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -8207,8 +10290,8 @@ func gammainv(probability, alpha, beta float64) float64 {
 //
 //	GAMMA.INV(probability,alpha,beta)
 func (fn *formulaFuncs) GAMMAdotINV(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "GAMMA.INV requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("GAMMA.INV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.GAMMAINV(argsList)
 }
@@ -8218,8 +10301,8 @@ func (fn *formulaFuncs) GAMMAdotINV(argsList *list.List) formulaArg {
 //
 //	GAMMAINV(probability,alpha,beta)
 func (fn *formulaFuncs) GAMMAINV(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "GAMMAINV requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("GAMMAINV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var probability, alpha, beta formulaArg
 	if probability = argsList.Front().Value.(formulaArg).ToNumber(); probability.Type != ArgNumber {
@@ -8253,9 +10336,9 @@ func (fn *formulaFuncs) GAMMALN(argsList *list.List) formulaArg {
 		return newErrorFormulaArg(formulaErrorVALUE, "GAMMALN requires 1 numeric argument")
 	}
 	if x.Number <= 0 {
-		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	return newNumberFormulaArg(math.Log(math.Gamma(x.Number)))
+	return newNumberFormulaArg(getLogGamma(x.Number))
 }
 
 // GAMMALNdotPRECISE function returns the natural logarithm of the Gamma
@@ -8297,24 +10380,86 @@ func (fn *formulaFuncs) GAUSS(argsList *list.List) formulaArg {
 	return newNumberFormulaArg(normdist.Number - 0.5)
 }
 
+// prepareMeanArgs collects the numbers of the arguments for the formula
+// functions GEOMEAN and HARMEAN. The text and logical values in the references
+// will be ignored, and the text typed directly as argument which can't be
+// converted to a number will be ignored if skipText is true, or returned as
+// error otherwise. The #NUM! error will be returned if any of the numbers is
+// not positive, or there are no numbers.
+func prepareMeanArgs(argsList *list.List, skipText bool) ([]float64, formulaArg) {
+	var numbers []float64
+	for token := argsList.Front(); token != nil; token = token.Next() {
+		arg := token.Value.(formulaArg)
+		switch arg.Type {
+		case ArgError:
+			return nil, arg
+		case ArgString:
+			if arg.cellRefs != nil && arg.cellRefs.Len() > 0 {
+				continue
+			}
+			num := arg.ToNumber()
+			if num.Type != ArgNumber {
+				if skipText {
+					continue
+				}
+				return nil, num
+			}
+			numbers = append(numbers, num.Number)
+		case ArgNumber:
+			if !isLogicalReference(arg) {
+				numbers = append(numbers, arg.Number)
+			}
+		case ArgList, ArgMatrix:
+			for _, cell := range arg.ToList() {
+				if cell.Type == ArgError {
+					return nil, cell
+				}
+				if cell.Type == ArgNumber && !cell.Boolean {
+					numbers = append(numbers, cell.Number)
+				}
+			}
+		}
+	}
+	if len(numbers) == 0 {
+		return nil, newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	}
+	for _, number := range numbers {
+		if number <= 0 {
+			return nil, newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+		}
+	}
+	return numbers, newEmptyFormulaArg()
+}
+
 // GEOMEAN function calculates the geometric mean of a supplied set of values.
-// The syntax of the function is:
+// All of the values must be positive, otherwise the function returns #NUM!
+// error. The syntax of the function is:
 //
 //	GEOMEAN(number1,[number2],...)
 func (fn *formulaFuncs) GEOMEAN(argsList *list.List) formulaArg {
 	if argsList.Len() < 1 {
 		return newErrorFormulaArg(formulaErrorVALUE, "GEOMEAN requires at least 1 numeric argument")
 	}
-	product := fn.PRODUCT(argsList)
-	if product.Type != ArgNumber {
-		return product
+	numbers, errArg := prepareMeanArgs(argsList, false)
+	if errArg.Type == ArgError {
+		return errArg
+	}
+	n, product := float64(len(numbers)), 1.0
+	for _, number := range numbers {
+		product *= number
 	}
-	count := fn.COUNT(argsList)
-	min := fn.MIN(argsList)
-	if product.Number > 0 && min.Number > 0 {
-		return newNumberFormulaArg(math.Pow(product.Number, 1/count.Number))
+	if !math.IsInf(product, 0) && product != 0 {
+		return newNumberFormulaArg(math.Pow(product, 1/n))
 	}
-	return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	// calculate with the separated mantissa and exponent of the product to
+	// avoid overflow and underflow
+	mantissa, exp := 1.0, 0
+	for _, number := range numbers {
+		m, e := math.Frexp(number)
+		m, e2 := math.Frexp(mantissa * m)
+		mantissa, exp = m, exp+e+e2
+	}
+	return newNumberFormulaArg(math.Pow(mantissa, 1/n) * math.Pow(2, float64(exp)/n))
 }
 
 // getNewMatrix create matrix by given columns and rows.
@@ -8339,7 +10484,24 @@ func approxSub(a, b float64) float64 {
 	if ((a < 0 && b < 0) || (a > 0 && b > 0)) && math.Abs(a-b) < 2.22045e-016 {
 		return 0
 	}
-	return a - b
+	return a - b
+}
+
+// matrixTranspose returns the transpose of the given matrix, the element in
+// row i and column j is placed in row j and column i.
+func matrixTranspose(matrix [][]float64) (transposed [][]float64) {
+	for i := 0; i < len(matrix); i++ {
+		for j := 0; j < len(matrix[i]); j++ {
+			for x := len(transposed); x <= j; x++ {
+				transposed = append(transposed, []float64{})
+			}
+			for k := len(transposed[j]); k <= i; k++ {
+				transposed[j] = append(transposed[j], 0)
+			}
+			transposed[j][i] = matrix[i][j]
+		}
+	}
+	return
 }
 
 // matrixClone return a copy of all elements of the original matrix.
@@ -8364,61 +10526,18 @@ type trendGrowthMatrixInfo struct {
 	mtxX, mtxY                          [][]float64
 }
 
-// prepareTrendGrowthMtxX is a part of implementation of the trend growth prepare.
-func prepareTrendGrowthMtxX(mtxX [][]float64) [][]float64 {
-	var mtx [][]float64
-	for i := 0; i < len(mtxX); i++ {
-		for j := 0; j < len(mtxX[i]); j++ {
-			if mtxX[i][j] == 0 {
-				return nil
-			}
-			for x := len(mtx); x <= j; x++ {
-				mtx = append(mtx, []float64{})
-			}
-			for y := len(mtx[j]); y <= i; y++ {
-				mtx[j] = append(mtx[j], 0)
-			}
-			mtx[j][i] = mtxX[i][j]
-		}
-	}
-	return mtx
-}
-
 // prepareTrendGrowthMtxY is a part of implementation of the trend growth prepare.
 func prepareTrendGrowthMtxY(bLOG bool, mtxY [][]float64) [][]float64 {
-	var mtx [][]float64
-	for i := 0; i < len(mtxY); i++ {
-		for j := 0; j < len(mtxY[i]); j++ {
-			if mtxY[i][j] == 0 {
-				return nil
-			}
-			for x := len(mtx); x <= j; x++ {
-				mtx = append(mtx, []float64{})
-			}
-			for y := len(mtx[j]); y <= i; y++ {
-				mtx[j] = append(mtx[j], 0)
-			}
-			mtx[j][i] = mtxY[i][j]
-		}
-	}
+	mtx := matrixTranspose(mtxY)
 	if bLOG {
-		var pNewY [][]float64
-		for i := 0; i < len(mtxY); i++ {
-			for j := 0; j < len(mtxY[i]); j++ {
-				fVal := mtxY[i][j]
-				if fVal <= 0 {
+		for i := 0; i < len(mtx); i++ {
+			for j := 0; j < len(mtx[i]); j++ {
+				if mtx[i][j] <= 0 {
 					return nil
 				}
-				for x := len(pNewY); x <= j; x++ {
-					pNewY = append(pNewY, []float64{})
-				}
-				for y := len(pNewY[j]); y <= i; y++ {
-					pNewY[j] = append(pNewY[j], 0)
-				}
-				pNewY[j][i] = math.Log(fVal)
+				mtx[i][j] = math.Log(mtx[i][j])
 			}
 		}
-		mtx = pNewY
 	}
 	return mtx
 }
@@ -8435,7 +10554,7 @@ func prepareTrendGrowth(bLOG bool, mtxX, mtxY [][]float64) (*trendGrowthMatrixIn
 	var newX [][]float64
 	if len(mtxX) != 0 {
 		nRX, nCX = len(mtxX), len(mtxX[0])
-		if newX = prepareTrendGrowthMtxX(mtxX); newX == nil {
+		if newX = matrixTranspose(mtxX); newX == nil {
 			return nil, newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 		}
 		if nCX == nCY && nRX == nRY {
@@ -8969,7 +11088,12 @@ func (fn *formulaFuncs) trendGrowth(name string, argsList *list.List) formulaArg
 		}
 	}
 	if argsList.Len() > 2 {
-		newX, errArg = newNumberMatrix(argsList.Front().Next().Next().Value.(formulaArg), false)
+		arg := argsList.Front().Next().Next().Value.(formulaArg)
+		if arg.Type != ArgMatrix {
+			// a single new_x value supplied as a number or a one cell reference
+			arg = newMatrixFormulaArg([][]formulaArg{{arg.ToNumber()}})
+		}
+		newX, errArg = newNumberMatrix(arg, false)
 		if errArg.Type == ArgError {
 			return errArg
 		}
@@ -8979,23 +11103,13 @@ func (fn *formulaFuncs) trendGrowth(name string, argsList *list.List) formulaArg
 			return constArg
 		}
 	}
-	var mtxNewX [][]float64
-	for i := 0; i < len(newX); i++ {
-		for j := 0; j < len(newX[i]); j++ {
-			for x := len(mtxNewX); x <= j; x++ {
-				mtxNewX = append(mtxNewX, []float64{})
-			}
-			for k := len(mtxNewX[j]); k <= i; k++ {
-				mtxNewX[j] = append(mtxNewX[j], 0)
-			}
-			mtxNewX[j][i] = newX[i][j]
-		}
-	}
-	mtx, errArg := calcTrendGrowth(knowY, knowX, mtxNewX, constArg.Number == 1, name == "GROWTH")
+	mtx, errArg := calcTrendGrowth(knowY, knowX, matrixTranspose(newX), constArg.Number == 1, name == "GROWTH")
 	if errArg.Type != ArgEmpty {
 		return errArg
 	}
-	return newMatrixFormulaArg(newFormulaArgMatrix(mtx))
+	// the regression works on the column-major matrix, transpose the result
+	// back to the layout of the new_x's
+	return newMatrixFormulaArg(newFormulaArgMatrix(matrixTranspose(mtx)))
 }
 
 // GROWTH function calculates the exponential growth curve through a given set
@@ -9009,36 +11123,23 @@ func (fn *formulaFuncs) GROWTH(argsList *list.List) formulaArg {
 }
 
 // HARMEAN function calculates the harmonic mean of a supplied set of values.
-// The syntax of the function is:
+// All of the values must be positive, otherwise the function returns #NUM!
+// error. The syntax of the function is:
 //
 //	HARMEAN(number1,[number2],...)
 func (fn *formulaFuncs) HARMEAN(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "HARMEAN requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("HARMEAN", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
-	if min := fn.MIN(argsList); min.Number < 0 {
-		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
+	numbers, errArg := prepareMeanArgs(argsList, true)
+	if errArg.Type == ArgError {
+		return errArg
 	}
-	number, val, cnt := 0.0, 0.0, 0.0
-	for token := argsList.Front(); token != nil; token = token.Next() {
-		arg := token.Value.(formulaArg)
-		switch arg.Type {
-		case ArgString:
-			num := arg.ToNumber()
-			if num.Type != ArgNumber {
-				continue
-			}
-			number = num.Number
-		case ArgNumber:
-			number = arg.Number
-		}
-		if number <= 0 {
-			return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
-		}
+	var val float64
+	for _, number := range numbers {
 		val += 1 / number
-		cnt++
 	}
-	return newNumberFormulaArg(1 / (val / cnt))
+	return newNumberFormulaArg(float64(len(numbers)) / val)
 }
 
 // checkHYPGEOMDISTArgs checking arguments for the formula function HYPGEOMDIST
@@ -9076,6 +11177,10 @@ func (fn *formulaFuncs) prepareHYPGEOMDISTArgs(name string, argsList *list.List)
 	if numberPop = argsList.Front().Next().Next().Next().Value.(formulaArg).ToNumber(); numberPop.Type != ArgNumber {
 		return numberPop
 	}
+	// all numeric arguments are truncated to integers
+	for _, arg := range []*formulaArg{&sampleS, &numberSample, &populationS, &numberPop} {
+		arg.Number = math.Trunc(arg.Number)
+	}
 	if checkHYPGEOMDISTArgs(sampleS, numberSample, populationS, numberPop) {
 		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
@@ -9100,8 +11205,11 @@ func (fn *formulaFuncs) HYPGEOMdotDIST(argsList *list.List) formulaArg {
 	}
 	sampleS, numberSample, populationS, numberPop, cumulative := args.List[0], args.List[1], args.List[2], args.List[3], args.List[4]
 	if cumulative.Number == 1 {
+		// the number of successes in the sample can't be less than the number
+		// of the sample exceeding the failures in the population
 		var res float64
-		for i := 0; i <= int(sampleS.Number); i++ {
+		lower := int(math.Max(0, numberSample.Number-numberPop.Number+populationS.Number))
+		for i := lower; i <= int(sampleS.Number); i++ {
 			res += binomCoeff(populationS.Number, float64(i)) *
 				binomCoeff(numberPop.Number-populationS.Number, numberSample.Number-float64(i)) /
 				binomCoeff(numberPop.Number, numberSample.Number)
@@ -9143,8 +11251,8 @@ func (fn *formulaFuncs) INTERCEPT(argsList *list.List) formulaArg {
 //
 //	KURT(number1,[number2],...)
 func (fn *formulaFuncs) KURT(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "KURT requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("KURT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	mean, stdev := fn.AVERAGE(argsList), fn.STDEV(argsList)
 	if stdev.Number > 0 {
@@ -9186,8 +11294,8 @@ func (fn *formulaFuncs) KURT(argsList *list.List) formulaArg {
 //
 //	EXPON.DIST(x,lambda,cumulative)
 func (fn *formulaFuncs) EXPONdotDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "EXPON.DIST requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("EXPON.DIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.EXPONDIST(argsList)
 }
@@ -9199,8 +11307,8 @@ func (fn *formulaFuncs) EXPONdotDIST(argsList *list.List) formulaArg {
 //
 //	EXPONDIST(x,lambda,cumulative)
 func (fn *formulaFuncs) EXPONDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "EXPONDIST requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("EXPONDIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, lambda, cumulative formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -9231,8 +11339,8 @@ func (fn *formulaFuncs) EXPONDIST(argsList *list.List) formulaArg {
 //
 //	F.DIST(x,deg_freedom1,deg_freedom2,cumulative)
 func (fn *formulaFuncs) FdotDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "F.DIST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("F.DIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, deg1, deg2, cumulative formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -9269,8 +11377,8 @@ func (fn *formulaFuncs) FdotDIST(argsList *list.List) formulaArg {
 //
 //	FDIST(x,deg_freedom1,deg_freedom2)
 func (fn *formulaFuncs) FDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "FDIST requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("FDIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, deg1, deg2 formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -9307,8 +11415,8 @@ func (fn *formulaFuncs) FDIST(argsList *list.List) formulaArg {
 //
 //	F.DIST.RT(x,deg_freedom1,deg_freedom2)
 func (fn *formulaFuncs) FdotDISTdotRT(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "F.DIST.RT requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("F.DIST.RT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.FDIST(argsList)
 }
@@ -9388,8 +11496,8 @@ func (fn *formulaFuncs) FINV(argsList *list.List) formulaArg {
 //
 //	F.TEST(array1,array2)
 func (fn *formulaFuncs) FdotTEST(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "F.TEST requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("F.TEST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	array1 := argsList.Front().Value.(formulaArg)
 	array2 := argsList.Back().Value.(formulaArg)
@@ -9444,20 +11552,278 @@ func (fn *formulaFuncs) FdotTEST(argsList *list.List) formulaArg {
 //
 //	FTEST(array1,array2)
 func (fn *formulaFuncs) FTEST(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "FTEST requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("FTEST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.FdotTEST(argsList)
 }
 
+// prepareLinestObservations returns the observations of the known x's and
+// the known y's for the formula functions LINEST and LOGEST, each row of the
+// returned known x's is the independent variables of an observation.
+func prepareLinestObservations(knownY, knownX [][]float64) ([][]float64, []float64, formulaArg) {
+	rowsY, colsY := len(knownY), len(knownY[0])
+	var (
+		obsX [][]float64
+		obsY []float64
+	)
+	if knownX == nil {
+		for r := 0; r < rowsY; r++ {
+			for c := 0; c < colsY; c++ {
+				obsX = append(obsX, []float64{float64(len(obsY) + 1)})
+				obsY = append(obsY, knownY[r][c])
+			}
+		}
+		return obsX, obsY, newEmptyFormulaArg()
+	}
+	rowsX, colsX := len(knownX), len(knownX[0])
+	switch {
+	case colsY == 1 && rowsX == rowsY:
+		for r := 0; r < rowsY; r++ {
+			obsX = append(obsX, knownX[r])
+			obsY = append(obsY, knownY[r][0])
+		}
+	case rowsY == 1 && colsX == colsY:
+		for c := 0; c < colsY; c++ {
+			var row []float64
+			for r := 0; r < rowsX; r++ {
+				row = append(row, knownX[r][c])
+			}
+			obsX = append(obsX, row)
+			obsY = append(obsY, knownY[0][c])
+		}
+	case rowsX == rowsY && colsX == colsY:
+		for r := 0; r < rowsY; r++ {
+			for c := 0; c < colsY; c++ {
+				obsX = append(obsX, []float64{knownX[r][c]})
+				obsY = append(obsY, knownY[r][c])
+			}
+		}
+	default:
+		return nil, nil, newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
+	}
+	for _, row := range obsX {
+		if len(row) != len(obsX[0]) {
+			return nil, nil, newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
+		}
+	}
+	return obsX, obsY, newEmptyFormulaArg()
+}
+
+// calcSolveWithLowerLeftTriangle solve for X in R'*X=S using forward
+// substitution, the R is the upper right triangle of the QR decomposition as
+// obtained from calcRowQRDecomposition.
+func calcSolveWithLowerLeftTriangle(mtxA [][]float64, vecR []float64, mtxS [][]float64, k int) {
+	for row := 0; row < k; row++ {
+		sum := getDouble(mtxS, row)
+		for col := 0; col < row; col++ {
+			sum -= mtxA[row][col] * getDouble(mtxS, col)
+		}
+		putDouble(mtxS, row, sum/vecR[row])
+	}
+}
+
+// calcLinest solves the least squares regression of the observations with
+// the QR decomposition as the calcTrendGrowth, and returns the coefficients
+// and the regression statistics in the layout of the formula function
+// LINEST.
+func calcLinest(obsX [][]float64, obsY []float64, bConstant, bStats bool) [][]formulaArg {
+	N, K := len(obsY), len(obsX[0])
+	if (bConstant && N < K+1) || (!bConstant && N < K) {
+		return nil
+	}
+	mtxX, mtxY := getNewMatrix(K, N), getNewMatrix(1, N)
+	for row := 0; row < N; row++ {
+		for col := 0; col < K; col++ {
+			mtxX[col][row] = obsX[row][col]
+		}
+		mtxY[0][row] = obsY[row]
+	}
+	vecR := make([]float64, N)  // for QR decomposition
+	means := getNewMatrix(K, 1) // mean of each column
+	var meanY float64
+	if bConstant {
+		meanY = calcMeanOverAll(mtxY, N)
+		for row := 0; row < N; row++ {
+			mtxY[0][row] = approxSub(mtxY[0][row], meanY)
+		}
+		calcColumnMeans(mtxX, means, K, N)
+		calcColumnsDelta(mtxX, means, K, N)
+	}
+	ssTotal := calcSumProduct(mtxY, mtxY, N)
+	if !calcRowQRDecomposition(mtxX, vecR, K, N) {
+		return nil
+	}
+	for col := 0; col < K; col++ {
+		if vecR[col] == 0 {
+			return nil
+		}
+	}
+	for col := 0; col < K; col++ {
+		calcApplyRowsHouseholderTransformation(mtxX, col, mtxY, N)
+	}
+	slopes := getNewMatrix(1, K) // from b1 to bK
+	for col := 0; col < K; col++ {
+		putDouble(slopes, col, getDouble(mtxY, col))
+	}
+	calcSolveWithUpperRightTriangle(mtxX, vecR, slopes, K, false)
+	var intercept float64
+	if bConstant {
+		intercept = meanY - calcSumProduct(means, slopes, K)
+	}
+	result := [][]formulaArg{make([]formulaArg, K+1)}
+	for col := 0; col < K; col++ {
+		result[0][K-1-col] = newNumberFormulaArg(getDouble(slopes, col))
+	}
+	result[0][K] = newNumberFormulaArg(intercept)
+	if !bStats {
+		return result
+	}
+	// the residual sum of squares is the sum of squares of the transformed
+	// known y's after the first K elements
+	var ssResid float64
+	for row := K; row < N; row++ {
+		ssResid += mtxY[0][row] * mtxY[0][row]
+	}
+	ssReg, df := ssTotal-ssResid, float64(N-K)
+	if bConstant {
+		df--
+	}
+	na := newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
+	for i := 1; i < 5; i++ {
+		result = append(result, make([]formulaArg, K+1))
+		for j := 0; j <= K; j++ {
+			result[i][j] = na
+		}
+	}
+	var seY float64
+	if df > 0 {
+		seY = math.Sqrt(ssResid / df)
+	}
+	// the diagonal elements of the inverse of X'X = R'R are the squared
+	// norms of the columns of the inverse of R'
+	for col := 0; col < K; col++ {
+		mtxZ := getNewMatrix(1, K)
+		putDouble(mtxZ, col, 1)
+		calcSolveWithLowerLeftTriangle(mtxX, vecR, mtxZ, K)
+		result[1][K-1-col] = newNumberFormulaArg(seY * math.Sqrt(calcSumProduct(mtxZ, mtxZ, K)))
+	}
+	if bConstant {
+		mtxZ := getNewMatrix(1, K)
+		for col := 0; col < K; col++ {
+			putDouble(mtxZ, col, getDouble(means, col))
+		}
+		calcSolveWithLowerLeftTriangle(mtxX, vecR, mtxZ, K)
+		result[1][K] = newNumberFormulaArg(seY * math.Sqrt(1/float64(N)+calcSumProduct(mtxZ, mtxZ, K)))
+	}
+	result[2][0] = newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	if ssTotal != 0 {
+		result[2][0] = newNumberFormulaArg(ssReg / ssTotal)
+	}
+	result[2][1] = newNumberFormulaArg(seY)
+	result[3][0] = newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	if df > 0 && ssResid != 0 {
+		result[3][0] = newNumberFormulaArg((ssReg / float64(K)) / (ssResid / df))
+	}
+	result[3][1] = newNumberFormulaArg(df)
+	result[4][0], result[4][1] = newNumberFormulaArg(ssReg), newNumberFormulaArg(ssResid)
+	return result
+}
+
+// linestLogest is an implementation of the formula functions LINEST and
+// LOGEST.
+func (fn *formulaFuncs) linestLogest(name string, argsList *list.List) formulaArg {
+	if argsCount := checkFormulaArgsCount(name, argsList); argsCount.Type == ArgError {
+		return argsCount
+	}
+	numberMatrix := func(arg formulaArg) ([][]float64, formulaArg) {
+		if arg.Type != ArgMatrix {
+			arg = newMatrixFormulaArg([][]formulaArg{{arg}})
+		}
+		return newNumberMatrix(arg, false)
+	}
+	var knownX [][]float64
+	knownY, errArg := numberMatrix(argsList.Front().Value.(formulaArg))
+	if errArg.Type == ArgError {
+		return errArg
+	}
+	if len(knownY) == 0 || len(knownY[0]) == 0 {
+		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+	}
+	if argsList.Len() > 1 {
+		if arg := argsList.Front().Next().Value.(formulaArg); arg.Type != ArgEmpty {
+			if knownX, errArg = numberMatrix(arg); errArg.Type == ArgError {
+				return errArg
+			}
+		}
+	}
+	bConstant, bStats := true, false
+	if argsList.Len() > 2 {
+		if arg := argsList.Front().Next().Next().Value.(formulaArg); arg.Type != ArgEmpty {
+			constArg := arg.ToBool()
+			if constArg.Type != ArgNumber {
+				return constArg
+			}
+			bConstant = constArg.Number == 1
+		}
+	}
+	if argsList.Len() > 3 {
+		statsArg := argsList.Back().Value.(formulaArg).ToBool()
+		if statsArg.Type != ArgNumber {
+			return statsArg
+		}
+		bStats = statsArg.Number == 1
+	}
+	obsX, obsY, errArg := prepareLinestObservations(knownY, knownX)
+	if errArg.Type == ArgError {
+		return errArg
+	}
+	if name == "LOGEST" {
+		for i, y := range obsY {
+			if y <= 0 {
+				return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+			}
+			obsY[i] = math.Log(y)
+		}
+	}
+	result := calcLinest(obsX, obsY, bConstant, bStats)
+	if result == nil {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	}
+	if name == "LOGEST" {
+		for i := range result[0] {
+			result[0][i] = newNumberFormulaArg(math.Exp(result[0][i].Number))
+		}
+	}
+	return newMatrixFormulaArg(result)
+}
+
+// LINEST function calculates the statistics for a straight line that best
+// fits the supplied data by using the least squares method, and returns an
+// array that describes the line. The syntax of the function is:
+//
+//	LINEST(known_y's,[known_x's],[const],[stats])
+func (fn *formulaFuncs) LINEST(argsList *list.List) formulaArg {
+	return fn.linestLogest("LINEST", argsList)
+}
+
+// LOGEST function calculates an exponential curve that best fits the supplied
+// data, and returns an array of values that describes the curve. The syntax
+// of the function is:
+//
+//	LOGEST(known_y's,[known_x's],[const],[stats])
+func (fn *formulaFuncs) LOGEST(argsList *list.List) formulaArg {
+	return fn.linestLogest("LOGEST", argsList)
+}
+
 // LOGINV function calculates the inverse of the Cumulative Log-Normal
 // Distribution Function of x, for a supplied probability. The syntax of the
 // function is:
 //
 //	LOGINV(probability,mean,standard_dev)
 func (fn *formulaFuncs) LOGINV(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "LOGINV requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("LOGINV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var probability, mean, stdDev formulaArg
 	if probability = argsList.Front().Value.(formulaArg).ToNumber(); probability.Type != ArgNumber {
@@ -9489,8 +11855,8 @@ func (fn *formulaFuncs) LOGINV(argsList *list.List) formulaArg {
 //
 //	LOGNORM.INV(probability,mean,standard_dev)
 func (fn *formulaFuncs) LOGNORMdotINV(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "LOGNORM.INV requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("LOGNORM.INV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.LOGINV(argsList)
 }
@@ -9501,8 +11867,8 @@ func (fn *formulaFuncs) LOGNORMdotINV(argsList *list.List) formulaArg {
 //
 //	LOGNORM.DIST(x,mean,standard_dev,cumulative)
 func (fn *formulaFuncs) LOGNORMdotDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "LOGNORM.DIST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("LOGNORM.DIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, mean, stdDev, cumulative formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -9537,8 +11903,8 @@ func (fn *formulaFuncs) LOGNORMdotDIST(argsList *list.List) formulaArg {
 //
 //	LOGNORMDIST(x,mean,standard_dev)
 func (fn *formulaFuncs) LOGNORMDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "LOGNORMDIST requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("LOGNORMDIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, mean, stdDev formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -9565,16 +11931,22 @@ func (fn *formulaFuncs) LOGNORMDIST(argsList *list.List) formulaArg {
 //
 //	MODE(number1,[number2],...)
 func (fn *formulaFuncs) MODE(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MODE requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("MODE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var values []float64
 	for arg := argsList.Front(); arg != nil; arg = arg.Next() {
 		cells := arg.Value.(formulaArg)
-		if cells.Type != ArgMatrix && cells.Type != ArgNumber {
+		if cells.Type == ArgError {
+			return cells
+		}
+		if cells.Type != ArgMatrix && cells.Type != ArgList && cells.Type != ArgNumber {
 			return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 		}
 		for _, cell := range cells.ToList() {
+			if cell.Type == ArgError {
+				return cell
+			}
 			if cell.Type == ArgNumber {
 				values = append(values, cell.Number)
 			}
@@ -9608,16 +11980,22 @@ func (fn *formulaFuncs) MODE(argsList *list.List) formulaArg {
 //
 //	MODE.MULT(number1,[number2],...)
 func (fn *formulaFuncs) MODEdotMULT(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MODE.MULT requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("MODE.MULT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var values []float64
 	for arg := argsList.Front(); arg != nil; arg = arg.Next() {
 		cells := arg.Value.(formulaArg)
-		if cells.Type != ArgMatrix && cells.Type != ArgNumber {
+		if cells.Type == ArgError {
+			return cells
+		}
+		if cells.Type != ArgMatrix && cells.Type != ArgList && cells.Type != ArgNumber {
 			return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 		}
 		for _, cell := range cells.ToList() {
+			if cell.Type == ArgError {
+				return cell
+			}
 			if cell.Type == ArgNumber {
 				values = append(values, cell.Number)
 			}
@@ -9655,8 +12033,8 @@ func (fn *formulaFuncs) MODEdotMULT(argsList *list.List) formulaArg {
 //
 //	MODE.SNGL(number1,[number2],...)
 func (fn *formulaFuncs) MODEdotSNGL(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MODE.SNGL requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("MODE.SNGL", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.MODE(argsList)
 }
@@ -9669,8 +12047,8 @@ func (fn *formulaFuncs) MODEdotSNGL(argsList *list.List) formulaArg {
 //
 //	NEGBINOM.DIST(number_f,number_s,probability_s,cumulative)
 func (fn *formulaFuncs) NEGBINOMdotDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "NEGBINOM.DIST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("NEGBINOM.DIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var f, s, probability, cumulative formulaArg
 	if f = argsList.Front().Value.(formulaArg).ToNumber(); f.Type != ArgNumber {
@@ -9701,8 +12079,8 @@ func (fn *formulaFuncs) NEGBINOMdotDIST(argsList *list.List) formulaArg {
 //
 //	NEGBINOMDIST(number_f,number_s,probability_s)
 func (fn *formulaFuncs) NEGBINOMDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "NEGBINOMDIST requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("NEGBINOMDIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var f, s, probability formulaArg
 	if f = argsList.Front().Value.(formulaArg).ToNumber(); f.Type != ArgNumber {
@@ -9726,8 +12104,8 @@ func (fn *formulaFuncs) NEGBINOMDIST(argsList *list.List) formulaArg {
 //
 //	NORM.DIST(x,mean,standard_dev,cumulative)
 func (fn *formulaFuncs) NORMdotDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "NORM.DIST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("NORM.DIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.NORMDIST(argsList)
 }
@@ -9738,8 +12116,8 @@ func (fn *formulaFuncs) NORMdotDIST(argsList *list.List) formulaArg {
 //
 //	NORMDIST(x,mean,standard_dev,cumulative)
 func (fn *formulaFuncs) NORMDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "NORMDIST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("NORMDIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, mean, stdDev, cumulative formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -9769,8 +12147,8 @@ func (fn *formulaFuncs) NORMDIST(argsList *list.List) formulaArg {
 //
 //	NORM.INV(probability,mean,standard_dev)
 func (fn *formulaFuncs) NORMdotINV(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "NORM.INV requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("NORM.INV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.NORMINV(argsList)
 }
@@ -9781,8 +12159,8 @@ func (fn *formulaFuncs) NORMdotINV(argsList *list.List) formulaArg {
 //
 //	NORMINV(probability,mean,standard_dev)
 func (fn *formulaFuncs) NORMINV(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "NORMINV requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("NORMINV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var prob, mean, stdDev formulaArg
 	if prob = argsList.Front().Value.(formulaArg).ToNumber(); prob.Type != ArgNumber {
@@ -9917,7 +12295,9 @@ func norminv(p float64) (float64, error) {
 	return 0, errors.New(formulaErrorNUM)
 }
 
-// kth is an implementation of the formula functions LARGE and SMALL.
+// kth is an implementation of the formula functions LARGE and SMALL. The
+// fractional k will be truncated, and only the numbers in the array will be
+// counted.
 func (fn *formulaFuncs) kth(name string, argsList *list.List) formulaArg {
 	if argsList.Len() != 2 {
 		return newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("%s requires 2 arguments", name))
@@ -9927,19 +12307,22 @@ func (fn *formulaFuncs) kth(name string, argsList *list.List) formulaArg {
 	if argK.Type != ArgNumber {
 		return argK
 	}
-	k := int(argK.Number)
-	if k < 1 {
+	if errArg := firstErrorArg(array); errArg.Type == ArgError {
+		return errArg
+	}
+	if argK.Number = math.Trunc(argK.Number); argK.Number < 1 {
 		return newErrorFormulaArg(formulaErrorNUM, "k should be > 0")
 	}
 	var data []float64
 	for _, arg := range array {
-		if arg.Type == ArgNumber {
+		if arg.Type == ArgNumber && !arg.Boolean {
 			data = append(data, arg.Number)
 		}
 	}
-	if len(data) < k {
+	if float64(len(data)) < argK.Number {
 		return newErrorFormulaArg(formulaErrorNUM, "k should be <= length of array")
 	}
+	k := int(argK.Number)
 	sort.Float64s(data)
 	if name == "LARGE" {
 		return newNumberFormulaArg(data[len(data)-k])
@@ -9960,8 +12343,8 @@ func (fn *formulaFuncs) LARGE(argsList *list.List) formulaArg {
 //
 //	MAX(number1,[number2],...)
 func (fn *formulaFuncs) MAX(argsList *list.List) formulaArg {
-	if argsList.Len() == 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MAX requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("MAX", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.max(false, argsList)
 }
@@ -9973,8 +12356,8 @@ func (fn *formulaFuncs) MAX(argsList *list.List) formulaArg {
 //
 //	MAXA(number1,[number2],...)
 func (fn *formulaFuncs) MAXA(argsList *list.List) formulaArg {
-	if argsList.Len() == 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MAXA requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("MAXA", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.max(true, argsList)
 }
@@ -9985,8 +12368,8 @@ func (fn *formulaFuncs) MAXA(argsList *list.List) formulaArg {
 //
 //	MAXIFS(max_range,criteria_range1,criteria1,[criteria_range2,criteria2],...)
 func (fn *formulaFuncs) MAXIFS(argsList *list.List) formulaArg {
-	if argsList.Len() < 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MAXIFS requires at least 3 arguments")
+	if argsCount := checkFormulaArgsCount("MAXIFS", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	if argsList.Len()%2 != 1 {
 		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
@@ -9997,6 +12380,9 @@ func (fn *formulaFuncs) MAXIFS(argsList *list.List) formulaArg {
 		args = append(args, arg.Value.(formulaArg))
 	}
 	for _, ref := range formulaIfsMatch(args) {
+		if ref.Row >= len(maxRange) || ref.Col >= len(maxRange[ref.Row]) {
+			continue
+		}
 		if num := maxRange[ref.Row][ref.Col].ToNumber(); num.Type == ArgNumber && max < num.Number {
 			max = num.Number
 		}
@@ -10060,8 +12446,8 @@ func (fn *formulaFuncs) max(maxa bool, argsList *list.List) formulaArg {
 //
 //	MEDIAN(number1,[number2],...)
 func (fn *formulaFuncs) MEDIAN(argsList *list.List) formulaArg {
-	if argsList.Len() == 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MEDIAN requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("MEDIAN", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var values []float64
 	var median float64
@@ -10076,12 +12462,15 @@ func (fn *formulaFuncs) MEDIAN(argsList *list.List) formulaArg {
 			values = append(values, value.Number)
 		case ArgNumber:
 			values = append(values, arg.Number)
-		case ArgMatrix:
-			for _, row := range arg.Matrix {
-				for _, cell := range row {
-					if cell.Type == ArgNumber {
-						values = append(values, cell.Number)
-					}
+		case ArgError:
+			return arg
+		case ArgMatrix, ArgList:
+			for _, cell := range arg.ToList() {
+				if cell.Type == ArgError {
+					return cell
+				}
+				if cell.Type == ArgNumber {
+					values = append(values, cell.Number)
 				}
 			}
 		}
@@ -10103,8 +12492,8 @@ func (fn *formulaFuncs) MEDIAN(argsList *list.List) formulaArg {
 //
 //	MIN(number1,[number2],...)
 func (fn *formulaFuncs) MIN(argsList *list.List) formulaArg {
-	if argsList.Len() == 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MIN requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("MIN", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.min(false, argsList)
 }
@@ -10116,8 +12505,8 @@ func (fn *formulaFuncs) MIN(argsList *list.List) formulaArg {
 //
 //	MINA(number1,[number2],...)
 func (fn *formulaFuncs) MINA(argsList *list.List) formulaArg {
-	if argsList.Len() == 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MINA requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("MINA", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.min(true, argsList)
 }
@@ -10128,8 +12517,8 @@ func (fn *formulaFuncs) MINA(argsList *list.List) formulaArg {
 //
 //	MINIFS(min_range,criteria_range1,criteria1,[criteria_range2,criteria2],...)
 func (fn *formulaFuncs) MINIFS(argsList *list.List) formulaArg {
-	if argsList.Len() < 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MINIFS requires at least 3 arguments")
+	if argsCount := checkFormulaArgsCount("MINIFS", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	if argsList.Len()%2 != 1 {
 		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
@@ -10140,6 +12529,9 @@ func (fn *formulaFuncs) MINIFS(argsList *list.List) formulaArg {
 		args = append(args, arg.Value.(formulaArg))
 	}
 	for _, ref := range formulaIfsMatch(args) {
+		if ref.Row >= len(minRange) || ref.Col >= len(minRange[ref.Row]) {
+			continue
+		}
 		if num := minRange[ref.Row][ref.Col].ToNumber(); num.Type == ArgNumber && min > num.Number {
 			min = num.Number
 		}
@@ -10229,6 +12621,9 @@ func (fn *formulaFuncs) pearsonProduct(name string, n int, argsList *list.List)
 		y += num2.Number
 		length++
 	}
+	if length == 0 {
+		return newErrorFormulaArg(formulaErrorDIV, formulaErrorDIV)
+	}
 	x /= length
 	y /= length
 	for i := 0; i < len(array1); i++ {
@@ -10240,7 +12635,9 @@ func (fn *formulaFuncs) pearsonProduct(name string, n int, argsList *list.List)
 		deltaX += (num1.Number - x) * (num1.Number - x)
 		deltaY += (num2.Number - y) * (num2.Number - y)
 	}
-	if sum*deltaX*deltaY == 0 {
+	// the x-values with zero variance is a vertical line, and the correlation
+	// coefficient requires the y-values with non-zero variance too
+	if deltaX == 0 || (deltaY == 0 && (name == "PEARSON" || name == "RSQ")) {
 		return newErrorFormulaArg(formulaErrorDIV, formulaErrorDIV)
 	}
 	return newNumberFormulaArg(map[string]float64{
@@ -10267,8 +12664,8 @@ func (fn *formulaFuncs) PEARSON(argsList *list.List) formulaArg {
 //
 //	PERCENTILE.EXC(array,k)
 func (fn *formulaFuncs) PERCENTILEdotEXC(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PERCENTILE.EXC requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("PERCENTILE.EXC", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	array := argsList.Front().Value.(formulaArg).ToList()
 	k := argsList.Back().Value.(formulaArg).ToNumber()
@@ -10288,11 +12685,19 @@ func (fn *formulaFuncs) PERCENTILEdotEXC(argsList *list.List) formulaArg {
 		}
 	}
 	cnt := len(numbers)
-	sort.Float64s(numbers)
+	// the rank of the percentile must be within 1 and the count of numbers,
+	// i.e. k must be within 1/(n+1) and n/(n+1)
 	idx := k.Number * (float64(cnt) + 1)
+	if idx < 1 || idx > float64(cnt) {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	}
+	sort.Float64s(numbers)
 	base := math.Floor(idx)
+	if int(base) == cnt {
+		return newNumberFormulaArg(numbers[cnt-1])
+	}
 	next := base - 1
-	proportion := math.Nextafter(idx, idx) - base
+	proportion := idx - base
 	return newNumberFormulaArg(numbers[int(next)] + ((numbers[int(base)] - numbers[int(next)]) * proportion))
 }
 
@@ -10302,8 +12707,8 @@ func (fn *formulaFuncs) PERCENTILEdotEXC(argsList *list.List) formulaArg {
 //
 //	PERCENTILE.INC(array,k)
 func (fn *formulaFuncs) PERCENTILEdotINC(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PERCENTILE.INC requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("PERCENTILE.INC", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.PERCENTILE(argsList)
 }
@@ -10314,8 +12719,8 @@ func (fn *formulaFuncs) PERCENTILEdotINC(argsList *list.List) formulaArg {
 //
 //	PERCENTILE(array,k)
 func (fn *formulaFuncs) PERCENTILE(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PERCENTILE requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("PERCENTILE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	array := argsList.Front().Value.(formulaArg).ToList()
 	k := argsList.Back().Value.(formulaArg).ToNumber()
@@ -10440,10 +12845,14 @@ func (fn *formulaFuncs) PERMUT(argsList *list.List) formulaArg {
 	if chosen.Type != ArgNumber {
 		return chosen
 	}
-	if number.Number < chosen.Number {
+	num, numChosen, val := math.Trunc(number.Number), math.Trunc(chosen.Number), 1.0
+	if num < numChosen {
 		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
 	}
-	return newNumberFormulaArg(math.Round(fact(number.Number) / fact(number.Number-chosen.Number)))
+	for i := num - numChosen + 1; i <= num && !math.IsInf(val, 0); i++ {
+		val *= i
+	}
+	return newFiniteNumberFormulaArg(val)
 }
 
 // PERMUTATIONA function calculates the number of permutations, with
@@ -10452,7 +12861,7 @@ func (fn *formulaFuncs) PERMUT(argsList *list.List) formulaArg {
 //
 //	PERMUTATIONA(number,number_chosen)
 func (fn *formulaFuncs) PERMUTATIONA(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
+	if argsList.Len() != 2 {
 		return newErrorFormulaArg(formulaErrorVALUE, "PERMUTATIONA requires 2 numeric arguments")
 	}
 	number := argsList.Front().Value.(formulaArg).ToNumber()
@@ -10467,7 +12876,7 @@ func (fn *formulaFuncs) PERMUTATIONA(argsList *list.List) formulaArg {
 	if num < 0 || numChosen < 0 {
 		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
 	}
-	return newNumberFormulaArg(math.Pow(num, numChosen))
+	return newFiniteNumberFormulaArg(math.Pow(num, numChosen))
 }
 
 // PHI function returns the value of the density function for a standard normal
@@ -10475,8 +12884,8 @@ func (fn *formulaFuncs) PERMUTATIONA(argsList *list.List) formulaArg {
 //
 //	PHI(x)
 func (fn *formulaFuncs) PHI(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PHI requires 1 argument")
+	if argsCount := checkFormulaArgsCount("PHI", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	x := argsList.Front().Value.(formulaArg).ToNumber()
 	if x.Type != ArgNumber {
@@ -10490,8 +12899,8 @@ func (fn *formulaFuncs) PHI(argsList *list.List) formulaArg {
 //
 //	QUARTILE(array,quart)
 func (fn *formulaFuncs) QUARTILE(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "QUARTILE requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("QUARTILE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	quart := argsList.Back().Value.(formulaArg).ToNumber()
 	if quart.Type != ArgNumber {
@@ -10512,8 +12921,8 @@ func (fn *formulaFuncs) QUARTILE(argsList *list.List) formulaArg {
 //
 //	QUARTILE.EXC(array,quart)
 func (fn *formulaFuncs) QUARTILEdotEXC(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "QUARTILE.EXC requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("QUARTILE.EXC", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	quart := argsList.Back().Value.(formulaArg).ToNumber()
 	if quart.Type != ArgNumber {
@@ -10533,13 +12942,15 @@ func (fn *formulaFuncs) QUARTILEdotEXC(argsList *list.List) formulaArg {
 //
 //	QUARTILE.INC(array,quart)
 func (fn *formulaFuncs) QUARTILEdotINC(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "QUARTILE.INC requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("QUARTILE.INC", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.QUARTILE(argsList)
 }
 
-// rank is an implementation of the formula functions RANK and RANK.EQ.
+// rank is an implementation of the formula functions RANK and RANK.EQ. The
+// text, logical values and empty cells in the reference will be ignored, and
+// the duplicate values will be given the same rank.
 func (fn *formulaFuncs) rank(name string, argsList *list.List) formulaArg {
 	if argsList.Len() < 2 {
 		return newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("%s requires at least 2 arguments", name))
@@ -10551,9 +12962,13 @@ func (fn *formulaFuncs) rank(name string, argsList *list.List) formulaArg {
 	if num.Type != ArgNumber {
 		return num
 	}
+	ref := argsList.Front().Next().Value.(formulaArg).ToList()
+	if errArg := firstErrorArg(ref); errArg.Type == ArgError {
+		return errArg
+	}
 	var arr []float64
-	for _, arg := range argsList.Front().Next().Value.(formulaArg).ToList() {
-		if arg.Type == ArgNumber {
+	for _, arg := range ref {
+		if arg.Type == ArgNumber && !arg.Boolean {
 			arr = append(arr, arg.Number)
 		}
 	}
@@ -10682,8 +13097,8 @@ func (fn *formulaFuncs) SMALL(argsList *list.List) formulaArg {
 //
 //	STANDARDIZE(x,mean,standard_dev)
 func (fn *formulaFuncs) STANDARDIZE(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "STANDARDIZE requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("STANDARDIZE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	x := argsList.Front().Value.(formulaArg).ToNumber()
 	if x.Type != ArgNumber {
@@ -10749,8 +13164,8 @@ func (fn *formulaFuncs) STDEVPA(argsList *list.List) formulaArg {
 //
 //	STEYX(known_y's,known_x's)
 func (fn *formulaFuncs) STEYX(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "STEYX requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("STEYX", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	array1 := argsList.Back().Value.(formulaArg).ToList()
 	array2 := argsList.Front().Value.(formulaArg).ToList()
@@ -10809,8 +13224,8 @@ func getTDist(T, fDF, nType float64) float64 {
 //
 //	T.DIST(x,degrees_freedom,cumulative)
 func (fn *formulaFuncs) TdotDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "T.DIST requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("T.DIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, degrees, cumulative formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -10844,8 +13259,8 @@ func (fn *formulaFuncs) TdotDIST(argsList *list.List) formulaArg {
 //
 //	T.DIST.2T(x,degrees_freedom)
 func (fn *formulaFuncs) TdotDISTdot2T(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "T.DIST.2T requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("T.DIST.2T", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, degrees formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -10867,8 +13282,8 @@ func (fn *formulaFuncs) TdotDISTdot2T(argsList *list.List) formulaArg {
 //
 //	T.DIST.RT(x,degrees_freedom)
 func (fn *formulaFuncs) TdotDISTdotRT(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "T.DIST.RT requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("T.DIST.RT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, degrees formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -10893,8 +13308,8 @@ func (fn *formulaFuncs) TdotDISTdotRT(argsList *list.List) formulaArg {
 //
 //	TDIST(x,degrees_freedom,tails)
 func (fn *formulaFuncs) TDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TDIST requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("TDIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var x, degrees, tails formulaArg
 	if x = argsList.Front().Value.(formulaArg).ToNumber(); x.Type != ArgNumber {
@@ -10919,8 +13334,8 @@ func (fn *formulaFuncs) TDIST(argsList *list.List) formulaArg {
 //
 //	T.INV(probability,degrees_freedom)
 func (fn *formulaFuncs) TdotINV(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "T.INV requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("T.INV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var probability, degrees formulaArg
 	if probability = argsList.Front().Value.(formulaArg).ToNumber(); probability.Type != ArgNumber {
@@ -10955,8 +13370,8 @@ func (fn *formulaFuncs) TdotINV(argsList *list.List) formulaArg {
 //
 //	T.INV.2T(probability,degrees_freedom)
 func (fn *formulaFuncs) TdotINVdot2T(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "T.INV.2T requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("T.INV.2T", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var probability, degrees formulaArg
 	if probability = argsList.Front().Value.(formulaArg).ToNumber(); probability.Type != ArgNumber {
@@ -10983,8 +13398,8 @@ func (fn *formulaFuncs) TdotINVdot2T(argsList *list.List) formulaArg {
 //
 //	TINV(probability,degrees_freedom)
 func (fn *formulaFuncs) TINV(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TINV requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("TINV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.TdotINVdot2T(argsList)
 }
@@ -11088,28 +13503,30 @@ func (fn *formulaFuncs) tTest(mtx1, mtx2 [][]formulaArg, fTails, fTyp float64) f
 //
 //	TTEST(array1,array2,tails,type)
 func (fn *formulaFuncs) TTEST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TTEST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("TTEST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	var array1, array2, tails, typeArg formulaArg
 	array1 = argsList.Front().Value.(formulaArg)
 	array2 = argsList.Front().Next().Value.(formulaArg)
-	if tails = argsList.Front().Next().Next().Value.(formulaArg); tails.Type != ArgNumber {
+	if tails = argsList.Front().Next().Next().Value.(formulaArg).ToNumber(); tails.Type != ArgNumber {
 		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 	}
-	if typeArg = argsList.Back().Value.(formulaArg); typeArg.Type != ArgNumber {
+	if typeArg = argsList.Back().Value.(formulaArg).ToNumber(); typeArg.Type != ArgNumber {
 		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 	}
 	if len(array1.Matrix) == 0 || len(array2.Matrix) == 0 {
 		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	if tails.Number != 1 && tails.Number != 2 {
+	// the tails and type arguments are truncated to integers
+	fTails, fTyp := math.Trunc(tails.Number), math.Trunc(typeArg.Number)
+	if fTails != 1 && fTails != 2 {
 		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	if typeArg.Number != 1 && typeArg.Number != 2 && typeArg.Number != 3 {
+	if fTyp != 1 && fTyp != 2 && fTyp != 3 {
 		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	return fn.tTest(array1.Matrix, array2.Matrix, tails.Number, typeArg.Number)
+	return fn.tTest(array1.Matrix, array2.Matrix, fTails, fTyp)
 }
 
 // TdotTEST function calculates the probability associated with the Student's T
@@ -11119,8 +13536,8 @@ func (fn *formulaFuncs) TTEST(argsList *list.List) formulaArg {
 //
 //	T.TEST(array1,array2,tails,type)
 func (fn *formulaFuncs) TdotTEST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "T.TEST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("T.TEST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.TTEST(argsList)
 }
@@ -11130,8 +13547,8 @@ func (fn *formulaFuncs) TdotTEST(argsList *list.List) formulaArg {
 //
 //	TRIMMEAN(array,percent)
 func (fn *formulaFuncs) TRIMMEAN(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TRIMMEAN requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("TRIMMEAN", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	percent := argsList.Back().Value.(formulaArg).ToNumber()
 	if percent.Type != ArgNumber {
@@ -11204,7 +13621,9 @@ func (fn *formulaFuncs) vars(name string, argsList *list.List) formulaArg {
 	if count > minimum {
 		summerA *= count
 		summerB *= summerB
-		return newNumberFormulaArg((summerA - summerB) / (count * (count - minimum)))
+		// the rounding error may produce a tiny negative variance for the
+		// equal values, which is zero
+		return newNumberFormulaArg(math.Max((summerA-summerB)/(count*(count-minimum)), 0))
 	}
 	return newErrorFormulaArg(formulaErrorDIV, formulaErrorDIV)
 }
@@ -11263,24 +13682,27 @@ func (fn *formulaFuncs) VARPA(argsList *list.List) formulaArg {
 //
 //	WEIBULL(x,alpha,beta,cumulative)
 func (fn *formulaFuncs) WEIBULL(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "WEIBULL requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("WEIBULL", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	x := argsList.Front().Value.(formulaArg).ToNumber()
 	alpha := argsList.Front().Next().Value.(formulaArg).ToNumber()
 	beta := argsList.Back().Prev().Value.(formulaArg).ToNumber()
-	if alpha.Type == ArgNumber && beta.Type == ArgNumber && x.Type == ArgNumber {
-		if alpha.Number < 0 || alpha.Number <= 0 || beta.Number <= 0 {
-			return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
-		}
-		cumulative := argsList.Back().Value.(formulaArg).ToBool()
-		if cumulative.Boolean && cumulative.Number == 1 {
-			return newNumberFormulaArg(1 - math.Exp(0-math.Pow(x.Number/beta.Number, alpha.Number)))
-		}
-		return newNumberFormulaArg((alpha.Number / math.Pow(beta.Number, alpha.Number)) *
-			math.Pow(x.Number, alpha.Number-1) * math.Exp(0-math.Pow(x.Number/beta.Number, alpha.Number)))
+	if alpha.Type != ArgNumber || beta.Type != ArgNumber || x.Type != ArgNumber {
+		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+	}
+	cumulative := argsList.Back().Value.(formulaArg).ToBool()
+	if cumulative.Type == ArgError {
+		return cumulative
+	}
+	if x.Number < 0 || alpha.Number <= 0 || beta.Number <= 0 {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	}
+	if cumulative.Number == 1 {
+		return newNumberFormulaArg(1 - math.Exp(0-math.Pow(x.Number/beta.Number, alpha.Number)))
 	}
-	return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+	return newNumberFormulaArg((alpha.Number / math.Pow(beta.Number, alpha.Number)) *
+		math.Pow(x.Number, alpha.Number-1) * math.Exp(0-math.Pow(x.Number/beta.Number, alpha.Number)))
 }
 
 // WEIBULLdotDIST function calculates the Weibull Probability Density Function
@@ -11289,8 +13711,8 @@ func (fn *formulaFuncs) WEIBULL(argsList *list.List) formulaArg {
 //
 //	WEIBULL.DIST(x,alpha,beta,cumulative)
 func (fn *formulaFuncs) WEIBULLdotDIST(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "WEIBULL.DIST requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("WEIBULL.DIST", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return fn.WEIBULL(argsList)
 }
@@ -11355,18 +13777,18 @@ func (fn *formulaFuncs) ZTEST(argsList *list.List) formulaArg {
 //
 //	ERROR.TYPE(error_val)
 func (fn *formulaFuncs) ERRORdotTYPE(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ERROR.TYPE requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ERROR.TYPE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	token := argsList.Front().Value.(formulaArg)
 	if token.Type == ArgError {
-		for i, errType := range []string{
-			formulaErrorNULL, formulaErrorDIV, formulaErrorVALUE, formulaErrorREF,
-			formulaErrorNAME, formulaErrorNUM, formulaErrorNA,
-		} {
-			if errType == token.String {
-				return newNumberFormulaArg(float64(i) + 1)
-			}
+		if errType, ok := map[string]int{
+			formulaErrorNULL: 1, formulaErrorDIV: 2, formulaErrorVALUE: 3,
+			formulaErrorREF: 4, formulaErrorNAME: 5, formulaErrorNUM: 6,
+			formulaErrorNA: 7, formulaErrorGETTINGDATA: 8, formulaErrorSPILL: 9,
+			formulaErrorCALC: 14,
+		}[token.String]; ok {
+			return newNumberFormulaArg(float64(errType))
 		}
 	}
 	return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
@@ -11378,8 +13800,8 @@ func (fn *formulaFuncs) ERRORdotTYPE(argsList *list.List) formulaArg {
 //
 //	ISBLANK(value)
 func (fn *formulaFuncs) ISBLANK(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISBLANK requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISBLANK", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	token := argsList.Front().Value.(formulaArg)
 	switch token.Type {
@@ -11397,8 +13819,8 @@ func (fn *formulaFuncs) ISBLANK(argsList *list.List) formulaArg {
 //
 //	ISERR(value)
 func (fn *formulaFuncs) ISERR(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISERR requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISERR", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	token := argsList.Front().Value.(formulaArg)
 	result := false
@@ -11422,8 +13844,8 @@ func (fn *formulaFuncs) ISERR(argsList *list.List) formulaArg {
 //
 //	ISERROR(value)
 func (fn *formulaFuncs) ISERROR(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISERROR requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISERROR", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	token := argsList.Front().Value.(formulaArg)
 	result := false
@@ -11447,8 +13869,8 @@ func (fn *formulaFuncs) ISERROR(argsList *list.List) formulaArg {
 //
 //	ISEVEN(value)
 func (fn *formulaFuncs) ISEVEN(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISEVEN requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISEVEN", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	token := argsList.Front().Value.(formulaArg)
 	switch token.Type {
@@ -11474,8 +13896,8 @@ func (fn *formulaFuncs) ISEVEN(argsList *list.List) formulaArg {
 //
 //	ISFORMULA(reference)
 func (fn *formulaFuncs) ISFORMULA(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISFORMULA requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISFORMULA", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	arg := argsList.Front().Value.(formulaArg)
 	if arg.cellRefs != nil && arg.cellRefs.Len() == 1 {
@@ -11494,8 +13916,8 @@ func (fn *formulaFuncs) ISFORMULA(argsList *list.List) formulaArg {
 //
 //	ISLOGICAL(value)
 func (fn *formulaFuncs) ISLOGICAL(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISLOGICAL requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISLOGICAL", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	val := argsList.Front().Value.(formulaArg).Value()
 	if strings.EqualFold("TRUE", val) || strings.EqualFold("FALSE", val) {
@@ -11510,8 +13932,8 @@ func (fn *formulaFuncs) ISLOGICAL(argsList *list.List) formulaArg {
 //
 //	ISNA(value)
 func (fn *formulaFuncs) ISNA(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISNA requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISNA", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	token := argsList.Front().Value.(formulaArg)
 	result := "FALSE"
@@ -11527,8 +13949,8 @@ func (fn *formulaFuncs) ISNA(argsList *list.List) formulaArg {
 //
 //	ISNONTEXT(value)
 func (fn *formulaFuncs) ISNONTEXT(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISNONTEXT requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISNONTEXT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	if argsList.Front().Value.(formulaArg).Type == ArgString {
 		return newBoolFormulaArg(false)
@@ -11542,8 +13964,8 @@ func (fn *formulaFuncs) ISNONTEXT(argsList *list.List) formulaArg {
 //
 //	ISNUMBER(value)
 func (fn *formulaFuncs) ISNUMBER(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISNUMBER requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISNUMBER", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	if argsList.Front().Value.(formulaArg).Type == ArgNumber {
 		return newBoolFormulaArg(true)
@@ -11557,8 +13979,8 @@ func (fn *formulaFuncs) ISNUMBER(argsList *list.List) formulaArg {
 //
 //	ISODD(value)
 func (fn *formulaFuncs) ISODD(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISODD requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISODD", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	arg := argsList.Front().Value.(formulaArg).ToNumber()
 	if arg.Type != ArgNumber {
@@ -11576,8 +13998,8 @@ func (fn *formulaFuncs) ISODD(argsList *list.List) formulaArg {
 //
 //	ISREF(value)
 func (fn *formulaFuncs) ISREF(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISREF requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISREF", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	arg := argsList.Front().Value.(formulaArg)
 	if arg.cellRanges != nil && arg.cellRanges.Len() > 0 || arg.cellRefs != nil && arg.cellRefs.Len() > 0 {
@@ -11591,8 +14013,8 @@ func (fn *formulaFuncs) ISREF(argsList *list.List) formulaArg {
 //
 //	ISTEXT(value)
 func (fn *formulaFuncs) ISTEXT(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISTEXT requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISTEXT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	token := argsList.Front().Value.(formulaArg)
 	if token.ToNumber().Type != ArgError {
@@ -11601,25 +14023,29 @@ func (fn *formulaFuncs) ISTEXT(argsList *list.List) formulaArg {
 	return newBoolFormulaArg(token.Type == ArgString)
 }
 
-// N function converts data into a numeric value. The syntax of the function
-// is:
+// N function converts data into a numeric value. Numbers and dates return
+// their numeric value, the logical value TRUE returns 1, errors are passed
+// through, and any other values return 0. The syntax of the function is:
 //
 //	N(value)
 func (fn *formulaFuncs) N(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "N requires 1 argument")
-	}
-	token, num := argsList.Front().Value.(formulaArg), 0.0
-	if token.Type == ArgError {
-		return token
+	if argsCount := checkFormulaArgsCount("N", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
-	if arg := token.ToNumber(); arg.Type == ArgNumber {
-		num = arg.Number
+	token := argsList.Front().Value.(formulaArg)
+	if token.Type == ArgMatrix {
+		if len(token.Matrix) == 0 || len(token.Matrix[0]) == 0 {
+			return newNumberFormulaArg(0)
+		}
+		token = token.Matrix[0][0]
 	}
-	if token.Value() == "TRUE" {
-		num = 1
+	switch token.Type {
+	case ArgError:
+		return token
+	case ArgNumber:
+		return newNumberFormulaArg(token.Number)
 	}
-	return newNumberFormulaArg(num)
+	return newNumberFormulaArg(0)
 }
 
 // NA function returns the Excel #N/A error. This error message has the
@@ -11693,19 +14119,24 @@ func (fn *formulaFuncs) SHEETS(argsList *list.List) formulaArg {
 	return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
 }
 
-// TYPE function returns an integer that represents the value's data type. The
-// syntax of the function is:
+// TYPE function returns an integer that represents the value's data type:
+// 1 for number, 2 for text, 4 for logical value, 16 for error and 64 for
+// array. The syntax of the function is:
 //
 //	TYPE(value)
 func (fn *formulaFuncs) TYPE(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TYPE requires 1 argument")
+	if argsCount := checkFormulaArgsCount("TYPE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	token := argsList.Front().Value.(formulaArg)
+	if token.Type == ArgMatrix && token.cellRanges != nil && token.cellRanges.Len() > 0 &&
+		len(token.Matrix) == 1 && len(token.Matrix[0]) == 1 {
+		token = token.Matrix[0][0]
+	}
 	switch token.Type {
 	case ArgError:
 		return newNumberFormulaArg(16)
-	case ArgMatrix:
+	case ArgMatrix, ArgList:
 		return newNumberFormulaArg(64)
 	case ArgNumber, ArgEmpty:
 		if token.Boolean {
@@ -11723,8 +14154,8 @@ func (fn *formulaFuncs) TYPE(argsList *list.List) formulaArg {
 //
 //	T(value)
 func (fn *formulaFuncs) T(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "T requires 1 argument")
+	if argsCount := checkFormulaArgsCount("T", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	token := argsList.Front().Value.(formulaArg)
 	if token.Type == ArgError {
@@ -11789,8 +14220,8 @@ func (fn *formulaFuncs) FALSE(argsList *list.List) formulaArg {
 //
 //	IFERROR(value,value_if_error)
 func (fn *formulaFuncs) IFERROR(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IFERROR requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("IFERROR", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value := argsList.Front().Value.(formulaArg)
 	if value.Type != ArgError {
@@ -11809,8 +14240,8 @@ func (fn *formulaFuncs) IFERROR(argsList *list.List) formulaArg {
 //
 //	IFNA(value,value_if_na)
 func (fn *formulaFuncs) IFNA(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IFNA requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("IFNA", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	arg := argsList.Front().Value.(formulaArg)
 	if arg.Type == ArgError && arg.String == formulaErrorNA {
@@ -11826,14 +14257,24 @@ func (fn *formulaFuncs) IFNA(argsList *list.List) formulaArg {
 //
 //	IFS(logical_test1,value_if_true1,[logical_test2,value_if_true2],...)
 func (fn *formulaFuncs) IFS(argsList *list.List) formulaArg {
-	if argsList.Len() < 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "IFS requires at least 2 arguments")
+	if argsCount := checkFormulaArgsCount("IFS", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
-	for arg := argsList.Front(); arg != nil; arg = arg.Next() {
-		if arg.Value.(formulaArg).ToBool().Number == 1 {
+	if argsList.Len()%2 != 0 {
+		return newErrorFormulaArg(formulaErrorVALUE, "IFS requires an even number of arguments")
+	}
+	for arg := argsList.Front(); arg != nil; arg = arg.Next().Next() {
+		cond := arg.Value.(formulaArg)
+		if cond.Type == ArgError {
+			return cond
+		}
+		if cond.Type == ArgString {
+			cond = cond.ToBool()
+		}
+		// returns the first matched value, the values after it will be ignored
+		if cond.Type == ArgNumber && cond.Number != 0 {
 			return arg.Next().Value.(formulaArg)
 		}
-		arg = arg.Next()
 	}
 	return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
 }
@@ -11843,8 +14284,8 @@ func (fn *formulaFuncs) IFS(argsList *list.List) formulaArg {
 //
 //	NOT(logical)
 func (fn *formulaFuncs) NOT(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "NOT requires 1 argument")
+	if argsCount := checkFormulaArgsCount("NOT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	token := argsList.Front().Value.(formulaArg)
 	switch token.Type {
@@ -11909,10 +14350,13 @@ func (fn *formulaFuncs) OR(argsList *list.List) formulaArg {
 //
 //	SWITCH(expression,value1,result1,[value2,result2],[value3,result3],...,[default])
 func (fn *formulaFuncs) SWITCH(argsList *list.List) formulaArg {
-	if argsList.Len() < 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "SWITCH requires at least 3 arguments")
+	if argsCount := checkFormulaArgsCount("SWITCH", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	target := argsList.Front().Value.(formulaArg)
+	if target.Type == ArgError {
+		return target
+	}
 	argCount := argsList.Len() - 1
 	switchCount := int(math.Floor(float64(argCount) / 2))
 	hasDefaultClause := argCount%2 != 0
@@ -11924,7 +14368,10 @@ func (fn *formulaFuncs) SWITCH(argsList *list.List) formulaArg {
 		arg := argsList.Front()
 		for i := 0; i < switchCount; i++ {
 			arg = arg.Next()
-			if target.Value() == arg.Value.(formulaArg).Value() {
+			// the number and text are never equal, and the text comparison is
+			// case-insensitive
+			if value := arg.Value.(formulaArg); target.Boolean == value.Boolean &&
+				compareFormulaArg(target, value, newNumberFormulaArg(matchModeExact), false) == criteriaEq {
 				result = arg.Next().Value.(formulaArg)
 				break
 			}
@@ -11983,16 +14430,18 @@ func calcXor(argsList *list.List) formulaArg {
 //
 //	XOR(logical_test1,[logical_test2],...)
 func (fn *formulaFuncs) XOR(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "XOR requires at least 1 argument")
+	if argsCount := checkFormulaArgsCount("XOR", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return calcXor(argsList)
 }
 
 // Date and Time Functions
 
-// DATE returns a date, from a user-supplied year, month and day. The syntax
-// of the function is:
+// DATE returns the serial number of a date, from a user-supplied year, month
+// and day. The years between 0 and 1899 are added to 1900, and the month or
+// day out of range will roll over into the adjacent months or years. The
+// syntax of the function is:
 //
 //	DATE(year,month,day)
 func (fn *formulaFuncs) DATE(argsList *list.List) formulaArg {
@@ -12005,8 +14454,15 @@ func (fn *formulaFuncs) DATE(argsList *list.List) formulaArg {
 	if year.Type != ArgNumber || month.Type != ArgNumber || day.Type != ArgNumber {
 		return newErrorFormulaArg(formulaErrorVALUE, "DATE requires 3 number arguments")
 	}
-	d := makeDate(int(year.Number), time.Month(month.Number), int(day.Number))
-	return newStringFormulaArg(timeFromExcelTime(daysBetween(excelMinTime1900.Unix(), d)+1, false).String())
+	y := int(year.Number)
+	if y < 0 || y > 9999 {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	}
+	if y < 1900 {
+		y += 1900
+	}
+	date := time.Date(y, time.Month(month.Number), int(day.Number), 0, 0, 0, 0, time.UTC)
+	return newNumberFormulaArg(fn.dateSerial(date.Year(), date.Month(), date.Day()))
 }
 
 // calcDateDif is an implementation of the formula function DATEDIF,
@@ -12320,6 +14776,28 @@ func strToDate(str string) (int, int, int, bool, formulaArg) {
 	return year, month, day, timeIsEmpty, newEmptyFormulaArg()
 }
 
+// strToDateTimeSerial converts the recognized date or time text to the Excel
+// date-time serial number, the boolean result indicates whether the given
+// text is a valid date or time.
+func strToDateTimeSerial(str string) (float64, bool) {
+	var serial float64
+	str = strings.ToLower(str)
+	y, m, d, timeIsEmpty, err := strToDate(str)
+	if err.Type != ArgError {
+		if serial = daysBetween(excelMinTime1900.Unix(), makeDate(y, time.Month(m), d)) + 1; timeIsEmpty {
+			return serial, true
+		}
+	}
+	h, mi, sec, pm, dateIsEmpty, timeErr := strToTime(str)
+	if timeErr.Type == ArgError || (err.Type == ArgError && !dateIsEmpty) {
+		return 0, false
+	}
+	if pm {
+		h += 12
+	}
+	return serial + (float64(h*3600+mi*60)+sec)/86400, true
+}
+
 // DATEVALUE function converts a text representation of a date into an Excel
 // date. For example, the function converts a text string representing a
 // date, into the serial number that represents the date in Excels' date-time
@@ -12327,8 +14805,8 @@ func strToDate(str string) (int, int, int, bool, formulaArg) {
 //
 //	DATEVALUE(date_text)
 func (fn *formulaFuncs) DATEVALUE(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "DATEVALUE requires 1 argument")
+	if argsCount := checkFormulaArgsCount("DATEVALUE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	dateText := argsList.Front().Value.(formulaArg).Value()
 	if !isDateOnlyFmt(dateText) {
@@ -12340,7 +14818,7 @@ func (fn *formulaFuncs) DATEVALUE(argsList *list.List) formulaArg {
 	if err.Type == ArgError {
 		return err
 	}
-	return newNumberFormulaArg(daysBetween(excelMinTime1900.Unix(), makeDate(y, time.Month(m), d)) + 1)
+	return newNumberFormulaArg(fn.dateSerial(y, time.Month(m), d))
 }
 
 // DAY function returns the day of a date, represented by a serial number. The
@@ -12370,10 +14848,10 @@ func (fn *formulaFuncs) DAY(argsList *list.List) formulaArg {
 	if num.Number < 0 {
 		return newErrorFormulaArg(formulaErrorNUM, "DAY only accepts positive argument")
 	}
-	if num.Number <= 60 {
+	if num.Number <= 60 && (fn.ctx == nil || !fn.ctx.gregorianDates) {
 		return newNumberFormulaArg(math.Mod(num.Number, 31.0))
 	}
-	return newNumberFormulaArg(float64(timeFromExcelTime(num.Number, false).Day()))
+	return newNumberFormulaArg(float64(fn.serialTime(num.Number).Day()))
 }
 
 // DAYS function returns the number of days between two supplied dates. The
@@ -12381,8 +14859,8 @@ func (fn *formulaFuncs) DAY(argsList *list.List) formulaArg {
 //
 //	DAYS(end_date,start_date)
 func (fn *formulaFuncs) DAYS(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "DAYS requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("DAYS", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareDataValueArgs(2, argsList)
 	if args.Type != ArgList {
@@ -12447,8 +14925,8 @@ func (fn *formulaFuncs) DAYS360(argsList *list.List) formulaArg {
 //
 //	ISOWEEKNUM(date)
 func (fn *formulaFuncs) ISOWEEKNUM(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISOWEEKNUM requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ISOWEEKNUM", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	date := argsList.Front().Value.(formulaArg)
 	num := date.ToNumber()
@@ -12474,13 +14952,23 @@ func (fn *formulaFuncs) ISOWEEKNUM(argsList *list.List) formulaArg {
 	return newNumberFormulaArg(float64(weekNum))
 }
 
+// addMonths returns the year and month which is the given number of months
+// before or after the given year and month.
+func addMonths(year, month, months int) (int, int) {
+	total := year*12 + month - 1 + months
+	if total < 0 {
+		return -1, 1
+	}
+	return total / 12, total%12 + 1
+}
+
 // EDATE function returns a date that is a specified number of months before or
 // after a supplied start date. The syntax of function is:
 //
 //	EDATE(start_date,months)
 func (fn *formulaFuncs) EDATE(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "EDATE requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("EDATE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	date := argsList.Front().Value.(formulaArg)
 	num := date.ToNumber()
@@ -12507,21 +14995,13 @@ func (fn *formulaFuncs) EDATE(argsList *list.List) formulaArg {
 	if month.Type != ArgNumber {
 		return month
 	}
-	y, d := dateTime.Year(), dateTime.Day()
-	m := int(dateTime.Month()) + int(month.Number)
-	if month.Number < 0 {
-		y -= int(math.Ceil(-1 * float64(m) / 12))
-	}
-	if month.Number > 11 {
-		y += int(math.Floor(float64(m) / 12))
-	}
-	if m = m % 12; m < 0 {
-		m += 12
+	y, m := addMonths(dateTime.Year(), int(dateTime.Month()), int(month.Number))
+	if y < 1900 {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	if d > 28 {
-		if days := getDaysInMonth(y, m); d > days {
-			d = days
-		}
+	d := dateTime.Day()
+	if days := getDaysInMonth(y, m); d > days {
+		d = days
 	}
 	result, _ := timeToExcelTime(time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC), false)
 	return newNumberFormulaArg(result)
@@ -12533,8 +15013,8 @@ func (fn *formulaFuncs) EDATE(argsList *list.List) formulaArg {
 //
 //	EOMONTH(start_date,months)
 func (fn *formulaFuncs) EOMONTH(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "EOMONTH requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("EOMONTH", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	date := argsList.Front().Value.(formulaArg)
 	num := date.ToNumber()
@@ -12561,17 +15041,11 @@ func (fn *formulaFuncs) EOMONTH(argsList *list.List) formulaArg {
 	if months.Type != ArgNumber {
 		return months
 	}
-	y, m := dateTime.Year(), int(dateTime.Month())+int(months.Number)-1
-	if m < 0 {
-		y -= int(math.Ceil(-1 * float64(m) / 12))
-	}
-	if m > 11 {
-		y += int(math.Floor(float64(m) / 12))
-	}
-	if m = m % 12; m < 0 {
-		m += 12
+	y, m := addMonths(dateTime.Year(), int(dateTime.Month()), int(months.Number))
+	if y < 1900 {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	result, _ := timeToExcelTime(time.Date(y, time.Month(m+1), getDaysInMonth(y, m+1), 0, 0, 0, 0, time.UTC), false)
+	result, _ := timeToExcelTime(time.Date(y, time.Month(m), getDaysInMonth(y, m), 0, 0, 0, 0, time.UTC), false)
 	return newNumberFormulaArg(result)
 }
 
@@ -12665,7 +15139,7 @@ func (fn *formulaFuncs) MONTH(argsList *list.List) formulaArg {
 	if num.Number < 0 {
 		return newErrorFormulaArg(formulaErrorNUM, "MONTH only accepts positive argument")
 	}
-	return newNumberFormulaArg(float64(timeFromExcelTime(num.Number, false).Month()))
+	return newNumberFormulaArg(float64(fn.serialTime(num.Number).Month()))
 }
 
 // genWeekendMask generate weekend mask of a series of seven 0's and 1's which
@@ -12989,7 +15463,7 @@ func (fn *formulaFuncs) YEAR(argsList *list.List) formulaArg {
 	if num.Number < 0 {
 		return newErrorFormulaArg(formulaErrorNUM, "YEAR only accepts positive argument")
 	}
-	return newNumberFormulaArg(float64(timeFromExcelTime(num.Number, false).Year()))
+	return newNumberFormulaArg(float64(fn.serialTime(num.Number).Year()))
 }
 
 // yearFracBasisCond is an implementation of the yearFracBasis1.
@@ -13110,8 +15584,9 @@ func getYearDays(year, basis int) int {
 }
 
 // YEARFRAC function returns the fraction of a year that is represented by the
-// number of whole days between two supplied dates. The syntax of the
-// function is:
+// number of whole days between two supplied dates. The time parts of the
+// dates are ignored, and the dates will be swapped if the start date is later
+// than the end date. The syntax of the function is:
 //
 //	YEARFRAC(start_date,end_date,[basis])
 func (fn *formulaFuncs) YEARFRAC(argsList *list.List) formulaArg {
@@ -13122,14 +15597,20 @@ func (fn *formulaFuncs) YEARFRAC(argsList *list.List) formulaArg {
 	if args.Type != ArgList {
 		return args
 	}
-	start, end := args.List[0], args.List[1]
+	start, end := math.Trunc(args.List[0].Number), math.Trunc(args.List[1].Number)
 	basis := newNumberFormulaArg(0)
 	if argsList.Len() == 3 {
 		if basis = argsList.Back().Value.(formulaArg).ToNumber(); basis.Type != ArgNumber {
 			return basis
 		}
 	}
-	return yearFrac(start.Number, end.Number, int(basis.Number))
+	if start < 0 || end < 0 {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	}
+	if start > end {
+		start, end = end, start
+	}
+	return yearFrac(start, end, int(basis.Number))
 }
 
 // NOW function returns the current date and time. The function receives no
@@ -13177,8 +15658,9 @@ func (fn *formulaFuncs) SECOND(argsList *list.List) formulaArg {
 
 // TIME function accepts three integer arguments representing hours, minutes
 // and seconds, and returns an Excel time. I.e. the function returns the
-// decimal value that represents the time in Excel. The syntax of the
-// function is:
+// decimal value that represents the time in Excel. The fractional part of
+// each argument is truncated, and the time over 24 hours wraps around into
+// the range [0,1). The syntax of the function is:
 //
 //	TIME(hour,minute,second)
 func (fn *formulaFuncs) TIME(argsList *list.List) formulaArg {
@@ -13191,11 +15673,14 @@ func (fn *formulaFuncs) TIME(argsList *list.List) formulaArg {
 	if h.Type != ArgNumber || m.Type != ArgNumber || s.Type != ArgNumber {
 		return newErrorFormulaArg(formulaErrorVALUE, "TIME requires 3 number arguments")
 	}
-	t := (h.Number*3600 + m.Number*60 + s.Number) / 86400
+	if h.Number > 32767 || m.Number > 32767 || s.Number > 32767 {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+	}
+	t := math.Trunc(h.Number)*3600 + math.Trunc(m.Number)*60 + math.Trunc(s.Number)
 	if t < 0 {
 		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	return newNumberFormulaArg(t)
+	return newNumberFormulaArg(math.Mod(t, 86400) / 86400)
 }
 
 // TIMEVALUE function converts a text representation of a time, into an Excel
@@ -13242,7 +15727,9 @@ func (fn *formulaFuncs) TODAY(argsList *list.List) formulaArg {
 }
 
 // makeDate return date as a Unix time, the number of seconds elapsed since
-// January 1, 1970 UTC.
+// January 1, 1970 UTC. Dates in January and February 1900 are shifted back by
+// one day to keep compatible with Excel, which treats 1900 as a leap year, so
+// the serial number 60 stands for the nonexistent date February 29, 1900.
 func makeDate(y int, m time.Month, d int) int64 {
 	if y == 1900 && int(m) <= 2 {
 		d--
@@ -13251,6 +15738,27 @@ func makeDate(y int, m time.Month, d int) int64 {
 	return date.Unix()
 }
 
+// dateSerial returns the serial number of the given date. The dates before
+// March 1, 1900 are counted by the proleptic Gregorian calendar if the
+// GregorianDates option is enabled, otherwise by the Excel compatible
+// calendar which has February 29, 1900.
+func (fn *formulaFuncs) dateSerial(y int, m time.Month, d int) float64 {
+	if fn.ctx != nil && fn.ctx.gregorianDates {
+		return daysBetween(excelMinTime1900.Unix(), time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix()) + 1
+	}
+	return daysBetween(excelMinTime1900.Unix(), makeDate(y, m, d)) + 1
+}
+
+// serialTime converts the serial number to the date time, it's the inverse of
+// the dateSerial function.
+func (fn *formulaFuncs) serialTime(serial float64) time.Time {
+	if fn.ctx != nil && fn.ctx.gregorianDates && serial < 61 {
+		days := math.Floor(serial)
+		return excelMinTime1900.AddDate(0, 0, int(days)-1).Add(time.Duration(math.Round((serial-days)*86400)) * time.Second)
+	}
+	return timeFromExcelTime(serial, false)
+}
+
 // daysBetween return time interval of the given start timestamp and end
 // timestamp.
 func daysBetween(startDate, endDate int64) float64 {
@@ -13258,7 +15766,9 @@ func daysBetween(startDate, endDate int64) float64 {
 }
 
 // WEEKDAY function returns an integer representing the day of the week for a
-// supplied date. The syntax of the function is:
+// supplied date. The return_type 1, 2, 3 and 11 to 17 specify the first day
+// of the week, and the other return types will return #NUM! error. The syntax
+// of the function is:
 //
 //	WEEKDAY(serial_number,[return_type])
 func (fn *formulaFuncs) WEEKDAY(argsList *list.List) formulaArg {
@@ -13287,7 +15797,7 @@ func (fn *formulaFuncs) WEEKDAY(argsList *list.List) formulaArg {
 		if num.Number < 0 {
 			return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 		}
-		weekday = int(timeFromExcelTime(num.Number, false).Weekday())
+		weekday = int(fn.serialTime(num.Number).Weekday())
 	}
 	if argsList.Len() == 2 {
 		returnTypeArg := argsList.Back().Value.(formulaArg).ToNumber()
@@ -13309,7 +15819,7 @@ func (fn *formulaFuncs) WEEKDAY(argsList *list.List) formulaArg {
 	if returnType >= 11 && returnType <= 17 {
 		return newNumberFormulaArg(float64((weekday+6-(returnType-10))%7 + 1))
 	}
-	return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+	return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 }
 
 // weeknum is an implementation of the formula function WEEKNUM.
@@ -13450,13 +15960,30 @@ func (fn *formulaFuncs) ARRAYTOTEXT(argsList *list.List) formulaArg {
 	return newStringFormulaArg(strings.Join(text, ", "))
 }
 
+// ASC function converts full-width (double-byte) characters to half-width
+// (single-byte) characters, such as the Latin letters, digits and katakana.
+// The characters without a half-width counterpart are returned unchanged.
+// The syntax of the function is:
+//
+//	ASC(text)
+func (fn *formulaFuncs) ASC(argsList *list.List) formulaArg {
+	if argsCount := checkFormulaArgsCount("ASC", argsList); argsCount.Type == ArgError {
+		return argsCount
+	}
+	text := argsList.Front().Value.(formulaArg)
+	if text.Type == ArgError {
+		return text
+	}
+	return newStringFormulaArg(width.Narrow.String(text.Value()))
+}
+
 // CHAR function returns the character relating to a supplied character set
 // number (from 1 to 255). The syntax of the function is:
 //
 //	CHAR(number)
 func (fn *formulaFuncs) CHAR(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "CHAR requires 1 argument")
+	if argsCount := checkFormulaArgsCount("CHAR", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	arg := argsList.Front().Value.(formulaArg).ToNumber()
 	if arg.Type != ArgNumber {
@@ -13469,17 +15996,18 @@ func (fn *formulaFuncs) CHAR(argsList *list.List) formulaArg {
 	return newStringFormulaArg(fmt.Sprintf("%c", num))
 }
 
-// CLEAN removes all non-printable characters from a supplied text string. The
+// CLEAN removes all non-printable characters from a supplied text string,
+// including the C0 and C1 control characters and the DEL character. The
 // syntax of the function is:
 //
 //	CLEAN(text)
 func (fn *formulaFuncs) CLEAN(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "CLEAN requires 1 argument")
+	if argsCount := checkFormulaArgsCount("CLEAN", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	b := bytes.Buffer{}
 	for _, c := range argsList.Front().Value.(formulaArg).Value() {
-		if c > 31 {
+		if !unicode.IsControl(c) {
 			b.WriteRune(c)
 		}
 	}
@@ -13507,6 +16035,10 @@ func (fn *formulaFuncs) code(name string, argsList *list.List) formulaArg {
 		}
 		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 	}
+	if name == "UNICODE" {
+		r, _ := utf8.DecodeRuneInString(text)
+		return newNumberFormulaArg(float64(r))
+	}
 	return newNumberFormulaArg(float64(text[0]))
 }
 
@@ -13541,14 +16073,31 @@ func (fn *formulaFuncs) concat(name string, argsList *list.List) formulaArg {
 	return newStringFormulaArg(buf.String())
 }
 
+// DBCS function converts half-width (single-byte) characters to full-width
+// (double-byte) characters, such as the Latin letters, digits and katakana.
+// The characters without a full-width counterpart are returned unchanged.
+// The syntax of the function is:
+//
+//	DBCS(text)
+func (fn *formulaFuncs) DBCS(argsList *list.List) formulaArg {
+	if argsCount := checkFormulaArgsCount("DBCS", argsList); argsCount.Type == ArgError {
+		return argsCount
+	}
+	text := argsList.Front().Value.(formulaArg)
+	if text.Type == ArgError {
+		return text
+	}
+	return newStringFormulaArg(width.Widen.String(text.Value()))
+}
+
 // EXACT function tests if two supplied text strings or values are exactly
 // equal and if so, returns TRUE; Otherwise, the function returns FALSE. The
 // function is case-sensitive. The syntax of the function is:
 //
 //	EXACT(text1,text2)
 func (fn *formulaFuncs) EXACT(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "EXACT requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("EXACT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	text1 := argsList.Front().Value.(formulaArg).Value()
 	text2 := argsList.Back().Value.(formulaArg).Value()
@@ -13556,15 +16105,15 @@ func (fn *formulaFuncs) EXACT(argsList *list.List) formulaArg {
 }
 
 // FIXED function rounds a supplied number to a specified number of decimal
-// places and then converts this into text. The syntax of the function is:
+// places and then converts this into text. The number will be rounded to the
+// left of the decimal point if the decimals is negative, and the thousands
+// separators will be omitted if the no_commas is TRUE. The syntax of the
+// function is:
 //
 //	FIXED(number,[decimals],[no_commas])
 func (fn *formulaFuncs) FIXED(argsList *list.List) formulaArg {
-	if argsList.Len() < 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "FIXED requires at least 1 argument")
-	}
-	if argsList.Len() > 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "FIXED allows at most 3 arguments")
+	if argsCount := checkFormulaArgsCount("FIXED", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	numArg := argsList.Front().Value.(formulaArg).ToNumber()
 	if numArg.Type != ArgNumber {
@@ -13584,15 +16133,20 @@ func (fn *formulaFuncs) FIXED(argsList *list.List) formulaArg {
 		decimals = int(decimalsArg.Number)
 	}
 	if argsList.Len() == 3 {
-		noCommasArg := argsList.Back().Value.(formulaArg).ToBool()
+		noCommasArg := argsList.Back().Value.(formulaArg)
+		if noCommasArg.Type != ArgNumber {
+			noCommasArg = noCommasArg.ToBool()
+		}
 		if noCommasArg.Type == ArgError {
 			return noCommasArg
 		}
-		noCommas = noCommasArg.Boolean
+		noCommas = noCommasArg.Number != 0
 	}
 	n := math.Pow(10, float64(decimals))
-	r := numArg.Number * n
-	fixed := float64(int(r+math.Copysign(0.5, r))) / n
+	fixed := math.Round(numArg.Number*n) / n
+	if fixed == 0 {
+		fixed = 0 // avoid the negative zero
+	}
 	if decimals > 0 {
 		precision = decimals
 	}
@@ -13659,27 +16213,57 @@ func (fn *formulaFuncs) find(name string, argsList *list.List) formulaArg {
 		return newNumberFormulaArg(float64(startNum))
 	}
 	dbcs, search := name == "FINDB" || name == "SEARCHB", name == "SEARCH" || name == "SEARCHB"
+	if dbcs {
+		startNum = dbcsToCharPosition(withinText, startNum)
+	}
+	text := withinText
 	if search {
-		findText, withinText = strings.ToUpper(findText), strings.ToUpper(withinText)
+		findText, text = strings.ToUpper(findText), strings.ToUpper(withinText)
 	}
-	offset, ok := matchPattern(findText, withinText, dbcs, startNum)
+	offset, ok := matchPattern(findText, text, dbcs, startNum)
 	if !ok {
 		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 	}
-	result := offset
 	if dbcs {
-		var pre int
-		for idx := range withinText {
-			if pre > offset {
-				break
-			}
-			if idx-pre > 1 {
-				result++
-			}
-			pre = idx
+		return newNumberFormulaArg(float64(charToDBCSPosition(withinText, offset)))
+	}
+	return newNumberFormulaArg(float64(offset))
+}
+
+// dbcsCharWidth returns the width of the character in the double-byte
+// character set, the multi-byte characters are counted as 2.
+func dbcsCharWidth(r rune) int {
+	if utf8.RuneLen(r) > 1 {
+		return 2
+	}
+	return 1
+}
+
+// charToDBCSPosition converts the 1-based character position in the text to
+// the 1-based byte position in the double-byte character set.
+func charToDBCSPosition(text string, pos int) int {
+	result := 1
+	for i, r := range []rune(text) {
+		if i+1 >= pos {
+			break
 		}
+		result += dbcsCharWidth(r)
 	}
-	return newNumberFormulaArg(float64(result))
+	return result
+}
+
+// dbcsToCharPosition converts the 1-based byte position in the double-byte
+// character set to the 1-based position of the first character starting at
+// or after it in the text.
+func dbcsToCharPosition(text string, pos int) int {
+	runes, start := []rune(text), 1
+	for i, r := range runes {
+		if pos <= start {
+			return i + 1
+		}
+		start += dbcsCharWidth(r)
+	}
+	return len(runes) + 1
 }
 
 // LEFT function returns a specified number of characters from the start of a
@@ -13719,14 +16303,20 @@ func (fn *formulaFuncs) leftRight(name string, argsList *list.List) formulaArg {
 		numChars = int(numArg.Number)
 	}
 	if name == "LEFTB" || name == "RIGHTB" {
-		if len(text) > numChars {
-			if name == "LEFTB" {
-				return newStringFormulaArg(text[:numChars])
+		runes, cnt := []rune(text), 0
+		if name == "LEFTB" {
+			idx := 0
+			for ; idx < len(runes) && cnt+dbcsCharWidth(runes[idx]) <= numChars; idx++ {
+				cnt += dbcsCharWidth(runes[idx])
 			}
-			// RIGHTB
-			return newStringFormulaArg(text[len(text)-numChars:])
+			return newStringFormulaArg(string(runes[:idx]))
 		}
-		return newStringFormulaArg(text)
+		// RIGHTB
+		idx := len(runes)
+		for ; idx > 0 && cnt+dbcsCharWidth(runes[idx-1]) <= numChars; idx-- {
+			cnt += dbcsCharWidth(runes[idx-1])
+		}
+		return newStringFormulaArg(string(runes[idx:]))
 	}
 	// LEFT/RIGHT
 	if utf8.RuneCountInString(text) > numChars {
@@ -13777,8 +16367,8 @@ func (fn *formulaFuncs) LENB(argsList *list.List) formulaArg {
 //
 //	LOWER(text)
 func (fn *formulaFuncs) LOWER(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "LOWER requires 1 argument")
+	if argsCount := checkFormulaArgsCount("LOWER", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return newStringFormulaArg(strings.ToLower(argsList.Front().Value.(formulaArg).String))
 }
@@ -13818,33 +16408,20 @@ func (fn *formulaFuncs) mid(name string, argsList *list.List) formulaArg {
 		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 	}
 	if name == "MIDB" {
-		var result string
+		var result strings.Builder
 		var cnt, offset int
 		for _, char := range text {
-			offset++
-			var dbcs bool
-			if utf8.RuneLen(char) > 1 {
-				dbcs = true
-				offset++
+			width := dbcsCharWidth(char)
+			if offset += width; offset < startNum {
+				continue
 			}
-			if cnt == int(numCharsArg.Number) {
+			if cnt+width > int(numCharsArg.Number) {
 				break
 			}
-			if offset+1 > startNum {
-				if dbcs {
-					if cnt+2 > int(numCharsArg.Number) {
-						result += string(char)[:1]
-						break
-					}
-					result += string(char)
-					cnt += 2
-				} else {
-					result += string(char)
-					cnt++
-				}
-			}
+			result.WriteRune(char)
+			cnt += width
 		}
-		return newStringFormulaArg(result)
+		return newStringFormulaArg(result.String())
 	}
 	// MID
 	textLen := utf8.RuneCountInString(text)
@@ -13853,12 +16430,55 @@ func (fn *formulaFuncs) mid(name string, argsList *list.List) formulaArg {
 	}
 	startNum--
 	endNum := startNum + int(numCharsArg.Number)
-	if endNum > textLen+1 {
+	if endNum > textLen {
 		return newStringFormulaArg(string([]rune(text)[startNum:]))
 	}
 	return newStringFormulaArg(string([]rune(text)[startNum:endNum]))
 }
 
+// PHONETIC function extracts the phonetic (furigana) characters from the
+// text string of the referenced cell, the plain text of the cell will be
+// returned if the cell has no phonetic text runs. The syntax of the function
+// is:
+//
+//	PHONETIC(reference)
+func (fn *formulaFuncs) PHONETIC(argsList *list.List) formulaArg {
+	if argsCount := checkFormulaArgsCount("PHONETIC", argsList); argsCount.Type == ArgError {
+		return argsCount
+	}
+	sheet, col, row := fn.sheet, 0, 0
+	refs := argsList.Front().Value.(formulaArg).cellRefs
+	if refs != nil && refs.Len() > 0 {
+		ref := refs.Front().Value.(cellRef)
+		col, row = ref.Col, ref.Row
+		if ref.Sheet != "" {
+			sheet = ref.Sheet
+		}
+	}
+	ranges := argsList.Front().Value.(formulaArg).cellRanges
+	if ranges != nil && ranges.Len() > 0 {
+		cr := ranges.Front().Value.(cellRange)
+		col, row = cr.From.Col, cr.From.Row
+		if cr.From.Sheet != "" {
+			sheet = cr.From.Sheet
+		}
+	}
+	cell, err := CoordinatesToCellName(col, row)
+	if err != nil {
+		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+	}
+	phonetic, ok, err := fn.f.getCellPhonetic(sheet, cell)
+	if err != nil {
+		return newErrorFormulaArg(formulaErrorVALUE, err.Error())
+	}
+	if !ok {
+		if phonetic, err = fn.f.GetCellValue(sheet, cell); err != nil {
+			return newErrorFormulaArg(formulaErrorVALUE, err.Error())
+		}
+	}
+	return newStringFormulaArg(phonetic)
+}
+
 // PROPER converts all characters in a supplied text string to proper case
 // (i.e. all letters that do not immediately follow another letter are set to
 // upper case and all other characters are lower case). The syntax of the
@@ -13866,8 +16486,8 @@ func (fn *formulaFuncs) mid(name string, argsList *list.List) formulaArg {
 //
 //	PROPER(text)
 func (fn *formulaFuncs) PROPER(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PROPER requires 1 argument")
+	if argsCount := checkFormulaArgsCount("PROPER", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	buf := bytes.Buffer{}
 	isLetter := false
@@ -13929,12 +16549,13 @@ func (fn *formulaFuncs) replace(name string, argsList *list.List) formulaArg {
 }
 
 // REPT function returns a supplied text string, repeated a specified number
-// of times. The syntax of the function is:
+// of times. The number of times will be truncated to an integer, and the
+// result can't exceed 32767 characters. The syntax of the function is:
 //
 //	REPT(text,number_times)
 func (fn *formulaFuncs) REPT(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "REPT requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("REPT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	text := argsList.Front().Value.(formulaArg)
 	if text.Type != ArgString {
@@ -13947,9 +16568,13 @@ func (fn *formulaFuncs) REPT(argsList *list.List) formulaArg {
 	if times.Number < 0 {
 		return newErrorFormulaArg(formulaErrorVALUE, "REPT requires second argument to be >= 0")
 	}
-	if times.Number == 0 {
+	times.Number = math.Trunc(times.Number)
+	if times.Number == 0 || text.String == "" {
 		return newStringFormulaArg("")
 	}
+	if float64(utf8.RuneCountInString(text.String))*times.Number > TotalCellChars {
+		return newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("REPT function exceeds %d characters", TotalCellChars))
+	}
 	buf := bytes.Buffer{}
 	for i := 0; i < int(times.Number); i++ {
 		buf.WriteString(text.String)
@@ -13991,16 +16616,22 @@ func (fn *formulaFuncs) SEARCHB(argsList *list.List) formulaArg {
 }
 
 // SUBSTITUTE function replaces one or more instances of a given text string,
-// within an original text string. The syntax of the function is:
+// within an original text string. The matching is case-sensitive, only the
+// specified occurrence will be replaced if the instance_num is provided, and
+// the original text will be returned unchanged if the old_text is empty. The
+// syntax of the function is:
 //
 //	SUBSTITUTE(text,old_text,new_text,[instance_num])
 func (fn *formulaFuncs) SUBSTITUTE(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 && argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "SUBSTITUTE requires 3 or 4 arguments")
+	if argsCount := checkFormulaArgsCount("SUBSTITUTE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	text, sourceText := argsList.Front().Value.(formulaArg), argsList.Front().Next().Value.(formulaArg)
 	targetText, instanceNum := argsList.Front().Next().Next().Value.(formulaArg), 0
 	if argsList.Len() == 3 {
+		if sourceText.Value() == "" {
+			return newStringFormulaArg(text.Value())
+		}
 		return newStringFormulaArg(strings.ReplaceAll(text.Value(), sourceText.Value(), targetText.Value()))
 	}
 	instanceNumArg := argsList.Back().Value.(formulaArg).ToNumber()
@@ -14011,6 +16642,9 @@ func (fn *formulaFuncs) SUBSTITUTE(argsList *list.List) formulaArg {
 	if instanceNum < 1 {
 		return newErrorFormulaArg(formulaErrorVALUE, "instance_num should be > 0")
 	}
+	if sourceText.Value() == "" {
+		return newStringFormulaArg(text.Value())
+	}
 	str, sourceTextLen, count, chars, pos := text.Value(), len(sourceText.Value()), instanceNum, 0, -1
 	for {
 		count--
@@ -14035,13 +16669,103 @@ func (fn *formulaFuncs) SUBSTITUTE(argsList *list.List) formulaArg {
 	return newStringFormulaArg(pre + targetText.Value() + post)
 }
 
+var (
+	// textFmtScientific matches the scientific format code, like 0.00E+00
+	textFmtScientific = regexp.MustCompile(`^0(?:\.(0+))?[Ee]([+-])(0+)$`)
+	// textFmtFraction matches the fraction format code, like # ?/? or # ?/8
+	textFmtFraction = regexp.MustCompile(`^(#+ )?(\?+)/(\?+|\d+)$`)
+	// textFmtThousandsScaled matches the number format code with trailing
+	// thousands separators, like #,##0,
+	textFmtThousandsScaled = regexp.MustCompile(`^([#0,.]*[#0])(,+)$`)
+)
+
+// textScientific formats the number by given scientific format code sub
+// matches: the mantissa decimal places, the exponent sign and digits.
+func textScientific(number float64, subMatch []string) string {
+	var exp int
+	mantissa := math.Abs(number)
+	if mantissa != 0 {
+		exp = int(math.Floor(math.Log10(mantissa)))
+		mantissa /= math.Pow10(exp)
+		if rounded, _ := strconv.ParseFloat(strconv.FormatFloat(mantissa, 'f', len(subMatch[1]), 64), 64); rounded >= 10 {
+			mantissa, exp = mantissa/10, exp+1
+		}
+	}
+	sign, expSign := "", subMatch[2]
+	if number < 0 {
+		sign = "-"
+	}
+	if exp < 0 {
+		expSign, exp = "-", -exp
+	} else if expSign == "-" {
+		expSign = ""
+	}
+	return fmt.Sprintf("%s%.*fE%s%0*d", sign, len(subMatch[1]), mantissa, expSign, len(subMatch[3]), exp)
+}
+
+// textFraction formats the number by given fraction format code sub matches:
+// the integer part placeholder, the numerator placeholder and the
+// denominator placeholder or fixed denominator. The fractional part will be
+// approximated with the denominator which has no more digits than the
+// denominator placeholders.
+func textFraction(number float64, subMatch []string) string {
+	sign, abs := "", math.Abs(number)
+	if number < 0 {
+		sign = "-"
+	}
+	whole, frac := 0.0, abs
+	if subMatch[1] != "" {
+		whole, frac = math.Modf(abs)
+	}
+	num, den := 0.0, 1.0
+	if fixed, err := strconv.Atoi(subMatch[3]); err == nil && fixed > 0 {
+		num, den = math.Round(frac*float64(fixed)), float64(fixed)
+	} else {
+		diff := math.Inf(1)
+		for d := 1.0; d < math.Pow10(len(subMatch[3])); d++ {
+			n := math.Round(frac * d)
+			if e := math.Abs(frac - n/d); e < diff {
+				num, den, diff = n, d, e
+			}
+		}
+	}
+	if subMatch[1] != "" && num == den {
+		whole, num = whole+1, 0
+	}
+	if num == 0 {
+		return sign + strconv.FormatFloat(whole, 'f', -1, 64)
+	}
+	fraction := fmt.Sprintf("%s/%s", strconv.FormatFloat(num, 'f', -1, 64), strconv.FormatFloat(den, 'f', -1, 64))
+	if whole == 0 {
+		return sign + fraction
+	}
+	return fmt.Sprintf("%s%s %s", sign, strconv.FormatFloat(whole, 'f', -1, 64), fraction)
+}
+
+// textNumberFormat formats the number by the scientific, fraction and
+// thousands scaled format codes for the formula function TEXT, the boolean
+// result indicates whether the format code has been applied.
+func textNumberFormat(number float64, fmtCode string) (string, bool) {
+	if subMatch := textFmtScientific.FindStringSubmatch(fmtCode); subMatch != nil {
+		return textScientific(number, subMatch), true
+	}
+	if subMatch := textFmtFraction.FindStringSubmatch(fmtCode); subMatch != nil {
+		return textFraction(number, subMatch), true
+	}
+	if subMatch := textFmtThousandsScaled.FindStringSubmatch(fmtCode); subMatch != nil {
+		scaled := number / math.Pow(1000, float64(len(subMatch[2])))
+		return format(strconv.FormatFloat(scaled, 'f', -1, 64), subMatch[1], false, CellTypeNumber, nil), true
+	}
+	return "", false
+}
+
 // TEXT function converts a supplied numeric value into text, in a
 // user-specified format. The syntax of the function is:
 //
 //	TEXT(value,format_text)
 func (fn *formulaFuncs) TEXT(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TEXT requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("TEXT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	value, fmtText := argsList.Front().Value.(formulaArg), argsList.Back().Value.(formulaArg)
 	if value.Type == ArgError {
@@ -14053,6 +16777,8 @@ func (fn *formulaFuncs) TEXT(argsList *list.List) formulaArg {
 	cellType := CellTypeNumber
 	if num := value.ToNumber(); num.Type != ArgNumber {
 		cellType = CellTypeSharedString
+	} else if result, ok := textNumberFormat(num.Number, fmtText.Value()); ok {
+		return newStringFormulaArg(result)
 	}
 	return newStringFormulaArg(format(value.Value(), fmtText.Value(), false, cellType, nil))
 }
@@ -14277,25 +17003,27 @@ func textJoin(arg *list.Element, arr []string, ignoreEmpty bool) ([]string, form
 //
 //	TRIM(text)
 func (fn *formulaFuncs) TRIM(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TRIM requires 1 argument")
+	if argsCount := checkFormulaArgsCount("TRIM", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return newStringFormulaArg(strings.TrimSpace(argsList.Front().Value.(formulaArg).Value()))
 }
 
 // UNICHAR returns the Unicode character that is referenced by the given
-// numeric value. The syntax of the function is:
+// numeric value, the code points above the basic multilingual plane are
+// supported, and the surrogate code points are invalid. The syntax of the
+// function is:
 //
 //	UNICHAR(number)
 func (fn *formulaFuncs) UNICHAR(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "UNICHAR requires 1 argument")
+	if argsCount := checkFormulaArgsCount("UNICHAR", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	numArg := argsList.Front().Value.(formulaArg).ToNumber()
 	if numArg.Type != ArgNumber {
 		return numArg
 	}
-	if numArg.Number <= 0 || numArg.Number > 55295 {
+	if numArg.Number <= 0 || numArg.Number > unicode.MaxRune || !utf8.ValidRune(rune(numArg.Number)) {
 		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 	}
 	return newStringFormulaArg(string(rune(numArg.Number)))
@@ -14314,8 +17042,8 @@ func (fn *formulaFuncs) UNICODE(argsList *list.List) formulaArg {
 //
 //	UPPER(text)
 func (fn *formulaFuncs) UPPER(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "UPPER requires 1 argument")
+	if argsCount := checkFormulaArgsCount("UPPER", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	return newStringFormulaArg(strings.ToUpper(argsList.Front().Value.(formulaArg).String))
 }
@@ -14325,8 +17053,8 @@ func (fn *formulaFuncs) UPPER(argsList *list.List) formulaArg {
 //
 //	VALUE(text)
 func (fn *formulaFuncs) VALUE(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "VALUE requires 1 argument")
+	if argsCount := checkFormulaArgsCount("VALUE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	text := strings.ReplaceAll(argsList.Front().Value.(formulaArg).Value(), ",", "")
 	percent := 1.0
@@ -14399,7 +17127,9 @@ func (fn *formulaFuncs) IF(argsList *list.List) formulaArg {
 			return newErrorFormulaArg(formulaErrorVALUE, err.Error())
 		}
 	case ArgNumber:
-		cond = token.Number == 1
+		cond = token.Number != 0
+	case ArgError:
+		return token
 	}
 
 	if argsList.Len() == 1 {
@@ -14470,7 +17200,7 @@ func (fn *formulaFuncs) ADDRESS(argsList *list.List) formulaArg {
 	}
 	var sheetText string
 	if argsList.Len() == 5 {
-		sheetText = fmt.Sprintf("%s!", argsList.Back().Value.(formulaArg).Value())
+		sheetText = fmt.Sprintf("%s!", quoteSheetName(argsList.Back().Value.(formulaArg).Value()))
 	}
 	formatter := addressFmtMaps[fmt.Sprintf("%d_%s", int(absNum.Number), a1.Value())]
 	addr, err := formatter(int(colNum.Number), int(rowNum.Number))
@@ -14480,14 +17210,113 @@ func (fn *formulaFuncs) ADDRESS(argsList *list.List) formulaArg {
 	return newStringFormulaArg(fmt.Sprintf("%s%s", sheetText, addr))
 }
 
+// ANCHORARRAY function returns the spilled range of the dynamic array formula
+// in the given anchor cell, it's the stored form of the spilled range
+// reference operator (#), such as A1#. The #REF! error will be returned if the
+// anchor cell doesn't have a spilled dynamic array result. The syntax of the
+// function is:
+//
+//	ANCHORARRAY(reference)
+func (fn *formulaFuncs) ANCHORARRAY(argsList *list.List) formulaArg {
+	if argsCount := checkFormulaArgsCount("ANCHORARRAY", argsList); argsCount.Type == ArgError {
+		return argsCount
+	}
+	arg := argsList.Front().Value.(formulaArg)
+	if fn.f == nil || arg.cellRefs == nil || arg.cellRefs.Len() != 1 ||
+		(arg.cellRanges != nil && arg.cellRanges.Len() != 0) {
+		return newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
+	}
+	ref := arg.cellRefs.Front().Value.(cellRef)
+	if ref.Sheet == "" {
+		ref.Sheet = fn.sheet
+	}
+	cell, err := CoordinatesToCellName(ref.Col, ref.Row)
+	if err != nil || (ref.Sheet == fn.sheet && cell == fn.cell) {
+		return newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
+	}
+	if formula, _ := fn.f.GetCellFormula(ref.Sheet, cell); formula == "" {
+		return newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
+	}
+	result, err := fn.f.spillResolver(fn.ctx, ref.Sheet, cell)
+	if err != nil || result.Type != ArgMatrix || len(result.Matrix) == 0 || len(result.Matrix[0]) == 0 {
+		return newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
+	}
+	result, _ = fn.f.checkSpillRange(ref.Sheet, cell, result)
+	if result.Type != ArgMatrix {
+		return newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
+	}
+	to := cellRef{Col: ref.Col + len(result.Matrix[0]) - 1, Row: ref.Row + len(result.Matrix) - 1, Sheet: ref.Sheet}
+	result.cellRefs, result.cellRanges = list.New(), list.New()
+	result.cellRanges.PushBack(cellRange{From: ref, To: to})
+	return result
+}
+
+// unquotedSheetName matches the sheet name which can be used in a reference
+// without single quotes.
+var unquotedSheetName = regexp.MustCompile(`^[A-Za-z_\p{L}][\w.\p{L}]*$`)
+
+// quoteSheetName wraps the sheet name in single quotes if it contains spaces,
+// special characters, starts with a digit or looks like a cell reference, and
+// the single quotes in the sheet name will be doubled.
+func quoteSheetName(name string) string {
+	if name == "" {
+		return name
+	}
+	if _, _, err := CellNameToCoordinates(name); err != nil && unquotedSheetName.MatchString(name) {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+// chooseMatrix is an implementation of the formula function CHOOSE with an
+// array index, which picks the value for each element of the index and
+// returns an array result. The array values will be picked by the same
+// position of the element, and a single row or column will be broadcast.
+func chooseMatrix(index formulaArg, argsList *list.List) formulaArg {
+	var values []formulaArg
+	for arg := argsList.Front().Next(); arg != nil; arg = arg.Next() {
+		values = append(values, arg.Value.(formulaArg))
+	}
+	var rows, cols int
+	for _, arg := range append([]formulaArg{index}, values...) {
+		if arg.Type != ArgMatrix {
+			continue
+		}
+		if len(arg.Matrix) > rows {
+			rows = len(arg.Matrix)
+		}
+		for _, row := range arg.Matrix {
+			if len(row) > cols {
+				cols = len(row)
+			}
+		}
+	}
+	matrix := make([][]formulaArg, rows)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			idx := matrixOperandElement(index, r, c).ToNumber()
+			if idx.Type != ArgNumber || int(idx.Number) < 1 || int(idx.Number) > len(values) {
+				matrix[r] = append(matrix[r], newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE))
+				continue
+			}
+			matrix[r] = append(matrix[r], matrixOperandElement(values[int(idx.Number)-1], r, c))
+		}
+	}
+	return newMatrixFormulaArg(matrix)
+}
+
 // CHOOSE function returns a value from an array, that corresponds to a
-// supplied index number (position). The syntax of the function is:
+// supplied index number (position). An array result will be returned if the
+// index_num is an array. The syntax of the function is:
 //
 //	CHOOSE(index_num,value1,[value2],...)
 func (fn *formulaFuncs) CHOOSE(argsList *list.List) formulaArg {
 	if argsList.Len() < 2 {
 		return newErrorFormulaArg(formulaErrorVALUE, "CHOOSE requires 2 arguments")
 	}
+	if index := argsList.Front().Value.(formulaArg); index.Type == ArgMatrix {
+		return chooseMatrix(index, argsList)
+	}
 	idx, err := strconv.Atoi(argsList.Front().Value.(formulaArg).Value())
 	if err != nil {
 		return newErrorFormulaArg(formulaErrorVALUE, "CHOOSE requires first argument of type number")
@@ -14638,8 +17467,35 @@ func compareFormulaArgMatrix(lhs, rhs, matchMode formulaArg, caseSensitive bool)
 	return criteriaEq
 }
 
-// COLUMN function returns the first column number within a supplied reference
-// or the number of the current column. The syntax of the function is:
+// rowColNumbers returns the row or column numbers within a supplied cell range
+// as an array, it's an implementation of the formula functions ROW and
+// COLUMN. The row numbers will be returned as a vertical array and the column
+// numbers will be returned as a horizontal array, and a number will be
+// returned if the cell range contains only one row or column.
+func rowColNumbers(cols bool, cr cellRange) formulaArg {
+	from, to := cr.From.Row, cr.To.Row
+	if cols {
+		from, to = cr.From.Col, cr.To.Col
+	}
+	if from == to {
+		return newNumberFormulaArg(float64(from))
+	}
+	var mtx [][]formulaArg
+	for i := from; i <= to; i++ {
+		if cols {
+			if len(mtx) == 0 {
+				mtx = append(mtx, []formulaArg{})
+			}
+			mtx[0] = append(mtx[0], newNumberFormulaArg(float64(i)))
+			continue
+		}
+		mtx = append(mtx, []formulaArg{newNumberFormulaArg(float64(i))})
+	}
+	return newMatrixFormulaArg(mtx)
+}
+
+// COLUMN function returns the column numbers within a supplied reference or
+// the number of the current column. The syntax of the function is:
 //
 //	COLUMN([reference])
 func (fn *formulaFuncs) COLUMN(argsList *list.List) formulaArg {
@@ -14648,7 +17504,7 @@ func (fn *formulaFuncs) COLUMN(argsList *list.List) formulaArg {
 	}
 	if argsList.Len() == 1 {
 		if argsList.Front().Value.(formulaArg).cellRanges != nil && argsList.Front().Value.(formulaArg).cellRanges.Len() > 0 {
-			return newNumberFormulaArg(float64(argsList.Front().Value.(formulaArg).cellRanges.Front().Value.(cellRange).From.Col))
+			return rowColNumbers(true, argsList.Front().Value.(formulaArg).cellRanges.Front().Value.(cellRange))
 		}
 		if argsList.Front().Value.(formulaArg).cellRefs != nil && argsList.Front().Value.(formulaArg).cellRefs.Len() > 0 {
 			return newNumberFormulaArg(float64(argsList.Front().Value.(formulaArg).cellRefs.Front().Value.(cellRef).Col))
@@ -14693,13 +17549,27 @@ func calcColsRowsMinMax(cols bool, argsList *list.List) (min, max int) {
 	return
 }
 
+// calcArrayRowsCols returns the number of the rows and columns of the given
+// array argument which is not a cell reference, such as the array constant or
+// the array returned by the formula function.
+func calcArrayRowsCols(arg formulaArg) (rows, cols int, ok bool) {
+	if arg.Type != ArgMatrix || len(arg.Matrix) == 0 ||
+		(arg.cellRanges != nil && arg.cellRanges.Len() > 0) || (arg.cellRefs != nil && arg.cellRefs.Len() > 0) {
+		return
+	}
+	return len(arg.Matrix), len(arg.Matrix[0]), true
+}
+
 // COLUMNS function receives an Excel range and returns the number of columns
 // that are contained within the range. The syntax of the function is:
 //
 //	COLUMNS(array)
 func (fn *formulaFuncs) COLUMNS(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "COLUMNS requires 1 argument")
+	if argsCount := checkFormulaArgsCount("COLUMNS", argsList); argsCount.Type == ArgError {
+		return argsCount
+	}
+	if _, cols, ok := calcArrayRowsCols(argsList.Front().Value.(formulaArg)); ok {
+		return newNumberFormulaArg(float64(cols))
 	}
 	min, max := calcColsRowsMinMax(true, argsList)
 	if max == MaxColumns {
@@ -14720,8 +17590,8 @@ func (fn *formulaFuncs) COLUMNS(argsList *list.List) formulaArg {
 //
 //	FORMULATEXT(reference)
 func (fn *formulaFuncs) FORMULATEXT(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "FORMULATEXT requires 1 argument")
+	if argsCount := checkFormulaArgsCount("FORMULATEXT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	refs := argsList.Front().Value.(formulaArg).cellRefs
 	col, row := 0, 0
@@ -14824,19 +17694,37 @@ func (fn *formulaFuncs) HYPERLINK(argsList *list.List) formulaArg {
 	return newStringFormulaArg(argsList.Back().Value.(formulaArg).Value())
 }
 
-// calcMatch returns the position of the value by given match type, criteria
-// and lookup array for the formula function MATCH.
-func calcMatch(matchType int, criteria *formulaCriteria, lookupArray []formulaArg) formulaArg {
-	idx := -1
+// calcMatch returns the position of the value by given match type, lookup
+// value and lookup array for the formula function MATCH. The exact match
+// type is case-insensitive and supports the wildcard characters like XMATCH,
+// and the other match types assume that the lookup array is sorted.
+func calcMatch(matchType int, lookupValue formulaArg, lookupArray []formulaArg) formulaArg {
+	idx, criteria := -1, formulaCriteriaParser(lookupValue)
 	switch matchType {
 	case 0:
+		// the wildcard pattern is anchored to the whole text
+		var exp *regexp.Regexp
+		if lookupValue.Type == ArgString {
+			pattern, _ := matchPatternToRegExp(strings.ToLower(lookupValue.String), false)
+			exp, _ = regexp.Compile(pattern + "$")
+		}
+		matchMode := newNumberFormulaArg(matchModeExact)
 		for i, arg := range lookupArray {
-			if ok, _ := formulaCriteriaEval(arg, criteria); ok {
+			if exp != nil && arg.Type == ArgString {
+				if exp.MatchString(strings.ToLower(arg.String)) {
+					return newNumberFormulaArg(float64(i + 1))
+				}
+				continue
+			}
+			if compareFormulaArg(arg, lookupValue, matchMode, false) == criteriaEq {
 				return newNumberFormulaArg(float64(i + 1))
 			}
 		}
 	case -1:
 		for i, arg := range lookupArray {
+			if arg.Type == ArgEmpty {
+				continue
+			}
 			if ok, _ := formulaCriteriaEval(arg, &formulaCriteria{
 				Type: criteriaGe, Condition: criteria.Condition,
 			}); ok {
@@ -14849,6 +17737,9 @@ func calcMatch(matchType int, criteria *formulaCriteria, lookupArray []formulaAr
 		}
 	case 1:
 		for i, arg := range lookupArray {
+			if arg.Type == ArgEmpty {
+				continue
+			}
 			if ok, _ := formulaCriteriaEval(arg, &formulaCriteria{
 				Type: criteriaLe, Condition: criteria.Condition,
 			}); ok {
@@ -14861,7 +17752,7 @@ func calcMatch(matchType int, criteria *formulaCriteria, lookupArray []formulaAr
 		}
 	}
 	if idx == -1 {
-		return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
+		return newErrorFormulaArg(formulaErrorNA, "MATCH no result found")
 	}
 	return newNumberFormulaArg(float64(idx + 1))
 }
@@ -14870,7 +17761,9 @@ func calcMatch(matchType int, criteria *formulaCriteria, lookupArray []formulaAr
 // the value within the array. The user can specify that the function should
 // only return a result if an exact match is found, or that the function
 // should return the position of the closest match (above or below), if an
-// exact match is not found. The syntax of the Match function is:
+// exact match is not found. The exact match supports the wildcard characters
+// ? and *, and the other match types assume that the lookup array is sorted.
+// The syntax of the Match function is:
 //
 //	MATCH(lookup_value,lookup_array,[match_type])
 func (fn *formulaFuncs) MATCH(argsList *list.List) formulaArg {
@@ -14901,7 +17794,7 @@ func (fn *formulaFuncs) MATCH(argsList *list.List) formulaArg {
 	default:
 		return newErrorFormulaArg(formulaErrorNA, lookupArrayErr)
 	}
-	return calcMatch(matchType, formulaCriteriaParser(argsList.Front().Value.(formulaArg)), lookupArray)
+	return calcMatch(matchType, argsList.Front().Value.(formulaArg), lookupArray)
 }
 
 // TRANSPOSE function 'transposes' an array of cells (i.e. the function copies
@@ -14910,26 +17803,31 @@ func (fn *formulaFuncs) MATCH(argsList *list.List) formulaArg {
 //
 //	TRANSPOSE(array)
 func (fn *formulaFuncs) TRANSPOSE(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TRANSPOSE requires 1 argument")
+	if argsCount := checkFormulaArgsCount("TRANSPOSE", argsList); argsCount.Type == ArgError {
+		return argsCount
+	}
+	arg := argsList.Back().Value.(formulaArg)
+	if arg.Type == ArgList {
+		arg = newMatrixFormulaArg([][]formulaArg{arg.List})
+	}
+	if arg.Type != ArgMatrix {
+		return arg
 	}
-	args := argsList.Back().Value.(formulaArg).ToList()
-	rmin, rmax := calcColsRowsMinMax(false, argsList)
-	cmin, cmax := calcColsRowsMinMax(true, argsList)
-	cols, rows := cmax-cmin+1, rmax-rmin+1
-	src := make([][]formulaArg, 0)
-	for i := 0; i < len(args); i += cols {
-		src = append(src, args[i:i+cols])
+	var cols int
+	for _, row := range arg.Matrix {
+		if len(row) > cols {
+			cols = len(row)
+		}
 	}
 	mtx := make([][]formulaArg, cols)
-	for r, row := range src {
-		colIdx := r % rows
-		for c, cell := range row {
-			rowIdx := c % cols
-			if len(mtx[rowIdx]) == 0 {
-				mtx[rowIdx] = make([]formulaArg, rows)
+	for c := range mtx {
+		mtx[c] = make([]formulaArg, len(arg.Matrix))
+		for r, row := range arg.Matrix {
+			if c < len(row) {
+				mtx[c][r] = row[c]
+				continue
 			}
-			mtx[rowIdx][colIdx] = cell
+			mtx[c][r] = newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
 		}
 	}
 	return newMatrixFormulaArg(mtx)
@@ -14938,14 +17836,7 @@ func (fn *formulaFuncs) TRANSPOSE(argsList *list.List) formulaArg {
 // lookupLinearSearch sequentially checks each look value of the lookup array until
 // a match is found or the whole list has been searched.
 func lookupLinearSearch(vertical bool, lookupValue, lookupArray, matchMode, searchMode formulaArg) (int, bool) {
-	var tableArray []formulaArg
-	if vertical {
-		for _, row := range lookupArray.Matrix {
-			tableArray = append(tableArray, row[0])
-		}
-	} else {
-		tableArray = lookupArray.Matrix[0]
-	}
+	tableArray := lookupValues(vertical, lookupArray)
 	matchIdx, wasExact := -1, false
 start:
 	for i, cell := range tableArray {
@@ -14967,7 +17858,7 @@ start:
 			}
 		}
 		if matchMode.Number == matchModeMinGreater || matchMode.Number == matchModeMaxLess {
-			matchIdx = int(calcMatch(int(matchMode.Number), formulaCriteriaParser(lookupValue), tableArray).Number)
+			matchIdx = int(calcMatch(int(matchMode.Number), lookupValue, tableArray).Number)
 			continue
 		}
 	}
@@ -15004,33 +17895,46 @@ func (fn *formulaFuncs) VLOOKUP(argsList *list.List) formulaArg {
 	return newErrorFormulaArg(formulaErrorNA, "VLOOKUP no result found")
 }
 
-// lookupBinarySearch finds the position of a target value when range lookup
-// is TRUE, if the data of table array can't guarantee be sorted, it will
-// return wrong result.
-func lookupBinarySearch(vertical bool, lookupValue, lookupArray, matchMode, searchMode formulaArg) (matchIdx int, wasExact bool) {
+// lookupValues returns the look values of the lookup array, which is the first
+// column for the vertical lookup, otherwise the first row.
+func lookupValues(vertical bool, lookupArray formulaArg) []formulaArg {
 	var tableArray []formulaArg
 	if vertical {
 		for _, row := range lookupArray.Matrix {
 			tableArray = append(tableArray, row[0])
 		}
-	} else {
-		tableArray = lookupArray.Matrix[0]
+		return tableArray
+	}
+	return lookupArray.Matrix[0]
+}
+
+// prepareLookupCell converts the look value of the lookup array to the type of
+// the lookup value for the comparison in the binary search.
+func prepareLookupCell(vertical bool, cell, lookupValue, lookupArray formulaArg) formulaArg {
+	lhs := cell
+	if lookupValue.Type == ArgNumber {
+		if lhs = cell.ToNumber(); lhs.Type == ArgError {
+			lhs = cell
+		}
+	} else if lookupValue.Type == ArgMatrix && vertical {
+		lhs = lookupArray
+	} else if lookupValue.Type == ArgString {
+		lhs = newStringFormulaArg(cell.Value())
 	}
+	return lhs
+}
+
+// lookupBinarySearch finds the position of a target value when range lookup
+// is TRUE, if the data of table array can't guarantee be sorted, it will
+// return wrong result.
+func lookupBinarySearch(vertical bool, lookupValue, lookupArray, matchMode, searchMode formulaArg) (matchIdx int, wasExact bool) {
+	tableArray := lookupValues(vertical, lookupArray)
 	low, high, lastMatchIdx := 0, len(tableArray)-1, -1
 	count := high
 	for low <= high {
 		mid := low + (high-low)/2
 		cell := tableArray[mid]
-		lhs := cell
-		if lookupValue.Type == ArgNumber {
-			if lhs = cell.ToNumber(); lhs.Type == ArgError {
-				lhs = cell
-			}
-		} else if lookupValue.Type == ArgMatrix && vertical {
-			lhs = lookupArray
-		} else if lookupValue.Type == ArgString {
-			lhs = newStringFormulaArg(cell.Value())
-		}
+		lhs := prepareLookupCell(vertical, cell, lookupValue, lookupArray)
 		result := compareFormulaArg(lhs, lookupValue, matchMode, false)
 		if result == criteriaEq {
 			matchIdx, wasExact = mid, true
@@ -15081,7 +17985,7 @@ func checkLookupArgs(argsList *list.List) (arrayForm bool, lookupValue, lookupVe
 // iterateLookupArgs iterate arguments to extract columns and calculate match
 // index for the formula function LOOKUP.
 func iterateLookupArgs(lookupValue, lookupVector formulaArg) ([]formulaArg, int, bool) {
-	cols, matchIdx, ok := lookupCol(lookupVector, 0), -1, false
+	cols, matchIdx, ok := lookupVectorCells(lookupVector, false), -1, false
 	for idx, col := range cols {
 		lhs := lookupValue
 		switch col.Type {
@@ -15159,7 +18063,7 @@ func (fn *formulaFuncs) prepareXlookupArgs(argsList *list.List) formulaArg {
 	lookupValue := argsList.Front().Value.(formulaArg)
 	lookupArray := argsList.Front().Next().Value.(formulaArg)
 	returnArray := argsList.Front().Next().Next().Value.(formulaArg)
-	ifNotFond := newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
+	ifNotFond := newErrorFormulaArg(formulaErrorNA, "XLOOKUP no result found")
 	matchMode, searchMode := newNumberFormulaArg(matchModeExact), newNumberFormulaArg(searchModeLinear)
 	if argsList.Len() > 3 {
 		ifNotFond = argsList.Front().Next().Next().Next().Value.(formulaArg)
@@ -15269,12 +18173,13 @@ func (fn *formulaFuncs) XLOOKUP(argsList *list.List) formulaArg {
 }
 
 // INDEX function returns a reference to a cell that lies in a specified row
-// and column of a range of cells. The syntax of the function is:
+// and column of a range of cells. The entire column or row will be returned
+// as an array if the row_num or col_num is 0. The syntax of the function is:
 //
 //	INDEX(array,row_num,[col_num])
 func (fn *formulaFuncs) INDEX(argsList *list.List) formulaArg {
-	if argsList.Len() < 2 || argsList.Len() > 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "INDEX requires 2 or 3 arguments")
+	if argsCount := checkFormulaArgsCount("INDEX", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	array := argsList.Front().Value.(formulaArg)
 	if array.Type != ArgMatrix && array.Type != ArgList {
@@ -15299,22 +18204,30 @@ func (fn *formulaFuncs) INDEX(argsList *list.List) formulaArg {
 		return array.ToList()[0]
 	}
 	cells := fn.index(array, rowIdx, colIdx)
+	if cells.Type == ArgMatrix && len(cells.Matrix) == 1 {
+		return cells.Matrix[0][0]
+	}
 	if cells.Type != ArgList {
 		return cells
 	}
 	if colIdx == -1 {
+		if len(cells.List) == 1 {
+			return cells.List[0]
+		}
 		return newMatrixFormulaArg([][]formulaArg{cells.List})
 	}
 	return cells.List[colIdx]
 }
 
-// INDIRECT function converts a text string into a cell reference. The syntax
-// of the Indirect function is:
+// INDIRECT function converts a text string into a cell reference. The
+// reference text could be an A1 or R1C1 style reference with or without the
+// worksheet name, and the #REF! error will be returned for the invalid
+// reference text. The syntax of the Indirect function is:
 //
 //	INDIRECT(ref_text,[a1])
 func (fn *formulaFuncs) INDIRECT(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 && argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "INDIRECT requires 1 or 2 arguments")
+	if argsCount := checkFormulaArgsCount("INDIRECT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	refText := argsList.Front().Value.(formulaArg).Value()
 	a1 := newBoolFormulaArg(true)
@@ -15323,57 +18236,71 @@ func (fn *formulaFuncs) INDIRECT(argsList *list.List) formulaArg {
 			return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 		}
 	}
-	R1C1ToA1 := func(ref string) (cell string, err error) {
-		parts := strings.Split(strings.TrimLeft(ref, "R"), "C")
-		if len(parts) != 2 {
-			return
-		}
-		row, err := strconv.Atoi(parts[0])
-		if err != nil {
-			return
-		}
-		col, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return
-		}
-		cell, err = CoordinatesToCellName(col, row)
-		return
+	var sheetText string
+	if idx := strings.LastIndex(refText, "!"); idx != -1 {
+		sheetText, refText = strings.Trim(refText[:idx], "'")+"!", refText[idx+1:]
 	}
 	refs := strings.Split(refText, ":")
-	fromRef, toRef := refs[0], ""
-	if len(refs) == 2 {
-		toRef = refs[1]
+	if len(refs) > 2 {
+		return newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
 	}
 	if a1.Number == 0 {
-		from, err := R1C1ToA1(refs[0])
+		col, row, err := CellNameToCoordinates(fn.cell)
 		if err != nil {
 			return newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
 		}
-		fromRef = from
-		if len(refs) == 2 {
-			to, err := R1C1ToA1(refs[1])
-			if err != nil {
+		for i, ref := range refs {
+			if refs[i], err = r1c1ToA1(ref, col, row); err != nil {
 				return newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
 			}
-			toRef = to
 		}
 	}
-	if len(refs) == 1 {
-		value, err := fn.f.GetCellValue(fn.sheet, fromRef)
+	arg, err := fn.f.parseReference(fn.ctx, fn.sheet, sheetText+strings.Join(refs, ":"))
+	if err != nil {
+		return newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
+	}
+	return arg
+}
+
+// r1c1Reference matches the R1C1 style cell reference, like R1C1 or R[-1]C
+var r1c1Reference = regexp.MustCompile(`^[Rr](\[-?\d+\]|\d+)?[Cc](\[-?\d+\]|\d+)?$`)
+
+// r1c1ToA1 converts the R1C1 style reference to the A1 style reference, the
+// relative row and column in square brackets are based on the given column
+// and row number of the current cell. For example, convert R[1]C2 in the
+// cell A1 to the B2.
+func r1c1ToA1(ref string, col, row int) (string, error) {
+	match := r1c1Reference.FindStringSubmatch(ref)
+	if match == nil {
+		return "", newInvalidCellNameError(ref)
+	}
+	coordinates := []int{row, col}
+	for i, part := range match[1:] {
+		if part == "" {
+			continue
+		}
+		num, err := strconv.Atoi(strings.Trim(part, "[]"))
 		if err != nil {
-			return newErrorFormulaArg(formulaErrorREF, formulaErrorREF)
+			return "", err
 		}
-		return newStringFormulaArg(value)
+		if strings.HasPrefix(part, "[") {
+			coordinates[i] += num
+			continue
+		}
+		coordinates[i] = num
 	}
-	arg, _ := fn.f.parseReference(fn.ctx, fn.sheet, fromRef+":"+toRef)
-	return arg
+	return CoordinatesToCellName(coordinates[1], coordinates[0])
 }
 
 // LOOKUP function performs an approximate match lookup in a one-column or
 // one-row range, and returns the corresponding value from another one-column
-// or one-row range. The syntax of the function is:
+// or one-row range. In the array form, the function searches in the first row
+// of the array if it is wider than it is tall, otherwise in the first column,
+// and returns the corresponding value from the last row or column. The syntax
+// of the function is:
 //
 //	LOOKUP(lookup_value,lookup_vector,[result_vector])
+//	LOOKUP(lookup_value,array)
 func (fn *formulaFuncs) LOOKUP(argsList *list.List) formulaArg {
 	arrayForm, lookupValue, lookupVector, errArg := checkLookupArgs(argsList)
 	if errArg.Type == ArgError {
@@ -15385,9 +18312,9 @@ func (fn *formulaFuncs) LOOKUP(argsList *list.List) formulaArg {
 	}
 	var column []formulaArg
 	if argsList.Len() == 3 {
-		column = lookupCol(argsList.Back().Value.(formulaArg), 0)
-	} else if arrayForm && len(lookupVector.Matrix[0]) > 1 {
-		column = lookupCol(lookupVector, 1)
+		column = lookupVectorCells(argsList.Back().Value.(formulaArg), false)
+	} else if arrayForm {
+		column = lookupVectorCells(lookupVector, true)
 	} else {
 		column = cols
 	}
@@ -15397,6 +18324,24 @@ func (fn *formulaFuncs) LOOKUP(argsList *list.List) formulaArg {
 	return column[matchIdx]
 }
 
+// lookupVectorCells extract the first or last row of the array if it is wider
+// than it is tall, otherwise extract the first or last column for LOOKUP.
+func lookupVectorCells(arr formulaArg, last bool) []formulaArg {
+	if arr.Type != ArgMatrix || len(arr.Matrix) == 0 {
+		return lookupCol(arr, 0)
+	}
+	if rows, cols := len(arr.Matrix), len(arr.Matrix[0]); cols > rows {
+		if last {
+			return arr.Matrix[rows-1]
+		}
+		return arr.Matrix[0]
+	}
+	if last {
+		return lookupCol(arr, len(arr.Matrix[0])-1)
+	}
+	return lookupCol(arr, 0)
+}
+
 // lookupCol extract columns for LOOKUP.
 func lookupCol(arr formulaArg, idx int) []formulaArg {
 	col := arr.List
@@ -15413,8 +18358,8 @@ func lookupCol(arr formulaArg, idx int) []formulaArg {
 	return col
 }
 
-// ROW function returns the first row number within a supplied reference or
-// the number of the current row. The syntax of the function is:
+// ROW function returns the row numbers within a supplied reference or the
+// number of the current row. The syntax of the function is:
 //
 //	ROW([reference])
 func (fn *formulaFuncs) ROW(argsList *list.List) formulaArg {
@@ -15423,7 +18368,7 @@ func (fn *formulaFuncs) ROW(argsList *list.List) formulaArg {
 	}
 	if argsList.Len() == 1 {
 		if argsList.Front().Value.(formulaArg).cellRanges != nil && argsList.Front().Value.(formulaArg).cellRanges.Len() > 0 {
-			return newNumberFormulaArg(float64(argsList.Front().Value.(formulaArg).cellRanges.Front().Value.(cellRange).From.Row))
+			return rowColNumbers(false, argsList.Front().Value.(formulaArg).cellRanges.Front().Value.(cellRange))
 		}
 		if argsList.Front().Value.(formulaArg).cellRefs != nil && argsList.Front().Value.(formulaArg).cellRefs.Len() > 0 {
 			return newNumberFormulaArg(float64(argsList.Front().Value.(formulaArg).cellRefs.Front().Value.(cellRef).Row))
@@ -15439,8 +18384,11 @@ func (fn *formulaFuncs) ROW(argsList *list.List) formulaArg {
 //
 //	ROWS(array)
 func (fn *formulaFuncs) ROWS(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ROWS requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ROWS", argsList); argsCount.Type == ArgError {
+		return argsCount
+	}
+	if rows, _, ok := calcArrayRowsCols(argsList.Front().Value.(formulaArg)); ok {
+		return newNumberFormulaArg(float64(rows))
 	}
 	min, max := calcColsRowsMinMax(false, argsList)
 	if max == TotalRows {
@@ -15464,8 +18412,8 @@ func (fn *formulaFuncs) ROWS(argsList *list.List) formulaArg {
 //
 //	ENCODEURL(url)
 func (fn *formulaFuncs) ENCODEURL(argsList *list.List) formulaArg {
-	if argsList.Len() != 1 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ENCODEURL requires 1 argument")
+	if argsCount := checkFormulaArgsCount("ENCODEURL", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	token := argsList.Front().Value.(formulaArg).Value()
 	return newStringFormulaArg(strings.ReplaceAll(url.QueryEscape(token), "+", "%20"))
@@ -15478,16 +18426,20 @@ func validateFrequency(freq float64) bool {
 	return freq == 1 || freq == 2 || freq == 4
 }
 
+// validateBasis check the day count basis if be one of the US (NASD) 30/360,
+// actual/actual, actual/360, actual/365 and European 30/360, the fractional
+// part of the basis will be truncated.
+func validateBasis(basis float64) bool {
+	return basis >= 0 && basis < 5
+}
+
 // ACCRINT function returns the accrued interest in a security that pays
 // periodic interest. The syntax of the function is:
 //
 //	ACCRINT(issue,first_interest,settlement,rate,par,frequency,[basis],[calc_method])
 func (fn *formulaFuncs) ACCRINT(argsList *list.List) formulaArg {
-	if argsList.Len() < 6 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ACCRINT requires at least 6 arguments")
-	}
-	if argsList.Len() > 8 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ACCRINT allows at most 8 arguments")
+	if argsCount := checkFormulaArgsCount("ACCRINT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareDataValueArgs(3, argsList)
 	if args.Type != ArgList {
@@ -15526,8 +18478,8 @@ func (fn *formulaFuncs) ACCRINT(argsList *list.List) formulaArg {
 //
 //	ACCRINTM(issue,settlement,rate,[par],[basis])
 func (fn *formulaFuncs) ACCRINTM(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 && argsList.Len() != 5 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ACCRINTM requires 4 or 5 arguments")
+	if argsCount := checkFormulaArgsCount("ACCRINTM", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareDataValueArgs(2, argsList)
 	if args.Type != ArgList {
@@ -15613,8 +18565,8 @@ func (fn *formulaFuncs) prepareAmorArgs(name string, argsList *list.List) formul
 //
 //	AMORDEGRC(cost,date_purchased,first_period,salvage,period,rate,[basis])
 func (fn *formulaFuncs) AMORDEGRC(argsList *list.List) formulaArg {
-	if argsList.Len() != 6 && argsList.Len() != 7 {
-		return newErrorFormulaArg(formulaErrorVALUE, "AMORDEGRC requires 6 or 7 arguments")
+	if argsCount := checkFormulaArgsCount("AMORDEGRC", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareAmorArgs("AMORDEGRC", argsList)
 	if args.Type != ArgList {
@@ -15663,8 +18615,8 @@ func (fn *formulaFuncs) AMORDEGRC(argsList *list.List) formulaArg {
 //
 //	AMORLINC(cost,date_purchased,first_period,salvage,period,rate,[basis])
 func (fn *formulaFuncs) AMORLINC(argsList *list.List) formulaArg {
-	if argsList.Len() != 6 && argsList.Len() != 7 {
-		return newErrorFormulaArg(formulaErrorVALUE, "AMORLINC requires 6 or 7 arguments")
+	if argsCount := checkFormulaArgsCount("AMORLINC", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareAmorArgs("AMORLINC", argsList)
 	if args.Type != ArgList {
@@ -15716,6 +18668,9 @@ func (fn *formulaFuncs) prepareCouponArgs(name string, argsList *list.List) form
 		if basis = argsList.Back().Value.(formulaArg).ToNumber(); basis.Type != ArgNumber {
 			return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 		}
+		if !validateBasis(basis.Number) {
+			return newErrorFormulaArg(formulaErrorNUM, "invalid basis")
+		}
 	}
 	return newListFormulaArg([]formulaArg{settlement, maturity, frequency, basis})
 }
@@ -15814,8 +18769,8 @@ func (fn *formulaFuncs) COUPDAYS(argsList *list.List) formulaArg {
 	basis := int(args.List[3].Number)
 	if basis == 1 {
 		pcd := timeFromExcelTime(fn.COUPPCD(argsList).Number, false)
-		next := pcd.AddDate(0, 12/int(freq), 0)
-		return newNumberFormulaArg(coupdays(pcd, next, basis))
+		ncd := timeFromExcelTime(fn.COUPNCD(argsList).Number, false)
+		return newNumberFormulaArg(coupdays(pcd, ncd, basis))
 	}
 	return newNumberFormulaArg(float64(getYearDays(0, basis)) / freq)
 }
@@ -15831,42 +18786,53 @@ func (fn *formulaFuncs) COUPDAYSNC(argsList *list.List) formulaArg {
 	}
 	settlement := timeFromExcelTime(args.List[0].Number, false)
 	basis := int(args.List[3].Number)
+	if is30BasisMethod(basis) {
+		return newNumberFormulaArg(fn.COUPDAYS(argsList).Number - fn.COUPDAYBS(argsList).Number)
+	}
 	ncd := timeFromExcelTime(fn.COUPNCD(argsList).Number, false)
 	return newNumberFormulaArg(coupdays(settlement, ncd, basis))
 }
 
+// couponDate returns the coupon date which is the given number of months
+// before the maturity date. The coupon dates are always on the last day of
+// month when the maturity date is the last day of month.
+func couponDate(maturity time.Time, months int) time.Time {
+	date := time.Date(maturity.Year(), maturity.Month()-time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	day, days := maturity.Day(), getDaysInMonth(date.Year(), int(date.Month()))
+	if getDaysInMonth(maturity.Year(), int(maturity.Month())) == maturity.Day() || day > days {
+		day = days
+	}
+	return time.Date(date.Year(), date.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// couponDates returns the previous coupon date on or before the settlement
+// date, the next coupon date after the settlement date and the number of
+// coupons payable between the settlement date and maturity date.
+func couponDates(settlement, maturity time.Time, frequency int) (time.Time, time.Time, int) {
+	months := 12 / frequency
+	num := ((maturity.Year()-settlement.Year())*12 + int(maturity.Month()) - int(settlement.Month())) / months
+	pcd := couponDate(maturity, num*months)
+	for pcd.After(settlement) {
+		num++
+		pcd = couponDate(maturity, num*months)
+	}
+	ncd := couponDate(maturity, (num-1)*months)
+	for !ncd.After(settlement) {
+		num--
+		pcd, ncd = ncd, couponDate(maturity, (num-1)*months)
+	}
+	return pcd, ncd, num
+}
+
 // coupons is an implementation of the formula functions COUPNCD and COUPPCD.
 func (fn *formulaFuncs) coupons(name string, arg formulaArg) formulaArg {
 	settlement := timeFromExcelTime(arg.List[0].Number, false)
 	maturity := timeFromExcelTime(arg.List[1].Number, false)
-	maturityDays := (maturity.Year()-settlement.Year())*12 + (int(maturity.Month()) - int(settlement.Month()))
-	coupon := 12 / int(arg.List[2].Number)
-	mod := maturityDays % coupon
-	year := settlement.Year()
-	month := int(settlement.Month())
-	if mod == 0 && settlement.Day() >= maturity.Day() {
-		month += coupon
-	} else {
-		month += mod
-	}
-	if name != "COUPNCD" {
-		month -= coupon
-	}
-	if month > 11 {
-		year++
-		month -= 12
-	} else if month < 0 {
-		year--
-		month += 12
+	date, ncd, _ := couponDates(settlement, maturity, int(arg.List[2].Number))
+	if name == "COUPNCD" {
+		date = ncd
 	}
-	day, lastDay := maturity.Day(), time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	days := getDaysInMonth(lastDay.Year(), int(lastDay.Month()))
-	if getDaysInMonth(maturity.Year(), int(maturity.Month())) == maturity.Day() {
-		day = days
-	} else if day > 27 && day > days {
-		day = days
-	}
-	return newNumberFormulaArg(daysBetween(excelMinTime1900.Unix(), makeDate(year, time.Month(month), day)) + 1)
+	return newNumberFormulaArg(daysBetween(excelMinTime1900.Unix(), makeDate(date.Year(), date.Month(), date.Day())) + 1)
 }
 
 // COUPNCD function calculates the number of coupons payable, between a
@@ -15892,8 +18858,10 @@ func (fn *formulaFuncs) COUPNUM(argsList *list.List) formulaArg {
 	if args.Type != ArgList {
 		return args
 	}
-	frac := yearFrac(args.List[0].Number, args.List[1].Number, 0)
-	return newNumberFormulaArg(math.Ceil(frac.Number * args.List[2].Number))
+	settlement := timeFromExcelTime(args.List[0].Number, false)
+	maturity := timeFromExcelTime(args.List[1].Number, false)
+	_, _, num := couponDates(settlement, maturity, int(args.List[2].Number))
+	return newNumberFormulaArg(float64(num))
 }
 
 // COUPPCD function returns the previous coupon date, before the settlement
@@ -16211,18 +19179,22 @@ func (fn *formulaFuncs) dollar(name string, argsList *list.List) formulaArg {
 	if frac.Number < 0 {
 		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	if frac.Number == 0 {
+	// the fraction is truncated to an integer, so the values between 0 and 1
+	// are also treated as a zero denominator
+	denom := math.Trunc(frac.Number)
+	if denom == 0 {
 		return newErrorFormulaArg(formulaErrorDIV, formulaErrorDIV)
 	}
-	cents := math.Mod(dollar.Number, 1)
+	integer := math.Trunc(dollar.Number)
+	cents := dollar.Number - integer
 	if name == "DOLLARDE" {
-		cents /= frac.Number
-		cents *= math.Pow(10, math.Ceil(math.Log10(frac.Number)))
+		cents /= denom
+		cents *= math.Pow(10, math.Ceil(math.Log10(denom)))
 	} else {
-		cents *= frac.Number
-		cents *= math.Pow(10, -math.Ceil(math.Log10(frac.Number)))
+		cents *= denom
+		cents *= math.Pow(10, -math.Ceil(math.Log10(denom)))
 	}
-	return newNumberFormulaArg(math.Floor(dollar.Number) + cents)
+	return newNumberFormulaArg(integer + cents)
 }
 
 // prepareDurationArgs checking and prepare arguments for the formula
@@ -16265,6 +19237,9 @@ func (fn *formulaFuncs) prepareDurationArgs(name string, argsList *list.List) fo
 		if basis = argsList.Back().Value.(formulaArg).ToNumber(); basis.Type != ArgNumber {
 			return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 		}
+		if !validateBasis(basis.Number) {
+			return newErrorFormulaArg(formulaErrorNUM, "invalid basis")
+		}
 	}
 	return newListFormulaArg([]formulaArg{settlement, maturity, coupon, yld, frequency, basis})
 }
@@ -16320,8 +19295,8 @@ func (fn *formulaFuncs) DURATION(argsList *list.List) formulaArg {
 //
 //	EFFECT(nominal_rate,npery)
 func (fn *formulaFuncs) EFFECT(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "EFFECT requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("EFFECT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	rate := argsList.Front().Value.(formulaArg).ToNumber()
 	if rate.Type != ArgNumber {
@@ -16340,22 +19315,23 @@ func (fn *formulaFuncs) EFFECT(argsList *list.List) formulaArg {
 // EUROCONVERT function convert a number to euro or from euro to a
 // participating currency. You can also use it to convert a number from one
 // participating currency to another by using the euro as an intermediary
-// (triangulation). The syntax of the function is:
+// (triangulation). The result is rounded by the calculation precision of the
+// target currency unless the full precision is TRUE, and the intermediate euro
+// value is rounded to the number of decimal places specified by the
+// triangulation precision, which must be greater than or equal to 3. The
+// syntax of the function is:
 //
 //	EUROCONVERT(number,sourcecurrency,targetcurrency[,fullprecision,triangulationprecision])
 func (fn *formulaFuncs) EUROCONVERT(argsList *list.List) formulaArg {
-	if argsList.Len() < 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "EUROCONVERT requires at least 3 arguments")
-	}
-	if argsList.Len() > 5 {
-		return newErrorFormulaArg(formulaErrorVALUE, "EUROCONVERT allows at most 5 arguments")
+	if argsCount := checkFormulaArgsCount("EUROCONVERT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	number := argsList.Front().Value.(formulaArg).ToNumber()
 	if number.Type != ArgNumber {
 		return number
 	}
-	sourceCurrency := argsList.Front().Next().Value.(formulaArg).Value()
-	targetCurrency := argsList.Front().Next().Next().Value.(formulaArg).Value()
+	sourceCurrency := strings.ToUpper(argsList.Front().Next().Value.(formulaArg).Value())
+	targetCurrency := strings.ToUpper(argsList.Front().Next().Next().Value.(formulaArg).Value())
 	fullPrec, triangulationPrec := newBoolFormulaArg(false), newNumberFormulaArg(0)
 	if argsList.Len() >= 4 {
 		if fullPrec = argsList.Front().Next().Next().Next().Value.(formulaArg).ToBool(); fullPrec.Type != ArgNumber {
@@ -16366,7 +19342,11 @@ func (fn *formulaFuncs) EUROCONVERT(argsList *list.List) formulaArg {
 		if triangulationPrec = argsList.Back().Value.(formulaArg).ToNumber(); triangulationPrec.Type != ArgNumber {
 			return triangulationPrec
 		}
+		if triangulationPrec.Number = math.Trunc(triangulationPrec.Number); triangulationPrec.Number < 3 {
+			return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+		}
 	}
+	// the fixed conversion rates and the calculation precision of the currencies
 	convertTable := map[string][]float64{
 		"EUR": {1.0, 2},
 		"ATS": {13.7603, 2},
@@ -16379,8 +19359,8 @@ func (fn *formulaFuncs) EUROCONVERT(argsList *list.List) formulaArg {
 		"ITL": {1936.27, 0},
 		"LUF": {40.3399, 0},
 		"NLG": {2.20371, 2},
-		"PTE": {200.482, 2},
-		"GRD": {340.750, 2},
+		"PTE": {200.482, 1},
+		"GRD": {340.750, 0},
 		"SIT": {239.640, 2},
 		"MTL": {0.429300, 2},
 		"CYP": {0.585274, 2},
@@ -16424,11 +19404,8 @@ func (fn *formulaFuncs) EUROCONVERT(argsList *list.List) formulaArg {
 //
 //	FV(rate,nper,[pmt],[pv],[type])
 func (fn *formulaFuncs) FV(argsList *list.List) formulaArg {
-	if argsList.Len() < 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "FV requires at least 3 arguments")
-	}
-	if argsList.Len() > 5 {
-		return newErrorFormulaArg(formulaErrorVALUE, "FV allows at most 5 arguments")
+	if argsCount := checkFormulaArgsCount("FV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	rate := argsList.Front().Value.(formulaArg).ToNumber()
 	if rate.Type != ArgNumber {
@@ -16467,8 +19444,8 @@ func (fn *formulaFuncs) FV(argsList *list.List) formulaArg {
 //
 //	FVSCHEDULE(principal,schedule)
 func (fn *formulaFuncs) FVSCHEDULE(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "FVSCHEDULE requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("FVSCHEDULE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	pri := argsList.Front().Value.(formulaArg).ToNumber()
 	if pri.Type != ArgNumber {
@@ -16576,7 +19553,9 @@ func (fn *formulaFuncs) ipmt(name string, argsList *list.List) formulaArg {
 
 // IRR function returns the Internal Rate of Return for a supplied series of
 // periodic cash flows (i.e. an initial investment value and a series of net
-// income values). The syntax of the function is:
+// income values). The #NUM! error will be returned if the cash flows don't
+// contain both positive and negative values, or the calculation doesn't
+// converge. The syntax of the function is:
 //
 //	IRR(values,[guess])
 func (fn *formulaFuncs) IRR(argsList *list.List) formulaArg {
@@ -16591,6 +19570,17 @@ func (fn *formulaFuncs) IRR(argsList *list.List) formulaArg {
 		if guess = argsList.Back().Value.(formulaArg).ToNumber(); guess.Type != ArgNumber {
 			return guess
 		}
+		if guess.Number <= -1 {
+			return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+		}
+	}
+	for _, v := range values {
+		if v.Type == ArgError {
+			return v
+		}
+	}
+	if !hasPositiveAndNegative(values) {
+		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
 	x1, x2 := newNumberFormulaArg(0), guess
 	args := list.New().Init()
@@ -16637,10 +19627,26 @@ func (fn *formulaFuncs) IRR(argsList *list.List) formulaArg {
 			rtb = xMid
 		}
 		if math.Abs(fMid) < financialPrecision || math.Abs(dx) < financialPrecision {
-			break
+			return newNumberFormulaArg(xMid)
+		}
+	}
+	return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
+}
+
+// hasPositiveAndNegative checking if the given cash flows contain at least one
+// positive value and one negative value, the empty cells and text values will
+// be ignored.
+func hasPositiveAndNegative(values []formulaArg) bool {
+	var positive, negative bool
+	for _, v := range values {
+		if v.Type == ArgEmpty {
+			continue
+		}
+		if num := v.ToNumber(); num.Type == ArgNumber {
+			positive, negative = positive || num.Number > 0, negative || num.Number < 0
 		}
 	}
-	return newNumberFormulaArg(xMid)
+	return positive && negative
 }
 
 // ISPMT function calculates the interest paid during a specific period of a
@@ -16648,8 +19654,8 @@ func (fn *formulaFuncs) IRR(argsList *list.List) formulaArg {
 //
 //	ISPMT(rate,per,nper,pv)
 func (fn *formulaFuncs) ISPMT(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ISPMT requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("ISPMT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	rate := argsList.Front().Value.(formulaArg).ToNumber()
 	if rate.Type != ArgNumber {
@@ -16697,13 +19703,14 @@ func (fn *formulaFuncs) MDURATION(argsList *list.List) formulaArg {
 
 // MIRR function returns the Modified Internal Rate of Return for a supplied
 // series of periodic cash flows (i.e. a set of values, which includes an
-// initial investment value and a series of net income values). The syntax of
-// the function is:
+// initial investment value and a series of net income values). The #DIV/0!
+// error will be returned if the cash flows don't contain both positive and
+// negative values. The syntax of the function is:
 //
 //	MIRR(values,finance_rate,reinvest_rate)
 func (fn *formulaFuncs) MIRR(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "MIRR requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("MIRR", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	values := argsList.Front().Value.(formulaArg).ToList()
 	financeRate := argsList.Front().Next().Value.(formulaArg).ToNumber()
@@ -16714,6 +19721,9 @@ func (fn *formulaFuncs) MIRR(argsList *list.List) formulaArg {
 	if reinvestRate.Type != ArgNumber {
 		return reinvestRate
 	}
+	if !hasPositiveAndNegative(values) || reinvestRate.Number <= -1 {
+		return newErrorFormulaArg(formulaErrorDIV, formulaErrorDIV)
+	}
 	n, fr, rr, npvPos, npvNeg := len(values), 1+financeRate.Number, 1+reinvestRate.Number, 0.0, 0.0
 	for i, v := range values {
 		val := v.ToNumber()
@@ -16723,9 +19733,6 @@ func (fn *formulaFuncs) MIRR(argsList *list.List) formulaArg {
 		}
 		npvNeg += val.Number / math.Pow(fr, float64(i))
 	}
-	if npvNeg == 0 || npvPos == 0 || reinvestRate.Number <= -1 {
-		return newErrorFormulaArg(formulaErrorDIV, formulaErrorDIV)
-	}
 	return newNumberFormulaArg(math.Pow(-npvPos*math.Pow(rr, float64(n))/(npvNeg*rr), 1/(float64(n)-1)) - 1)
 }
 
@@ -16735,8 +19742,8 @@ func (fn *formulaFuncs) MIRR(argsList *list.List) formulaArg {
 //
 //	NOMINAL(effect_rate,npery)
 func (fn *formulaFuncs) NOMINAL(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "NOMINAL requires 2 arguments")
+	if argsCount := checkFormulaArgsCount("NOMINAL", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	rate := argsList.Front().Value.(formulaArg).ToNumber()
 	if rate.Type != ArgNumber {
@@ -16758,11 +19765,8 @@ func (fn *formulaFuncs) NOMINAL(argsList *list.List) formulaArg {
 //
 //	NPER(rate,pmt,pv,[fv],[type])
 func (fn *formulaFuncs) NPER(argsList *list.List) formulaArg {
-	if argsList.Len() < 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "NPER requires at least 3 arguments")
-	}
-	if argsList.Len() > 5 {
-		return newErrorFormulaArg(formulaErrorVALUE, "NPER allows at most 5 arguments")
+	if argsCount := checkFormulaArgsCount("NPER", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	rate := argsList.Front().Value.(formulaArg).ToNumber()
 	if rate.Type != ArgNumber {
@@ -16802,12 +19806,13 @@ func (fn *formulaFuncs) NPER(argsList *list.List) formulaArg {
 
 // NPV function calculates the Net Present Value of an investment, based on a
 // supplied discount rate, and a series of future payments and income. The
-// syntax of the function is:
+// empty cells and text values will be ignored. The syntax of the function
+// is:
 //
 //	NPV(rate,value1,[value2],[value3],...)
 func (fn *formulaFuncs) NPV(argsList *list.List) formulaArg {
-	if argsList.Len() < 2 {
-		return newErrorFormulaArg(formulaErrorVALUE, "NPV requires at least 2 arguments")
+	if argsCount := checkFormulaArgsCount("NPV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	rate := argsList.Front().Value.(formulaArg).ToNumber()
 	if rate.Type != ArgNumber {
@@ -16815,12 +19820,20 @@ func (fn *formulaFuncs) NPV(argsList *list.List) formulaArg {
 	}
 	val, i := 0.0, 1
 	for arg := argsList.Front().Next(); arg != nil; arg = arg.Next() {
-		num := arg.Value.(formulaArg).ToNumber()
-		if num.Type != ArgNumber {
-			continue
+		for _, cell := range arg.Value.(formulaArg).ToList() {
+			if cell.Type == ArgError {
+				return cell
+			}
+			if cell.Type == ArgEmpty {
+				continue
+			}
+			num := cell.ToNumber()
+			if num.Type != ArgNumber {
+				continue
+			}
+			val += num.Number / math.Pow(1+rate.Number, float64(i))
+			i++
 		}
-		val += num.Number / math.Pow(1+rate.Number, float64(i))
-		i++
 	}
 	return newNumberFormulaArg(val)
 }
@@ -16983,15 +19996,15 @@ func (fn *formulaFuncs) prepareOddfArgs(name string, argsList *list.List) formul
 //
 //	ODDFPRICE(settlement,maturity,issue,first_coupon,rate,yld,redemption,frequency,[basis])
 func (fn *formulaFuncs) ODDFPRICE(argsList *list.List) formulaArg {
-	if argsList.Len() != 8 && argsList.Len() != 9 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ODDFPRICE requires 8 or 9 arguments")
+	if argsCount := checkFormulaArgsCount("ODDFPRICE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareOddfArgs("ODDFPRICE", argsList)
 	if args.Type != ArgList {
 		return args
 	}
 	settlement, maturity, issue, firstCoupon, rate, yld, redemption, frequency, basisArg := args.List[0], args.List[1], args.List[2], args.List[3], args.List[4], args.List[5], args.List[6], args.List[7], args.List[8]
-	if basisArg.Number < 0 || basisArg.Number > 4 {
+	if !validateBasis(basisArg.Number) {
 		return newErrorFormulaArg(formulaErrorNUM, "invalid basis")
 	}
 	issueTime := timeFromExcelTime(issue.Number, false)
@@ -17127,15 +20140,15 @@ func getODDFPRICE(f func(yld float64) float64, x, cnt, prec float64) float64 {
 //
 //	ODDFYIELD(settlement,maturity,issue,first_coupon,rate,pr,redemption,frequency,[basis])
 func (fn *formulaFuncs) ODDFYIELD(argsList *list.List) formulaArg {
-	if argsList.Len() != 8 && argsList.Len() != 9 {
-		return newErrorFormulaArg(formulaErrorVALUE, "ODDFYIELD requires 8 or 9 arguments")
+	if argsCount := checkFormulaArgsCount("ODDFYIELD", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareOddfArgs("ODDFYIELD", argsList)
 	if args.Type != ArgList {
 		return args
 	}
 	settlement, maturity, issue, firstCoupon, rate, pr, redemption, frequency, basisArg := args.List[0], args.List[1], args.List[2], args.List[3], args.List[4], args.List[5], args.List[6], args.List[7], args.List[8]
-	if basisArg.Number < 0 || basisArg.Number > 4 {
+	if !validateBasis(basisArg.Number) {
 		return newErrorFormulaArg(formulaErrorNUM, "invalid basis")
 	}
 	settlementTime := timeFromExcelTime(settlement.Number, false)
@@ -17222,7 +20235,7 @@ func (fn *formulaFuncs) oddl(name string, argsList *list.List) formulaArg {
 		return args
 	}
 	settlement, maturity, lastInterest, rate, prOrYld, redemption, frequency, basisArg := args.List[0], args.List[1], args.List[2], args.List[3], args.List[4], args.List[5], args.List[6], args.List[7]
-	if basisArg.Number < 0 || basisArg.Number > 4 {
+	if !validateBasis(basisArg.Number) {
 		return newErrorFormulaArg(formulaErrorNUM, "invalid basis")
 	}
 	settlementTime := timeFromExcelTime(settlement.Number, false)
@@ -17304,8 +20317,8 @@ func (fn *formulaFuncs) ODDLYIELD(argsList *list.List) formulaArg {
 //
 //	PDURATION(rate,pv,fv)
 func (fn *formulaFuncs) PDURATION(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PDURATION requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("PDURATION", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	rate := argsList.Front().Value.(formulaArg).ToNumber()
 	if rate.Type != ArgNumber {
@@ -17331,11 +20344,8 @@ func (fn *formulaFuncs) PDURATION(argsList *list.List) formulaArg {
 //
 //	PMT(rate,nper,pv,[fv],[type])
 func (fn *formulaFuncs) PMT(argsList *list.List) formulaArg {
-	if argsList.Len() < 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PMT requires at least 3 arguments")
-	}
-	if argsList.Len() > 5 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PMT allows at most 5 arguments")
+	if argsCount := checkFormulaArgsCount("PMT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	rate := argsList.Front().Value.(formulaArg).ToNumber()
 	if rate.Type != ArgNumber {
@@ -17381,7 +20391,7 @@ func (fn *formulaFuncs) PPMT(argsList *list.List) formulaArg {
 
 // price is an implementation of the formula function PRICE.
 func (fn *formulaFuncs) price(settlement, maturity, rate, yld, redemption, frequency, basis formulaArg) formulaArg {
-	if basis.Number < 0 || basis.Number > 4 {
+	if !validateBasis(basis.Number) {
 		return newErrorFormulaArg(formulaErrorNUM, "invalid basis")
 	}
 	argsList := list.New().Init()
@@ -17474,6 +20484,9 @@ func (fn *formulaFuncs) priceYield(name string, argsList *list.List) formulaArg
 		if basis = argsList.Back().Value.(formulaArg).ToNumber(); basis.Type != ArgNumber {
 			return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 		}
+		if !validateBasis(basis.Number) {
+			return newErrorFormulaArg(formulaErrorNUM, "invalid basis")
+		}
 	}
 	if name == "PRICE" {
 		return fn.price(settlement, maturity, rate, prYld, redemption, frequency, basis)
@@ -17494,8 +20507,8 @@ func (fn *formulaFuncs) PRICE(argsList *list.List) formulaArg {
 //
 //	PRICEDISC(settlement,maturity,discount,redemption,[basis])
 func (fn *formulaFuncs) PRICEDISC(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 && argsList.Len() != 5 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PRICEDISC requires 4 or 5 arguments")
+	if argsCount := checkFormulaArgsCount("PRICEDISC", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareDataValueArgs(2, argsList)
 	if args.Type != ArgList {
@@ -17537,8 +20550,8 @@ func (fn *formulaFuncs) PRICEDISC(argsList *list.List) formulaArg {
 //
 //	PRICEMAT(settlement,maturity,issue,rate,yld,[basis])
 func (fn *formulaFuncs) PRICEMAT(argsList *list.List) formulaArg {
-	if argsList.Len() != 5 && argsList.Len() != 6 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PRICEMAT requires 5 or 6 arguments")
+	if argsCount := checkFormulaArgsCount("PRICEMAT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareDataValueArgs(3, argsList)
 	if args.Type != ArgList {
@@ -17585,11 +20598,8 @@ func (fn *formulaFuncs) PRICEMAT(argsList *list.List) formulaArg {
 //
 //	PV(rate,nper,pmt,[fv],[type])
 func (fn *formulaFuncs) PV(argsList *list.List) formulaArg {
-	if argsList.Len() < 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PV requires at least 3 arguments")
-	}
-	if argsList.Len() > 5 {
-		return newErrorFormulaArg(formulaErrorVALUE, "PV allows at most 5 arguments")
+	if argsCount := checkFormulaArgsCount("PV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	rate := argsList.Front().Value.(formulaArg).ToNumber()
 	if rate.Type != ArgNumber {
@@ -17653,11 +20663,8 @@ func (fn *formulaFuncs) rate(nper, pmt, pv, fv, t, guess formulaArg) formulaArg
 //
 //	RATE(nper,pmt,pv,[fv],[type],[guess])
 func (fn *formulaFuncs) RATE(argsList *list.List) formulaArg {
-	if argsList.Len() < 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "RATE requires at least 3 arguments")
-	}
-	if argsList.Len() > 6 {
-		return newErrorFormulaArg(formulaErrorVALUE, "RATE allows at most 6 arguments")
+	if argsCount := checkFormulaArgsCount("RATE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	nper := argsList.Front().Value.(formulaArg).ToNumber()
 	if nper.Type != ArgNumber {
@@ -17741,8 +20748,8 @@ func (fn *formulaFuncs) RECEIVED(argsList *list.List) formulaArg {
 //
 //	RRI(nper,pv,fv)
 func (fn *formulaFuncs) RRI(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "RRI requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("RRI", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	nper := argsList.Front().Value.(formulaArg).ToNumber()
 	pv := argsList.Front().Next().Value.(formulaArg).ToNumber()
@@ -17767,8 +20774,8 @@ func (fn *formulaFuncs) RRI(argsList *list.List) formulaArg {
 //
 //	SLN(cost,salvage,life)
 func (fn *formulaFuncs) SLN(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "SLN requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("SLN", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	cost := argsList.Front().Value.(formulaArg).ToNumber()
 	salvage := argsList.Front().Next().Value.(formulaArg).ToNumber()
@@ -17788,8 +20795,8 @@ func (fn *formulaFuncs) SLN(argsList *list.List) formulaArg {
 //
 //	SYD(cost,salvage,life,per)
 func (fn *formulaFuncs) SYD(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 {
-		return newErrorFormulaArg(formulaErrorVALUE, "SYD requires 4 arguments")
+	if argsCount := checkFormulaArgsCount("SYD", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	cost := argsList.Front().Value.(formulaArg).ToNumber()
 	salvage := argsList.Front().Next().Value.(formulaArg).ToNumber()
@@ -17815,8 +20822,8 @@ func (fn *formulaFuncs) SYD(argsList *list.List) formulaArg {
 //
 //	TBILLEQ(settlement,maturity,discount)
 func (fn *formulaFuncs) TBILLEQ(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TBILLEQ requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("TBILLEQ", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareDataValueArgs(2, argsList)
 	if args.Type != ArgList {
@@ -17842,8 +20849,8 @@ func (fn *formulaFuncs) TBILLEQ(argsList *list.List) formulaArg {
 //
 //	TBILLPRICE(settlement,maturity,discount)
 func (fn *formulaFuncs) TBILLPRICE(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TBILLPRICE requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("TBILLPRICE", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareDataValueArgs(2, argsList)
 	if args.Type != ArgList {
@@ -17869,8 +20876,8 @@ func (fn *formulaFuncs) TBILLPRICE(argsList *list.List) formulaArg {
 //
 //	TBILLYIELD(settlement,maturity,pr)
 func (fn *formulaFuncs) TBILLYIELD(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "TBILLYIELD requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("TBILLYIELD", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareDataValueArgs(2, argsList)
 	if args.Type != ArgList {
@@ -18027,24 +21034,27 @@ func (fn *formulaFuncs) VDB(argsList *list.List) formulaArg {
 	return fn.vdb(cost, salvage, life, newNumberFormulaArg(life.Number-startPeriod.Number), newNumberFormulaArg(endPeriod.Number-startPeriod.Number), factor)
 }
 
-// prepareXArgs prepare arguments for the formula function XIRR and XNPV.
+// prepareXArgs prepare arguments for the formula function XIRR and XNPV. The
+// empty cells and text values in the values will be skipped along with the
+// corresponding dates.
 func (fn *formulaFuncs) prepareXArgs(values, dates formulaArg) (valuesArg, datesArg []float64, err formulaArg) {
-	for _, arg := range values.ToList() {
-		if numArg := arg.ToNumber(); numArg.Type == ArgNumber {
-			valuesArg = append(valuesArg, numArg.Number)
-			continue
-		}
-		err = newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
-		return
-	}
-	if len(valuesArg) < 2 {
+	valueList, dateList := values.ToList(), dates.ToList()
+	if len(valueList) != len(dateList) {
 		err = newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 		return
 	}
 	args, date := list.New(), 0.0
-	for _, arg := range dates.ToList() {
+	for idx, arg := range valueList {
+		if arg.Type == ArgError {
+			err = arg
+			return
+		}
+		numArg := arg.ToNumber()
+		if arg.Type == ArgEmpty || numArg.Type != ArgNumber {
+			continue
+		}
 		args.Init()
-		args.PushBack(arg)
+		args.PushBack(dateList[idx])
 		dateValue := fn.DATEVALUE(args)
 		if dateValue.Type != ArgNumber {
 			err = newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
@@ -18054,10 +21064,11 @@ func (fn *formulaFuncs) prepareXArgs(values, dates formulaArg) (valuesArg, dates
 			err = newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 			return
 		}
+		valuesArg = append(valuesArg, numArg.Number)
 		datesArg = append(datesArg, dateValue.Number)
 		date = dateValue.Number
 	}
-	if len(valuesArg) != len(datesArg) {
+	if len(valuesArg) < 2 {
 		err = newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 		return
 	}
@@ -18079,25 +21090,21 @@ func (fn *formulaFuncs) xirr(values, dates []float64, guess float64) formulaArg
 	if !positive || !negative {
 		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	result, epsMax, count, maxIterate, err := guess, 1e-10, 0, 50, false
-	for {
+	// Newton's method, returns #NUM! error if not converge within the maximum
+	// financial iterations
+	result, epsMax := guess, 1e-10
+	for i := 0; i < maxFinancialIterations; i++ {
 		resultValue := xirrPart1(values, dates, result)
 		newRate := result - resultValue/xirrPart2(values, dates, result)
 		epsRate := math.Abs(newRate - result)
-		result = newRate
-		count++
-		if epsRate <= epsMax || math.Abs(resultValue) <= epsMax {
+		if result = newRate; math.IsNaN(result) || math.IsInf(result, 0) {
 			break
 		}
-		if count > maxIterate {
-			err = true
-			break
+		if epsRate <= epsMax || math.Abs(resultValue) <= epsMax {
+			return newNumberFormulaArg(result)
 		}
 	}
-	if err || math.IsNaN(result) || math.IsInf(result, 0) {
-		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
-	}
-	return newNumberFormulaArg(result)
+	return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 }
 
 // xirrPart1 is a part of implementation of the formula function XIRR.
@@ -18132,8 +21139,8 @@ func xirrPart2(values, dates []float64, rate float64) float64 {
 //
 //	XIRR(values,dates,[guess])
 func (fn *formulaFuncs) XIRR(argsList *list.List) formulaArg {
-	if argsList.Len() != 2 && argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "XIRR requires 2 or 3 arguments")
+	if argsCount := checkFormulaArgsCount("XIRR", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	values, dates, err := fn.prepareXArgs(argsList.Front().Value.(formulaArg), argsList.Front().Next().Value.(formulaArg))
 	if err.Type != ArgEmpty {
@@ -18156,8 +21163,8 @@ func (fn *formulaFuncs) XIRR(argsList *list.List) formulaArg {
 //
 //	XNPV(rate,values,dates)
 func (fn *formulaFuncs) XNPV(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "XNPV requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("XNPV", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	rate := argsList.Front().Value.(formulaArg).ToNumber()
 	if rate.Type != ArgNumber {
@@ -18226,8 +21233,8 @@ func (fn *formulaFuncs) YIELD(argsList *list.List) formulaArg {
 //
 //	YIELDDISC(settlement,maturity,pr,redemption,[basis])
 func (fn *formulaFuncs) YIELDDISC(argsList *list.List) formulaArg {
-	if argsList.Len() != 4 && argsList.Len() != 5 {
-		return newErrorFormulaArg(formulaErrorVALUE, "YIELDDISC requires 4 or 5 arguments")
+	if argsCount := checkFormulaArgsCount("YIELDDISC", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareDataValueArgs(2, argsList)
 	if args.Type != ArgList {
@@ -18266,8 +21273,8 @@ func (fn *formulaFuncs) YIELDDISC(argsList *list.List) formulaArg {
 //
 //	YIELDMAT(settlement,maturity,issue,rate,pr,[basis])
 func (fn *formulaFuncs) YIELDMAT(argsList *list.List) formulaArg {
-	if argsList.Len() != 5 && argsList.Len() != 6 {
-		return newErrorFormulaArg(formulaErrorVALUE, "YIELDMAT requires 5 or 6 arguments")
+	if argsCount := checkFormulaArgsCount("YIELDMAT", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	args := fn.prepareDataValueArgs(2, argsList)
 	if args.Type != ArgList {
@@ -18328,6 +21335,7 @@ type calcDatabase struct {
 	indexMap map[int]int
 	database [][]formulaArg
 	criteria [][]formulaArg
+	computed map[[2]int]func(row int) bool
 }
 
 // newCalcDatabase function returns formula database by given data range of
@@ -18337,6 +21345,7 @@ func newCalcDatabase(database, field, criteria formulaArg) *calcDatabase {
 		indexMap: make(map[int]int),
 		database: database.Matrix,
 		criteria: criteria.Matrix,
+		computed: make(map[[2]int]func(row int) bool),
 	}
 	exp := len(database.Matrix) < 2 || len(database.Matrix[0]) < 1 ||
 		len(criteria.Matrix) < 2 || len(criteria.Matrix[0]) < 1
@@ -18356,15 +21365,25 @@ func newCalcDatabase(database, field, criteria formulaArg) *calcDatabase {
 // which user want to return the count of non-blank cells.
 func (db *calcDatabase) columnIndex(database [][]formulaArg, field formulaArg) int {
 	num := field.ToNumber()
-	if num.Type != ArgNumber && len(database) > 0 {
+	if num.Type != ArgNumber {
+		return db.headerIndex(database, field)
+	}
+	return int(num.Number - 1)
+}
+
+// headerIndex return index of the column within the database which header
+// matches the given criteria header case-insensitively. Unlike the field
+// argument, a numeric criteria header is matched by the label instead of the
+// column position.
+func (db *calcDatabase) headerIndex(database [][]formulaArg, header formulaArg) int {
+	if len(database) > 0 {
 		for i := 0; i < len(database[0]); i++ {
-			if title := database[0][i]; strings.EqualFold(title.Value(), field.Value()) {
+			if title := database[0][i]; strings.EqualFold(title.Value(), header.Value()) {
 				return i
 			}
 		}
-		return -1
 	}
-	return int(num.Number - 1)
+	return -1
 }
 
 // criteriaEval evaluate formula criteria expression.
@@ -18378,7 +21397,7 @@ func (db *calcDatabase) criteriaEval() bool {
 	if len(db.indexMap) == 0 {
 		fields := criteria[0]
 		for j := 0; j < columns; j++ {
-			if k = db.columnIndex(db.database, fields[j]); k < 0 {
+			if k = db.headerIndex(db.database, fields[j]); k < 0 && !db.isComputedColumn(j) {
 				return false
 			}
 			db.indexMap[j] = k
@@ -18387,10 +21406,18 @@ func (db *calcDatabase) criteriaEval() bool {
 	for i := 1; !matched && i < rows; i++ {
 		matched = true
 		for j := 0; matched && j < columns; j++ {
+			if computed, ok := db.computed[[2]int{i, j}]; ok {
+				matched = computed(db.row)
+				continue
+			}
 			criteriaExp := db.criteria[i][j]
 			if criteriaExp.Value() == "" {
 				continue
 			}
+			if db.indexMap[j] < 0 {
+				matched = false
+				continue
+			}
 			criteria := formulaCriteriaParser(criteriaExp)
 			cell := db.database[db.row][db.indexMap[j]]
 			matched, _ = formulaCriteriaEval(cell, criteria)
@@ -18399,6 +21426,68 @@ func (db *calcDatabase) criteriaEval() bool {
 	return matched
 }
 
+// isComputedColumn returns true if the criteria column by given index contains
+// computed criteria.
+func (db *calcDatabase) isComputedColumn(col int) bool {
+	for cell := range db.computed {
+		if cell[1] == col {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareComputedCriteria prepares the computed criteria for the database
+// functions. A computed criteria is a formula in the criteria range under a
+// column header which is blank or doesn't match any field of the database,
+// such as =B5>AVERAGE($B$5:$B$10). The formula references the first record of
+// the database and will be evaluated for each record with the relative
+// references shifted to the row of that record.
+func (fn *formulaFuncs) prepareComputedCriteria(db *calcDatabase, criteria formulaArg) {
+	if db == nil || fn.f == nil || fn.ctx == nil || criteria.cellRanges == nil || criteria.cellRanges.Len() == 0 {
+		return
+	}
+	cr := criteria.cellRanges.Front().Value.(cellRange)
+	sheet, col, row := cr.From.Sheet, cr.From.Col, cr.From.Row
+	if sheet == "" {
+		sheet = fn.sheet
+	}
+	if cr.To.Col < col {
+		col = cr.To.Col
+	}
+	if cr.To.Row < row {
+		row = cr.To.Row
+	}
+	for i := 1; i < len(db.criteria); i++ {
+		for j := 0; j < len(db.criteria[i]) && j < len(db.criteria[0]); j++ {
+			if db.headerIndex(db.database, db.criteria[0][j]) >= 0 {
+				continue
+			}
+			cell, err := CoordinatesToCellName(col+j, row+i)
+			if err != nil {
+				continue
+			}
+			formula, err := fn.f.GetCellFormula(sheet, cell)
+			if err != nil || formula == "" {
+				continue
+			}
+			orig := []byte(strings.TrimPrefix(formula, "="))
+			db.computed[[2]int{i, j}] = func(record int) bool {
+				shifted, start := parseSharedFormula(0, record-1, orig)
+				if start < len(orig) {
+					shifted += string(orig[start:])
+				}
+				ps := efp.ExcelParser()
+				result, err := fn.f.evalInfixExp(fn.ctx, sheet, cell, ps.Parse(shifted))
+				if err != nil {
+					return false
+				}
+				return result.ToBool().Number == 1
+			}
+		}
+	}
+}
+
 // value returns the current cell value.
 func (db *calcDatabase) value() formulaArg {
 	if db.col == -1 {
@@ -18430,6 +21519,7 @@ func (fn *formulaFuncs) database(name string, argsList *list.List) formulaArg {
 	if db == nil {
 		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 	}
+	fn.prepareComputedCriteria(db, criteria)
 	args := list.New()
 	for db.next() {
 		args.PushBack(db.value())
@@ -18483,6 +21573,7 @@ func (fn *formulaFuncs) dcount(name string, argsList *list.List) formulaArg {
 	if db == nil {
 		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 	}
+	fn.prepareComputedCriteria(db, criteria)
 	args := list.New()
 	for db.next() {
 		args.PushBack(db.value())
@@ -18519,8 +21610,8 @@ func (fn *formulaFuncs) DCOUNTA(argsList *list.List) formulaArg {
 //
 //	DGET(database,field,criteria)
 func (fn *formulaFuncs) DGET(argsList *list.List) formulaArg {
-	if argsList.Len() != 3 {
-		return newErrorFormulaArg(formulaErrorVALUE, "DGET requires 3 arguments")
+	if argsCount := checkFormulaArgsCount("DGET", argsList); argsCount.Type == ArgError {
+		return argsCount
 	}
 	database := argsList.Front().Value.(formulaArg)
 	field := argsList.Front().Next().Value.(formulaArg)
@@ -18529,6 +21620,7 @@ func (fn *formulaFuncs) DGET(argsList *list.List) formulaArg {
 	if db == nil {
 		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 	}
+	fn.prepareComputedCriteria(db, criteria)
 	value := newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
 	if db.next() {
 		if value = db.value(); db.next() {
@@ -18614,4 +21706,4 @@ func (fn *formulaFuncs) DVAR(argsList *list.List) formulaArg {
 //	DVARP(database,field,criteria)
 func (fn *formulaFuncs) DVARP(argsList *list.List) formulaArg {
 	return fn.database("DVARP", argsList)
-}
\ No newline at end of file
+}
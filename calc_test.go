@@ -2,10 +2,14 @@ package excelize
 
 import (
 	"container/list"
+	"context"
+	"fmt"
 	"math"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xuri/efp"
@@ -71,6 +75,13 @@ func TestCalcCellValue(t *testing.T) {
 		"=TRUE()&\"1\"":          "TRUE1",
 		"=TRUE<>FALSE()":         "TRUE",
 		"=TRUE<>1&\"x\"":         "TRUE",
+		"=\"2020-01-01\"+1":      "43832",
+		"=1+\"2020-01-01\"":      "43832",
+		"=\"01/01/2020\"+30":     "43861",
+		"=\"01/01/2020\"-1":      "43830",
+		"=\"2020-01-01\"*1":      "43831",
+		"=\"12:00\"+1":           "1.5",
+		"=\"6:00 pm\"*4":         "3",
 		// Engineering Functions
 		// BESSELI
 		"=BESSELI(4.5,1)":    "15.3892227537359",
@@ -194,9 +205,28 @@ func TestCalcCellValue(t *testing.T) {
 		"=CONVERT(123.45,\"K\",\"kel\")":                 "123.45",
 		"=CONVERT(123.45,\"C\",\"cel\")":                 "123.45",
 		"=CONVERT(123.45,\"F\",\"fah\")":                 "123.45",
+		"=CONVERT(100,\"C\",\"F\")":                      "212",
+		"=CONVERT(100,\"C\",\"K\")":                      "373.15",
+		"=CONVERT(100,\"C\",\"Rank\")":                   "671.67",
+		"=CONVERT(100,\"C\",\"Reau\")":                   "80",
+		"=CONVERT(80,\"Reau\",\"C\")":                    "100",
+		"=CONVERT(80,\"Reau\",\"F\")":                    "212",
+		"=CONVERT(671.67,\"Rank\",\"C\")":                "100",
+		"=CONVERT(671.67,\"Rank\",\"Reau\")":             "80",
+		"=CONVERT(212,\"F\",\"Rank\")":                   "671.67",
 		"=CONVERT(16,\"bit\",\"byte\")":                  "2",
 		"=CONVERT(1,\"kbyte\",\"byte\")":                 "1000",
 		"=CONVERT(1,\"kibyte\",\"byte\")":                "1024",
+		"=CONVERT(1,\"Mibyte\",\"byte\")":                "1048576",
+		"=CONVERT(1,\"Gibit\",\"Mibyte\")":               "128",
+		"=CONVERT(8,\"kibit\",\"byte\")":                 "1024",
+		"=CONVERT(1,\"Tibyte\",\"Gibyte\")":              "1024",
+		"=CONVERT(1,\"Mbyte\",\"kibyte\")":               "976.5625",
+		"=CONVERT(100,\"kph\",\"m/s\")":                  "27.7777777777778",
+		"=CONVERT(36,\"kph\",\"km/h\")":                  "36",
+		"=CONVERT(100,\"°C\",\"°F\")":                    "212",
+		"=CONVERT(32,\"°F\",\"K\")":                      "273.15",
+		"=CONVERT(25,\"cel\",\"°C\")":                    "25",
 		// DEC2BIN
 		"=DEC2BIN(2)":    "10",
 		"=DEC2BIN(3)":    "11",
@@ -432,7 +462,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=_xlfn.ARABIC(\"-IV\")":      "-4",
 		"=_xlfn.ARABIC(\"MCXX\")":     "1120",
 		"=_xlfn.ARABIC(\"\")":         "0",
-		"=_xlfn.ARABIC(\" ll  lc \")": "-50",
+		"=_xlfn.ARABIC(\" mim \")":    "1999",
+		"=_xlfn.ARABIC(\"-MLMVLIV\")": "-1999",
 		// ASIN
 		"=ASIN(-1)":      "-1.5707963267949",
 		"=ASIN(0)":       "0",
@@ -462,6 +493,13 @@ func TestCalcCellValue(t *testing.T) {
 		"=BASE(12,2,8)":        "00001100",
 		"=BASE(100000,16)":     "186A0",
 		"=BASE(BASE(12,2),16)": "44C",
+		// BASE and DECIMAL round-trip
+		"=BASE(48879,16)":                        "BEEF",
+		"=BASE(123456789,36)":                    "21I3V9",
+		"=_xlfn.DECIMAL(BASE(48879,16),16)":      "48879",
+		"=_xlfn.DECIMAL(BASE(123456789,36),36)":  "123456789",
+		"=BASE(_xlfn.DECIMAL(\"beef\",16),16)":   "BEEF",
+		"=BASE(_xlfn.DECIMAL(\"21i3V9\",36),36)": "21I3V9",
 		// CEILING
 		"=CEILING(22.25,0.1)":              "22.3",
 		"=CEILING(22.25,0.5)":              "22.5",
@@ -478,11 +516,20 @@ func TestCalcCellValue(t *testing.T) {
 		"=_xlfn.CEILING.MATH(15.25,0.1)":                     "15.3",
 		"=_xlfn.CEILING.MATH(15.25,5)":                       "20",
 		"=_xlfn.CEILING.MATH(-15.25,1)":                      "-15",
-		"=_xlfn.CEILING.MATH(-15.25,1,1)":                    "-15", // should be 16
+		"=_xlfn.CEILING.MATH(-15.25,1,1)":                    "-16",
 		"=_xlfn.CEILING.MATH(-15.25,10)":                     "-10",
 		"=_xlfn.CEILING.MATH(-15.25)":                        "-15",
-		"=_xlfn.CEILING.MATH(-15.25,-5,-1)":                  "-10",
+		"=_xlfn.CEILING.MATH(-15.25,-5,-1)":                  "-20",
 		"=_xlfn.CEILING.MATH(_xlfn.CEILING.MATH(15.25,1),1)": "16",
+		// CEILING.MATH with negative number and significance
+		"=_xlfn.CEILING.MATH(-15.25,1,0)":  "-15",
+		"=_xlfn.CEILING.MATH(-15.25,-1)":   "-15",
+		"=_xlfn.CEILING.MATH(-15.25,-1,1)": "-16",
+		"=_xlfn.CEILING.MATH(-15.25,5,1)":  "-20",
+		"=_xlfn.CEILING.MATH(-15.25,-5)":   "-15",
+		"=_xlfn.CEILING.MATH(-15,5,1)":     "-15",
+		"=_xlfn.CEILING.MATH(15.25,-5)":    "20",
+		"=_xlfn.CEILING.MATH(15.25,0)":     "0",
 		// _xlfn.CEILING.PRECISE
 		"=_xlfn.CEILING.PRECISE(22.25,0.1)":                          "22.3",
 		"=_xlfn.CEILING.PRECISE(22.25,0.5)":                          "22.5",
@@ -503,6 +550,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=COMBIN(6,6)":           "1",
 		"=COMBIN(0,0)":           "1",
 		"=COMBIN(6,COMBIN(0,0))": "6",
+		// COMBIN with large arguments
+		"=COMBIN(1000,2)": "499500",
 		// _xlfn.COMBINA
 		"=_xlfn.COMBINA(6,1)":                  "6",
 		"=_xlfn.COMBINA(6,2)":                  "21",
@@ -587,9 +636,17 @@ func TestCalcCellValue(t *testing.T) {
 		"=_xlfn.FLOOR.MATH(58.55,1,1)":              "58",
 		"=_xlfn.FLOOR.MATH(-58.55,1)":               "-59",
 		"=_xlfn.FLOOR.MATH(-58.55,1,-1)":            "-58",
-		"=_xlfn.FLOOR.MATH(-58.55,1,1)":             "-59", // should be -58
+		"=_xlfn.FLOOR.MATH(-58.55,1,1)":             "-58",
 		"=_xlfn.FLOOR.MATH(-58.55,10)":              "-60",
 		"=_xlfn.FLOOR.MATH(_xlfn.FLOOR.MATH(1),10)": "0",
+		// FLOOR.MATH with negative number and significance
+		"=_xlfn.FLOOR.MATH(-58.55,1,0)":  "-59",
+		"=_xlfn.FLOOR.MATH(-58.55,-1)":   "-59",
+		"=_xlfn.FLOOR.MATH(-58.55,-1,1)": "-58",
+		"=_xlfn.FLOOR.MATH(-58.55,10,1)": "-50",
+		"=_xlfn.FLOOR.MATH(-58.55,-10)":  "-60",
+		"=_xlfn.FLOOR.MATH(58.55,-5)":    "55",
+		"=_xlfn.FLOOR.MATH(58.55,0)":     "0",
 		// _xlfn.FLOOR.PRECISE
 		"=_xlfn.FLOOR.PRECISE(26.75,0.1)":                     "26.7",
 		"=_xlfn.FLOOR.PRECISE(26.75,0.5)":                     "26.5",
@@ -617,6 +674,21 @@ func TestCalcCellValue(t *testing.T) {
 		"=INT(-6.1)":   "-7",
 		"=INT(-100.9)": "-101",
 		"=INT(INT(0))": "0",
+		// INT compared with TRUNC
+		"=INT(-2.5)":             "-3",
+		"=TRUNC(-2.5)":           "-2",
+		"=INT(-3)":               "-3",
+		"=INT(-3.0)":             "-3",
+		"=TRUNC(-3)":             "-3",
+		"=INT(2.5)":              "2",
+		"=TRUNC(2.5)":            "2",
+		"=INT(-0.5)":             "-1",
+		"=TRUNC(-0.5)":           "0",
+		"=INT(-0)":               "0",
+		"=INT(-2.5)=TRUNC(-2.5)": "FALSE",
+		"=INT(-3)=TRUNC(-3)":     "TRUE",
+		"=INT(2.5)=TRUNC(2.5)":   "TRUE",
+		"=INT(-1000000.1)":       "-1000001",
 		// ISO.CEILING
 		"=ISO.CEILING(22.25)":              "23",
 		"=ISO.CEILING(22.25,1)":            "23",
@@ -671,7 +743,7 @@ func TestCalcCellValue(t *testing.T) {
 		"=IMPRODUCT(COMPLEX(5,2),COMPLEX(0,1))": "-2+5i",
 		"=IMPRODUCT(A1:C1)":                     "4",
 		// MINVERSE
-		"=MINVERSE(A1:B2)": "-0",
+		"=MINVERSE(A1:B2)": "-1.66666666666667",
 		// MMULT
 		"=MMULT(0,0)":         "0",
 		"=MMULT(2,4)":         "8",
@@ -683,6 +755,17 @@ func TestCalcCellValue(t *testing.T) {
 		"=MOD(6,1.333)":    "0.668",
 		"=MOD(-10.23,1)":   "0.77",
 		"=MOD(MOD(1,1),1)": "0",
+		// MOD with large operands and negative divisors
+		"=MOD(2^53,3)":                "2",
+		"=MOD(10^17,7)":               "5",
+		"=MOD(10^20,3)":               "1",
+		"=MOD(123456789012345678,97)": "70",
+		"=MOD(-1000000000000001,7)":   "0",
+		"=MOD(1000000000000001,-7)":   "0",
+		"=MOD(1000000000000002,-7)":   "-6",
+		"=MOD(3,-2)":                  "-1",
+		"=MOD(-3,2)":                  "1",
+		"=MOD(-3,-2)":                 "-1",
 		// MROUND
 		"=MROUND(333.7,0.5)":     "333.5",
 		"=MROUND(333.8,1)":       "334",
@@ -693,10 +776,25 @@ func TestCalcCellValue(t *testing.T) {
 		"=MROUND(-555.4,-1)":     "-555",
 		"=MROUND(-1555,-1000)":   "-2000",
 		"=MROUND(MROUND(1,1),1)": "1",
+		// MROUND with negative arguments and halves
+		"=MROUND(-10,-3)":    "-9",
+		"=MROUND(10,3)":      "9",
+		"=MROUND(5,10)":      "10",
+		"=MROUND(-5,-10)":    "-10",
+		"=MROUND(7.5,5)":     "10",
+		"=MROUND(-7.5,-5)":   "-10",
+		"=MROUND(1.3,0.2)":   "1.4",
+		"=MROUND(-1.3,-0.2)": "-1.4",
+		"=MROUND(0.15,0.1)":  "0.2",
+		"=MROUND(0,-3)":      "0",
+		"=MROUND(-1,-5)":     "0",
 		// MULTINOMIAL
 		"=MULTINOMIAL(3,1,2,5)":        "27720",
 		"=MULTINOMIAL(\"\",3,1,2,5)":   "27720",
 		"=MULTINOMIAL(MULTINOMIAL(1))": "1",
+		// MULTINOMIAL with large arguments
+		"=MULTINOMIAL(200,1)":   "201",
+		"=MULTINOMIAL(1.9,1.5)": "2",
 		// _xlfn.MUNIT
 		"=_xlfn.MUNIT(4)": "1",
 		// ODD
@@ -750,6 +848,33 @@ func TestCalcCellValue(t *testing.T) {
 		"=ROUND(999,-1)":          "1000",
 		"=ROUND(991,-1)":          "990",
 		"=ROUND(ROUND(100,1),-1)": "100",
+		"=ROUND(2.5,0)":           "3",
+		"=ROUND(3.5,0)":           "4",
+		// ROUND with the binary floating-point artifacts
+		"=ROUND(2.675,2)":        "2.68",
+		"=ROUND(-2.675,2)":       "-2.68",
+		"=ROUND(1.005,2)":        "1.01",
+		"=ROUND(-1.005,2)":       "-1.01",
+		"=ROUND(1.255,2)":        "1.26",
+		"=ROUND(0.285,2)":        "0.29",
+		"=ROUND(5.015,2)":        "5.02",
+		"=ROUND(8.345,2)":        "8.35",
+		"=ROUND(-8.345,2)":       "-8.35",
+		"=ROUND(0.1+0.2,16)":     "0.3",
+		"=ROUND(0.4999999995,0)": "0",
+		"=ROUND(-0.4,0)":         "0",
+		"=ROUND(123.456,1.9)":    "123.5",
+		// ROUND.EVEN
+		"=ROUND.EVEN(2.5,0)":   "2",
+		"=ROUND.EVEN(3.5,0)":   "4",
+		"=ROUND.EVEN(-2.5,0)":  "-2",
+		"=ROUND.EVEN(-3.5,0)":  "-4",
+		"=ROUND.EVEN(2.51,0)":  "3",
+		"=ROUND.EVEN(2.4,0)":   "2",
+		"=ROUND.EVEN(2.675,2)": "2.68",
+		"=ROUND.EVEN(0.125,2)": "0.12",
+		"=ROUND.EVEN(1250,-2)": "1200",
+		"=ROUND.EVEN(1350,-2)": "1400",
 		// ROUNDDOWN
 		"=ROUNDDOWN(99.999,1)":            "99.9",
 		"=ROUNDDOWN(99.999,2)":            "99.99",
@@ -758,6 +883,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=ROUNDDOWN(-99.999,2)":           "-99.99",
 		"=ROUNDDOWN(-99.999,-1)":          "-90",
 		"=ROUNDDOWN(ROUNDDOWN(100,1),-1)": "100",
+		"=ROUNDDOWN(2.3,1)":               "2.3",
+		"=ROUNDDOWN(-4.35*100,0)":         "-435",
 		// ROUNDUP
 		"=ROUNDUP(11.111,1)":          "11.2",
 		"=ROUNDUP(11.111,2)":          "11.12",
@@ -766,6 +893,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=ROUNDUP(-11.111,2)":         "-11.12",
 		"=ROUNDUP(-11.111,-1)":        "-20",
 		"=ROUNDUP(ROUNDUP(100,1),-1)": "100",
+		"=ROUNDUP(0.1+0.2,1)":         "0.3",
+		"=ROUNDUP(-1.1*3,1)":          "-3.3",
 		// SEARCH
 		"=SEARCH(\"s\",F1)":           "1",
 		"=SEARCH(\"s\",F1,2)":         "5",
@@ -778,6 +907,9 @@ func TestCalcCellValue(t *testing.T) {
 		"=SEARCH(\"?l\",\"你好world\")": "5",
 		"=SEARCH(\"?+\",\"你好 1+2\")":  "4",
 		"=SEARCH(\" ?+\",\"你好 1+2\")": "3",
+		"=SEARCH(\"É\",\"café\")":     "4",
+		"=SEARCH(\"本\",\"中文文本\")":     "4",
+		"=SEARCH(\"文\",\"中文文本\",3)":   "3",
 		// SEARCHB
 		"=SEARCHB(\"s\",F1)":           "1",
 		"=SEARCHB(\"s\",F1,2)":         "5",
@@ -790,6 +922,9 @@ func TestCalcCellValue(t *testing.T) {
 		"=SEARCHB(\"?l\",\"你好world\")": "7",
 		"=SEARCHB(\"?+\",\"你好 1+2\")":  "6",
 		"=SEARCHB(\" ?+\",\"你好 1+2\")": "5",
+		"=SEARCHB(\"É\",\"café\")":     "4",
+		"=SEARCHB(\"B\",\"你你你你b\")":    "9",
+		"=SEARCHB(\"文\",\"中文文本\",4)":   "5",
 		// SEC
 		"=_xlfn.SEC(-3.14159265358979)": "-1",
 		"=_xlfn.SEC(0)":                 "1",
@@ -886,6 +1021,10 @@ func TestCalcCellValue(t *testing.T) {
 		"=SUM(1+ROW())":                       "2",
 		"=SUM((SUM(2))+1)":                    "3",
 		"=SUM({1,2,3,4,\"\"})":                "10",
+		"=SUM(50%,1)":                         "1.5",
+		"=SUM(1,50%)":                         "1.5",
+		"=SUM(A2%,1)":                         "1.02",
+		"=SUM(SUM(25,25)%,1)":                 "1.5",
 		// SUMIF
 		"=SUMIF(F1:F5, \"\")":             "0",
 		"=SUMIF(A1:A5, \"3\")":            "3",
@@ -902,11 +1041,23 @@ func TestCalcCellValue(t *testing.T) {
 		"=SUMIF(E2:E9,\"North*\",F2:F9)":  "138772",
 		"=SUMIF(D1:D3,\"Month\",D1:D3)":   "0",
 		// SUMPRODUCT
-		"=SUMPRODUCT(A1,B1)":             "4",
-		"=SUMPRODUCT(A1:A2,B1:B2)":       "14",
-		"=SUMPRODUCT(A1:A3,B1:B3)":       "14",
-		"=SUMPRODUCT(A1:B3)":             "15",
-		"=SUMPRODUCT(A1:A3,B1:B3,B2:B4)": "20",
+		"=SUMPRODUCT(A1,B1)":                   "4",
+		"=SUMPRODUCT(A1:A2,B1:B2)":             "14",
+		"=SUMPRODUCT(A1:A3,B1:B3)":             "14",
+		"=SUMPRODUCT(A1:B3)":                   "15",
+		"=SUMPRODUCT(A1:A3,B1:B3,B2:B4)":       "20",
+		"=SUMPRODUCT((A1:A3>1)*1,B1:B3)":       "5",
+		"=SUMPRODUCT((A1:A2>1)*(B1:B2>4))":     "1",
+		"=SUMPRODUCT((A1:A2>=1)*B1:B2)":        "9",
+		"=SUMPRODUCT((D2:D9=\"Feb\")*1,F2:F9)": "157559",
+		"=SUMPRODUCT(--(A1:A3>1))":             "2",
+		"=SUMPRODUCT(-(A1:A3>1))":              "-2",
+		"=SUMPRODUCT(--(A1:A2>1),B1:B2)":       "5",
+		"=SUMPRODUCT(--(D2:D9=\"Jan\"),F2:F9)": "146554",
+		"=SUMPRODUCT(A1:A3,A1:B1)":             "30",
+		"=SUMPRODUCT(A1:B1,A1:A3)":             "30",
+		"=SUMPRODUCT(A1:A3,A1:B1,A1:A3)":       "70",
+		"=SUMPRODUCT(A1:A2,A1:B1)":             "9",
 		// SUMSQ
 		"=SUMSQ(A1:A4)":              "14",
 		"=SUMSQ(A1,B1,A2,B2,6)":      "82",
@@ -937,6 +1088,17 @@ func TestCalcCellValue(t *testing.T) {
 		"=TRUNC(-99.999,2)":   "-99.99",
 		"=TRUNC(-99.999,-1)":  "-90",
 		"=TRUNC(TRUNC(1),-1)": "0",
+		// TRUNC with negative or default digits
+		"=TRUNC(123.45,-1)":   "120",
+		"=TRUNC(123.45,-2)":   "100",
+		"=TRUNC(123.45,-3)":   "0",
+		"=TRUNC(-123.45,-1)":  "-120",
+		"=TRUNC(123.45,-1.9)": "120",
+		"=TRUNC(8.9)":         "8",
+		"=TRUNC(-8.9)":        "-8",
+		"=TRUNC(-8.9,0)":      "-8",
+		"=TRUNC(4.35,2)":      "4.35",
+		"=TRUNC(1.5,1.9)":     "1.5",
 		// Statistical Functions
 		// AVEDEV
 		"=AVEDEV(1,2)":          "0.5",
@@ -1060,6 +1222,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=COUNTIF(D1:D9,\"<>Jan\")":   "5",
 		"=COUNTIF(A1:F9,\">=50000\")": "2",
 		"=COUNTIF(A1:F9,TRUE)":        "0",
+		"=COUNTIF(A1:A4,\">50%\")":    "3",
+		"=COUNTIF(A1:A4,\"<=50%\")":   "1",
 		// COUNTIFS
 		"=COUNTIFS(A1:A9,2,D1:D9,\"Jan\")":          "1",
 		"=COUNTIFS(F1:F9,\">20000\",D1:D9,\"Jan\")": "4",
@@ -1099,6 +1263,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=GAMMA(1.5)":     "0.886226925452758",
 		"=GAMMA(5.5)":     "52.3427777845535",
 		"=GAMMA(\"5.5\")": "52.3427777845535",
+		"=GAMMA(-0.5)":    "-3.54490770181103",
+		"=GAMMA(-1.5)":    "2.36327180120735",
 		// GAMMA.DIST
 		"=GAMMA.DIST(6,3,2,FALSE)": "0.112020903827694",
 		"=GAMMA.DIST(6,3,2,TRUE)":  "0.576809918873156",
@@ -1125,9 +1291,17 @@ func TestCalcCellValue(t *testing.T) {
 		"=GAUSS(2.5)":   "0.493790334674224",
 		// GEOMEAN
 		"=GEOMEAN(2.5,3,0.5,1,3)": "1.6226711115996",
+		// GEOMEAN with ranges and overflowed product
+		"=GEOMEAN(A1:A3)":         "1.81712059283214",
+		"=GEOMEAN(A1:A3,D1)":      "1.81712059283214",
+		"=GEOMEAN(1E+200,1E+200)": "1E+200",
+		"=GEOMEAN(1E-200,1E-200)": "1E-200",
 		// HARMEAN
 		"=HARMEAN(2.5,3,0.5,1,3)":               "1.22950819672131",
 		"=HARMEAN(\"2.5\",3,0.5,1,INT(3),\"\")": "1.22950819672131",
+		// HARMEAN with ranges
+		"=HARMEAN(A1:A3)":    "1.63636363636364",
+		"=HARMEAN(A1:A3,D1)": "1.63636363636364",
 		// HYPGEOM.DIST
 		"=HYPGEOM.DIST(0,3,3,9,TRUE)":   "0.238095238095238",
 		"=HYPGEOM.DIST(1,3,3,9,TRUE)":   "0.773809523809524",
@@ -1137,6 +1311,13 @@ func TestCalcCellValue(t *testing.T) {
 		"=HYPGEOM.DIST(2,4,4,12,FALSE)": "0.339393939393939",
 		"=HYPGEOM.DIST(3,4,4,12,FALSE)": "0.0646464646464646",
 		"=HYPGEOM.DIST(4,4,4,12,FALSE)": "0.00202020202020202",
+		"=HYPGEOM.DIST(1,4,4,12,TRUE)":  "0.593939393939394",
+		"=HYPGEOM.DIST(3,4,6,8,FALSE)":  "0.571428571428571",
+		"=HYPGEOM.DIST(3,4,6,8,TRUE)":   "0.785714285714286",
+		"=HYPGEOM.DIST(4,4,6,8,TRUE)":   "1",
+		// HYPGEOM.DIST with non-integer arguments
+		"=HYPGEOM.DIST(1.9,4.2,4.7,12.5,FALSE)": "0.452525252525253",
+		"=HYPGEOM.DIST(3.5,4,6,8.9,TRUE)":       "0.785714285714286",
 		// HYPGEOMDIST
 		"=HYPGEOMDIST(1,4,4,12)": "0.452525252525253",
 		"=HYPGEOMDIST(2,4,4,12)": "0.339393939393939",
@@ -1152,6 +1333,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=EXPON.DIST(0.5,1,TRUE)":  "0.393469340287367",
 		"=EXPON.DIST(0.5,1,FALSE)": "0.606530659712633",
 		"=EXPON.DIST(2,1,TRUE)":    "0.864664716763387",
+		"=EXPON.DIST(0,3,FALSE)":   "3",
+		"=EXPON.DIST(0,3,TRUE)":    "0",
 		// EXPONDIST
 		"=EXPONDIST(0.5,1,TRUE)":  "0.393469340287367",
 		"=EXPONDIST(0.5,1,FALSE)": "0.606530659712633",
@@ -1226,6 +1409,9 @@ func TestCalcCellValue(t *testing.T) {
 		"=LARGE(A1:B5,2)": "4",
 		"=LARGE(A1,1)":    "1",
 		"=LARGE(A1:F2,1)": "36693",
+		// LARGE with fractional k
+		"=LARGE(A1:A5,1.9)": "3",
+		"=LARGE(A1:A5,4.5)": "0",
 		// MAX
 		"=MAX(1)":           "1",
 		"=MAX(TRUE())":      "1",
@@ -1243,7 +1429,11 @@ func TestCalcCellValue(t *testing.T) {
 		"=MAXA(INT(1))":     "1",
 		"=MAXA(A1:B4,MUNIT(1),INT(0),1,E1:F2,\"\")": "36693",
 		// MAXIFS
-		"=MAXIFS(F2:F4,A2:A4,\">0\")": "36693",
+		"=MAXIFS(F2:F4,A2:A4,\">0\")":                    "36693",
+		"=MAXIFS(F2:F9,D2:D9,\"Mar\")":                   "0",
+		"=MAXIFS(F2:F9,F2:F9,\">=40000\",D2:D9,\"Jan\")": "53321",
+		"=MAXIFS(F2:F9,D2:D9,\"Feb\",E2:E9,\"North*\")":  "50090",
+		"=MAXIFS(F2:F9,D2:D9,\"Jan\",E2:E9,\"West*\")":   "0",
 		// MEDIAN
 		"=MEDIAN(A1:A5,12)":               "2",
 		"=MEDIAN(A1:A5)":                  "1.5",
@@ -1266,7 +1456,12 @@ func TestCalcCellValue(t *testing.T) {
 		"=MINA(INT(1))":      "1",
 		"=MINA(A1:B4,MUNIT(1),INT(0),1,E1:F2,\"\")": "0",
 		// MINIFS
-		"=MINIFS(F2:F4,A2:A4,\">0\")": "22100",
+		"=MINIFS(F2:F4,A2:A4,\">0\")":                   "22100",
+		"=MINIFS(F2:F9,D2:D9,\"Mar\")":                  "0",
+		"=MINIFS(F2:F9,F2:F9,\">=40000\")":              "45500",
+		"=MINIFS(F2:F9,E2:E9,\"South ?\")":              "32080",
+		"=MINIFS(F2:F9,D2:D9,\"Feb\",E2:E9,\"North*\")": "29889",
+		"=MINIFS(F2:F9,D2:D9,\"Jan\",F2:F9,\"<20000\")": "0",
 		// PEARSON
 		"=PEARSON(A1:A4,B1:B4)": "1",
 		// PERCENTILE.EXC
@@ -1299,6 +1494,10 @@ func TestCalcCellValue(t *testing.T) {
 		"=PERMUT(6,6)":  "720",
 		"=PERMUT(7,6)":  "5040",
 		"=PERMUT(10,6)": "151200",
+		// PERMUT with large arguments
+		"=PERMUT(200,2)":    "39800",
+		"=PERMUT(10.9,6.5)": "151200",
+		"=PERMUT(6.5,6.9)":  "720",
 		// PERMUTATIONA
 		"=PERMUTATIONA(6,6)": "46656",
 		"=PERMUTATIONA(7,6)": "117649",
@@ -1340,6 +1539,9 @@ func TestCalcCellValue(t *testing.T) {
 		"=SMALL(A1:B5,2)": "1",
 		"=SMALL(A1,1)":    "1",
 		"=SMALL(A1:F2,1)": "1",
+		// SMALL with fractional k
+		"=SMALL(A1:A5,2.7)": "1",
+		"=SMALL(A1:A5,4.5)": "3",
 		// STANDARDIZE
 		"=STANDARDIZE(5.5,5,2)":   "0.25",
 		"=STANDARDIZE(12,15,1.5)": "-2",
@@ -1396,6 +1598,8 @@ func TestCalcCellValue(t *testing.T) {
 		// WEIBULL
 		"=WEIBULL(1,3,1,FALSE)":  "1.10363832351433",
 		"=WEIBULL(2,5,1.5,TRUE)": "0.985212776817482",
+		"=WEIBULL(0,1,2,FALSE)":  "0.5",
+		"=WEIBULL(0,1,2,TRUE)":   "0",
 		// WEIBULL.DIST
 		"=WEIBULL.DIST(1,3,1,FALSE)":  "1.10363832351433",
 		"=WEIBULL.DIST(2,5,1.5,TRUE)": "0.985212776817482",
@@ -1455,11 +1659,15 @@ func TestCalcCellValue(t *testing.T) {
 		"=ISTEXT(D1)": "TRUE",
 		"=ISTEXT(A1)": "FALSE",
 		// N
-		"=N(10)":     "10",
-		"=N(\"10\")": "10",
-		"=N(\"x\")":  "0",
-		"=N(TRUE)":   "1",
-		"=N(FALSE)":  "0",
+		"=N(10)":       "10",
+		"=N(\"10\")":   "0",
+		"=N(\"x\")":    "0",
+		"=N(\"TRUE\")": "0",
+		"=N(TRUE)":     "1",
+		"=N(FALSE)":    "0",
+		"=N(1<2)":      "1",
+		// N with date text
+		"=N(\"1/1/2020\")": "0",
 		// SHEET
 		"=SHEET()":           "1",
 		"=SHEET(\"Sheet1\")": "1",
@@ -1474,6 +1682,18 @@ func TestCalcCellValue(t *testing.T) {
 		"=TYPE(TRUE)":     "4",
 		"=TYPE(NA())":     "16",
 		"=TYPE(MUNIT(2))": "64",
+		// TYPE with array and reference
+		"=TYPE(D1)":            "2",
+		"=TYPE(D1:D1)":         "2",
+		"=TYPE(A1:A1)":         "1",
+		"=TYPE(A1:B2)":         "64",
+		"=TYPE(FALSE)":         "4",
+		"=TYPE(1/0)":           "16",
+		"=TYPE({1,2})":         "64",
+		"=TYPE({1;2})":         "64",
+		"=TYPE({\"a\",\"b\"})": "64",
+		"=TYPE({1})":           "64",
+		"=TYPE({1,2;3,4})":     "64",
 		// T
 		"=T(\"text\")": "text",
 		"=T(N(10))":    "",
@@ -1501,9 +1721,12 @@ func TestCalcCellValue(t *testing.T) {
 		"=IFNA(NA(),\"not found\")":                "not found",
 		"=IFNA(HLOOKUP(D2,D:D,1,2),\"not found\")": "not found",
 		// IFS
-		"=IFS(4>1,5/4,4<-1,-5/4,TRUE,0)":     "1.25",
-		"=IFS(-2>1,5/-2,-2<-1,-5/-2,TRUE,0)": "2.5",
-		"=IFS(0>1,5/0,0<-1,-5/0,TRUE,0)":     "0",
+		"=IFS(4>1,5/4,4<-1,-5/4,TRUE,0)":      "1.25",
+		"=IFS(-2>1,5/-2,-2<-1,-5/-2,TRUE,0)":  "2.5",
+		"=IFS(0>1,5/0,0<-1,-5/0,TRUE,0)":      "0",
+		"=IFS(1>0,\"first\",TRUE,\"second\")": "first",
+		"=IFS(TRUE,1,TRUE,NA())":              "1",
+		"=IFS(FALSE,NA(),2,\"two\")":          "two",
 		// NOT
 		"=NOT(FALSE())":     "TRUE",
 		"=NOT(\"false\")":   "TRUE",
@@ -1520,6 +1743,12 @@ func TestCalcCellValue(t *testing.T) {
 		"=SWITCH(1,1,\"A\",2,\"B\",3,\"C\",\"N\")": "A",
 		"=SWITCH(3,1,\"A\",2,\"B\",3,\"C\",\"N\")": "C",
 		"=SWITCH(4,1,\"A\",2,\"B\",3,\"C\",\"N\")": "N",
+		"=SWITCH(1,1,\"one\")":                     "one",
+		"=SWITCH(\"b\",\"A\",1,\"B\",2)":           "2",
+		"=SWITCH(A2,1,\"A\",2,\"B\")":              "B",
+		"=SWITCH(1,\"1\",\"text\",1,\"number\")":   "number",
+		"=SWITCH(\"1\",1,\"number\",\"N\")":        "N",
+		"=SWITCH(TRUE,1,\"number\",TRUE,\"bool\")": "bool",
 		// TRUE
 		"=TRUE()": "TRUE",
 		// XOR
@@ -1528,8 +1757,15 @@ func TestCalcCellValue(t *testing.T) {
 		"=XOR(1>0,0>1,INT(0),INT(1),A1:A4,2)": "FALSE",
 		// Date and Time Functions
 		// DATE
-		"=DATE(2020,10,21)": "2020-10-21 00:00:00 +0000 UTC",
-		"=DATE(1900,1,1)":   "1899-12-31 00:00:00 +0000 UTC",
+		"=DATE(2020,10,21)": "44125",
+		"=DATE(1900,1,1)":   "1",
+		"=DATE(2023,13,1)":  "45292",
+		"=DATE(2023,-1,15)": "44880",
+		"=DATE(2023,1,0)":   "44926",
+		"=DATE(2023,3,-1)":  "44984",
+		"=DATE(2023,2,29)":  "44986",
+		"=DATE(123,1,1)":    "44927",
+		"=DATE(99,12,31)":   "36525",
 		// DATEDIF
 		"=DATEDIF(43101,43101,\"D\")":  "0",
 		"=DATEDIF(43101,43891,\"d\")":  "790",
@@ -1549,6 +1785,10 @@ func TestCalcCellValue(t *testing.T) {
 		"=DATEVALUE(\"01/01/2016\")": "42370",
 		"=DATEVALUE(\"01/01/29\")":   "47119",
 		"=DATEVALUE(\"01/01/30\")":   "10959",
+		"=DATEVALUE(\"01/01/1900\")": "1",
+		"=DATEVALUE(\"02/28/1900\")": "59",
+		"=DATEVALUE(\"03/01/1900\")": "61",
+		"=DATEVALUE(\"03/02/1900\")": "62",
 		// DAY
 		"=DAY(0)":                                "0",
 		"=DAY(INT(7))":                           "7",
@@ -1585,10 +1825,22 @@ func TestCalcCellValue(t *testing.T) {
 		"=EDATE(\"01/31/2020\",1)":  "43890",
 		"=EDATE(\"01/29/2020\",12)": "44225",
 		"=EDATE(\"6/12/2021\",-14)": "43933",
+		"=EDATE(\"01/31/2021\",1)":  "44255",
+		"=EDATE(\"03/31/2021\",-1)": "44255",
+		"=EDATE(\"02/29/2020\",12)": "44255",
+		"=EDATE(\"02/29/2020\",48)": "45351",
+		"=EDATE(\"01/15/2021\",11)": "44545",
+		"=EDATE(\"08/15/2021\",5)":  "44576",
+		"=EDATE(\"1/15/2021\",-13)": "43814",
 		// EOMONTH
 		"=EOMONTH(\"01/01/2021\",-1)":  "44196",
 		"=EOMONTH(\"01/29/2020\",12)":  "44227",
 		"=EOMONTH(\"01/12/2021\",-18)": "43677",
+		"=EOMONTH(\"01/31/2020\",1)":   "43890",
+		"=EOMONTH(\"03/15/2021\",-1)":  "44255",
+		"=EOMONTH(\"01/15/2021\",11)":  "44561",
+		"=EOMONTH(\"08/15/2021\",5)":   "44592",
+		"=EOMONTH(\"1/15/2021\",-13)":  "43830",
 		// HOUR
 		"=HOUR(1)":                    "0",
 		"=HOUR(43543.5032060185)":     "12",
@@ -1640,6 +1892,20 @@ func TestCalcCellValue(t *testing.T) {
 		"=YEARFRAC(\"02/29/2000\", \"01/29/2001\",1)": "0.915300546448087",
 		"=YEARFRAC(\"02/29/2000\", \"03/29/2000\",1)": "0.0792349726775956",
 		"=YEARFRAC(\"01/31/2000\", \"03/29/2000\",4)": "0.163888888888889",
+		// YEARFRAC with actual/actual basis spanning leap year
+		"=YEARFRAC(\"06/01/2019\",\"06/01/2020\",1)": "1",
+		"=YEARFRAC(\"01/01/2020\",\"01/01/2021\",1)": "1",
+		"=YEARFRAC(\"12/15/2019\",\"03/15/2021\",1)": "1.24817518248175",
+		"=YEARFRAC(\"07/01/2021\",\"06/30/2022\",1)": "0.997260273972603",
+		"=YEARFRAC(\"06/01/2020\",\"06/01/2019\",1)": "1",
+		"=YEARFRAC(43983.75,43617.25,1)":             "1",
+		// YEARFRAC with all basis for the same dates
+		"=YEARFRAC(\"01/15/2020\",\"08/20/2021\")":   "1.59722222222222",
+		"=YEARFRAC(\"01/15/2020\",\"08/20/2021\",0)": "1.59722222222222",
+		"=YEARFRAC(\"01/15/2020\",\"08/20/2021\",1)": "1.59507523939808",
+		"=YEARFRAC(\"01/15/2020\",\"08/20/2021\",2)": "1.61944444444444",
+		"=YEARFRAC(\"01/15/2020\",\"08/20/2021\",3)": "1.5972602739726",
+		"=YEARFRAC(\"01/15/2020\",\"08/20/2021\",4)": "1.59722222222222",
 		// SECOND
 		"=SECOND(\"13:35:55\")":            "55",
 		"=SECOND(\"13:10:60\")":            "0",
@@ -1653,6 +1919,14 @@ func TestCalcCellValue(t *testing.T) {
 		"=TIME(5,44,32)":             "0.239259259259259",
 		"=TIME(\"5\",\"44\",\"32\")": "0.239259259259259",
 		"=TIME(0,0,73)":              "0.000844907407407407",
+		"=TIME(25,0,0)":              "0.0416666666666667",
+		"=TIME(49,0,0)":              "0.0416666666666667",
+		"=TIME(24,0,0)":              "0",
+		"=TIME(0,90,0)":              "0.0625",
+		"=TIME(0,0,3661)":            "0.0423726851851852",
+		"=TIME(24,-1,0)":             "0.999305555555556",
+		"=TIME(6,0,30.9)":            "0.250347222222222",
+		"=TIME(12.9,0,0)":            "0.5",
 		// TIMEVALUE
 		"=TIMEVALUE(\"2:23\")":             "0.0993055555555555",
 		"=TIMEVALUE(\"2:23 am\")":          "0.0993055555555555",
@@ -1674,6 +1948,12 @@ func TestCalcCellValue(t *testing.T) {
 		"=WEEKDAY(\"12/25/2012\",15)": "5",
 		"=WEEKDAY(\"12/25/2012\",16)": "4",
 		"=WEEKDAY(\"12/25/2012\",17)": "3",
+		// WEEKDAY with known Monday
+		"=WEEKDAY(45292)":    "2",
+		"=WEEKDAY(45292,1)":  "2",
+		"=WEEKDAY(45292,2)":  "1",
+		"=WEEKDAY(45292,3)":  "0",
+		"=WEEKDAY(45292,11)": "1",
 		// WEEKNUM
 		"=WEEKNUM(\"01/01/2011\")":    "1",
 		"=WEEKNUM(\"01/03/2011\")":    "2",
@@ -1691,6 +1971,18 @@ func TestCalcCellValue(t *testing.T) {
 		"=ARRAYTOTEXT(A1:D2)":   "1, 4, , Month, 2, 5, , Jan",
 		"=ARRAYTOTEXT(A1:D2,0)": "1, 4, , Month, 2, 5, , Jan",
 		"=ARRAYTOTEXT(A1:D2,1)": "{1,4,,\"Month\";2,5,,\"Jan\"}",
+		// ASC
+		"=ASC(\"１２３\")":       "123",
+		"=ASC(\"ＡＢＣ　ｘｙｚ\")":   "ABC xyz",
+		"=ASC(\"アイウ\")":       "ｱｲｳ",
+		"=ASC(\"漢字\")":        "漢字",
+		"=ASC(DBCS(\"ｱｲｳ\"))": "ｱｲｳ",
+		// DBCS
+		"=DBCS(123)":          "１２３",
+		"=DBCS(\"ABC xyz\")":  "ＡＢＣ　ｘｙｚ",
+		"=DBCS(\"ｱｲｳ\")":      "アイウ",
+		"=DBCS(\"漢字\")":       "漢字",
+		"=DBCS(ASC(\"１２３\"))": "１２３",
 		// CHAR
 		"=CHAR(65)": "A",
 		"=CHAR(97)": "a",
@@ -1699,6 +1991,10 @@ func TestCalcCellValue(t *testing.T) {
 		// CLEAN
 		"=CLEAN(\"\u0009clean text\")": "clean text",
 		"=CLEAN(0)":                    "0",
+		"=CLEAN(\"a\u007fb\")":         "ab",
+		"=CLEAN(\"\u0080a\u009fb\")":   "ab",
+		"=CLEAN(\"a\u00a0b\")":         "a\u00a0b",
+		"=CLEAN(\"清\u0085文\")":         "清文",
 		// CODE
 		"=CODE(\"Alpha\")": "65",
 		"=CODE(\"alpha\")": "97",
@@ -1726,6 +2022,17 @@ func TestCalcCellValue(t *testing.T) {
 		"=FIXED(5123.591,-3,TRUE)": "5000",
 		"=FIXED(5123.591,-5)":      "0",
 		"=FIXED(-77262.23973,-5)":  "-100,000",
+		"=FIXED(1234567.8,1)":      "1,234,567.8",
+		"=FIXED(1234567.8,1,0)":    "1,234,567.8",
+		"=FIXED(1234567.8,1,1)":    "1234567.8",
+		"=FIXED(1234567.8,1,2)":    "1234567.8",
+		"=FIXED(1234567.8,1,-1)":   "1234567.8",
+		"=FIXED(1234.5,1,FALSE)":   "1,234.5",
+		"=FIXED(1234.5,1,TRUE)":    "1234.5",
+		"=FIXED(-1234.5,-2)":       "-1,200",
+		"=FIXED(-1234.5,-2,TRUE)":  "-1200",
+		"=FIXED(1250,-2)":          "1,300",
+		"=FIXED(-0.4,0)":           "0",
 		// FIND
 		"=FIND(\"T\",\"Original Text\")":   "10",
 		"=FIND(\"t\",\"Original Text\")":   "13",
@@ -1734,6 +2041,10 @@ func TestCalcCellValue(t *testing.T) {
 		"=FIND(\"\",\"Original Text\")":    "1",
 		"=FIND(\"\",\"Original Text\",2)":  "2",
 		"=FIND(\"s\",\"Sales\",2)":         "5",
+		"=FIND(\"é\",\"café\")":            "4",
+		"=FIND(\"f\",\"café\")":            "3",
+		"=FIND(\"本\",\"中文文本\")":            "4",
+		"=FIND(\"文\",\"中文文本\",3)":          "3",
 		// FINDB
 		"=FINDB(\"T\",\"Original Text\")":   "10",
 		"=FINDB(\"t\",\"Original Text\")":   "13",
@@ -1742,6 +2053,12 @@ func TestCalcCellValue(t *testing.T) {
 		"=FINDB(\"\",\"Original Text\")":    "1",
 		"=FINDB(\"\",\"Original Text\",2)":  "2",
 		"=FINDB(\"s\",\"Sales\",2)":         "5",
+		"=FINDB(\"é\",\"café\")":            "4",
+		"=FINDB(\"é\",\"éé\",2)":            "3",
+		"=FINDB(\"本\",\"中文文本\")":            "7",
+		"=FINDB(\"文\",\"中文文本\",4)":          "5",
+		"=FINDB(\"b\",\"你你你你b\")":           "9",
+		"=FINDB(\"你\",\"a你b\")":             "2",
 		// LEFT
 		"=LEFT(\"Original Text\")":    "O",
 		"=LEFT(\"Original Text\",4)":  "Orig",
@@ -1753,12 +2070,17 @@ func TestCalcCellValue(t *testing.T) {
 		"=LEFT(\"オリジナルテキスト\",5)":      "オリジナル",
 		"=LEFT(\"オリジナルテキスト\",7)":      "オリジナルテキ",
 		"=LEFT(\"オリジナルテキスト\",20)":     "オリジナルテキスト",
+		"=LEFT(\"😀😁😂\",2)":            "😀😁",
 		// LEFTB
 		"=LEFTB(\"Original Text\")":    "O",
 		"=LEFTB(\"Original Text\",4)":  "Orig",
 		"=LEFTB(\"Original Text\",0)":  "",
 		"=LEFTB(\"Original Text\",13)": "Original Text",
 		"=LEFTB(\"Original Text\",20)": "Original Text",
+		"=LEFTB(\"中文文本\",3)":           "中",
+		"=LEFTB(\"中文文本\",4)":           "中文",
+		"=LEFTB(\"😀a\",1)":             "",
+		"=LEFTB(\"a😀\",3)":             "a😀",
 		// LEN
 		"=LEN(\"\")":          "0",
 		"=LEN(D1)":            "5",
@@ -1781,6 +2103,9 @@ func TestCalcCellValue(t *testing.T) {
 		"=MID(\"text\",3,6)":          "xt",
 		"=MID(\"text\",6,0)":          "",
 		"=MID(\"你好World\",5,1)":       "r",
+		"=MID(\"text\",1,5)":          "text",
+		"=MID(\"😀😁😂\",2,1)":           "😁",
+		"=MID(\"中文文本\",2,2)":          "文文",
 		"=MID(\"\u30AA\u30EA\u30B8\u30CA\u30EB\u30C6\u30AD\u30B9\u30C8\",6,4)": "\u30C6\u30AD\u30B9\u30C8",
 		"=MID(\"\u30AA\u30EA\u30B8\u30CA\u30EB\u30C6\u30AD\u30B9\u30C8\",3,5)": "\u30B8\u30CA\u30EB\u30C6\u30AD",
 		// MIDB
@@ -1790,8 +2115,11 @@ func TestCalcCellValue(t *testing.T) {
 		"=MIDB(\"text\",3,6)":          "xt",
 		"=MIDB(\"text\",6,0)":          "",
 		"=MIDB(\"你好World\",5,1)":       "W",
+		"=MIDB(\"中文文本\",3,4)":          "文文",
+		"=MIDB(\"😀😁😂\",3,2)":           "😁",
+		"=MIDB(\"😀😁😂\",3,3)":           "😁",
 		"=MIDB(\"\u30AA\u30EA\u30B8\u30CA\u30EB\u30C6\u30AD\u30B9\u30C8\",6,4)": "\u30B8\u30CA",
-		"=MIDB(\"\u30AA\u30EA\u30B8\u30CA\u30EB\u30C6\u30AD\u30B9\u30C8\",3,5)": "\u30EA\u30B8\xe3",
+		"=MIDB(\"\u30AA\u30EA\u30B8\u30CA\u30EB\u30C6\u30AD\u30B9\u30C8\",3,5)": "\u30EA\u30B8",
 		// PROPER
 		"=PROPER(\"this is a test sentence\")": "This Is A Test Sentence",
 		"=PROPER(\"THIS IS A TEST SENTENCE\")": "This Is A Test Sentence",
@@ -1811,6 +2139,11 @@ func TestCalcCellValue(t *testing.T) {
 		"=REPT(\"*\",0)":  "",
 		"=REPT(\"*\",1)":  "*",
 		"=REPT(\"**\",2)": "****",
+		// REPT with fractional or large number of times
+		"=REPT(\"*\",2.9)":        "**",
+		"=REPT(\"ab\",0.5)":       "",
+		"=REPT(\"\",40000)":       "",
+		"=LEN(REPT(\"*\",32767))": "32767",
 		// RIGHT
 		"=RIGHT(\"Original Text\")":    "t",
 		"=RIGHT(\"Original Text\",4)":  "Text",
@@ -1822,18 +2155,33 @@ func TestCalcCellValue(t *testing.T) {
 		"=RIGHT(\"オリジナルテキスト\",4)":      "テキスト",
 		"=RIGHT(\"オリジナルテキスト\",7)":      "ジナルテキスト",
 		"=RIGHT(\"オリジナルテキスト\",20)":     "オリジナルテキスト",
+		"=RIGHT(\"😀😁😂\",2)":            "😁😂",
 		// RIGHTB
 		"=RIGHTB(\"Original Text\")":    "t",
 		"=RIGHTB(\"Original Text\",4)":  "Text",
 		"=RIGHTB(\"Original Text\",0)":  "",
 		"=RIGHTB(\"Original Text\",13)": "Original Text",
 		"=RIGHTB(\"Original Text\",20)": "Original Text",
+		"=RIGHTB(\"中文文本\",3)":           "本",
+		"=RIGHTB(\"中文文本\",4)":           "文本",
+		"=RIGHTB(\"a😀\",2)":             "😀",
+		"=RIGHTB(\"😀a\",2)":             "a",
 		// SUBSTITUTE
 		"=SUBSTITUTE(\"abab\",\"a\",\"X\")":                      "XbXb",
 		"=SUBSTITUTE(\"abab\",\"a\",\"X\",2)":                    "abXb",
 		"=SUBSTITUTE(\"abab\",\"x\",\"X\",2)":                    "abab",
 		"=SUBSTITUTE(\"John is 5 years old\",\"John\",\"Jack\")": "Jack is 5 years old",
 		"=SUBSTITUTE(\"John is 5 years old\",\"5\",\"6\")":       "John is 6 years old",
+		"=SUBSTITUTE(\"a-b-c-d\",\"-\",\"+\",2)":                 "a-b+c-d",
+		"=SUBSTITUTE(\"a-b-c-d\",\"-\",\"+\",4)":                 "a-b-c-d",
+		"=SUBSTITUTE(\"abab\",\"\",\"X\")":                       "abab",
+		"=SUBSTITUTE(\"abab\",\"\",\"X\",1)":                     "abab",
+		"=SUBSTITUTE(\"aaaa\",\"aa\",\"X\")":                     "XX",
+		"=SUBSTITUTE(\"aaaaa\",\"aa\",\"X\")":                    "XXa",
+		"=SUBSTITUTE(\"aaaa\",\"aa\",\"X\",2)":                   "aaX",
+		"=SUBSTITUTE(\"aaaa\",\"aa\",\"X\",3)":                   "aaaa",
+		"=SUBSTITUTE(\"AbAb\",\"a\",\"X\")":                      "AbAb",
+		"=SUBSTITUTE(\"abAb\",\"A\",\"X\",1)":                    "abXb",
 		// TEXT
 		"=TEXT(\"07/07/2015\",\"mm/dd/yyyy\")":        "07/07/2015",
 		"=TEXT(42192,\"mm/dd/yyyy\")":                 "07/07/2015",
@@ -1843,6 +2191,15 @@ func TestCalcCellValue(t *testing.T) {
 		"=TEXT(567.9,\"$#,##0.00\")":                  "$567.90",
 		"=TEXT(-5,\"+ $#,##0.00;- $#,##0.00;$0.00\")": "- $5.00",
 		"=TEXT(5,\"+ $#,##0.00;- $#,##0.00;$0.00\")":  "+ $5.00",
+		"=TEXT(1.25,\"# ?/?\")":                       "1 1/4",
+		"=TEXT(-1.25,\"# ?/?\")":                      "-1 1/4",
+		"=TEXT(0.3333,\"?/?\")":                       "1/3",
+		"=TEXT(1.5,\"?/?\")":                          "3/2",
+		"=TEXT(3.14159,\"# ??/??\")":                  "3 14/99",
+		"=TEXT(1.2,\"# ?/8\")":                        "1 2/8",
+		"=TEXT(2,\"# ?/?\")":                          "2",
+		"=TEXT(12345678,\"#,##0,\")":                  "12,346",
+		"=TEXT(1234567,\"0.0,,\")":                    "1.2",
 		// TEXTAFTER
 		"=TEXTAFTER(\"Red riding hood's, red hood\",\"hood\")":               "'s, red hood",
 		"=TEXTAFTER(\"Red riding hood's, red hood\",\"HOOD\",1,1)":           "'s, red hood",
@@ -1891,6 +2248,13 @@ func TestCalcCellValue(t *testing.T) {
 		"=UNICODE(\"alpha\")": "97",
 		"=UNICODE(\"?\")":     "63",
 		"=UNICODE(\"3\")":     "51",
+		// UNICHAR and UNICODE with the supplementary planes
+		"=UNICHAR(128512)":           "😀",
+		"=UNICHAR(UNICODE(\"😀x\"))":  "😀",
+		"=UNICODE(\"😀\")":            "128512",
+		"=UNICODE(\"中文\")":           "20013",
+		"=UNICODE(UNICHAR(128512))":  "128512",
+		"=UNICODE(UNICHAR(1114111))": "1114111",
 		// UPPER
 		"=UPPER(\"test\")":     "TEST",
 		"=UPPER(\"TEST\")":     "TEST",
@@ -1923,6 +2287,11 @@ func TestCalcCellValue(t *testing.T) {
 		"=IF(FALSE,0,ROUND(4/2,0))":                  "2",
 		"=IF(TRUE,ROUND(4/2,0),0)":                   "2",
 		"=IF(A4>0.4,\"TRUE\",\"FALSE\")":             "FALSE",
+		"=IF(5,\"a\",\"b\")":                         "a",
+		"=IF(-0.5,\"a\",\"b\")":                      "a",
+		"=IF(0,\"a\",\"b\")":                         "b",
+		"=IF(A2,\"a\",\"b\")":                        "a",
+		"=IF(A4,\"a\",\"b\")":                        "b",
 		// Excel Lookup and Reference Functions
 		// ADDRESS
 		"=ADDRESS(1,1,1,TRUE)":            "$A$1",
@@ -1944,6 +2313,15 @@ func TestCalcCellValue(t *testing.T) {
 		"=ADDRESS(1,1,4,TRUE,\"\")":       "!A1",
 		"=ADDRESS(1,2,4,TRUE,\"\")":       "!B1",
 		"=ADDRESS(1,1,4,TRUE,\"Sheet1\")": "Sheet1!A1",
+		// ADDRESS with sheet name requires quotes
+		"=ADDRESS(1,1,1,TRUE,\"Sheet 1\")":   "'Sheet 1'!$A$1",
+		"=ADDRESS(2,3,4,FALSE,\"Sheet 1\")":  "'Sheet 1'!R[2]C[3]",
+		"=ADDRESS(1,1,2,TRUE,\"Bob's\")":     "'Bob''s'!A$1",
+		"=ADDRESS(1,1,3,TRUE,\"2024\")":      "'2024'!$A1",
+		"=ADDRESS(1,1,4,TRUE,\"AB12\")":      "'AB12'!A1",
+		"=ADDRESS(1,1,1,FALSE,\"Data_1.x\")": "Data_1.x!R1C1",
+		"=ADDRESS(5,10,2,FALSE)":             "R5C[10]",
+		"=ADDRESS(5,10,3,FALSE)":             "R[5]C10",
 		// CHOOSE
 		"=CHOOSE(4,\"red\",\"blue\",\"green\",\"brown\")": "brown",
 		"=CHOOSE(1,\"red\",\"blue\",\"green\",\"brown\")": "red",
@@ -1954,6 +2332,9 @@ func TestCalcCellValue(t *testing.T) {
 		"=COLUMN(Sheet1!A1:B1:C1)": "1",
 		"=COLUMN(Sheet1!F1:G1)":    "6",
 		"=COLUMN(H1)":              "8",
+		"=COLUMN(B1:B3)":           "2",
+		"=SUM(COLUMN(B1:D1))":      "9",
+		"=SUM(COLUMN(A1:C2))":      "6",
 		// COLUMNS
 		"=COLUMNS(B1)":                   "1",
 		"=COLUMNS(1:1)":                  "16384",
@@ -1963,6 +2344,7 @@ func TestCalcCellValue(t *testing.T) {
 		"=COLUMNS(E5:H7:B1:C1:Z1:C1:B1)": "25",
 		"=COLUMNS(E5:B1)":                "4",
 		"=COLUMNS(EM38:HZ81)":            "92",
+		"=COLUMNS({1,2,3})":              "3",
 		// HLOOKUP
 		"=HLOOKUP(D2,D2:D8,1,FALSE)":          "Jan",
 		"=HLOOKUP(F3,F3:F8,3,FALSE)":          "34440",
@@ -1999,6 +2381,11 @@ func TestCalcCellValue(t *testing.T) {
 		"=INDIRECT(\"E\"&ROW(),TRUE)":         "Team",
 		"=INDIRECT(\"R1C5\",FALSE)":           "Team",
 		"=INDIRECT(\"R\"&1&\"C\"&5,FALSE)":    "Team",
+		"=INDIRECT(\"r1c5\",FALSE)":           "Team",
+		"=INDIRECT(\"RC[2]\",FALSE)":          "Team",
+		"=INDIRECT(\"R[1]C[3]\",FALSE)":       "36693",
+		"=SUM(INDIRECT(\"R2C6:R3C6\",FALSE))": "58793",
+		"=INDIRECT(\"Sheet1!E1\")":            "Team",
 		"=SUM(INDIRECT(\"A1:B2\"))":           "12",
 		"=SUM(INDIRECT(\"A1:B2\",TRUE))":      "12",
 		"=SUM(INDIRECT(\"R1C1:R2C2\",FALSE))": "12",
@@ -2017,6 +2404,9 @@ func TestCalcCellValue(t *testing.T) {
 		"=ROW(Sheet1!A1:B2:C3)": "1",
 		"=ROW(Sheet1!F5:G6)":    "5",
 		"=ROW(A8)":              "8",
+		"=ROW(A2:C2)":           "2",
+		"=SUM(ROW(A2:A4))":      "9",
+		"=SUM(ROW(A1:B3))":      "6",
 		// ROWS
 		"=ROWS(B1)":                    "1",
 		"=ROWS(B:B)":                   "1048576",
@@ -2026,6 +2416,7 @@ func TestCalcCellValue(t *testing.T) {
 		"=ROWS(E5:H8:B2:C3:Z26:C3:B2)": "25",
 		"=ROWS(E5:B1)":                 "5",
 		"=ROWS(EM38:HZ81)":             "44",
+		"=ROWS({1,2;3,4})":             "2",
 		// Web Functions
 		// ENCODEURL
 		"=ENCODEURL(\"https://xuri.me/excelize/en/?q=Save As\")": "https%3A%2F%2Fxuri.me%2Fexcelize%2Fen%2F%3Fq%3DSave%20As",
@@ -2098,9 +2489,16 @@ func TestCalcCellValue(t *testing.T) {
 		// DISC
 		"=DISC(\"04/01/2016\",\"03/31/2021\",95,100)": "0.01",
 		// DOLLARDE
-		"=DOLLARDE(1.01,16)": "1.0625",
+		"=DOLLARDE(1.01,16)":   "1.0625",
+		"=DOLLARDE(1.02,16)":   "1.125",
+		"=DOLLARDE(1.1,32)":    "1.3125",
+		"=DOLLARDE(1.02,16.5)": "1.125",
+		"=DOLLARDE(-1.02,16)":  "-1.125",
 		// DOLLARFR
 		"=DOLLARFR(1.0625,16)": "1.01",
+		"=DOLLARFR(1.125,16)":  "1.02",
+		"=DOLLARFR(1.125,32)":  "1.04",
+		"=DOLLARFR(-1.125,16)": "-1.02",
 		// DURATION
 		"=DURATION(\"04/01/2015\",\"03/31/2025\",10%,8%,4)": "6.67442279848313",
 		// EFFECT
@@ -2113,6 +2511,15 @@ func TestCalcCellValue(t *testing.T) {
 		"=EUROCONVERT(1.47,\"FRF\",\"DEM\",FALSE)":   "0.44",
 		"=EUROCONVERT(1.47,\"FRF\",\"DEM\",FALSE,3)": "0.44",
 		"=EUROCONVERT(1.47,\"FRF\",\"DEM\",TRUE,3)":  "0.43810592",
+		// EUROCONVERT triangulation through the euro
+		"=EUROCONVERT(100,\"DEM\",\"FRF\")":         "335.39",
+		"=EUROCONVERT(100,\"DEM\",\"FRF\",TRUE)":    "335.385488513828",
+		"=EUROCONVERT(100,\"DEM\",\"FRF\",FALSE,3)": "335.38",
+		"=EUROCONVERT(100,\"DEM\",\"FRF\",TRUE,3)":  "335.38425453",
+		"=EUROCONVERT(100,\"DEM\",\"EUR\",TRUE,3)":  "51.129",
+		"=EUROCONVERT(1,\"eur\",\"dem\")":           "1.96",
+		"=EUROCONVERT(1,\"EUR\",\"PTE\")":           "200.5",
+		"=EUROCONVERT(1,\"EUR\",\"GRD\")":           "341",
 		// FV
 		"=FV(0.05/12,60,-1000)":   "68006.0828408434",
 		"=FV(0.1/4,16,-2000,0,1)": "39729.4608941662",
@@ -2150,7 +2557,7 @@ func TestCalcCellValue(t *testing.T) {
 		// ODDFYIELD
 		"=ODDFYIELD(\"05/01/2017\",\"06/30/2021\",\"03/15/2017\",\"06/30/2017\",5.5%,102,100,1)":   "0.0495998049937776",
 		"=ODDFYIELD(\"05/01/2017\",\"06/30/2021\",\"03/15/2017\",\"06/30/2017\",5.5%,102,100,2)":   "0.0496289417392839",
-		"=ODDFYIELD(\"05/01/2017\",\"06/30/2021\",\"03/15/2017\",\"06/30/2017\",5.5%,102,100,4,1)": "0.0464750282973541",
+		"=ODDFYIELD(\"05/01/2017\",\"06/30/2021\",\"03/15/2017\",\"06/30/2017\",5.5%,102,100,4,1)": "0.046485888991948",
 		// ODDLPRICE
 		"=ODDLPRICE(\"04/20/2008\",\"06/15/2008\",\"12/24/2007\",3.75%,99.875,100,2)":   "5.0517841252892",
 		"=ODDLPRICE(\"04/20/2008\",\"06/15/2008\",\"12/24/2007\",3.75%,99.875,100,4,1)": "10.3667274303228",
@@ -2213,7 +2620,7 @@ func TestCalcCellValue(t *testing.T) {
 		"=VDB(24000,3000,100,50,100,1)":    "10377.2944184652",
 		"=VDB(24000,3000,100,50,100,2)":    "5740.0723220908",
 		// YIELD
-		"=YIELD(\"01/01/2010\",\"06/30/2015\",10%,101,100,4)":               "0.0975631546829798",
+		"=YIELD(\"01/01/2010\",\"06/30/2015\",10%,101,100,4)":               "0.0976269355643988",
 		"=YIELD(\"01/01/2010\",\"06/30/2015\",10%,101,100,4,4)":             "0.0976269355643988",
 		"=YIELD(\"01/01/2010\",\"06/30/2010\",0.5,1,1,1,4)":                 "1.91285866099894",
 		"=YIELD(\"01/01/2010\",\"06/30/2010\",0,1,1,1,4)":                   "0",
@@ -2233,7 +2640,11 @@ func TestCalcCellValue(t *testing.T) {
 		assert.Equal(t, expected, result, formula)
 	}
 	mathCalcError := map[string][]string{
-		"=1/0":       {"", "#DIV/0!"},
+		"=1/0":       {"#DIV/0!", "#DIV/0!"},
+		"=1+(1/0)":   {"#DIV/0!", "#DIV/0!"},
+		"=(1/0)-1":   {"#DIV/0!", "#DIV/0!"},
+		"=-(1/0)":    {"#DIV/0!", "#DIV/0!"},
+		"=1+NA()":    {"#N/A", "#N/A"},
 		"1^\"text\"": {"", "strconv.ParseFloat: parsing \"text\": invalid syntax"},
 		"\"text\"^1": {"", "strconv.ParseFloat: parsing \"text\": invalid syntax"},
 		"1+\"text\"": {"", "strconv.ParseFloat: parsing \"text\": invalid syntax"},
@@ -2345,6 +2756,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=CONVERT(12345.6,\"cwt\",\"baton\")": {"#N/A", "#N/A"},
 		"=CONVERT(234.56,\"xxxx\",\"m\")":     {"#N/A", "#N/A"},
 		"=CONVERT(234.56,\"m\",\"xxxx\")":     {"#N/A", "#N/A"},
+		"=CONVERT(1,\"Mim\",\"m\")":           {"#N/A", "#N/A"},
+		"=CONVERT(1,\"kikph\",\"m/s\")":       {"#N/A", "#N/A"},
 		// DEC2BIN
 		"=DEC2BIN()":        {"#VALUE!", "DEC2BIN requires at least 1 argument"},
 		"=DEC2BIN(1,1,1)":   {"#VALUE!", "DEC2BIN allows at most 2 arguments"},
@@ -2517,6 +2930,7 @@ func TestCalcCellValue(t *testing.T) {
 		// Math and Trigonometric Functions
 		// ABS
 		"=ABS()":      {"#VALUE!", "ABS requires 1 numeric argument"},
+		"=ABS(1,2)":   {"#VALUE!", "ABS requires 1 numeric argument"},
 		"=ABS(\"X\")": {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		"=ABS(~)":     {"#NAME?", "invalid reference"},
 		// ACOS
@@ -2545,6 +2959,12 @@ func TestCalcCellValue(t *testing.T) {
 		// _xlfn.ARABIC
 		"=_xlfn.ARABIC()": {"#VALUE!", "ARABIC requires 1 numeric argument"},
 		"=_xlfn.ARABIC(\"" + strings.Repeat("I", 256) + "\")": {"#VALUE!", "#VALUE!"},
+		// ARABIC with invalid Roman numeral
+		"=_xlfn.ARABIC(\" ll  lc \")": {"#VALUE!", "#VALUE!"},
+		"=_xlfn.ARABIC(\"ABC\")":      {"#VALUE!", "#VALUE!"},
+		"=_xlfn.ARABIC(\"IIII\")":     {"#VALUE!", "#VALUE!"},
+		"=_xlfn.ARABIC(\"VX\")":       {"#VALUE!", "#VALUE!"},
+		"=_xlfn.ARABIC(\"MMMM\")":     {"#VALUE!", "#VALUE!"},
 		// ASIN
 		"=ASIN()":    {"#VALUE!", "ASIN requires 1 numeric argument"},
 		`=ASIN("X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
@@ -2568,6 +2988,10 @@ func TestCalcCellValue(t *testing.T) {
 		`=BASE("X",2)`:   {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		`=BASE(1,"X")`:   {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		`=BASE(1,2,"X")`: {"#VALUE!", "strconv.Atoi: parsing \"X\": invalid syntax"},
+		// BASE with invalid number or minimum length
+		"=BASE(-1,2)":               {"#NUM!", "#NUM!"},
+		"=BASE(9007199254740992,2)": {"#NUM!", "#NUM!"},
+		"=BASE(1,2,256)":            {"#NUM!", "#NUM!"},
 		// CEILING
 		"=CEILING()":      {"#VALUE!", "CEILING requires at least 1 argument"},
 		"=CEILING(1,2,3)": {"#VALUE!", "CEILING allows at most 2 arguments"},
@@ -2590,12 +3014,16 @@ func TestCalcCellValue(t *testing.T) {
 		"=COMBIN(-1,1)":   {"#VALUE!", "COMBIN requires number >= number_chosen"},
 		`=COMBIN("X",1)`:  {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		`=COMBIN(-1,"X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
+		// COMBIN with overflowed result
+		"=COMBIN(2000,1000)": {"#NUM!", "#NUM!"},
 		// _xlfn.COMBINA
 		"=_xlfn.COMBINA()":       {"#VALUE!", "COMBINA requires 2 argument"},
 		"=_xlfn.COMBINA(-1,1)":   {"#VALUE!", "COMBINA requires number > number_chosen"},
 		"=_xlfn.COMBINA(-1,-1)":  {"#VALUE!", "COMBIN requires number >= number_chosen"},
 		`=_xlfn.COMBINA("X",1)`:  {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		`=_xlfn.COMBINA(-1,"X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
+		// _xlfn.COMBINA with overflowed result
+		"=_xlfn.COMBINA(1000,1000)": {"#NUM!", "#NUM!"},
 		// COS
 		"=COS()":    {"#VALUE!", "COS requires 1 numeric argument"},
 		`=COS("X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
@@ -2620,8 +3048,13 @@ func TestCalcCellValue(t *testing.T) {
 		"=_xlfn.CSCH(0)":   {"#DIV/0!", "#DIV/0!"},
 		// _xlfn.DECIMAL
 		"=_xlfn.DECIMAL()":         {"#VALUE!", "DECIMAL requires 2 numeric arguments"},
-		`=_xlfn.DECIMAL("X",2)`:    {"#VALUE!", "strconv.ParseInt: parsing \"X\": invalid syntax"},
+		`=_xlfn.DECIMAL("X",2)`:    {"#NUM!", "#NUM!"},
 		`=_xlfn.DECIMAL(2000,"X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
+		// DECIMAL with digits out of range for the radix
+		`=_xlfn.DECIMAL("12",2)`:  {"#NUM!", "#NUM!"},
+		`=_xlfn.DECIMAL("G",16)`:  {"#NUM!", "#NUM!"},
+		`=_xlfn.DECIMAL("-1",10)`: {"#NUM!", "#NUM!"},
+		`=_xlfn.DECIMAL("1",37)`:  {"#NUM!", "#NUM!"},
 		// DEGREES
 		"=DEGREES()":    {"#VALUE!", "DEGREES requires 1 numeric argument"},
 		`=DEGREES("X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
@@ -2631,11 +3064,13 @@ func TestCalcCellValue(t *testing.T) {
 		`=EVEN("X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		// EXP
 		"=EXP()":    {"#VALUE!", "EXP requires 1 numeric argument"},
+		"=EXP(1,2)": {"#VALUE!", "EXP requires 1 numeric argument"},
 		`=EXP("X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		// FACT
 		"=FACT()":    {"#VALUE!", "FACT requires 1 numeric argument"},
 		`=FACT("X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		"=FACT(-1)":  {"#NUM!", "#NUM!"},
+		"=FACT(171)": {"#NUM!", "#NUM!"},
 		// FACTDOUBLE
 		"=FACTDOUBLE()":    {"#VALUE!", "FACTDOUBLE requires 1 numeric argument"},
 		`=FACTDOUBLE("X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
@@ -2677,6 +3112,7 @@ func TestCalcCellValue(t *testing.T) {
 		`=LCM("X")`:  {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		// LN
 		"=LN()":      {"#VALUE!", "LN requires 1 numeric argument"},
+		"=LN(1,2)":   {"#VALUE!", "LN requires 1 numeric argument"},
 		"=LN(\"X\")": {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		// LOG
 		"=LOG()":      {"#VALUE!", "LOG requires at least 1 argument"},
@@ -2690,7 +3126,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=LOG10()":      {"#VALUE!", "LOG10 requires 1 numeric argument"},
 		"=LOG10(\"X\")": {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		// MDETERM
-		"=MDETERM()": {"#VALUE!", "MDETERM requires 1 argument"},
+		"=MDETERM()":            {"#VALUE!", "MDETERM requires 1 argument"},
+		"=MDETERM(A1:B2,A1:B2)": {"#VALUE!", "MDETERM requires 1 argument"},
 		// MINVERSE
 		"=MINVERSE()":      {"#VALUE!", "MINVERSE requires 1 argument"},
 		"=MINVERSE(B3:C4)": {"#VALUE!", "#VALUE!"},
@@ -2712,8 +3149,14 @@ func TestCalcCellValue(t *testing.T) {
 		"=MROUND(1,-1)":  {"#NUM!", "#NUM!"},
 		`=MROUND("X",0)`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		`=MROUND(1,"X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
+		// MROUND with mixed signs
+		"=MROUND(-10,3)": {"#NUM!", "#NUM!"},
+		"=MROUND(10,-3)": {"#NUM!", "#NUM!"},
 		// MULTINOMIAL
 		`=MULTINOMIAL("X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
+		// MULTINOMIAL with overflowed result
+		"=MULTINOMIAL(600,600)":     {"#NUM!", "#NUM!"},
+		"=MULTINOMIAL(10^15,10^15)": {"#NUM!", "#NUM!"},
 		// _xlfn.MUNIT
 		"=_xlfn.MUNIT()":    {"#VALUE!", "MUNIT requires 1 numeric argument"},
 		`=_xlfn.MUNIT("X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
@@ -2753,10 +3196,17 @@ func TestCalcCellValue(t *testing.T) {
 		"=ROMAN(1,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=ROMAN(\"\")":   {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=ROMAN(\"\",1)": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
+		// ROMAN with number out of range
+		"=ROMAN(-1)":   {"#VALUE!", "#VALUE!"},
+		"=ROMAN(4000)": {"#VALUE!", "#VALUE!"},
 		// ROUND
 		"=ROUND()":      {"#VALUE!", "ROUND requires 2 numeric arguments"},
 		`=ROUND("X",1)`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		`=ROUND(1,"X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
+		// ROUND.EVEN
+		"=ROUND.EVEN()":      {"#VALUE!", "ROUND.EVEN requires 2 numeric arguments"},
+		`=ROUND.EVEN("X",1)`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
+		`=ROUND.EVEN(1,"X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		// ROUNDDOWN
 		"=ROUNDDOWN()":      {"#VALUE!", "ROUNDDOWN requires 2 numeric arguments"},
 		`=ROUNDDOWN("X",1)`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
@@ -2799,6 +3249,7 @@ func TestCalcCellValue(t *testing.T) {
 		`=SINH("X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		// SQRT
 		"=SQRT()":    {"#VALUE!", "SQRT requires 1 numeric argument"},
+		"=SQRT(4,2)": {"#VALUE!", "SQRT requires 1 numeric argument"},
 		`=SQRT("")`:  {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		`=SQRT("X")`: {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		"=SQRT(-1)":  {"#NUM!", "#NUM!"},
@@ -2843,9 +3294,14 @@ func TestCalcCellValue(t *testing.T) {
 		"=SUM(1*)":           {ErrInvalidFormula.Error(), ErrInvalidFormula.Error()},
 		"=SUM(1/)":           {ErrInvalidFormula.Error(), ErrInvalidFormula.Error()},
 		"=SUM(1*SUM(1/0))":   {"#DIV/0!", "#DIV/0!"},
-		"=SUM(1*SUM(1/0)*1)": {"", "#DIV/0!"},
+		"=SUM(1*SUM(1/0)*1)": {"#DIV/0!", "#DIV/0!"},
+		"=SUM(1/0,1)":        {"#DIV/0!", "#DIV/0!"},
+		"=SUM(NA(),1)":       {"#N/A", "#N/A"},
+		"=SUM(1,NA()*2)":     {"#N/A", "#N/A"},
+		"=SUM(\"X\"%)":       {"", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		// SUMIF
-		"=SUMIF()": {"#VALUE!", "SUMIF requires at least 2 arguments"},
+		"=SUMIF()":          {"#VALUE!", "SUMIF requires 2 or 3 arguments"},
+		"=SUMIF(A1,1,A1,1)": {"#VALUE!", "SUMIF requires 2 or 3 arguments"},
 		// SUMSQ
 		"=SUMSQ(\"X\")": {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		"=SUMSQ(C1:D2)": {"#VALUE!", "strconv.ParseFloat: parsing \"Month\": invalid syntax"},
@@ -2856,6 +3312,7 @@ func TestCalcCellValue(t *testing.T) {
 		"=SUMPRODUCT(A1:A3,D1:D3)": {"#VALUE!", "#VALUE!"},
 		"=SUMPRODUCT(A1:A2,B1:B3)": {"#VALUE!", "#VALUE!"},
 		"=SUMPRODUCT(\"\")":        {"#VALUE!", "#VALUE!"},
+		"=SUMPRODUCT(A1:A3,D1:E1)": {"#VALUE!", "#VALUE!"},
 		"=SUMPRODUCT(A1,NA())":     {"#N/A", "#N/A"},
 		// SUMX2MY2
 		"=SUMX2MY2()":         {"#VALUE!", "SUMX2MY2 requires 2 arguments"},
@@ -2873,7 +3330,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=TANH()":      {"#VALUE!", "TANH requires 1 numeric argument"},
 		"=TANH(\"X\")": {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		// TRUNC
-		"=TRUNC()":        {"#VALUE!", "TRUNC requires at least 1 argument"},
+		"=TRUNC()":        {"#VALUE!", "TRUNC requires 1 or 2 arguments"},
+		"=TRUNC(1,2,3)":   {"#VALUE!", "TRUNC requires 1 or 2 arguments"},
 		"=TRUNC(\"X\")":   {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		"=TRUNC(1,\"X\")": {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		// Statistical Functions
@@ -2886,7 +3344,8 @@ func TestCalcCellValue(t *testing.T) {
 		// AVERAGEA
 		"=AVERAGEA(H1)": {"#DIV/0!", "#DIV/0!"},
 		// AVERAGEIF
-		"=AVERAGEIF()":                      {"#VALUE!", "AVERAGEIF requires at least 2 arguments"},
+		"=AVERAGEIF()":                      {"#VALUE!", "AVERAGEIF requires 2 or 3 arguments"},
+		"=AVERAGEIF(A1,1,A1,1)":             {"#VALUE!", "AVERAGEIF requires 2 or 3 arguments"},
 		"=AVERAGEIF(H1,\"\")":               {"#DIV/0!", "#DIV/0!"},
 		"=AVERAGEIF(D1:D3,\"Month\",D1:D3)": {"#DIV/0!", "#DIV/0!"},
 		"=AVERAGEIF(C1:C3,\"Month\",D1:D3)": {"#DIV/0!", "#DIV/0!"},
@@ -3104,9 +3563,12 @@ func TestCalcCellValue(t *testing.T) {
 		// GAMMA
 		"=GAMMA()":       {"#VALUE!", "GAMMA requires 1 numeric argument"},
 		"=GAMMA(F1)":     {"#VALUE!", "GAMMA requires 1 numeric argument"},
-		"=GAMMA(0)":      {"#N/A", "#N/A"},
-		"=GAMMA(\"0\")":  {"#N/A", "#N/A"},
-		"=GAMMA(INT(0))": {"#N/A", "#N/A"},
+		"=GAMMA(0)":      {"#NUM!", "#NUM!"},
+		"=GAMMA(\"0\")":  {"#NUM!", "#NUM!"},
+		"=GAMMA(INT(0))": {"#NUM!", "#NUM!"},
+		"=GAMMA(-1)":     {"#NUM!", "#NUM!"},
+		"=GAMMA(-2)":     {"#NUM!", "#NUM!"},
+		"=GAMMA(172)":    {"#NUM!", "#NUM!"},
 		// GAMMA.DIST
 		"=GAMMA.DIST()":               {"#VALUE!", "GAMMA.DIST requires 4 arguments"},
 		"=GAMMA.DIST(\"\",3,2,FALSE)": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
@@ -3146,8 +3608,9 @@ func TestCalcCellValue(t *testing.T) {
 		// GAMMALN
 		"=GAMMALN()":       {"#VALUE!", "GAMMALN requires 1 numeric argument"},
 		"=GAMMALN(F1)":     {"#VALUE!", "GAMMALN requires 1 numeric argument"},
-		"=GAMMALN(0)":      {"#N/A", "#N/A"},
-		"=GAMMALN(INT(0))": {"#N/A", "#N/A"},
+		"=GAMMALN(0)":      {"#NUM!", "#NUM!"},
+		"=GAMMALN(INT(0))": {"#NUM!", "#NUM!"},
+		"=GAMMALN(-0.5)":   {"#NUM!", "#NUM!"},
 		// GAMMALN.PRECISE
 		"=GAMMALN.PRECISE()":     {"#VALUE!", "GAMMALN.PRECISE requires 1 numeric argument"},
 		"=GAMMALN.PRECISE(\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
@@ -3160,10 +3623,22 @@ func TestCalcCellValue(t *testing.T) {
 		"=GEOMEAN(0)":     {"#NUM!", "#NUM!"},
 		"=GEOMEAN(D1:D2)": {"#NUM!", "#NUM!"},
 		"=GEOMEAN(\"\")":  {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
+		// GEOMEAN with non-positive values
+		"=GEOMEAN(2,0)":    {"#NUM!", "#NUM!"},
+		"=GEOMEAN(2,-2)":   {"#NUM!", "#NUM!"},
+		"=GEOMEAN(-2,-2)":  {"#NUM!", "#NUM!"},
+		"=GEOMEAN(A1:A4)":  {"#NUM!", "#NUM!"},
+		"=GEOMEAN(1,NA())": {"#N/A", "#N/A"},
 		// HARMEAN
 		"=HARMEAN()":   {"#VALUE!", "HARMEAN requires at least 1 argument"},
-		"=HARMEAN(-1)": {"#N/A", "#N/A"},
-		"=HARMEAN(0)":  {"#N/A", "#N/A"},
+		"=HARMEAN(-1)": {"#NUM!", "#NUM!"},
+		"=HARMEAN(0)":  {"#NUM!", "#NUM!"},
+		// HARMEAN with non-positive values
+		"=HARMEAN(2,0)":    {"#NUM!", "#NUM!"},
+		"=HARMEAN(2,-2)":   {"#NUM!", "#NUM!"},
+		"=HARMEAN(A1:A4)":  {"#NUM!", "#NUM!"},
+		"=HARMEAN(D1:D2)":  {"#NUM!", "#NUM!"},
+		"=HARMEAN(1,NA())": {"#N/A", "#N/A"},
 		// HYPGEOM.DIST
 		"=HYPGEOM.DIST()":                  {"#VALUE!", "HYPGEOM.DIST requires 5 arguments"},
 		"=HYPGEOM.DIST(\"\",4,4,12,FALSE)": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
@@ -3179,6 +3654,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=HYPGEOM.DIST(1,4,4,2,FALSE)":     {"#NUM!", "#NUM!"},
 		"=HYPGEOM.DIST(1,4,0,12,FALSE)":    {"#NUM!", "#NUM!"},
 		"=HYPGEOM.DIST(1,4,4,0,FALSE)":     {"#NUM!", "#NUM!"},
+		"=HYPGEOM.DIST(1,4,6,8,TRUE)":      {"#NUM!", "#NUM!"},
+		"=HYPGEOM.DIST(0,0.9,4,12,TRUE)":   {"#NUM!", "#NUM!"},
 		// HYPGEOMDIST
 		"=HYPGEOMDIST()":            {"#VALUE!", "HYPGEOMDIST requires 4 numeric arguments"},
 		"=HYPGEOMDIST(\"\",4,4,12)": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
@@ -3207,6 +3684,7 @@ func TestCalcCellValue(t *testing.T) {
 		"=EXPON.DIST(0,1,\"\")":    {"#VALUE!", "strconv.ParseBool: parsing \"\": invalid syntax"},
 		"=EXPON.DIST(-1,1,TRUE)":   {"#NUM!", "#NUM!"},
 		"=EXPON.DIST(1,0,TRUE)":    {"#NUM!", "#NUM!"},
+		"=EXPON.DIST(1,-1,FALSE)":  {"#NUM!", "#NUM!"},
 		// EXPONDIST
 		"=EXPONDIST()":            {"#VALUE!", "EXPONDIST requires 3 arguments"},
 		"=EXPONDIST(\"\",1,TRUE)": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
@@ -3351,6 +3829,12 @@ func TestCalcCellValue(t *testing.T) {
 		"=LARGE(A1:A5,0)":    {"#NUM!", "k should be > 0"},
 		"=LARGE(A1:A5,6)":    {"#NUM!", "k should be <= length of array"},
 		"=LARGE(A1:A5,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
+		// LARGE with k out of range
+		"=LARGE(A1:A5,0.5)":   {"#NUM!", "k should be > 0"},
+		"=LARGE(A1:A5,-1)":    {"#NUM!", "k should be > 0"},
+		"=LARGE(A1:A5,5)":     {"#NUM!", "k should be <= length of array"},
+		"=LARGE(A1:A5,1E+20)": {"#NUM!", "k should be <= length of array"},
+		"=LARGE(D1:D2,1)":     {"#NUM!", "k should be <= length of array"},
 		// MAX
 		"=MAX()":     {"#VALUE!", "MAX requires at least 1 argument"},
 		"=MAX(NA())": {"#N/A", "#N/A"},
@@ -3417,12 +3901,17 @@ func TestCalcCellValue(t *testing.T) {
 		"=PERMUT(\"\",0)": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=PERMUT(0,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=PERMUT(6,8)":    {"#N/A", "#N/A"},
+		// PERMUT with overflowed result
+		"=PERMUT(200,200)":     {"#NUM!", "#NUM!"},
+		"=PERMUT(10^15,10^14)": {"#NUM!", "#NUM!"},
 		// PERMUTATIONA
 		"=PERMUTATIONA()":       {"#VALUE!", "PERMUTATIONA requires 2 numeric arguments"},
+		"=PERMUTATIONA(3,2,1)":  {"#VALUE!", "PERMUTATIONA requires 2 numeric arguments"},
 		"=PERMUTATIONA(\"\",0)": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=PERMUTATIONA(0,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=PERMUTATIONA(-1,0)":   {"#N/A", "#N/A"},
 		"=PERMUTATIONA(0,-1)":   {"#N/A", "#N/A"},
+		"=PERMUTATIONA(10,400)": {"#NUM!", "#NUM!"},
 		// PHI
 		"=PHI()":     {"#VALUE!", "PHI requires 1 argument"},
 		"=PHI(\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
@@ -3471,6 +3960,11 @@ func TestCalcCellValue(t *testing.T) {
 		"=SMALL(A1:A5,0)":    {"#NUM!", "k should be > 0"},
 		"=SMALL(A1:A5,6)":    {"#NUM!", "k should be <= length of array"},
 		"=SMALL(A1:A5,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
+		// SMALL with k out of range
+		"=SMALL(A1:A5,0.5)":   {"#NUM!", "k should be > 0"},
+		"=SMALL(A1:A5,-1)":    {"#NUM!", "k should be > 0"},
+		"=SMALL(A1:A5,5)":     {"#NUM!", "k should be <= length of array"},
+		"=SMALL(A1:A5,1E+20)": {"#NUM!", "k should be <= length of array"},
 		// STANDARDIZE
 		"=STANDARDIZE()":         {"#VALUE!", "STANDARDIZE requires 3 arguments"},
 		"=STANDARDIZE(\"\",0,5)": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
@@ -3554,13 +4048,18 @@ func TestCalcCellValue(t *testing.T) {
 		// WEIBULL
 		"=WEIBULL()":               {"#VALUE!", "WEIBULL requires 4 arguments"},
 		"=WEIBULL(\"\",1,1,FALSE)": {"#VALUE!", "#VALUE!"},
-		"=WEIBULL(1,0,1,FALSE)":    {"#N/A", "#N/A"},
-		"=WEIBULL(1,1,-1,FALSE)":   {"#N/A", "#N/A"},
+		"=WEIBULL(1,0,1,FALSE)":    {"#NUM!", "#NUM!"},
+		"=WEIBULL(1,1,-1,FALSE)":   {"#NUM!", "#NUM!"},
+		"=WEIBULL(1,1,0,TRUE)":     {"#NUM!", "#NUM!"},
+		"=WEIBULL(-1,1,1,TRUE)":    {"#NUM!", "#NUM!"},
+		"=WEIBULL(1,1,1,\"\")":     {"#VALUE!", "strconv.ParseBool: parsing \"\": invalid syntax"},
 		// WEIBULL.DIST
 		"=WEIBULL.DIST()":               {"#VALUE!", "WEIBULL.DIST requires 4 arguments"},
 		"=WEIBULL.DIST(\"\",1,1,FALSE)": {"#VALUE!", "#VALUE!"},
-		"=WEIBULL.DIST(1,0,1,FALSE)":    {"#N/A", "#N/A"},
-		"=WEIBULL.DIST(1,1,-1,FALSE)":   {"#N/A", "#N/A"},
+		"=WEIBULL.DIST(1,0,1,FALSE)":    {"#NUM!", "#NUM!"},
+		"=WEIBULL.DIST(1,1,-1,FALSE)":   {"#NUM!", "#NUM!"},
+		"=WEIBULL.DIST(1,-2,1,FALSE)":   {"#NUM!", "#NUM!"},
+		"=WEIBULL.DIST(-0.5,1,1,FALSE)": {"#NUM!", "#NUM!"},
 		// Z.TEST
 		"=Z.TEST(A1)":        {"#VALUE!", "Z.TEST requires at least 2 arguments"},
 		"=Z.TEST(A1,0,0,0)":  {"#VALUE!", "Z.TEST accepts at most 3 arguments"},
@@ -3637,8 +4136,12 @@ func TestCalcCellValue(t *testing.T) {
 		// IFNA
 		"=IFNA()": {"#VALUE!", "IFNA requires 2 arguments"},
 		// IFS
-		"=IFS()":            {"#VALUE!", "IFS requires at least 2 arguments"},
-		"=IFS(FALSE,FALSE)": {"#N/A", "#N/A"},
+		"=IFS()":              {"#VALUE!", "IFS requires at least 2 arguments"},
+		"=IFS(FALSE,FALSE)":   {"#N/A", "#N/A"},
+		"=IFS(1>2,1,0,2)":     {"#N/A", "#N/A"},
+		"=IFS(NA(),1,TRUE,2)": {"#N/A", "#N/A"},
+		"=IFS(FALSE,1,1/0,2)": {"#DIV/0!", "#DIV/0!"},
+		"=IFS(TRUE,1,FALSE)":  {"#VALUE!", "IFS requires an even number of arguments"},
 		// NOT
 		"=NOT()":      {"#VALUE!", "NOT requires 1 argument"},
 		"=NOT(NOT())": {"#VALUE!", "NOT requires 1 argument"},
@@ -3650,8 +4153,10 @@ func TestCalcCellValue(t *testing.T) {
 		"=OR()":                                  {"#VALUE!", "OR requires at least 1 argument"},
 		"=OR(1" + strings.Repeat(",1", 30) + ")": {"#VALUE!", "OR accepts at most 30 arguments"},
 		// SWITCH
-		"=SWITCH()":      {"#VALUE!", "SWITCH requires at least 3 arguments"},
-		"=SWITCH(0,1,2)": {"#N/A", "#N/A"},
+		"=SWITCH()":           {"#VALUE!", "SWITCH requires at least 3 arguments"},
+		"=SWITCH(0,1,2)":      {"#N/A", "#N/A"},
+		"=SWITCH(1,\"1\",2)":  {"#N/A", "#N/A"},
+		"=SWITCH(NA(),1,2,3)": {"#N/A", "#N/A"},
 		// TRUE
 		"=TRUE(A1)": {"#VALUE!", "TRUE takes no arguments"},
 		// XOR
@@ -3665,6 +4170,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=DATE(\"text\",10,21)":   {"#VALUE!", "DATE requires 3 number arguments"},
 		"=DATE(2020,\"text\",21)": {"#VALUE!", "DATE requires 3 number arguments"},
 		"=DATE(2020,10,\"text\")": {"#VALUE!", "DATE requires 3 number arguments"},
+		"=DATE(-1,1,1)":           {"#NUM!", "#NUM!"},
+		"=DATE(10000,1,1)":        {"#NUM!", "#NUM!"},
 		// DATEDIF
 		"=DATEDIF()":                  {"#VALUE!", "DATEDIF requires 3 number arguments"},
 		"=DATEDIF(\"\",\"\",\"\")":    {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
@@ -3726,12 +4233,14 @@ func TestCalcCellValue(t *testing.T) {
 		"=EDATE(-1,0)":                  {"#NUM!", "#NUM!"},
 		"=EDATE(\"\",0)":                {"#VALUE!", "#VALUE!"},
 		"=EDATE(\"January 25, 100\",0)": {"#VALUE!", "#VALUE!"},
+		"=EDATE(\"01/15/1900\",-1)":     {"#NUM!", "#NUM!"},
 		// EOMONTH
 		"=EOMONTH()":                      {"#VALUE!", "EOMONTH requires 2 arguments"},
 		"=EOMONTH(0,\"\")":                {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=EOMONTH(-1,0)":                  {"#NUM!", "#NUM!"},
 		"=EOMONTH(\"\",0)":                {"#VALUE!", "#VALUE!"},
 		"=EOMONTH(\"January 25, 100\",0)": {"#VALUE!", "#VALUE!"},
+		"=EOMONTH(\"01/15/1900\",-1)":     {"#NUM!", "#NUM!"},
 		// HOUR
 		"=HOUR()":             {"#VALUE!", "HOUR requires exactly 1 argument"},
 		"=HOUR(-1)":           {"#NUM!", "HOUR only accepts positive argument"},
@@ -3765,6 +4274,7 @@ func TestCalcCellValue(t *testing.T) {
 		"=YEARFRAC(\"\",42094,5)":     {"#VALUE!", "#VALUE!"},
 		"=YEARFRAC(42005,\"\",5)":     {"#VALUE!", "#VALUE!"},
 		"=YEARFRAC(42005,42094,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
+		"=YEARFRAC(-1,42094)":         {"#NUM!", "#NUM!"},
 		// NOW
 		"=NOW(A1)": {"#VALUE!", "NOW accepts no arguments"},
 		// SECOND
@@ -3776,6 +4286,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=TIME()":         {"#VALUE!", "TIME requires 3 number arguments"},
 		"=TIME(\"\",0,0)": {"#VALUE!", "TIME requires 3 number arguments"},
 		"=TIME(0,0,-1)":   {"#NUM!", "#NUM!"},
+		// TIME with argument greater than 32767
+		"=TIME(32768,0,0)": {"#NUM!", "#NUM!"},
 		// TIMEVALUE
 		"=TIMEVALUE()":          {"#VALUE!", "TIMEVALUE requires exactly 1 argument"},
 		"=TIMEVALUE(1)":         {"#VALUE!", "#VALUE!"},
@@ -3788,9 +4300,13 @@ func TestCalcCellValue(t *testing.T) {
 		"=WEEKDAY(0,1,0)":               {"#VALUE!", "WEEKDAY allows at most 2 arguments"},
 		"=WEEKDAY(0,\"\")":              {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=WEEKDAY(\"\",1)":              {"#VALUE!", "#VALUE!"},
-		"=WEEKDAY(0,0)":                 {"#VALUE!", "#VALUE!"},
+		"=WEEKDAY(0,0)":                 {"#NUM!", "#NUM!"},
 		"=WEEKDAY(\"January 25, 100\")": {"#VALUE!", "#VALUE!"},
 		"=WEEKDAY(-1,1)":                {"#NUM!", "#NUM!"},
+		// WEEKDAY with unsupported return type
+		"=WEEKDAY(45292,4)":  {"#NUM!", "#NUM!"},
+		"=WEEKDAY(45292,10)": {"#NUM!", "#NUM!"},
+		"=WEEKDAY(45292,18)": {"#NUM!", "#NUM!"},
 		// WEEKNUM
 		"=WEEKNUM()":                    {"#VALUE!", "WEEKNUM requires at least 1 argument"},
 		"=WEEKNUM(0,1,0)":               {"#VALUE!", "WEEKNUM allows at most 2 arguments"},
@@ -3805,6 +4321,12 @@ func TestCalcCellValue(t *testing.T) {
 		"=ARRAYTOTEXT(A1,0,0)":  {"#VALUE!", "ARRAYTOTEXT allows at most 2 arguments"},
 		"=ARRAYTOTEXT(A1,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=ARRAYTOTEXT(A1,2)":    {"#VALUE!", "#VALUE!"},
+		// ASC
+		"=ASC()":    {"#VALUE!", "ASC requires 1 argument"},
+		"=ASC(1,2)": {"#VALUE!", "ASC requires 1 argument"},
+		// DBCS
+		"=DBCS()":    {"#VALUE!", "DBCS requires 1 argument"},
+		"=DBCS(1,2)": {"#VALUE!", "DBCS requires 1 argument"},
 		// CHAR
 		"=CHAR()":     {"#VALUE!", "CHAR requires 1 argument"},
 		"=CHAR(-1)":   {"#VALUE!", "#VALUE!"},
@@ -3872,6 +4394,9 @@ func TestCalcCellValue(t *testing.T) {
 		"=MIDB(\"\",1,-1)":   {"#VALUE!", "#VALUE!"},
 		"=MIDB(\"\",\"\",1)": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=MIDB(\"\",1,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
+		// PHONETIC
+		"=PHONETIC()":  {"#VALUE!", "PHONETIC requires 1 argument"},
+		"=PHONETIC(1)": {"#VALUE!", "#VALUE!"},
 		// PROPER
 		"=PROPER()":    {"#VALUE!", "PROPER requires 1 argument"},
 		"=PROPER(1,2)": {"#VALUE!", "PROPER requires 1 argument"},
@@ -3890,6 +4415,9 @@ func TestCalcCellValue(t *testing.T) {
 		"=REPT(INT(0),2)":    {"#VALUE!", "REPT requires first argument to be a string"},
 		"=REPT(\"*\",\"*\")": {"#VALUE!", "REPT requires second argument to be a number"},
 		"=REPT(\"*\",-1)":    {"#VALUE!", "REPT requires second argument to be >= 0"},
+		"=REPT(\"*\",-0.5)":  {"#VALUE!", "REPT requires second argument to be >= 0"},
+		"=REPT(\"*\",32768)": {"#VALUE!", "REPT function exceeds 32767 characters"},
+		"=REPT(D1,9999)":     {"#VALUE!", "REPT function exceeds 32767 characters"},
 		// RIGHT
 		"=RIGHT()":          {"#VALUE!", "RIGHT requires at least 1 argument"},
 		"=RIGHT(\"\",2,3)":  {"#VALUE!", "RIGHT allows at most 2 arguments"},
@@ -3900,6 +4428,14 @@ func TestCalcCellValue(t *testing.T) {
 		"=RIGHTB(\"\",2,3)":  {"#VALUE!", "RIGHTB allows at most 2 arguments"},
 		"=RIGHTB(\"\",\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=RIGHTB(\"\",-1)":   {"#VALUE!", "#VALUE!"},
+		// LEFT, MID and RIGHT with negative arguments on multibyte text
+		"=LEFT(\"中文\",-1)":   {"#VALUE!", "#VALUE!"},
+		"=LEFTB(\"中文\",-1)":  {"#VALUE!", "#VALUE!"},
+		"=MID(\"😀😁\",-1,1)":  {"#VALUE!", "#VALUE!"},
+		"=MID(\"😀😁\",1,-1)":  {"#VALUE!", "#VALUE!"},
+		"=MIDB(\"😀😁\",1,-1)": {"#VALUE!", "#VALUE!"},
+		"=RIGHT(\"中文\",-1)":  {"#VALUE!", "#VALUE!"},
+		"=RIGHTB(\"中文\",-1)": {"#VALUE!", "#VALUE!"},
 		// SUBSTITUTE
 		"=SUBSTITUTE()":                    {"#VALUE!", "SUBSTITUTE requires 3 or 4 arguments"},
 		"=SUBSTITUTE(\"\",\"\",\"\",\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
@@ -3931,7 +4467,7 @@ func TestCalcCellValue(t *testing.T) {
 		"=TEXTJOIN(\"\",\"\",1)":    {"#VALUE!", "#VALUE!"},
 		"=TEXTJOIN(\"\",TRUE,NA())": {"#N/A", "#N/A"},
 		"=TEXTJOIN(\"\",TRUE," + strings.Repeat("0,", 250) + ",0)": {"#VALUE!", "TEXTJOIN accepts at most 252 arguments"},
-		"=TEXTJOIN(\",\",FALSE,REPT(\"*\",32768))":                 {"#VALUE!", "TEXTJOIN function exceeds 32767 characters"},
+		"=TEXTJOIN(\",\",FALSE,REPT(\"*\",32767),\"*\")":           {"#VALUE!", "TEXTJOIN function exceeds 32767 characters"},
 		// TRIM
 		"=TRIM()":    {"#VALUE!", "TRIM requires 1 argument"},
 		"=TRIM(1,2)": {"#VALUE!", "TRIM requires 1 argument"},
@@ -3940,6 +4476,9 @@ func TestCalcCellValue(t *testing.T) {
 		"=UNICHAR(\"\")":  {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=UNICHAR(55296)": {"#VALUE!", "#VALUE!"},
 		"=UNICHAR(0)":     {"#VALUE!", "#VALUE!"},
+		// UNICHAR with the surrogate or out of range code points
+		"=UNICHAR(57343)":   {"#VALUE!", "#VALUE!"},
+		"=UNICHAR(1114112)": {"#VALUE!", "#VALUE!"},
 		// UNICODE
 		"=UNICODE()":     {"#VALUE!", "UNICODE requires 1 argument"},
 		"=UNICODE(\"\")": {"#VALUE!", "#VALUE!"},
@@ -3956,9 +4495,10 @@ func TestCalcCellValue(t *testing.T) {
 		"=UPPER(1,2)": {"#VALUE!", "UPPER requires 1 argument"},
 		// Conditional Functions
 		// IF
-		"=IF()":        {"#VALUE!", "IF requires at least 1 argument"},
-		"=IF(0,1,2,3)": {"#VALUE!", "IF accepts at most 3 arguments"},
-		"=IF(D1,1,2)":  {"#VALUE!", "strconv.ParseBool: parsing \"Month\": invalid syntax"},
+		"=IF()":          {"#VALUE!", "IF requires at least 1 argument"},
+		"=IF(0,1,2,3)":   {"#VALUE!", "IF accepts at most 3 arguments"},
+		"=IF(D1,1,2)":    {"#VALUE!", "strconv.ParseBool: parsing \"Month\": invalid syntax"},
+		"=IF(\"a\",1,2)": {"#VALUE!", "strconv.ParseBool: parsing \"a\": invalid syntax"},
 		// Excel Lookup and Reference Functions
 		// ADDRESS
 		"=ADDRESS()":                        {"#VALUE!", "ADDRESS requires at least 2 arguments"},
@@ -4012,7 +4552,7 @@ func TestCalcCellValue(t *testing.T) {
 		"=MATCH(0,A1:A1,\"x\")": {"#VALUE!", "MATCH requires numeric match_type argument"},
 		"=MATCH(0,A1)":          {"#N/A", "MATCH arguments lookup_array should be one-dimensional array"},
 		"=MATCH(0,A1:B2)":       {"#N/A", "MATCH arguments lookup_array should be one-dimensional array"},
-		"=MATCH(0,A1:B1)":       {"#N/A", "#N/A"},
+		"=MATCH(0,A1:B1)":       {"#N/A", "MATCH no result found"},
 		// TRANSPOSE
 		"=TRANSPOSE()": {"#VALUE!", "TRANSPOSE requires 1 argument"},
 		// HYPERLINK
@@ -4051,6 +4591,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=INDIRECT(\"R C1\",FALSE)":       {"#REF!", "#REF!"},
 		"=INDIRECT(\"R1C \",FALSE)":       {"#REF!", "#REF!"},
 		"=INDIRECT(\"R1C1:R2C \",FALSE)":  {"#REF!", "#REF!"},
+		"=INDIRECT(\"R[-1]C1\",FALSE)":    {"#REF!", "#REF!"},
+		"=INDIRECT(\"A1:B2:C3\")":         {"#REF!", "#REF!"},
 		// LOOKUP
 		"=LOOKUP()":                     {"#VALUE!", "LOOKUP requires at least 2 arguments"},
 		"=LOOKUP(D2,D1,D2)":             {"#VALUE!", "LOOKUP requires second argument of table array"},
@@ -4232,12 +4774,14 @@ func TestCalcCellValue(t *testing.T) {
 		"=DOLLARDE(0,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=DOLLARDE(0,-1)":   {"#NUM!", "#NUM!"},
 		"=DOLLARDE(0,0)":    {"#DIV/0!", "#DIV/0!"},
+		"=DOLLARDE(0,0.5)":  {"#DIV/0!", "#DIV/0!"},
 		// DOLLARFR
 		"=DOLLARFR()":       {"#VALUE!", "DOLLARFR requires 2 arguments"},
 		"=DOLLARFR(\"\",0)": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=DOLLARFR(0,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=DOLLARFR(0,-1)":   {"#NUM!", "#NUM!"},
 		"=DOLLARFR(0,0)":    {"#DIV/0!", "#DIV/0!"},
+		"=DOLLARFR(0,0.5)":  {"#DIV/0!", "#DIV/0!"},
 		// DURATION
 		"=DURATION()": {"#VALUE!", "DURATION requires 5 or 6 arguments"},
 		"=DURATION(\"\",\"03/31/2025\",10%,8%,4)":                {"#VALUE!", "#VALUE!"},
@@ -4265,6 +4809,8 @@ func TestCalcCellValue(t *testing.T) {
 		"=EUROCONVERT(1.47,\"FRF\",\"DEM\",TRUE,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=EUROCONVERT(1.47,\"\",\"DEM\")":              {"#VALUE!", "#VALUE!"},
 		"=EUROCONVERT(1.47,\"FRF\",\"\",TRUE,3)":       {"#VALUE!", "#VALUE!"},
+		"=EUROCONVERT(1.47,\"FRF\",\"DEM\",TRUE,2)":    {"#VALUE!", "#VALUE!"},
+		"=EUROCONVERT(1.47,\"FRF\",\"USD\")":           {"#VALUE!", "#VALUE!"},
 		// FV
 		"=FV()":              {"#VALUE!", "FV requires at least 3 arguments"},
 		"=FV(0,0,0,0,0,0,0)": {"#VALUE!", "FV allows at most 5 arguments"},
@@ -4639,7 +5185,7 @@ func TestCalcCellValue(t *testing.T) {
 		// MDETERM
 		"=MDETERM(A1:B3)": {"#VALUE!", "#VALUE!"},
 		// SUM
-		"=1+SUM(SUM(A1+A2/A4)*(2-3),2)": {"#VALUE!", "#DIV/0!"},
+		"=1+SUM(SUM(A1+A2/A4)*(2-3),2)": {"#DIV/0!", "#DIV/0!"},
 	}
 	for formula, expected := range referenceCalcError {
 		f := prepareCalcData(cellData)
@@ -4719,6 +5265,77 @@ func TestCalcWithDefinedName(t *testing.T) {
 	result, err = f.CalcCellValue("Sheet1", "D1")
 	assert.NoError(t, err)
 	assert.Equal(t, "YES", result, `=IF("B1_as_string"=defined_name1,"YES","NO")`)
+
+	// self-including defined name, the calculating cell will not be
+	// recalculated recursively
+	assert.NoError(t, f.SetDefinedName(&DefinedName{Name: "total", RefersTo: "Sheet1!$A$1:$E$1", Scope: "Workbook"}))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "E1", "=SUM(total)"))
+	result, err = f.CalcCellValue("Sheet1", "E1")
+	assert.NoError(t, err)
+	assert.Equal(t, "123", result, "=SUM(total)")
+	// self-including defined name used without dereference
+	for formula, expected := range map[string]string{
+		"=COLUMNS(total)": "5",
+		"=ROWS(total)":    "1",
+		"=ISREF(total)":   "TRUE",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "E1", formula))
+		result, err = f.CalcCellValue("Sheet1", "E1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
+func TestCalcROMANAndARABIC(t *testing.T) {
+	fn := formulaFuncs{}
+	newArgs := func(args ...formulaArg) *list.List {
+		argsList := list.New()
+		for _, arg := range args {
+			argsList.PushBack(arg)
+		}
+		return argsList
+	}
+	for number := 1; number <= 3999; number++ {
+		roman := fn.ROMAN(newArgs(newNumberFormulaArg(float64(number)), newNumberFormulaArg(0)))
+		assert.Equal(t, ArgString, roman.Type, number)
+		assert.Equal(t, newNumberFormulaArg(float64(number)), fn.ARABIC(newArgs(roman)), number)
+	}
+	for form, expected := range []string{"CDXCIX", "LDVLIV", "XDIX", "VDIV", "ID"} {
+		roman := fn.ROMAN(newArgs(newNumberFormulaArg(499), newNumberFormulaArg(float64(form))))
+		assert.Equal(t, expected, roman.Value(), form)
+		assert.Equal(t, newNumberFormulaArg(499), fn.ARABIC(newArgs(roman)), form)
+	}
+}
+
+func TestCalcERRORTYPE(t *testing.T) {
+	fn := formulaFuncs{}
+	for errType, expected := range map[string]float64{
+		formulaErrorNULL:        1,
+		formulaErrorDIV:         2,
+		formulaErrorVALUE:       3,
+		formulaErrorREF:         4,
+		formulaErrorNAME:        5,
+		formulaErrorNUM:         6,
+		formulaErrorNA:          7,
+		formulaErrorGETTINGDATA: 8,
+		formulaErrorSPILL:       9,
+		formulaErrorCALC:        14,
+	} {
+		argsList := list.New()
+		argsList.PushBack(newErrorFormulaArg(errType, errType))
+		result := fn.ERRORdotTYPE(argsList)
+		assert.Equal(t, ArgNumber, result.Type, errType)
+		assert.Equal(t, expected, result.Number, errType)
+	}
+	for _, arg := range []formulaArg{
+		newNumberFormulaArg(1), newStringFormulaArg("#N/A"), newBoolFormulaArg(true), newEmptyFormulaArg(),
+	} {
+		argsList := list.New()
+		argsList.PushBack(arg)
+		result := fn.ERRORdotTYPE(argsList)
+		assert.Equal(t, ArgError, result.Type, arg.Value())
+		assert.Equal(t, formulaErrorNA, result.String, arg.Value())
+	}
 }
 
 func TestCalcISBLANK(t *testing.T) {
@@ -4820,6 +5437,33 @@ func TestCalcTRANSPOSE(t *testing.T) {
 		FormulaOpts{Ref: &ref, Type: &formulaType}))
 	_, err := f.CalcCellValue("Sheet1", "D1")
 	assert.NoError(t, err, formula)
+
+	fn := formulaFuncs{}
+	// Test transpose mixed-type matrix
+	args := list.New().Init()
+	args.PushBack(newMatrixFormulaArg([][]formulaArg{
+		{newNumberFormulaArg(1), newStringFormulaArg("a"), newBoolFormulaArg(true)},
+		{newErrorFormulaArg(formulaErrorDIV, formulaErrorDIV), newEmptyFormulaArg(), newNumberFormulaArg(2)},
+	}))
+	assert.Equal(t, newMatrixFormulaArg([][]formulaArg{
+		{newNumberFormulaArg(1), newErrorFormulaArg(formulaErrorDIV, formulaErrorDIV)},
+		{newStringFormulaArg("a"), newEmptyFormulaArg()},
+		{newBoolFormulaArg(true), newNumberFormulaArg(2)},
+	}), fn.TRANSPOSE(args))
+	// Test transpose jagged matrix
+	args.Init()
+	args.PushBack(newMatrixFormulaArg([][]formulaArg{
+		{newNumberFormulaArg(1), newNumberFormulaArg(2)},
+		{newNumberFormulaArg(3)},
+	}))
+	assert.Equal(t, newMatrixFormulaArg([][]formulaArg{
+		{newNumberFormulaArg(1), newNumberFormulaArg(3)},
+		{newNumberFormulaArg(2), newErrorFormulaArg(formulaErrorNA, formulaErrorNA)},
+	}), fn.TRANSPOSE(args))
+	// Test transpose scalar value
+	args.Init()
+	args.PushBack(newStringFormulaArg("a"))
+	assert.Equal(t, newStringFormulaArg("a"), fn.TRANSPOSE(args))
 }
 
 func TestCalcVLOOKUP(t *testing.T) {
@@ -4991,6 +5635,8 @@ func TestCalcDatabase(t *testing.T) {
 	assert.NoError(t, f.SetCellFormula("Sheet1", "A2", "=\"=Apple\""))
 	assert.NoError(t, f.SetCellFormula("Sheet1", "A3", "=\"=Pear\""))
 	assert.NoError(t, f.SetCellFormula("Sheet1", "C8", "=NA()"))
+	// computed criteria with blank column header
+	assert.NoError(t, f.SetCellFormula("Sheet1", "G2", "=B5>AVERAGE($B$5:$B$10)"))
 	formulaList := map[string]string{
 		"=DAVERAGE(A4:E10,\"Profit\",A1:F3)": "73.25",
 		"=DCOUNT(A4:E10,\"Age\",A1:F2)":      "1",
@@ -5014,6 +5660,11 @@ func TestCalcDatabase(t *testing.T) {
 		"=DSUM(A4:E10,\"Profit\",A1:F3)":     "293",
 		"=DVAR(A4:E10,\"Profit\",A1:F3)":     "444.25",
 		"=DVARP(A4:E10,\"Profit\",A1:F3)":    "333.1875",
+		"=DCOUNT(A4:E10,,G1:G2)":             "2",
+		"=DMAX(A4:E10,\"Profit\",G1:G2)":     "105",
+		"=DMIN(A4:E10,\"Profit\",G1:G2)":     "75",
+		"=DSUM(A4:E10,\"Profit\",G1:G2)":     "180",
+		"=DSUM(A4:E10,\"Profit\",F1:G2)":     "75",
 	}
 	for formula, expected := range formulaList {
 		assert.NoError(t, f.SetCellFormula("Sheet1", "A11", formula))
@@ -5065,6 +5716,216 @@ func TestCalcDatabase(t *testing.T) {
 	}
 }
 
+func TestCalcDatabaseCriteria(t *testing.T) {
+	cellData := [][]interface{}{
+		{"Region", "Product", "Units", 2020, nil, "region", "PRODUCT", 2020, "Region"},
+		{"East", "Pen", 10, 1, nil, "East", "Book", ">4", "South"},
+		{"West", "Pen", 20, 2, nil, "West", "Pen"},
+		{"East", "Book", 30, 3},
+		{"West", "Book", 40, 4, nil, "Region"},
+		{"North", "Pen", 50, 5, nil, "East"},
+		{nil, nil, nil, nil, nil, "North"},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		// multiple criteria columns AND together with case-insensitive headers
+		"=DSUM(A1:D6,\"Units\",F1:G2)":   "30",
+		"=DGET(A1:D6,\"units\",F1:G2)":   "30",
+		"=DCOUNT(A1:D6,\"Units\",F1:G2)": "1",
+		// multiple criteria rows OR together
+		"=DSUM(A1:D6,\"Units\",F1:G3)":   "50",
+		"=DCOUNT(A1:D6,\"Units\",F1:G3)": "2",
+		"=DSUM(A1:D6,\"Units\",F5:F7)":   "90",
+		"=DCOUNTA(A1:D6,,F5:F7)":         "3",
+		// numeric criteria header matched by label
+		"=DSUM(A1:D6,\"Units\",H1:H2)": "50",
+		"=DGET(A1:D6,3,H1:H2)":         "50",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "K1", formula))
+		result, err := f.CalcCellValue("Sheet1", "K1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	calcError := map[string][]string{
+		"=DGET(A1:D6,\"Units\",F1:G3)": {"#NUM!", "#NUM!"},
+		"=DGET(A1:D6,\"Units\",F5:F7)": {"#NUM!", "#NUM!"},
+		"=DGET(A1:D6,\"Units\",I1:I2)": {"#VALUE!", "#VALUE!"},
+	}
+	for formula, expected := range calcError {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "K1", formula))
+		result, err := f.CalcCellValue("Sheet1", "K1")
+		assert.Equal(t, expected[0], result, formula)
+		assert.EqualError(t, err, expected[1], formula)
+	}
+}
+
+func TestCalcDayCountBasis(t *testing.T) {
+	f := NewFile()
+	// Test the coupon days with the US (NASD) 30/360, actual/actual,
+	// actual/360, actual/365 and European 30/360 day count basis
+	formulaList := map[string]string{
+		"=COUPDAYBS(\"01/25/2011\",\"11/15/2011\",2,0)":  "70",
+		"=COUPDAYBS(\"01/25/2011\",\"11/15/2011\",2,1)":  "71",
+		"=COUPDAYBS(\"01/25/2011\",\"11/15/2011\",2,2)":  "71",
+		"=COUPDAYBS(\"01/25/2011\",\"11/15/2011\",2,3)":  "71",
+		"=COUPDAYBS(\"01/25/2011\",\"11/15/2011\",2,4)":  "70",
+		"=COUPDAYS(\"01/25/2011\",\"11/15/2011\",2,0)":   "180",
+		"=COUPDAYS(\"01/25/2011\",\"11/15/2011\",2,1)":   "181",
+		"=COUPDAYS(\"01/25/2011\",\"11/15/2011\",2,2)":   "180",
+		"=COUPDAYS(\"01/25/2011\",\"11/15/2011\",2,3)":   "182.5",
+		"=COUPDAYS(\"01/25/2011\",\"11/15/2011\",2,4)":   "180",
+		"=COUPDAYSNC(\"01/25/2011\",\"11/15/2011\",2,0)": "110",
+		"=COUPDAYSNC(\"01/25/2011\",\"11/15/2011\",2,1)": "110",
+		"=COUPDAYSNC(\"01/25/2011\",\"11/15/2011\",2,2)": "110",
+		"=COUPDAYSNC(\"01/25/2011\",\"11/15/2011\",2,3)": "110",
+		"=COUPDAYSNC(\"01/25/2011\",\"11/15/2011\",2,4)": "110",
+		"=COUPDAYS(\"01/25/2011\",\"11/15/2011\",2,3.9)": "182.5",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "A1", formula))
+		result, err := f.CalcCellValue("Sheet1", "A1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	// Test the bond functions with invalid day count basis
+	for _, formula := range []string{
+		"=ACCRINT(\"01/25/2011\",\"05/15/2011\",\"11/15/2011\",5%,100,2,5)",
+		"=COUPDAYBS(\"01/25/2011\",\"11/15/2011\",2,-1)",
+		"=COUPDAYS(\"01/25/2011\",\"11/15/2011\",2,5)",
+		"=COUPDAYSNC(\"01/25/2011\",\"11/15/2011\",2,5)",
+		"=DURATION(\"01/25/2011\",\"11/15/2011\",5%,6%,2,5)",
+		"=PRICE(\"01/25/2011\",\"11/15/2011\",5%,6%,100,2,5)",
+		"=YIELD(\"01/25/2011\",\"11/15/2011\",5%,99,100,2,-1)",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "A1", formula))
+		result, err := f.CalcCellValue("Sheet1", "A1")
+		assert.Equal(t, formulaErrorNUM, result, formula)
+		assert.EqualError(t, err, "invalid basis", formula)
+	}
+}
+
+func TestCalcCouponDates(t *testing.T) {
+	f := NewFile()
+	formulaList := map[string]string{
+		// Test semiannual bond with month-end maturity, settlement off the coupon date
+		"=COUPPCD(\"05/30/2011\",\"11/30/2011\",2)":      "40512",
+		"=COUPNCD(\"05/30/2011\",\"11/30/2011\",2)":      "40694",
+		"=COUPNUM(\"05/30/2011\",\"11/30/2011\",2)":      "2",
+		"=COUPDAYBS(\"05/30/2011\",\"11/30/2011\",2,0)":  "180",
+		"=COUPDAYBS(\"05/30/2011\",\"11/30/2011\",2,1)":  "181",
+		"=COUPDAYS(\"05/30/2011\",\"11/30/2011\",2,0)":   "180",
+		"=COUPDAYS(\"05/30/2011\",\"11/30/2011\",2,1)":   "182",
+		"=COUPDAYSNC(\"05/30/2011\",\"11/30/2011\",2,0)": "0",
+		"=COUPDAYSNC(\"05/30/2011\",\"11/30/2011\",2,1)": "1",
+		// Test semiannual bond with month-end maturity, settlement on the coupon date
+		"=COUPPCD(\"05/31/2011\",\"11/30/2011\",2)":      "40694",
+		"=COUPNCD(\"05/31/2011\",\"11/30/2011\",2)":      "40877",
+		"=COUPNUM(\"05/31/2011\",\"11/30/2011\",2)":      "1",
+		"=COUPDAYBS(\"05/31/2011\",\"11/30/2011\",2,0)":  "0",
+		"=COUPDAYBS(\"05/31/2011\",\"11/30/2011\",2,1)":  "0",
+		"=COUPDAYS(\"05/31/2011\",\"11/30/2011\",2,1)":   "183",
+		"=COUPDAYSNC(\"05/31/2011\",\"11/30/2011\",2,0)": "180",
+		"=COUPDAYSNC(\"05/31/2011\",\"11/30/2011\",2,1)": "183",
+		"=COUPDAYSNC(\"05/31/2011\",\"11/30/2011\",2,3)": "183",
+		// Test quarterly bond, settlement on the coupon date
+		"=COUPPCD(\"03/31/2011\",\"12/31/2011\",4)":      "40633",
+		"=COUPNCD(\"03/31/2011\",\"12/31/2011\",4)":      "40724",
+		"=COUPNUM(\"03/31/2011\",\"12/31/2011\",4)":      "3",
+		"=COUPDAYBS(\"03/31/2011\",\"12/31/2011\",4,1)":  "0",
+		"=COUPDAYS(\"03/31/2011\",\"12/31/2011\",4,1)":   "91",
+		"=COUPDAYS(\"03/31/2011\",\"12/31/2011\",4,3)":   "91.25",
+		"=COUPDAYSNC(\"03/31/2011\",\"12/31/2011\",4,0)": "90",
+		"=COUPDAYSNC(\"03/31/2011\",\"12/31/2011\",4,1)": "91",
+		"=COUPDAYSNC(\"03/31/2011\",\"12/31/2011\",4,4)": "90",
+		// Test quarterly bond, settlement off the coupon date
+		"=COUPPCD(\"02/15/2011\",\"12/31/2011\",4)":      "40543",
+		"=COUPNCD(\"02/15/2011\",\"12/31/2011\",4)":      "40633",
+		"=COUPNUM(\"02/15/2011\",\"12/31/2011\",4)":      "4",
+		"=COUPDAYBS(\"02/15/2011\",\"12/31/2011\",4,0)":  "45",
+		"=COUPDAYBS(\"02/15/2011\",\"12/31/2011\",4,1)":  "46",
+		"=COUPDAYBS(\"02/15/2011\",\"12/31/2011\",4,2)":  "46",
+		"=COUPDAYS(\"02/15/2011\",\"12/31/2011\",4,1)":   "90",
+		"=COUPDAYS(\"02/15/2011\",\"12/31/2011\",4,2)":   "90",
+		"=COUPDAYSNC(\"02/15/2011\",\"12/31/2011\",4,0)": "45",
+		"=COUPDAYSNC(\"02/15/2011\",\"12/31/2011\",4,1)": "44",
+		"=COUPDAYSNC(\"02/15/2011\",\"12/31/2011\",4,4)": "45",
+		// Test annual bond, settlement on the coupon date
+		"=COUPPCD(\"12/15/2011\",\"12/15/2012\",1)": "40892",
+		"=COUPNCD(\"12/15/2011\",\"12/15/2012\",1)": "41258",
+		"=COUPNUM(\"12/15/2011\",\"12/15/2012\",1)": "1",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "A1", formula))
+		result, err := f.CalcCellValue("Sheet1", "A1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
+func TestCalcGregorianDates(t *testing.T) {
+	f := NewFile()
+	for formula, expected := range map[string][2]string{
+		"=DATEVALUE(\"01/01/1900\")": {"1", "2"},
+		"=DATEVALUE(\"02/28/1900\")": {"59", "60"},
+		"=DATEVALUE(\"03/01/1900\")": {"61", "61"},
+		"=DAY(59)":                   {"28", "27"},
+		"=DAY(60)":                   {"29", "28"},
+		"=DAY(61)":                   {"1", "1"},
+		"=MONTH(60)":                 {"2", "2"},
+		"=DAY(1)":                    {"1", "31"},
+		"=WEEKDAY(59)":               {"3", "3"},
+		"=WEEKDAY(61)":               {"5", "5"},
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "A1", formula))
+		result, err := f.CalcCellValue("Sheet1", "A1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected[0], result, formula)
+		result, err = f.CalcCellValue("Sheet1", "A1", Options{GregorianDates: true})
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected[1], result, formula)
+	}
+}
+
+func TestCalcFREQUENCY(t *testing.T) {
+	cellData := [][]interface{}{
+		{79, 70, 89, 79},
+		{85, 79, 70, 79},
+		{78, 89, 79, 89},
+		{85},
+		{50},
+		{81},
+		{95},
+		{88},
+		{97},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=ROWS(FREQUENCY(A1:A9,B1:B3))":    "4",
+		"=COLUMNS(FREQUENCY(A1:A9,B1:B3))": "1",
+		"=INDEX(FREQUENCY(A1:A9,B1:B3),1)": "1",
+		"=INDEX(FREQUENCY(A1:A9,B1:B3),2)": "2",
+		"=INDEX(FREQUENCY(A1:A9,B1:B3),3)": "4",
+		"=INDEX(FREQUENCY(A1:A9,B1:B3),4)": "2",
+		// unsorted bins
+		"=INDEX(FREQUENCY(A1:A9,C1:C3),1)": "4",
+		"=INDEX(FREQUENCY(A1:A9,C1:C3),2)": "1",
+		"=INDEX(FREQUENCY(A1:A9,C1:C3),3)": "2",
+		"=INDEX(FREQUENCY(A1:A9,C1:C3),4)": "2",
+		// duplicate bins
+		"=INDEX(FREQUENCY(A1:A9,D1:D3),1)": "3",
+		"=INDEX(FREQUENCY(A1:A9,D1:D3),2)": "0",
+		"=INDEX(FREQUENCY(A1:A9,D1:D3),3)": "4",
+		"=INDEX(FREQUENCY(A1:A9,D1:D3),4)": "2",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "F1", formula))
+		result, err := f.CalcCellValue("Sheet1", "F1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
 func TestCalcFORMULATEXT(t *testing.T) {
 	f, formulaText := NewFile(), "=SUM(B1:C1)"
 	assert.NoError(t, f.SetCellFormula("Sheet1", "A1", formulaText))
@@ -5154,6 +6015,131 @@ func TestCalcGROWTHandTREND(t *testing.T) {
 	}
 }
 
+func TestCalcTRENDMultipleRegression(t *testing.T) {
+	cellData := [][]interface{}{
+		{10, 1, 4, 0},
+		{14, 2, 3, 0},
+		{21, 3, 7, 1},
+		{25, 4, 6, 1},
+		{33, 5, 9, 2},
+		{},
+		{nil, 6, 8},
+		{nil, 7, 12},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=TREND(A1:A5,B1:C5,B7:C8)":       "36.4033898305085",
+		"=TREND(A1:A5,B1:C5,B8:C8)":       "44.1627118644068",
+		"=TREND(A1:A5,B1:C5)":             "9.81016949152543",
+		"=TREND(A1:A5,B1:C5,B7:C8,FALSE)": "36.5861386138614",
+		"=TREND(A1:A5,B1:C5,B8:C8,FALSE)": "45.7465346534653",
+		// known x's and known y's contains zero
+		"=TREND(A1:A5,D1:D5,B7)": "75.5714285714286",
+		"=TREND(D1:D5,B1:B5,B7)": "2.3",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "E1", formula))
+		result, err := f.CalcCellValue("Sheet1", "E1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	// Test the result matrix has the same layout as the new x's
+	fn := formulaFuncs{}
+	knownY := [][]formulaArg{}
+	knownX := [][]formulaArg{}
+	for _, row := range cellData[:5] {
+		knownY = append(knownY, []formulaArg{newNumberFormulaArg(float64(row[0].(int)))})
+		knownX = append(knownX, []formulaArg{newNumberFormulaArg(float64(row[1].(int))), newNumberFormulaArg(float64(row[2].(int)))})
+	}
+	args := list.New().Init()
+	args.PushBack(newMatrixFormulaArg(knownY))
+	args.PushBack(newMatrixFormulaArg(knownX))
+	args.PushBack(newMatrixFormulaArg([][]formulaArg{
+		{newNumberFormulaArg(6), newNumberFormulaArg(8)},
+		{newNumberFormulaArg(7), newNumberFormulaArg(12)},
+	}))
+	args.PushBack(newBoolFormulaArg(false))
+	result := fn.TREND(args)
+	assert.Equal(t, ArgMatrix, result.Type)
+	assert.Len(t, result.Matrix, 2)
+	assert.Len(t, result.Matrix[0], 1)
+	assert.InDelta(t, 36.5861386138614, result.Matrix[0][0].Number, 1e-9)
+	assert.InDelta(t, 45.7465346534653, result.Matrix[1][0].Number, 1e-9)
+}
+
+func TestCalcLINESTandLOGEST(t *testing.T) {
+	cellData := [][]interface{}{
+		{142000, 2310, 2, 2, 20, nil, 11, 33100, nil, 1, 0, 1},
+		{144000, 2333, 2, 2, 12, nil, 12, 47300, nil, 9, 4, 1},
+		{151000, 2356, 3, 1.5, 33, nil, 13, 69000, nil, 5, 2, 1},
+		{150000, 2379, 3, 2, 43, nil, 14, 102000, nil, 7, 3, 1},
+		{139000, 2402, 2, 3, 53, nil, 15, 150000},
+		{169000, 2425, 4, 2, 23, nil, 16, 220000},
+		{126000, 2448, 2, 1.5, 99},
+		{142900, 2471, 2, 2, 34},
+		{163000, 2494, 3, 3, 23},
+		{169000, 2517, 4, 4, 55},
+		{149000, 2540, 2, 3, 22},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		// simple linear regression
+		"=LINEST(J1:J4,K1:K4)":                           "2",
+		"=INDEX(LINEST(J1:J4,K1:K4),1,2)":                "1",
+		"=ROUND(INDEX(LINEST(J1:J4,K1:K4,FALSE),1,1),6)": "2.310345",
+		"=INDEX(LINEST(J1:J4,K1:K4,FALSE),1,2)":          "0",
+		"=INDEX(LINEST(J1:J4),1,1)":                      "1.4",
+		"=ROUND(INDEX(LINEST(J1:J4,,,TRUE),3,1),6)":      "0.28",
+		// multiple regression with the statistics
+		"=ROUND(INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),1,1),4)": "-234.2372",
+		"=ROUND(INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),1,4),4)": "27.6414",
+		"=ROUND(INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),1,5),4)": "52317.8305",
+		"=ROUND(INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),2,1),4)": "13.268",
+		"=ROUND(INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),2,5),4)": "12237.3616",
+		"=ROUND(INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),3,1),4)": "0.9967",
+		"=ROUND(INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),3,2),4)": "970.5785",
+		"=ROUND(INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),4,1),4)": "459.7537",
+		"=INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),4,2)":          "6",
+		"=ROUND(INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),5,1),4)": "1732393319.2293",
+		"=ROUND(INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),5,2),4)": "5652135.3162",
+		"=ROUND(INDEX(LINEST(J1:J4,K1:K4,FALSE,TRUE),3,1),6)":  "0.992263",
+		"=INDEX(LINEST(J1:J4,K1:K4,FALSE,TRUE),4,2)":           "3",
+		// exponential regression
+		"=ROUND(INDEX(LOGEST(H1:H6,G1:G6),1,1),6)":           "1.463276",
+		"=ROUND(INDEX(LOGEST(H1:H6,G1:G6),1,2),4)":           "495.3048",
+		"=INDEX(LOGEST(H1:H6,G1:G6,FALSE),1,2)":              "1",
+		"=ROUND(INDEX(LOGEST(H1:H6,G1:G6,TRUE,TRUE),2,1),6)": "0.002633",
+		"=ROUND(INDEX(LOGEST(H1:H6,G1:G6,TRUE,TRUE),3,1),6)": "0.999809",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "N1", formula))
+		result, err := f.CalcCellValue("Sheet1", "N1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	calcError := map[string][]string{
+		"=LINEST()":                                   {"#VALUE!", "LINEST requires at least 1 argument"},
+		"=LINEST(J1:J4,K1:K4,TRUE,TRUE,TRUE)":         {"#VALUE!", "LINEST allows at most 4 arguments"},
+		"=LINEST(\"\")":                               {"#VALUE!", "#VALUE!"},
+		"=LINEST(J1:J4,\"\")":                         {"#VALUE!", "#VALUE!"},
+		"=LINEST(J1:J4,K1:K4,\"\")":                   {"#VALUE!", "strconv.ParseBool: parsing \"\": invalid syntax"},
+		"=LINEST(J1:J4,K1:K4,TRUE,\"\")":              {"#VALUE!", "strconv.ParseBool: parsing \"\": invalid syntax"},
+		"=LINEST(J1:J4,K1:K3)":                        {"#REF!", "#REF!"},
+		"=LINEST(J1:J4,L1:L4)":                        {"#NUM!", "#NUM!"},
+		"=LINEST(J1,K1)":                              {"#NUM!", "#NUM!"},
+		"=INDEX(LINEST(A1:A11,B1:E11,TRUE,TRUE),3,3)": {"#N/A", "#N/A"},
+		"=INDEX(LINEST(J1:J4,K1:K4,FALSE,TRUE),2,2)":  {"#N/A", "#N/A"},
+		"=LOGEST()":                                   {"#VALUE!", "LOGEST requires at least 1 argument"},
+		"=LOGEST(K1:K4)":                              {"#NUM!", "#NUM!"},
+	}
+	for formula, expected := range calcError {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "N1", formula))
+		result, err := f.CalcCellValue("Sheet1", "N1")
+		assert.Equal(t, expected[0], result, formula)
+		assert.EqualError(t, err, expected[1], formula)
+	}
+}
+
 func TestCalcHLOOKUP(t *testing.T) {
 	cellData := [][]interface{}{
 		{"Example Result Table"},
@@ -5285,12 +6271,13 @@ func TestCalcFTEST(t *testing.T) {
 }
 
 func TestCalcIRR(t *testing.T) {
-	cellData := [][]interface{}{{-1}, {0.2}, {0.24}, {0.288}, {0.3456}, {0.4147}}
+	cellData := [][]interface{}{{-1, nil, -1}, {0.2, nil, 1}, {0.24, nil, -1}, {0.288}, {0.3456}, {0.4147}}
 	f := prepareCalcData(cellData)
 	formulaList := map[string]string{
 		"=IRR(A1:A4)":      "-0.136189509034157",
 		"=IRR(A1:A6)":      "0.130575760006905",
 		"=IRR(A1:A4,-0.1)": "-0.136189514994621",
+		"=IRR(A1:A6,0.5)":  "0.130575753748417",
 	}
 	for formula, expected := range formulaList {
 		assert.NoError(t, f.SetCellFormula("Sheet1", "B1", formula))
@@ -5303,6 +6290,9 @@ func TestCalcIRR(t *testing.T) {
 		"=IRR(0,0,0)":  {"#VALUE!", "IRR allows at most 2 arguments"},
 		"=IRR(0,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=IRR(A2:A3)":  {"#NUM!", "#NUM!"},
+		"=IRR(A2:A6)":  {"#NUM!", "#NUM!"},
+		"=IRR(C1:C3)":  {"#NUM!", "#NUM!"},
+		"=IRR(NA())":   {"#N/A", "#N/A"},
 	}
 	for formula, expected := range calcError {
 		assert.NoError(t, f.SetCellFormula("Sheet1", "B1", formula))
@@ -5353,6 +6343,8 @@ func TestCalcMIRR(t *testing.T) {
 		"=MIRR(A1:A5,\"\",0)": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=MIRR(A1:A5,0,\"\")": {"#VALUE!", "strconv.ParseFloat: parsing \"\": invalid syntax"},
 		"=MIRR(B1:B5,0,0)":    {"#DIV/0!", "#DIV/0!"},
+		"=MIRR(A2:A6,0,0)":    {"#DIV/0!", "#DIV/0!"},
+		"=MIRR(A1,0,0)":       {"#DIV/0!", "#DIV/0!"},
 	}
 	for formula, expected := range calcError {
 		assert.NoError(t, f.SetCellFormula("Sheet1", "B1", formula))
@@ -5362,6 +6354,198 @@ func TestCalcMIRR(t *testing.T) {
 	}
 }
 
+func TestCalcSUBTOTALNested(t *testing.T) {
+	cellData := [][]interface{}{{10}, {20}, {nil}, {5}}
+	f := prepareCalcData(cellData)
+	for cell, formula := range map[string]string{
+		"A3": "=SUBTOTAL(9,A1:A2)",
+		"A5": "=SUBTOTAL(9,A4)",
+		"A6": "=SUM(A1:A2)",
+		"A7": "=_xlfn.AGGREGATE(9,3,A1:A2)",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", cell, formula))
+	}
+	formulaList := map[string]string{
+		"=SUBTOTAL(9,A1:A5)":             "35",
+		"=SUBTOTAL(109,A1:A5)":           "35",
+		"=SUBTOTAL(2,A1:A5)":             "3",
+		"=SUBTOTAL(9,A1:A7)":             "65",
+		"=SUBTOTAL(9,A3)":                "0",
+		"=SUBTOTAL(9,A3,A1,A6)":          "40",
+		"=SUBTOTAL(9,Sheet1!A1:A5)":      "35",
+		"=_xlfn.AGGREGATE(9,0,A1:A7)":    "65",
+		"=_xlfn.AGGREGATE(9,3,A1:A5)":    "35",
+		"=_xlfn.AGGREGATE(9,4,A1:A5)":    "70",
+		"=_xlfn.AGGREGATE(14,0,A1:A7,1)": "30",
+		"=SUM(A1:A5)":                    "70",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
+func TestCalcN(t *testing.T) {
+	f := NewFile()
+	assert.NoError(t, f.SetCellValue("Sheet1", "A1", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A2", true))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A3", false))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A4", "text"))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A5", 2.5))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "A6", "=NA()"))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A8", "TRUE"))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A9", "10"))
+	formulaList := map[string]string{
+		"=N(A1)":    "43831",
+		"=N(A2)":    "1",
+		"=N(A3)":    "0",
+		"=N(A4)":    "0",
+		"=N(A5)":    "2.5",
+		"=N(A1:A5)": "43831",
+		"=N(A7)":    "0",
+		"=N(A8)":    "0",
+		"=N(A9)":    "0",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "B1", formula))
+		result, err := f.CalcCellValue("Sheet1", "B1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	assert.NoError(t, f.SetCellFormula("Sheet1", "B1", "=N(A6)"))
+	result, err := f.CalcCellValue("Sheet1", "B1")
+	assert.Equal(t, "#N/A", result)
+	assert.EqualError(t, err, "#N/A")
+}
+
+func TestCalcRANK(t *testing.T) {
+	cellData := [][]interface{}{{7}, {3}, {3}, {"text"}, {nil}, {5}, {true}, {1}}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=RANK(7,A1:A8)":         "1",
+		"=RANK(5,A1:A8)":         "2",
+		"=RANK(3,A1:A8)":         "3",
+		"=RANK(1,A1:A8)":         "5",
+		"=RANK(3,A1:A8,0)":       "3",
+		"=RANK(1,A1:A8,1)":       "1",
+		"=RANK(3,A1:A8,1)":       "2",
+		"=RANK(5,A1:A8,1)":       "4",
+		"=RANK(7,A1:A8,-1)":      "5",
+		"=RANK(5,A1:A8,0.5)":     "4",
+		"=RANK.EQ(3,A1:A8)":      "3",
+		"=RANK.EQ(5,A1:A8)":      "2",
+		"=RANK.EQ(3,A1:A8,1)":    "2",
+		"=RANK.EQ(5,A1:A8,TRUE)": "4",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "B1", formula))
+		result, err := f.CalcCellValue("Sheet1", "B1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	assert.NoError(t, f.SetCellFormula("Sheet1", "A9", "=NA()"))
+	calcError := map[string][]string{
+		"=RANK(4,A1:A8)":    {"#N/A", "#N/A"},
+		"=RANK(1,A1:A9)":    {"#N/A", "#N/A"},
+		"=RANK.EQ(1,A1:A9)": {"#N/A", "#N/A"},
+	}
+	for formula, expected := range calcError {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "B1", formula))
+		result, err := f.CalcCellValue("Sheet1", "B1")
+		assert.EqualError(t, err, expected[1], formula)
+		assert.Equal(t, expected[0], result, formula)
+	}
+}
+
+func TestCalcArrayErrorPropagation(t *testing.T) {
+	cellData := [][]interface{}{{1, 4, 7}, {2, 5, nil}, {3, 6, 9}}
+	f := prepareCalcData(cellData)
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C2", "=NA()"))
+	formulaList := map[string]string{
+		"=SUMSQ(A1:A3)":            "14",
+		"=SUMSQ(A1:A3,C1:C3)":      "#N/A",
+		"=SUMSQ(A1,NA())":          "#N/A",
+		"=SUMX2MY2(A1:A3,C1:C3)":   "#N/A",
+		"=SUMX2PY2(C1:C3,A1:A3)":   "#N/A",
+		"=SUMXMY2(A1:A3,C1:C3)":    "#N/A",
+		"=SUMPRODUCT(A1:A3,B1:B3)": "32",
+		"=SUMPRODUCT(A1:A3,C1:C3)": "#N/A",
+		"=SUMPRODUCT(A2:C2,A1:A3)": "#N/A",
+		"=SERIESSUM(1,0,1,A1:A3)":  "6",
+		"=SERIESSUM(1,0,1,C1:C3)":  "#N/A",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "E1", formula))
+		result, err := f.CalcCellValue("Sheet1", "E1")
+		assert.Equal(t, expected, result, formula)
+		if expected == "#N/A" {
+			assert.EqualError(t, err, expected, formula)
+			continue
+		}
+		assert.NoError(t, err, formula)
+	}
+}
+
+func TestCalcSUMandPRODUCTLogicalValues(t *testing.T) {
+	cellData := [][]interface{}{{true}, {2}, {false}}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=SUM(TRUE,1)":         "2",
+		"=SUM(TRUE,FALSE,1)":   "2",
+		"=SUM(A1:A2)":          "2",
+		"=SUM(A1,A2)":          "2",
+		"=SUM(A1:A3,TRUE)":     "3",
+		"=PRODUCT(TRUE,3)":     "3",
+		"=PRODUCT(FALSE,3)":    "0",
+		"=PRODUCT(A1:A3,3)":    "6",
+		"=PRODUCT(A3,5)":       "5",
+		"=AVERAGE(TRUE,1)":     "1",
+		"=AVERAGE(TRUE,FALSE)": "0.5",
+		"=AVERAGE(A1:A3)":      "2",
+		"=AVERAGE(A1,A2)":      "2",
+		"=AVERAGEA(A1:A3)":     "1",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
+func TestCalcSUMIFAndAVERAGEIFRangeShapes(t *testing.T) {
+	cellData := [][]interface{}{
+		{1, 10, 100, 1000},
+		{2, 20, 200, 2000},
+		{3, 30, 300, 3000},
+		{4, 40, 400, 4000},
+		{5, 50, 500, 5000},
+		{nil, nil, 600},
+		{nil, nil, 700},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=SUMIF(A1:A5,\">2\",B1)":        "120",
+		"=SUMIF(A1:A5,\">2\",B1:B2)":     "120",
+		"=SUMIF(A1:A5,\">2\",C3:C4)":     "1800",
+		"=SUMIF(A1:A5,\">2\",B1:D5)":     "120",
+		"=SUMIF(A1:B2,\">2\",C1)":        "3000",
+		"=SUMIF(A1:A5,\">2\",C4:C3)":     "1800",
+		"=AVERAGEIF(A1:A5,\">2\",B1)":    "40",
+		"=AVERAGEIF(A1:A5,\">2\",C3:C4)": "600",
+		"=AVERAGEIF(A1:A5,\">2\",B1:D5)": "40",
+		"=AVERAGEIF(A1:B2,\">2\",C1)":    "1500",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "F1", formula))
+		result, err := f.CalcCellValue("Sheet1", "F1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
 func TestCalcSUMIFSAndAVERAGEIFS(t *testing.T) {
 	cellData := [][]interface{}{
 		{"Quarter", "Area", "Sales Rep.", "Sales"},
@@ -5413,20 +6597,51 @@ func TestCalcSUMIFSAndAVERAGEIFS(t *testing.T) {
 	}
 }
 
+func TestCalcCOUNTIFSAndSUMIFSDateCriteria(t *testing.T) {
+	cellData := [][]interface{}{
+		{time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC), 10},
+		{time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 20},
+		{time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC), 30},
+		{time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), 40},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=COUNTIFS(A1:A4,\">=2023-01-01\")":                         "3",
+		"=COUNTIFS(A1:A4,\">=2023-01-01\",A1:A4,\"<2023-02-01\")":   "2",
+		"=COUNTIFS(A1:A4,\"<>2023-01-15\")":                         "3",
+		"=COUNTIFS(A1:A4,\"2023-01-15\")":                           "1",
+		"=COUNTIFS(A1:A4,\">=\"&DATE(2023,1,1))":                    "3",
+		"=SUMIFS(B1:B4,A1:A4,\">=2023-01-01\")":                     "90",
+		"=SUMIFS(B1:B4,A1:A4,\">1/1/2023\",A1:A4,\"<=2023-02-01\")": "70",
+		"=SUMIFS(B1:B4,A1:A4,\"2023-01-15\")":                       "30",
+		"=SUMIFS(B1:B4,A1:A4,\"<2023-01-01 12:00\")":                "30",
+		"=SUMIFS(B1:B4,A1:A4,\">=\"&DATE(2023,1,1),B1:B4,\"<40\")":  "50",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
 func TestCalcXIRR(t *testing.T) {
 	cellData := [][]interface{}{
-		{-100.00, "01/01/2016"},
-		{20.00, "04/01/2016"},
-		{40.00, "10/01/2016"},
-		{25.00, "02/01/2017"},
-		{8.00, "03/01/2017"},
+		{-100.00, "01/01/2016", nil, -10000, "01/01/2008"},
+		{20.00, "04/01/2016", nil, 2750, "03/01/2008"},
+		{40.00, "10/01/2016", nil, 4250, "10/30/2008"},
+		{25.00, "02/01/2017", nil, 3250, "02/15/2009"},
+		{8.00, "03/01/2017", nil, 2750, "04/01/2009"},
 		{15.00, "06/01/2017"},
 		{-1e-10, "09/01/2017"},
 	}
 	f := prepareCalcData(cellData)
 	formulaList := map[string]string{
 		"=XIRR(A1:A4,B1:B4)":     "-0.196743861298328",
+		"=XIRR(A1:A4,B1:B4,0.1)": "-0.196743861298328",
 		"=XIRR(A1:A6,B1:B6,0.5)": "0.0944390744445204",
+		"=XIRR(D1:D5,E1:E5)":     "0.373362533518831",
+		"=XIRR(D1:D5,E1:E5,0.1)": "0.373362533518832",
 	}
 	for formula, expected := range formulaList {
 		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
@@ -5442,6 +6657,7 @@ func TestCalcXIRR(t *testing.T) {
 		"=XIRR(A1:A4,B1:B4,\"\")": {"#NUM!", "#NUM!"},
 		"=XIRR(A2:A6,B2:B6)":      {"#NUM!", "#NUM!"},
 		"=XIRR(A2:A7,B2:B7)":      {"#NUM!", "#NUM!"},
+		"=XIRR(D1:D5,E1:E5,5)":    {"#NUM!", "#NUM!"},
 	}
 	for formula, expected := range calcError {
 		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
@@ -5492,6 +6708,7 @@ func TestCalcXLOOKUP(t *testing.T) {
 		"=XLOOKUP()": {"#VALUE!", "XLOOKUP requires at least 3 arguments"},
 		"=XLOOKUP($C3,$C5:$C5,$C6:$C17,NA(),0,2,1)":  {"#VALUE!", "XLOOKUP allows at most 6 arguments"},
 		"=XLOOKUP($C3,$C5,$C6,NA(),0,2)":             {"#N/A", "#N/A"},
+		"=XLOOKUP(\"Dividends\",B6:B17,C6:C17)":      {"#N/A", "XLOOKUP no result found"},
 		"=XLOOKUP($C3,$C4:$D5,$C6:$C17,NA(),0,2)":    {"#VALUE!", "#VALUE!"},
 		"=XLOOKUP($C3,$C5:$C5,$C6:$G17,NA(),0,-2)":   {"#VALUE!", "#VALUE!"},
 		"=XLOOKUP($C3,$C5:$G5,$C6:$F7,NA(),0,2)":     {"#VALUE!", "#VALUE!"},
@@ -5600,29 +6817,85 @@ func TestCalcXNPV(t *testing.T) {
 	}
 }
 
-func TestCalcMATCH(t *testing.T) {
-	f := NewFile()
-	for cell, row := range map[string][]interface{}{
-		"A1": {"cccc", 7, 4, 16},
-		"A2": {"dddd", 2, 6, 11},
-		"A3": {"aaaa", 4, 7, 10},
-		"A4": {"bbbb", 1, 10, 7},
-		"A5": {"eeee", 8, 11, 6},
-		"A6": {nil, 11, 16, 4},
+func TestCalcTEXT(t *testing.T) {
+	fn := formulaFuncs{}
+	for _, c := range []struct {
+		value    float64
+		fmtText  string
+		expected string
+	}{
+		{12345.678, "0.00E+00", "1.23E+04"},
+		{-1234, "0.00E+00", "-1.23E+03"},
+		{0.000123, "0.0E+0", "1.2E-4"},
+		{0.000123, "0.0E-0", "1.2E-4"},
+		{123, "0.0E-0", "1.2E2"},
+		{9.999, "0.00E+00", "1.00E+01"},
+		{0, "0.00E+00", "0.00E+00"},
+		{1.25, "# ?/?", "1 1/4"},
+		{12345678, "#,##0,", "12,346"},
 	} {
-		assert.NoError(t, f.SetSheetRow("Sheet1", cell, &row))
+		argsList := list.New()
+		argsList.PushBack(newNumberFormulaArg(c.value))
+		argsList.PushBack(newStringFormulaArg(c.fmtText))
+		result := fn.TEXT(argsList)
+		assert.Equal(t, ArgString, result.Type, c.fmtText)
+		assert.Equal(t, c.expected, result.String, c.fmtText)
+	}
+}
+
+func TestCalcCHOOSE(t *testing.T) {
+	cellData := [][]interface{}{
+		{"a", 10},
+		{"b", 20},
+		{"c", 30},
 	}
+	f := prepareCalcData(cellData)
 	formulaList := map[string]string{
-		"=MATCH(\"aaaa\",A1:A6,0)": "3",
-		"=MATCH(\"*b\",A1:A5,0)":   "4",
-		"=MATCH(\"?eee\",A1:A5,0)": "5",
-		"=MATCH(\"?*?e\",A1:A5,0)": "5",
-		"=MATCH(\"aaaa\",A1:A6,1)": "3",
-		"=MATCH(10,B1:B6)":         "5",
-		"=MATCH(8,C1:C6,1)":        "3",
-		"=MATCH(6,B1:B6,-1)":       "1",
-		"=MATCH(10,D1:D6,-1)":      "3",
-		"=MATCH(-10,D1:D6,-1)":     "6",
+		"=CHOOSE(2,\"x\",\"y\")":                            "y",
+		"=CHOOSE(1,A1:A3,B1:B3)":                            "a",
+		"=CHOOSE({2,1},\"x\",\"y\")":                        "y",
+		"=SUM(CHOOSE({1,2},10,20))":                         "30",
+		"=SUM(CHOOSE({2,2,1},10,20))":                       "50",
+		"=SUM(CHOOSE({1,2},B1:B3,1))":                       "63",
+		"=VLOOKUP(20,CHOOSE({1,2},B1:B3,A1:A3),2,FALSE)":    "b",
+		"=VLOOKUP(\"c\",CHOOSE({1,2},A1:A3,B1:B3),2,FALSE)": "30",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "D1", formula))
+		result, err := f.CalcCellValue("Sheet1", "D1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	// Test array index returns an array result
+	fn := formulaFuncs{}
+	argsList := list.New()
+	argsList.PushBack(newMatrixFormulaArg([][]formulaArg{{newNumberFormulaArg(2), newNumberFormulaArg(1), newNumberFormulaArg(3)}}))
+	argsList.PushBack(newStringFormulaArg("x"))
+	argsList.PushBack(newMatrixFormulaArg([][]formulaArg{{newNumberFormulaArg(1)}, {newNumberFormulaArg(2)}}))
+	assert.Equal(t, newMatrixFormulaArg([][]formulaArg{
+		{newNumberFormulaArg(1), newStringFormulaArg("x"), newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)},
+		{newNumberFormulaArg(2), newStringFormulaArg("x"), newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)},
+	}), fn.CHOOSE(argsList))
+}
+
+func TestCalcINDEX(t *testing.T) {
+	cellData := [][]interface{}{
+		{1, 2, 3},
+		{4, 5, 6},
+		{7, 8, 9},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=INDEX(A1:C3,2,3)":        "6",
+		"=INDEX(A1:C3,3,1)":        "7",
+		"=INDEX(A1:A3,2)":          "4",
+		"=INDEX(A1:C1,1,0)":        "1",
+		"=SUM(INDEX(A1:C3,0,2))":   "15",
+		"=SUM(INDEX(A1:C3,3,0))":   "24",
+		"=SUM(INDEX(A1:C3,3))":     "24",
+		"=SUM(INDEX(A1:C3,2,3))":   "6",
+		"=MAX(INDEX(A1:C3,0,1))":   "7",
+		"=COUNT(INDEX(A1:C3,0,3))": "3",
 	}
 	for formula, expected := range formulaList {
 		assert.NoError(t, f.SetCellFormula("Sheet1", "E1", formula))
@@ -5630,17 +6903,157 @@ func TestCalcMATCH(t *testing.T) {
 		assert.NoError(t, err, formula)
 		assert.Equal(t, expected, result, formula)
 	}
-	calcError := map[string]string{
-		"=MATCH(3,C1:C6,1)":  "#N/A",
-		"=MATCH(5,C1:C6,-1)": "#N/A",
+	calcError := map[string][]string{
+		"=INDEX(A1:C3,4,1)":  {"#REF!", "INDEX row_num out of range"},
+		"=INDEX(A1:C3,4,0)":  {"#REF!", "INDEX row_num out of range"},
+		"=INDEX(A1:C3,-1,1)": {"#REF!", "INDEX row_num out of range"},
+		"=INDEX(A1:C3,1,4)":  {"#REF!", "INDEX col_num out of range"},
+		"=INDEX(A1:C3,0,4)":  {"#REF!", "INDEX col_num out of range"},
+		"=INDEX(A1:C3,1,-1)": {"#REF!", "INDEX col_num out of range"},
 	}
 	for formula, expected := range calcError {
 		assert.NoError(t, f.SetCellFormula("Sheet1", "E1", formula))
 		result, err := f.CalcCellValue("Sheet1", "E1")
-		assert.EqualError(t, err, expected, formula)
-		assert.Equal(t, expected, result, formula)
+		assert.Equal(t, expected[0], result, formula)
+		assert.EqualError(t, err, expected[1], formula)
+	}
+	// Test the whole column, whole row and scalar results of the array
+	fn := formulaFuncs{f: f, sheet: "Sheet1", cell: "E1", ctx: &calcContext{}}
+	array, err := f.parseReference(&calcContext{}, "Sheet1", "A1:C3")
+	assert.NoError(t, err)
+	for _, c := range []struct {
+		row, col float64
+		expected formulaArg
+	}{
+		{0, 2, newMatrixFormulaArg([][]formulaArg{{newNumberFormulaArg(2)}, {newNumberFormulaArg(5)}, {newNumberFormulaArg(8)}})},
+		{2, 0, newMatrixFormulaArg([][]formulaArg{{newNumberFormulaArg(4), newNumberFormulaArg(5), newNumberFormulaArg(6)}})},
+		{3, 3, newNumberFormulaArg(9)},
+	} {
+		argsList := list.New()
+		argsList.PushBack(array)
+		argsList.PushBack(newNumberFormulaArg(c.row))
+		argsList.PushBack(newNumberFormulaArg(c.col))
+		assert.Equal(t, c.expected, fn.INDEX(argsList))
+	}
+}
+
+func TestCalcINDIRECT(t *testing.T) {
+	f := NewFile()
+	_, err := f.NewSheet("Sheet 2")
+	assert.NoError(t, err)
+	assert.NoError(t, f.SetSheetRow("Sheet 2", "A1", &[]interface{}{10, "text"}))
+	assert.NoError(t, f.SetSheetRow("Sheet 2", "A2", &[]interface{}{20, 30}))
+	for formula, expected := range map[string]string{
+		"=INDIRECT(\"'Sheet 2'!A1\")":              "10",
+		"=INDIRECT(\"'Sheet 2'!$B$1\")":            "text",
+		"=SUM(INDIRECT(\"'Sheet 2'!A1:B2\"))":      "60",
+		"=INDIRECT(\"'Sheet 2'!R2C2\",FALSE)":      "30",
+		"=INDIRECT(\"'Sheet 2'!R[1]C[-2]\",FALSE)": "20",
+		"=INDIRECT(\"SheetN!A1\")":                 "#REF!",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, _ := f.CalcCellValue("Sheet1", "C1")
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
+func TestCalcLOOKUP(t *testing.T) {
+	cellData := [][]interface{}{
+		{1, 2, 3, 4, nil, 1.5, "w"},
+		{"a", "b", "c", "d", nil, 2.5, "x"},
+		{nil, nil, nil, nil, nil, 3.5, "y"},
+		{nil, nil, nil, nil, nil, 4.5, "z"},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		// Vector form
+		"=LOOKUP(3,F1:F4,G1:G4)":   "x",
+		"=LOOKUP(4.5,F1:F4,G1:G4)": "z",
+		"=LOOKUP(2.5,A1:D1,A2:D2)": "b",
+		"=LOOKUP(4,A1:D1,A2:D2)":   "d",
+		"=LOOKUP(9,A1:D1,A2:D2)":   "d",
+		"=LOOKUP(3,A1:D1,G1:G4)":   "y",
+		"=LOOKUP(3.5,F1:F4,A2:D2)": "c",
+		// Array form
+		"=LOOKUP(3,A1:D2)":   "c",
+		"=LOOKUP(9,A1:D2)":   "d",
+		"=LOOKUP(3,F1:G4)":   "x",
+		"=LOOKUP(4.5,F1:G4)": "z",
+		"=LOOKUP(1,A1:B2)":   "2",
+		"=LOOKUP(2,A1:D1)":   "2",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "H1", formula))
+		result, err := f.CalcCellValue("Sheet1", "H1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	calcError := map[string][]string{
+		"=LOOKUP(0,A1:D1,A2:D2)": {"#N/A", "LOOKUP no result found"},
+		"=LOOKUP(1,F1:G4)":       {"#N/A", "LOOKUP no result found"},
+	}
+	for formula, expected := range calcError {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "H1", formula))
+		result, err := f.CalcCellValue("Sheet1", "H1")
+		assert.Equal(t, expected[0], result, formula)
+		assert.EqualError(t, err, expected[1], formula)
 	}
-	assert.Equal(t, newErrorFormulaArg(formulaErrorNA, formulaErrorNA), calcMatch(2, nil, []formulaArg{}))
+}
+
+func TestCalcMATCH(t *testing.T) {
+	f := NewFile()
+	for cell, row := range map[string][]interface{}{
+		"A1": {"cccc", 7, 4, 16},
+		"A2": {"dddd", 2, 6, 11},
+		"A3": {"aaaa", 4, 7, 10},
+		"A4": {"bbbb", 1, 10, 7},
+		"A5": {"eeee", 8, 11, 6},
+		"A6": {nil, 11, 16, 4},
+	} {
+		assert.NoError(t, f.SetSheetRow("Sheet1", cell, &row))
+	}
+	formulaList := map[string]string{
+		"=MATCH(\"aaaa\",A1:A6,0)": "3",
+		"=MATCH(\"*b\",A1:A5,0)":   "4",
+		"=MATCH(\"?eee\",A1:A5,0)": "5",
+		"=MATCH(\"?*?e\",A1:A5,0)": "5",
+		"=MATCH(\"aaaa\",A1:A6,1)": "3",
+		"=MATCH(\"zzzz\",A1:A6,1)": "5",
+		"=MATCH(10,B1:B6)":         "5",
+		"=MATCH(8,C1:C6,1)":        "3",
+		"=MATCH(6,B1:B6,-1)":       "1",
+		"=MATCH(10,D1:D6,-1)":      "3",
+		"=MATCH(-10,D1:D6,-1)":     "6",
+		"=MATCH(\"AAAA\",A1:A6,0)": "3",
+		"=MATCH(\"b*\",A1:A5,0)":   "4",
+		"=MATCH(\"*D\",A1:A5,0)":   "2",
+		"=MATCH(1,B1:B6,0)":        "4",
+		"=MATCH(9,C1:C6,1)":        "3",
+		"=MATCH(16,C1:C6,1)":       "6",
+		"=MATCH(5,D1:D6,-1)":       "5",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "E1", formula))
+		result, err := f.CalcCellValue("Sheet1", "E1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	calcError := map[string][]string{
+		"=MATCH(3,C1:C6,1)":        {"#N/A", "MATCH no result found"},
+		"=MATCH(5,C1:C6,-1)":       {"#N/A", "MATCH no result found"},
+		"=MATCH(\"ffff\",A1:A5,0)": {"#N/A", "MATCH no result found"},
+		"=MATCH(\"a\",A1:A5,0)":    {"#N/A", "MATCH no result found"},
+		"=MATCH(\"<5\",C1:C6,0)":   {"#N/A", "MATCH no result found"},
+		"=MATCH(1,D1:D6,0)":        {"#N/A", "MATCH no result found"},
+		"=MATCH(17,D1:D6,-1)":      {"#N/A", "MATCH no result found"},
+	}
+	for formula, expected := range calcError {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "E1", formula))
+		result, err := f.CalcCellValue("Sheet1", "E1")
+		assert.EqualError(t, err, expected[1], formula)
+		assert.Equal(t, expected[0], result, formula)
+	}
+	assert.Equal(t, newErrorFormulaArg(formulaErrorNA, "MATCH no result found"), calcMatch(2, newEmptyFormulaArg(), []formulaArg{}))
 }
 
 func TestCalcISFORMULA(t *testing.T) {
@@ -5700,6 +7113,78 @@ func TestCalcMODE(t *testing.T) {
 	}
 }
 
+func TestCalcMEDIANandMODEMixedArguments(t *testing.T) {
+	cellData := [][]interface{}{
+		{2, 1},
+		{"x", 2},
+		{nil, 3},
+		{4},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=MEDIAN(1,A1:A4,5)":      "3",
+		"=MEDIAN(A1:A4,7)":        "4",
+		"=MEDIAN(1,{2,3},10)":     "2.5",
+		"=MEDIAN(A1:A4,B1:B3)":    "2",
+		"=MODE.SNGL(1,A1:A4,2)":   "2",
+		"=MODE.SNGL(B1:B3,3)":     "3",
+		"=MODE.SNGL(B1:B3,{1,2})": "1",
+		"=MODE(A1:A4,B1:B3)":      "2",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "D1", formula))
+		result, err := f.CalcCellValue("Sheet1", "D1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	calcError := map[string][]string{
+		"=MEDIAN(A1:A4,NA())":     {"#N/A", "#N/A"},
+		"=MODE.SNGL(B1:B3,4)":     {"#N/A", "#N/A"},
+		"=MODE.SNGL(A1:A4,5)":     {"#N/A", "#N/A"},
+		"=MODE.SNGL(\"x\",B1:B3)": {"#VALUE!", "#VALUE!"},
+	}
+	for formula, expected := range calcError {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "D1", formula))
+		result, err := f.CalcCellValue("Sheet1", "D1")
+		assert.Equal(t, expected[0], result, formula)
+		assert.EqualError(t, err, expected[1], formula)
+	}
+}
+
+func TestCalcNPVAndXNPV(t *testing.T) {
+	cellData := [][]interface{}{
+		{0.1, -10000, "01/01/2016"},
+		{nil, nil, "02/01/2016"},
+		{nil, 3000, "05/01/2016"},
+		{nil, "text", "07/01/2016"},
+		{nil, 4200, "11/01/2016"},
+		{nil, 6800, "01/01/2017"},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=NPV(A1,B1:B6)":                 "1188.44341233522",
+		"=NPV(A1,-10000,3000,4200,6800)": "1188.44341233522",
+		"=NPV(A1,B1,B2:B4,B5:B6)":        "1188.44341233522",
+		"=XNPV(A1,B1:B6,C1:C6)":          "2965.37203243357",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "D1", formula))
+		result, err := f.CalcCellValue("Sheet1", "D1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	calcError := map[string][]string{
+		"=NPV(A1,B1:B6,NA())":   {"#N/A", "#N/A"},
+		"=XNPV(A1,B2:B4,C2:C4)": {"#NUM!", "#NUM!"},
+	}
+	for formula, expected := range calcError {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "D1", formula))
+		result, err := f.CalcCellValue("Sheet1", "D1")
+		assert.Equal(t, expected[0], result, formula)
+		assert.EqualError(t, err, expected[1], formula)
+	}
+}
+
 func TestCalcPEARSON(t *testing.T) {
 	cellData := [][]interface{}{
 		{"x", "y"},
@@ -5737,6 +7222,27 @@ func TestCalcPEARSON(t *testing.T) {
 	}
 }
 
+func TestCalcPHONETIC(t *testing.T) {
+	f := NewFile()
+	assert.NoError(t, f.SetCellValue("Sheet1", "A1", "東京"))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A2", "大阪"))
+	sst, err := f.sharedStringsReader()
+	assert.NoError(t, err)
+	sst.SI[0].RPh = []*xlsxPhoneticRun{{Sb: 0, Eb: 1, T: "トウ"}, {Sb: 1, Eb: 2, T: "キョウ"}}
+	for formula, expected := range map[string]string{
+		"=PHONETIC(A1)":        "トウキョウ",
+		"=PHONETIC(Sheet1!A1)": "トウキョウ",
+		"=PHONETIC(A1:A2)":     "トウキョウ",
+		"=PHONETIC(A2)":        "大阪",
+		"=PHONETIC(A3)":        "",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
 func TestCalcPROB(t *testing.T) {
 	cellData := [][]interface{}{
 		{"x", "probability"},
@@ -5773,6 +7279,42 @@ func TestCalcPROB(t *testing.T) {
 	}
 }
 
+func TestCalcPERCENTILEdotEXC(t *testing.T) {
+	cellData := [][]interface{}{
+		{1, 10},
+		{2, 20},
+		{3},
+		{4},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=PERCENTILE.EXC(A1:A4,0.2)": "1",
+		"=PERCENTILE.EXC(A1:A4,0.3)": "1.5",
+		"=PERCENTILE.EXC(A1:A4,0.5)": "2.5",
+		"=PERCENTILE.EXC(A1:A4,0.8)": "4",
+		"=QUARTILE.EXC(B1:B2,2)":     "15",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "D1", formula))
+		result, err := f.CalcCellValue("Sheet1", "D1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	calcError := map[string][]string{
+		"=PERCENTILE.EXC(A1:A4,0.19)": {"#NUM!", "#NUM!"},
+		"=PERCENTILE.EXC(A1:A4,0.81)": {"#NUM!", "#NUM!"},
+		"=PERCENTILE.EXC(C1:C2,0.5)":  {"#NUM!", "#NUM!"},
+		"=QUARTILE.EXC(B1:B2,1)":      {"#NUM!", "#NUM!"},
+		"=QUARTILE.EXC(B1:B2,3)":      {"#NUM!", "#NUM!"},
+	}
+	for formula, expected := range calcError {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "D1", formula))
+		result, err := f.CalcCellValue("Sheet1", "D1")
+		assert.Equal(t, expected[0], result, formula)
+		assert.EqualError(t, err, expected[1], formula)
+	}
+}
+
 func TestCalcRSQ(t *testing.T) {
 	cellData := [][]interface{}{
 		{"known_y's", "known_x's"},
@@ -5819,6 +7361,48 @@ func TestCalcSLOP(t *testing.T) {
 	}
 }
 
+func TestCalcSLOPEZeroVariance(t *testing.T) {
+	cellData := [][]interface{}{
+		{2, 1, 3, "a"},
+		{4, 2, 3, "b"},
+		{5, 3, 3, "c"},
+		{4, 4, 3, "d"},
+		{5, 5, 3, "e"},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=SLOPE(A1:A5,B1:B5)":      "0.6",
+		"=INTERCEPT(A1:A5,B1:B5)":  "2.2",
+		"=RSQ(A1:A5,B1:B5)":        "0.6",
+		"=PEARSON(A1:A5,B1:B5)":    "0.774596669241483",
+		"=FORECAST(6,A1:A5,B1:B5)": "5.8",
+		"=SLOPE(C1:C5,B1:B5)":      "0",
+		"=INTERCEPT(C1:C5,B1:B5)":  "3",
+		"=FORECAST(6,C1:C5,B1:B5)": "3",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "F1", formula))
+		result, err := f.CalcCellValue("Sheet1", "F1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	calcError := map[string][]string{
+		"=SLOPE(A1:A5,C1:C5)":      {"#DIV/0!", "#DIV/0!"},
+		"=INTERCEPT(A1:A5,C1:C5)":  {"#DIV/0!", "#DIV/0!"},
+		"=RSQ(A1:A5,C1:C5)":        {"#DIV/0!", "#DIV/0!"},
+		"=RSQ(C1:C5,B1:B5)":        {"#DIV/0!", "#DIV/0!"},
+		"=PEARSON(C1:C5,B1:B5)":    {"#DIV/0!", "#DIV/0!"},
+		"=FORECAST(6,A1:A5,C1:C5)": {"#DIV/0!", "#DIV/0!"},
+		"=SLOPE(D1:D5,B1:B5)":      {"#DIV/0!", "#DIV/0!"},
+	}
+	for formula, expected := range calcError {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "F1", formula))
+		result, err := f.CalcCellValue("Sheet1", "F1")
+		assert.Equal(t, expected[0], result, formula)
+		assert.EqualError(t, err, expected[1], formula)
+	}
+}
+
 func TestCalcSHEET(t *testing.T) {
 	f := NewFile()
 	_, err := f.NewSheet("Sheet2")
@@ -5852,6 +7436,103 @@ func TestCalcSHEETS(t *testing.T) {
 	}
 }
 
+func TestCalc3DReference(t *testing.T) {
+	f := NewFile()
+	for _, sheet := range []string{"Sheet2", "Sheet3", "Sheet4"} {
+		_, err := f.NewSheet(sheet)
+		assert.NoError(t, err)
+	}
+	for sheet, row := range map[string][]interface{}{
+		"Sheet1": {1, 4},
+		"Sheet2": {2, "text"},
+		"Sheet3": {6, 3},
+	} {
+		assert.NoError(t, f.SetSheetRow(sheet, "A1", &row))
+	}
+	formulaList := map[string]string{
+		"=AVERAGE(Sheet1:Sheet3!A1)":    "3",
+		"=AVERAGE(Sheet1:Sheet3!A1:B1)": "3.2",
+		"=COUNT(Sheet1:Sheet3!A1:B1)":   "5",
+		"=MAX(Sheet1:Sheet3!A1)":        "6",
+		"=MAX(Sheet1:Sheet2!A1:B1)":     "4",
+		"=MAX(Sheet2:Sheet3!B1)":        "3",
+		"=MIN(Sheet1:Sheet3!A1:B1)":     "1",
+		"=MIN(Sheet2:Sheet2!A1)":        "2",
+		"=SUM(Sheet1:Sheet3!A1:B1)":     "16",
+		"=SUM(Sheet3:Sheet1!A1)":        "9",
+		"=SUM(Sheet1:Sheet3!$A$1)":      "9",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet4", "A1", formula))
+		result, err := f.CalcCellValue("Sheet4", "A1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	assert.NoError(t, f.SetCellFormula("Sheet4", "A1", "=SUM(Sheet1:Sheet5!A1)"))
+	result, err := f.CalcCellValue("Sheet4", "A1")
+	assert.EqualError(t, err, "invalid reference")
+	assert.Equal(t, formulaErrorREF, result)
+	// Test the worksheet list is resolved once in the calculation context
+	ctx := &calcContext{iterations: make(map[string]uint), iterationsCache: make(map[string]formulaArg)}
+	ps := efp.ExcelParser()
+	arg, err := f.evalInfixExp(ctx, "Sheet4", "A1", ps.Parse("SUM(Sheet1:Sheet3!A1)+SUM(Sheet2:Sheet3!B1)"))
+	assert.NoError(t, err)
+	assert.Equal(t, "12", arg.Value())
+	assert.Equal(t, []string{"Sheet1", "Sheet2", "Sheet3", "Sheet4"}, ctx.sheetList)
+}
+
+func TestCalcSTDEVandVARSingleValue(t *testing.T) {
+	cellData := [][]interface{}{
+		{5, 0.7},
+		{"text", 0.7},
+		{"x", 0.7},
+		{nil, 0.7},
+		{nil, 0.7},
+	}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		// population forms work with one value
+		"=STDEVP(A1)":     "0",
+		"=STDEV.P(A1)":    "0",
+		"=STDEVPA(A1)":    "0",
+		"=VARP(A1)":       "0",
+		"=VAR.P(A1)":      "0",
+		"=VARPA(A1)":      "0",
+		"=STDEV.P(A1:A3)": "0",
+		"=VAR.P(A1:A3)":   "0",
+		"=STDEV.P(B1:B5)": "0",
+		"=VAR.P(B1:B5)":   "0",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	calcError := map[string][]string{
+		// sample forms require at least two numeric values
+		"=STDEV(A1)":      {"#DIV/0!", "#DIV/0!"},
+		"=STDEV.S(A1)":    {"#DIV/0!", "#DIV/0!"},
+		"=STDEVA(A1)":     {"#DIV/0!", "#DIV/0!"},
+		"=VAR(A1)":        {"#DIV/0!", "#DIV/0!"},
+		"=VAR.S(A1)":      {"#DIV/0!", "#DIV/0!"},
+		"=VARA(A1)":       {"#DIV/0!", "#DIV/0!"},
+		"=STDEV.S(A1:A3)": {"#DIV/0!", "#DIV/0!"},
+		"=VAR.S(A1:A3)":   {"#DIV/0!", "#DIV/0!"},
+		// all text values
+		"=STDEV.S(A2:A3)": {"#DIV/0!", "#DIV/0!"},
+		"=STDEV.P(A2:A3)": {"#DIV/0!", "#DIV/0!"},
+		"=VAR.S(A2:A3)":   {"#DIV/0!", "#DIV/0!"},
+		"=VAR.P(A2:A3)":   {"#DIV/0!", "#DIV/0!"},
+	}
+	for formula, expected := range calcError {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1")
+		assert.Equal(t, expected[0], result, formula)
+		assert.EqualError(t, err, expected[1], formula)
+	}
+}
+
 func TestCalcSTEY(t *testing.T) {
 	cellData := [][]interface{}{
 		{"known_x's", "known_y's"},
@@ -5917,6 +7598,10 @@ func TestCalcTTEST(t *testing.T) {
 		"=T.TEST(A1:A12,B1:B12,2,1)": "0.898141378888559",
 		"=T.TEST(A1:A12,B1:B12,2,2)": "0.873434612058567",
 		"=T.TEST(A1:A12,B1:B12,2,3)": "0.873444030769511",
+		// the tails and type arguments are truncated to integers
+		"=T.TEST(A1:A12,B1:B12,1.5,2.9)":  "0.436717306029283",
+		"=T.TEST(A1:A12,B1:B12,\"2\",3)":  "0.873444030769511",
+		"=TTEST(A1:A12,B1:B12,2.99,1.01)": "0.898141378888559",
 	}
 	for formula, expected := range formulaList {
 		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
@@ -5949,6 +7634,12 @@ func TestCalcTTEST(t *testing.T) {
 		"=T.TEST(A12:A13,B12:B13,1,1)":  {"#DIV/0!", "#DIV/0!"},
 		"=T.TEST(A13:A14,B13:B14,1,2)":  {"#NUM!", "#NUM!"},
 		"=T.TEST(D1:D4,E1:E4,1,3)":      {"#NUM!", "#NUM!"},
+		// tails and type out of range, and mismatched paired arrays
+		"=T.TEST(A1:A12,B1:B12,3,1)":   {"#NUM!", "#NUM!"},
+		"=T.TEST(A1:A12,B1:B12,0.5,1)": {"#NUM!", "#NUM!"},
+		"=T.TEST(A1:A12,B1:B12,2,4)":   {"#NUM!", "#NUM!"},
+		"=T.TEST(A1:A12,B1:B12,2,-1)":  {"#NUM!", "#NUM!"},
+		"=T.TEST(A1:A12,B1:B11,2,1)":   {"#N/A", "#N/A"},
 	}
 	for formula, expected := range calcError {
 		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
@@ -6169,9 +7860,9 @@ func TestFormulaArgToToken(t *testing.T) {
 }
 
 func TestPrepareTrendGrowth(t *testing.T) {
-	assert.Equal(t, [][]float64(nil), prepareTrendGrowthMtxX([][]float64{{0, 0}, {0, 0}}))
-	assert.Equal(t, [][]float64(nil), prepareTrendGrowthMtxY(false, [][]float64{{0, 0}, {0, 0}}))
-	info, err := prepareTrendGrowth(false, [][]float64{{0, 0}, {0, 0}}, [][]float64{{0, 0}, {0, 0}})
+	assert.Equal(t, [][]float64{{0, 2}, {1, 3}}, prepareTrendGrowthMtxY(false, [][]float64{{0, 1}, {2, 3}}))
+	assert.Equal(t, [][]float64(nil), prepareTrendGrowthMtxY(true, [][]float64{{0, 0}, {0, 0}}))
+	info, err := prepareTrendGrowth(true, [][]float64{{0, 0}, {0, 0}}, [][]float64{{0, 0}, {0, 0}})
 	assert.Nil(t, info)
 	assert.Equal(t, newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM), err)
 }
@@ -6215,6 +7906,430 @@ func TestCalcCellResolver(t *testing.T) {
 	}
 }
 
+func TestEvalFormula(t *testing.T) {
+	f := prepareCalcData([][]interface{}{{1, 2}, {3, 4}})
+	for _, c := range []struct{ origin, formula, expected string }{
+		{"A1", "=A1+B1", "3"},
+		{"A2", "=A1+B1", "7"},
+		{"B1", "=A1+B1", "2"},
+		{"A2", "=$A$1+B1", "5"},
+		{"B2", "=SUM($A1:A1)", "7"},
+	} {
+		result, err := f.EvalFormula("Sheet1", c.origin, c.formula)
+		assert.NoError(t, err, c.formula)
+		assert.Equal(t, c.expected, result, c.formula)
+	}
+	// Test evaluate formula with invalid origin cell
+	_, err := f.EvalFormula("Sheet1", "A", "=A1")
+	assert.Equal(t, newCellNameToCoordinatesError("A", newInvalidCellNameError("A")), err)
+}
+
+func TestCalcExponentiationPrecedence(t *testing.T) {
+	f := NewFile()
+	formulaList := map[string]string{
+		"=2^3^2":         "64",
+		"=2^3^2^0.5":     "8",
+		"=(2^3)^2":       "64",
+		"=2^(3^2)":       "512",
+		"=-2^2":          "4",
+		"=-2^3":          "-8",
+		"=-(2^2)":        "-4",
+		"=0-2^2":         "-4",
+		"=1-2^2":         "-3",
+		"=-2^2+1":        "5",
+		"=2^-2":          "0.25",
+		"=2*-2^2":        "8",
+		"=2*3^2":         "18",
+		"=2^3*2":         "16",
+		"=SUM(2^3^2)":    "64",
+		"=SUM(-2^2,1)":   "5",
+		"=--1":           "1",
+		"=---1":          "-1",
+		"=--2^2":         "4",
+		"=2*--3":         "6",
+		"=--TRUE":        "1",
+		"=--\"2\"":       "2",
+		"=SUM(1,--TRUE)": "2",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "A1", formula))
+		result, err := f.CalcCellValue("Sheet1", "A1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
+func TestCalcBlankCellOperands(t *testing.T) {
+	cellData := [][]interface{}{{nil, "x"}, {2, nil}}
+	f := prepareCalcData(cellData)
+	formulaList := map[string]string{
+		"=A1+1":          "1",
+		"=1+A1":          "1",
+		"=A1+A2":         "2",
+		"=A1-1":          "-1",
+		"=A1*2":          "0",
+		"=A1/2":          "0",
+		"=A1^2":          "0",
+		"=-A1":           "0",
+		"=A1%":           "0",
+		"=A1&\"x\"":      "x",
+		"=\"x\"&A1":      "x",
+		"=A1&B1":         "x",
+		"=A1&A1":         "",
+		"=A1&1":          "1",
+		"=SUM(A1+1,2)":   "3",
+		"=A1=0":          "TRUE",
+		"=A1=\"\"":       "TRUE",
+		"=A1=FALSE":      "TRUE",
+		"=A1=B2":         "TRUE",
+		"=A1<>0":         "FALSE",
+		"=A1<1":          "TRUE",
+		"=A1<=0":         "TRUE",
+		"=A1>-1":         "TRUE",
+		"=A1>=0":         "TRUE",
+		"=\"a\">A1":      "TRUE",
+		"=A1<\"a\"":      "TRUE",
+		"=IF(A1=0,1,2)":  "1",
+		"=(A1+1)*(A2+1)": "3",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	calcError := map[string][]string{
+		"=2/A1":    {"#DIV/0!", "#DIV/0!"},
+		"=A1+B1":   {"", "strconv.ParseFloat: parsing \"x\": invalid syntax"},
+		"=A1+NA()": {"#N/A", "#N/A"},
+	}
+	for formula, expected := range calcError {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1")
+		assert.EqualError(t, err, expected[1], formula)
+		assert.Equal(t, expected[0], result, formula)
+	}
+}
+
+func TestCalcWholeColumnAndRowReference(t *testing.T) {
+	f := NewFile()
+	for cell, value := range map[string]interface{}{"A1": 1, "A10": 2, "A50": 3, "B3": 4, "B50": 5, "C20": "text"} {
+		assert.NoError(t, f.SetCellValue("Sheet1", cell, value))
+	}
+	_, err := f.NewSheet("Sheet2")
+	assert.NoError(t, err)
+	calc := func(formula string) string {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "Z100", formula))
+		result, err := f.CalcCellValue("Sheet1", "Z100")
+		assert.NoError(t, err, formula)
+		return result
+	}
+	// Test the whole column and row references get the same result with the
+	// references of the used range
+	for _, c := range []struct{ formula, naive, expected string }{
+		{"=SUM(A:A)", "=SUM(A1:A100)", "6"},
+		{"=SUM(A:B)", "=SUM(A1:B100)", "15"},
+		{"=COUNT(A:A)", "=COUNT(A1:A100)", "3"},
+		{"=COUNTA(A:C)", "=COUNTA(A1:C100)", "6"},
+		{"=AVERAGE(B:B)", "=AVERAGE(B1:B100)", "4.5"},
+		{"=MAX(A:A)", "=MAX(A1:A100)", "3"},
+		{"=SUMPRODUCT(50:50,50:50)", "=SUMPRODUCT(A50:Z50,A50:Z50)", "34"},
+		{"=SUM(1:1)", "=SUM(A1:Z1)", "1"},
+		{"=SUM(50:50)", "=SUM(A50:Z50)", "8"},
+		{"=MATCH(5,50:50,0)", "=MATCH(5,A50:Z50,0)", "2"},
+		{"=INDEX(50:50,1,2)", "=INDEX(A50:Z50,1,2)", "5"},
+		{"=SUM(Sheet2!A:A)", "=SUM(Sheet2!A1:A100)", "0"},
+	} {
+		assert.Equal(t, c.expected, calc(c.formula), c.formula)
+		assert.Equal(t, c.expected, calc(c.naive), c.naive)
+	}
+	// Test the cells beyond the used range of the worksheet are empty
+	for formula, expected := range map[string]string{
+		"=COLUMNS(1:1)":             "16384",
+		"=COUNTBLANK(1:1)":          "16383",
+		"=INDEX(1:1,1,1000)":        "",
+		"=HLOOKUP(1,1:10,10,FALSE)": "2",
+		"=SUM(A:A)+COUNT(A:A)":      "9",
+	} {
+		assert.Equal(t, expected, calc(formula), formula)
+	}
+	// Test the whole column and row references are not limited to the used
+	// range for the functions which count or compare the blank cells
+	for formula, naive := range map[string]string{
+		"=COUNTIF(1:1,\"<>x\")":     "=COUNTIF(A1:XFD1,\"<>x\")",
+		"=SUMPRODUCT(--(1:1=\"\"))": "=SUMPRODUCT(--(A1:XFD1=\"\"))",
+		"=COUNT(1/(1:1=\"\"))":      "=COUNT(1/(A1:XFD1=\"\"))",
+	} {
+		assert.Equal(t, calc(naive), calc(formula), formula)
+	}
+	// Test get the used range by the dimension of the worksheet
+	maxCol, maxRow, err := f.getUsedRange(&calcContext{}, "Sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{26, 100}, []int{maxCol, maxRow})
+	ws, err := f.workSheetReader("Sheet1")
+	assert.NoError(t, err)
+	ws.Dimension = &xlsxDimension{Ref: "A1:AB200"}
+	maxCol, maxRow, err = f.getUsedRange(&calcContext{}, "Sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{28, 200}, []int{maxCol, maxRow})
+	// Test the bound cells beyond the used range of the worksheet
+	assert.NoError(t, f.SetCellFormula("Sheet1", "Z100", "=SUM(A:A)"))
+	result, err := f.CalcCellValueWithBindings("Sheet1", "Z100", map[string]FormulaResult{"A2000": {Type: ArgNumber, Number: 10}})
+	assert.NoError(t, err)
+	assert.Equal(t, "16", result)
+	// Test calculate the whole column reference on the not exist worksheet
+	assert.NoError(t, f.SetCellFormula("Sheet1", "Z100", "=SUM(SheetN!A:A)"))
+	_, err = f.CalcCellValue("Sheet1", "Z100")
+	assert.EqualError(t, err, "sheet SheetN does not exist")
+}
+
+func BenchmarkCalcWholeColumnReference(b *testing.B) {
+	f := NewFile()
+	for row := 1; row <= 10000; row += 100 {
+		cell, _ := CoordinatesToCellName(1, row)
+		if err := f.SetCellValue("Sheet1", cell, row); err != nil {
+			b.Error(err)
+		}
+	}
+	if err := f.SetCellFormula("Sheet1", "B1", "=SUM(A:A)"); err != nil {
+		b.Error(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := f.CalcCellValue("Sheet1", "B1"); err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func TestCalcCellValueWithBindings(t *testing.T) {
+	f := prepareCalcData([][]interface{}{{1, 2}, {3, 4}})
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=A1+B1"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C2", "=C1*A2"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C3", "=IF(B2,SUM(A1:B2),\"none\")"))
+	_, err := f.NewSheet("Sheet2")
+	assert.NoError(t, err)
+	assert.NoError(t, f.SetCellValue("Sheet2", "A1", 5))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C4", "=Sheet2!A1*2"))
+	for _, c := range []struct {
+		cell     string
+		bindings map[string]FormulaResult
+		expected string
+	}{
+		{"C2", nil, "9"},
+		{"C2", map[string]FormulaResult{"A1": {Type: ArgNumber, Number: 10}}, "36"},
+		{"C2", map[string]FormulaResult{"$A$2": {Type: ArgNumber, Number: 2}}, "6"},
+		{"C2", map[string]FormulaResult{"C1": {Type: ArgNumber, Number: 100}}, "300"},
+		{"C1", map[string]FormulaResult{"B1": {Type: ArgError, String: formulaErrorDIV}}, formulaErrorDIV},
+		{"C2", map[string]FormulaResult{"Sheet1!B1": {Type: ArgError, String: formulaErrorNA}}, formulaErrorNA},
+		{"C3", map[string]FormulaResult{"B2": {Type: ArgNumber, Number: 1, Boolean: true}}, "6"},
+		{"C3", map[string]FormulaResult{"B2": {Type: ArgNumber, Boolean: true}}, "none"},
+		{"C3", map[string]FormulaResult{"A1": {Type: ArgString, String: "text"}}, "9"},
+		{"C4", map[string]FormulaResult{"Sheet2!A1": {Type: ArgNumber, Number: 0.5}}, "1"},
+		{"C4", map[string]FormulaResult{"A1": {Type: ArgNumber, Number: 0.5}}, "10"},
+	} {
+		result, _ := f.CalcCellValueWithBindings("Sheet1", c.cell, c.bindings)
+		assert.Equal(t, c.expected, result, c.cell)
+	}
+	// Test the bindings not change the workbook
+	result, err := f.CalcCellValue("Sheet1", "C2")
+	assert.NoError(t, err)
+	assert.Equal(t, "9", result)
+	value, err := f.GetCellValue("Sheet1", "A1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+	// Test calculate cell value with invalid binding cell reference
+	_, err = f.CalcCellValueWithBindings("Sheet1", "C2", map[string]FormulaResult{"A": {}})
+	assert.Equal(t, newCellNameToCoordinatesError("A", newInvalidCellNameError("A")), err)
+}
+
+func TestCalcSheetValues(t *testing.T) {
+	f := prepareCalcData([][]interface{}{{1}, {2}, {3}, {4}, {5}})
+	assert.NoError(t, f.SetDefinedName(&DefinedName{Name: "amount", RefersTo: "Sheet1!$B$1:$B$5", Scope: "Workbook"}))
+	for cell, formula := range map[string]string{
+		"B1": "=A1*2",
+		"B2": "=B1+A2",
+		"B3": "=B2+A3",
+		"B4": "=B3+A4",
+		"B5": "=B4+A5",
+		"C1": "=SUM(A1:A5)",
+		"C2": "=SUM(amount)",
+		"C3": "=SUM(B1:B5)/C1",
+		"C4": "=C3/0",
+		"C5": "=A5&\"-\"&B5",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", cell, formula))
+	}
+	cells, err := f.getFormulaCells("Sheet1")
+	assert.NoError(t, err)
+	groups, err := f.groupFormulaCells("Sheet1", cells)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"B1", "C1"},
+		{"B2"}, {"B3"}, {"B4"}, {"B5"},
+		{"C2", "C3", "C5"}, {"C4"},
+	}, groups)
+	// Test calculate the worksheet concurrently and sequentially
+	for _, workers := range []int{0, 1, 4} {
+		values, err := f.CalcSheetValues("Sheet1", workers)
+		assert.NoError(t, err)
+		assert.Len(t, values, len(cells))
+		for _, cell := range cells {
+			expected, _ := f.CalcCellValue("Sheet1", cell)
+			assert.Equal(t, expected, values[cell], cell)
+		}
+	}
+	values, err := f.CalcSheetValues("Sheet1", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, "16", values["B5"])
+	assert.Equal(t, "2.66666666666667", values["C3"])
+	assert.Equal(t, "#DIV/0!", values["C4"])
+	assert.Equal(t, "5-16", values["C5"])
+	// Test calculate the worksheet with circular reference
+	assert.NoError(t, f.SetCellFormula("Sheet1", "D1", "=D2+1"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "D2", "=D1+1"))
+	values, err = f.CalcSheetValues("Sheet1", 4)
+	assert.EqualError(t, err, "circular reference")
+	assert.Nil(t, values)
+	values, err = f.CalcSheetValues("Sheet1", 4, Options{MaxCalcIterations: 10})
+	assert.NoError(t, err)
+	assert.Contains(t, values, "D1")
+	assert.Contains(t, values, "D2")
+	// Test calculate the worksheet with not exist worksheet
+	_, err = f.CalcSheetValues("SheetN", 4)
+	assert.EqualError(t, err, "sheet SheetN does not exist")
+	// Test group the cells depend on each other through the other worksheet
+	// and the cells reference the cells dynamically
+	f = prepareCalcData([][]interface{}{{1}})
+	_, err = f.NewSheet("Sheet2")
+	assert.NoError(t, err)
+	assert.NoError(t, f.SetCellFormula("Sheet2", "A1", "=Sheet1!B2+1"))
+	for cell, formula := range map[string]string{
+		"B1": "=INDIRECT(\"B3\")+1",
+		"B2": "=A1*10",
+		"B3": "=Sheet2!A1*2",
+		"B4": "=B1+B3",
+		"B5": "=RAND()",
+		"B6": "=B5",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", cell, formula))
+	}
+	cells, err = f.getFormulaCells("Sheet1")
+	assert.NoError(t, err)
+	groups, err = f.groupFormulaCells("Sheet1", cells)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"B2", "B5"}, {"B3", "B6"}, {"B1"}, {"B4"}}, groups)
+	values, err = f.CalcSheetValues("Sheet1", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, "23", values["B1"])
+	assert.Equal(t, "22", values["B3"])
+	assert.Equal(t, "45", values["B4"])
+	// Test the calculated values of the previous groups are reused
+	assert.Equal(t, values["B5"], values["B6"])
+}
+
+func BenchmarkCalcSheetValues(b *testing.B) {
+	f := NewFile()
+	for row := 1; row <= 1000; row++ {
+		_ = f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), row)
+		_ = f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("=A%d*2+SQRT(A%d)", row, row))
+		_ = f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", row), fmt.Sprintf("=ROUND(B%d/3,2)", row))
+	}
+	for _, workers := range []int{1, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := f.CalcSheetValues("Sheet1", workers); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSpillRange(t *testing.T) {
+	f := prepareCalcData([][]interface{}{{1, 2}, {3, 4}})
+	result := newMatrixFormulaArg([][]formulaArg{
+		{newNumberFormulaArg(1), newNumberFormulaArg(2)},
+		{newNumberFormulaArg(3), newNumberFormulaArg(4)},
+	})
+	// Test spill the result into the empty range
+	arg, err := f.checkSpillRange("Sheet1", "C1", result)
+	assert.NoError(t, err)
+	assert.Equal(t, result, arg)
+	// Test spill the result which anchor cell has value
+	arg, err = f.checkSpillRange("Sheet1", "B2", newMatrixFormulaArg([][]formulaArg{{newNumberFormulaArg(1)}}))
+	assert.NoError(t, err)
+	assert.Equal(t, ArgMatrix, arg.Type)
+	// Test spill the result into the range which has value
+	arg, err = f.checkSpillRange("Sheet1", "B1", result)
+	assert.NoError(t, err)
+	assert.Equal(t, newErrorFormulaArg(formulaErrorSPILL, formulaErrorSPILL), arg)
+	// Test spill the result into the range which has formula
+	assert.NoError(t, f.SetCellFormula("Sheet1", "D2", "=A1"))
+	arg, err = f.checkSpillRange("Sheet1", "C1", result)
+	assert.NoError(t, err)
+	assert.Equal(t, newErrorFormulaArg(formulaErrorSPILL, formulaErrorSPILL), arg)
+	// Test spill the result out of the worksheet
+	arg, err = f.checkSpillRange("Sheet1", "XFD1", result)
+	assert.NoError(t, err)
+	assert.Equal(t, newErrorFormulaArg(formulaErrorSPILL, formulaErrorSPILL), arg)
+	arg, err = f.checkSpillRange("Sheet1", fmt.Sprintf("A%d", TotalRows), result)
+	assert.NoError(t, err)
+	assert.Equal(t, newErrorFormulaArg(formulaErrorSPILL, formulaErrorSPILL), arg)
+	// Test check spill range with the result which is not a matrix
+	arg, err = f.checkSpillRange("Sheet1", "A1", newNumberFormulaArg(1))
+	assert.NoError(t, err)
+	assert.Equal(t, newNumberFormulaArg(1), arg)
+	// Test check spill range with invalid anchor cell and worksheet
+	_, err = f.checkSpillRange("Sheet1", "A", result)
+	assert.Equal(t, newCellNameToCoordinatesError("A", newInvalidCellNameError("A")), err)
+	_, err = f.checkSpillRange("SheetN", "A1", result)
+	assert.EqualError(t, err, "sheet SheetN does not exist")
+}
+
+func TestCalcSpillReference(t *testing.T) {
+	f := NewFile()
+	assert.NoError(t, f.SetCellFormula("Sheet1", "A1", "=MUNIT(3)"))
+	assert.NoError(t, f.SetCellValue("Sheet1", "E1", 5))
+	formulaList := map[string]string{
+		"=SUM(A1#)":                   "3",
+		"=SUM($A$1#)":                 "3",
+		"=SUM(Sheet1!A1#)":            "3",
+		"=SUM(_xlfn.ANCHORARRAY(A1))": "3",
+		"=ROWS(A1#)":                  "3",
+		"=COLUMNS(A1#)":               "3",
+		"=SUM(A1#)&\"A1#\"":           "3A1#",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "G1", formula))
+		result, err := f.CalcCellValue("Sheet1", "G1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	// Test spilled range reference on the cell which is not a spill anchor
+	for _, formula := range []string{"=SUM(E1#)", "=SUM(F1#)", "=SUM(G1#)"} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "G1", formula))
+		result, err := f.CalcCellValue("Sheet1", "G1")
+		assert.EqualError(t, err, formulaErrorREF, formula)
+		assert.Equal(t, formulaErrorREF, result, formula)
+	}
+	// Test spilled range reference on the blocked spill range
+	assert.NoError(t, f.SetCellValue("Sheet1", "B2", 1))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "G1", "=SUM(A1#)"))
+	result, err := f.CalcCellValue("Sheet1", "G1")
+	assert.EqualError(t, err, formulaErrorREF)
+	assert.Equal(t, formulaErrorREF, result)
+	// Test the spilled range references between the anchor cells
+	f = NewFile()
+	assert.NoError(t, f.SetCellFormula("Sheet1", "A1", "=MUNIT(2)+SUM(C1#)"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=MUNIT(2)+SUM(A1#)"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "E1", "=SUM(A1#)"))
+	result, err = f.CalcCellValue("Sheet1", "E1")
+	assert.EqualError(t, err, formulaErrorREF)
+	assert.Equal(t, formulaErrorREF, result)
+	// Test validate formula with spilled range reference
+	assert.NoError(t, f.ValidateFormula("Sheet1", "=SUM(A1#)"))
+}
+
 func TestEvalInfixExp(t *testing.T) {
 	f := NewFile()
 	arg, err := f.evalInfixExp(nil, "Sheet1", "A1", []efp.Token{
@@ -6229,4 +8344,419 @@ func TestParseToken(t *testing.T) {
 	assert.Equal(t, formulaErrorNAME, f.parseToken(nil, "Sheet1",
 		efp.Token{TSubType: efp.TokenSubTypeRange, TValue: "1A"}, nil, nil,
 	).Error())
-}
\ No newline at end of file
+}
+
+func TestRegisterFunction(t *testing.T) {
+	f := prepareCalcData([][]interface{}{{1, 2}, {3, 4}})
+	assert.NoError(t, f.RegisterFunction("myadd", func(args []FormulaArgument) (FormulaResult, error) {
+		var sum float64
+		for _, arg := range args {
+			if arg.Type == ArgMatrix {
+				for _, row := range arg.Matrix {
+					for _, cell := range row {
+						sum += cell.Number
+					}
+				}
+				continue
+			}
+			sum += arg.Number
+		}
+		return FormulaResult{Type: ArgNumber, Number: sum}, nil
+	}))
+	assert.NoError(t, f.RegisterFunction("MYERR", func(args []FormulaArgument) (FormulaResult, error) {
+		if len(args) == 0 {
+			return FormulaResult{}, ErrParameterInvalid
+		}
+		return FormulaResult{Type: ArgError, String: formulaErrorNA}, nil
+	}))
+	assert.NoError(t, f.RegisterFunction("MYTYPE", func(args []FormulaArgument) (FormulaResult, error) {
+		if args[0].Type == ArgNumber && args[0].Boolean {
+			return FormulaResult{Type: ArgString, String: "logical"}, nil
+		}
+		return FormulaResult{Type: ArgString, String: "text"}, nil
+	}))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A3", true))
+	for formula, expected := range map[string]string{
+		"=MYTYPE(TRUE)":        "logical",
+		"=MYTYPE(A3)":          "logical",
+		"=MYTYPE(1<2)":         "logical",
+		"=MYTYPE(\"TRUE\")":    "text",
+		"=MYADD(1,2)":          "3",
+		"=myadd(A1,B2)":        "5",
+		"=MYADD(A1:B2)":        "10",
+		"=SUM(MYADD(1,2),1)":   "4",
+		"=MYADD(1,2)*2":        "6",
+		"=IFERROR(MYERR(1),0)": "0",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=MYERR()"))
+	result, err := f.CalcCellValue("Sheet1", "C1")
+	assert.Equal(t, formulaErrorVALUE, result)
+	assert.EqualError(t, err, ErrParameterInvalid.Error())
+	// Test register custom function with invalid name
+	for _, name := range []string{"", "1ADD", "MY.ADD", "MY ADD", strings.Repeat("A", MaxFieldLength+1)} {
+		assert.EqualError(t, f.RegisterFunction(name, func(args []FormulaArgument) (FormulaResult, error) {
+			return FormulaResult{}, nil
+		}), "invalid function name "+strings.ToUpper(name))
+	}
+	// Test register custom function with built-in function name
+	assert.EqualError(t, f.RegisterFunction("sum", func(args []FormulaArgument) (FormulaResult, error) {
+		return FormulaResult{}, nil
+	}), "built-in function SUM can not be overridden")
+	// Test register custom function without function
+	assert.Equal(t, ErrParameterInvalid, f.RegisterFunction("MYFUNC", nil))
+	// Test custom function is not available for other workbooks
+	f = prepareCalcData([][]interface{}{{1, 2}})
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=MYADD(1,2)"))
+	_, err = f.CalcCellValue("Sheet1", "C1")
+	assert.EqualError(t, err, "not support MYADD function")
+}
+
+func TestSetExternalWorkbook(t *testing.T) {
+	ext := prepareCalcData([][]interface{}{{10, 20}, {30, 40}})
+	assert.NoError(t, ext.SetCellFormula("Sheet1", "C1", "=A1+B2"))
+	_, err := ext.NewSheet("Sheet 2")
+	assert.NoError(t, err)
+	assert.NoError(t, ext.SetCellValue("Sheet 2", "A1", 5))
+	f := prepareCalcData([][]interface{}{{1, 2}})
+	f.SetExternalWorkbook("Budget.xlsx", ext)
+	// Test resolve the index form external references by the external links
+	wb, err := f.workbookReader()
+	assert.NoError(t, err)
+	wb.ExternalReferences = &xlsxExternalReferences{ExternalReference: []xlsxExternalReference{{RID: "rId8"}}}
+	rels, err := f.relsReader(f.getWorkbookRelsPath())
+	assert.NoError(t, err)
+	rels.Relationships = append(rels.Relationships, xlsxRelationship{ID: "rId8", Target: "externalLinks/externalLink1.xml"})
+	f.Pkg.Store("xl/externalLinks/_rels/externalLink1.xml.rels", []byte(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/externalLinkPath" Target="file:///C:\Data\Budget.xlsx" TargetMode="External"/></Relationships>`))
+	for formula, expected := range map[string]string{
+		"=[1]Sheet1!A1":                             "10",
+		"=SUM('[1]Sheet 2'!A1,[1]Sheet1!B2)":        "45",
+		"=IFERROR([2]Sheet1!A1,\"none\")":           "none",
+		"=[Budget.xlsx]Sheet1!A1":                   "10",
+		"='[Budget.xlsx]Sheet1'!$B$2":               "40",
+		"=[budget.xlsx]Sheet1!C1":                   "50",
+		"=SUM([Budget.xlsx]Sheet1!A1:B2)":           "100",
+		"=SUM([Budget.xlsx]Sheet1!A1:B2,A1:B1)":     "103",
+		"=A1+'[Budget.xlsx]Sheet 2'!A1":             "6",
+		"=IFERROR([Other.xlsx]Sheet1!A1,\"none\")":  "none",
+		"=IFERROR([Budget.xlsx]Sheet3!A1,\"none\")": "none",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	// Test calculate the external reference to the unregistered workbook
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=SUM([Other.xlsx]Sheet1!A1:B2)"))
+	result, err := f.CalcCellValue("Sheet1", "C1")
+	assert.Equal(t, formulaErrorREF, result)
+	assert.EqualError(t, err, formulaErrorREF)
+	// Test calculate the circular references between the workbooks
+	ext.SetExternalWorkbook("Main.xlsx", f)
+	assert.NoError(t, ext.SetCellFormula("Sheet1", "D1", "=[Main.xlsx]Sheet1!C1+1"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=[Budget.xlsx]Sheet1!D1+1"))
+	result, err = f.CalcCellValue("Sheet1", "C1")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", result)
+	// Test unregister the external workbook
+	f.SetExternalWorkbook("BUDGET.XLSX", nil)
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=SUM([Budget.xlsx]Sheet1!A1)"))
+	result, err = f.CalcCellValue("Sheet1", "C1")
+	assert.Equal(t, formulaErrorREF, result)
+	assert.EqualError(t, err, formulaErrorREF)
+	// Test external workbooks are not available for other workbooks
+	f = prepareCalcData([][]interface{}{{1, 2}})
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=SUM([Budget.xlsx]Sheet1!A1)"))
+	result, err = f.CalcCellValue("Sheet1", "C1")
+	assert.Equal(t, formulaErrorREF, result)
+	assert.EqualError(t, err, formulaErrorREF)
+}
+
+func TestClearCalcCache(t *testing.T) {
+	f := prepareCalcData([][]interface{}{{1, 2}})
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=SUM(A1:B1)*2"))
+	result, err := f.CalcCellValue("Sheet1", "C1")
+	assert.NoError(t, err)
+	assert.Equal(t, "6", result)
+	tokens, ok := f.calcTokens.load("=SUM(A1:B1)*2")
+	assert.True(t, ok)
+	assert.Equal(t, 1, f.calcTokens.len())
+	// Test the memoized tokens are reused for the formulas with the same text
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C2", "=SUM(A1:B1)*2"))
+	result, err = f.CalcCellValue("Sheet1", "C2")
+	assert.NoError(t, err)
+	assert.Equal(t, "6", result)
+	assert.Equal(t, 1, f.calcTokens.len())
+	// Test the parsed tokens are dropped and parsed again after clearing
+	f.ClearCalcCache()
+	_, ok = f.calcTokens.load("=SUM(A1:B1)*2")
+	assert.False(t, ok)
+	assert.Equal(t, 0, f.calcTokens.len())
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A1", &[]interface{}{10, 20}))
+	result, err = f.CalcCellValue("Sheet1", "C1")
+	assert.NoError(t, err)
+	assert.Equal(t, "60", result)
+	reparsed, ok := f.calcTokens.load("=SUM(A1:B1)*2")
+	assert.True(t, ok)
+	assert.Equal(t, tokens, reparsed)
+	// Test evict the oldest formula when the cache is full
+	for i := 0; i <= formulaTokensCacheSize; i++ {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", fmt.Sprintf("=SUM(A1:B1)*%d", i)))
+		_, err = f.CalcCellValue("Sheet1", "C1")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, formulaTokensCacheSize, f.calcTokens.len())
+	_, ok = f.calcTokens.load("=SUM(A1:B1)*2")
+	assert.False(t, ok)
+	_, ok = f.calcTokens.load(fmt.Sprintf("=SUM(A1:B1)*%d", formulaTokensCacheSize))
+	assert.True(t, ok)
+	// Test clear the calculation cache of the workbook without cache
+	f = NewFile()
+	f.ClearCalcCache()
+	assert.Equal(t, 0, f.calcTokens.len())
+}
+
+func TestValidateFormula(t *testing.T) {
+	f := NewFile()
+	for _, formula := range []string{
+		"=SUM(A1:A3)",
+		"=IF(A1>1,SUM(1,2),\"x\")",
+		"=sum((1+2)*3)",
+		"=SUM({1,2,3})",
+		"=_xlfn.HYPGEOM.DIST(1,4,8,20,TRUE)",
+		"=INDEX(A1:B2,1,)",
+		"=A1+1",
+	} {
+		assert.NoError(t, f.ValidateFormula("Sheet1", formula), formula)
+	}
+	for formula, expected := range map[string]string{
+		"=SUMM(1)":           "unsupported formula function SUMM",
+		"=SUM(1,NOSUCH(2))":  "unsupported formula function NOSUCH",
+		"=SUM(1,2":           "formula has mismatched parentheses",
+		"=SUM(1,2))":         "formula has mismatched parentheses",
+		"=(1+2":              "formula has mismatched parentheses",
+		"=ISODD(1,2)":        "ISODD requires 1 argument",
+		"=INDEX(A1:B2)":      "INDEX requires 2 or 3 arguments",
+		"=IMSUM()":           "IMSUM requires at least 1 argument",
+		"=SUM(ISODD(1,2),1)": "ISODD requires 1 argument",
+		"=TRUNC(1,2,3)":      "TRUNC requires 1 or 2 arguments",
+		"=SUMIF(A1,1,A1,1)":  "SUMIF requires 2 or 3 arguments",
+	} {
+		assert.EqualError(t, f.ValidateFormula("Sheet1", formula), expected, formula)
+	}
+	// Test the number of arguments is checked on calculation for the functions
+	// which not listed in the arity table
+	assert.NoError(t, f.ValidateFormula("Sheet1", "=ABS(1,2)"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "A1", "=ABS(1,2)"))
+	result, err := f.CalcCellValue("Sheet1", "A1")
+	assert.Equal(t, formulaErrorVALUE, result)
+	assert.EqualError(t, err, "ABS requires 1 numeric argument")
+	// Test validate formula with custom formula function
+	assert.EqualError(t, f.ValidateFormula("Sheet1", "=MYFUNC(1)"), "unsupported formula function MYFUNC")
+	assert.NoError(t, f.RegisterFunction("MYFUNC", func(args []FormulaArgument) (FormulaResult, error) {
+		return FormulaResult{}, nil
+	}))
+	assert.NoError(t, f.ValidateFormula("Sheet1", "=MYFUNC(1)"))
+	// Test validate formula on not exists worksheet
+	assert.EqualError(t, f.ValidateFormula("SheetN", "=SUM(1)"), "sheet SheetN does not exist")
+}
+
+func TestCalcCellValueContext(t *testing.T) {
+	f := prepareCalcData([][]interface{}{{1, 2}})
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=SUM(A1:B1)"))
+	result, err := f.CalcCellValueContext(context.Background(), "Sheet1", "C1")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", result)
+	// Test calculate with the canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = f.CalcCellValueContext(ctx, "Sheet1", "C1")
+	assert.ErrorIs(t, err, context.Canceled)
+	// Test an expensive calculation returns in time after the deadline exceeded
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=SUM(A:A,B:B)+D1"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "D1", "=SUMPRODUCT(A:A,B:B)"))
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = f.CalcCellValueContext(ctx, "Sheet1", "C1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestCalcPureCalc(t *testing.T) {
+	f := prepareCalcData([][]interface{}{{1, 2}})
+	for formula, expected := range map[string]string{
+		"SUM(A1:B1)":       "3",
+		"INDIRECT(\"B1\")": "2",
+	} {
+		ps := efp.ExcelParser()
+		ctx := &calcContext{pureCalc: true, iterations: make(map[string]uint), iterationsCache: make(map[string]formulaArg)}
+		result, err := f.evalInfixExp(ctx, "Sheet1", "C1", ps.Parse(formula))
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result.Value(), formula)
+	}
+	for formula, expected := range map[string]string{
+		"NOW()":                        "NOW function is not allowed in pure calculation",
+		"SUM(1,RAND())":                "RAND function is not allowed in pure calculation",
+		"randbetween(1,2)":             "RANDBETWEEN function is not allowed in pure calculation",
+		"INDIRECT(\"Sheet2!A1\")":      "INDIRECT function is not allowed in pure calculation",
+		"INDIRECT(\"A1:'Sheet2'!B1\")": "INDIRECT function is not allowed in pure calculation",
+		"OFFSET(A1,0,1)":               "OFFSET function is not allowed in pure calculation",
+		"INFO(\"osversion\")":          "INFO function is not allowed in pure calculation",
+		"CELL(\"address\",A1)":         "CELL function is not allowed in pure calculation",
+	} {
+		ps := efp.ExcelParser()
+		ctx := &calcContext{pureCalc: true, iterations: make(map[string]uint), iterationsCache: make(map[string]formulaArg)}
+		result, err := f.evalInfixExp(ctx, "Sheet1", "C1", ps.Parse(formula))
+		assert.EqualError(t, err, expected, formula)
+		assert.Equal(t, formulaErrorVALUE, result.String, formula)
+	}
+	// Test calculate cell value with the pure calculation option
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=SUM(A1:B1)+RAND()"))
+	result, err := f.CalcCellValue("Sheet1", "C1", Options{PureCalc: true})
+	assert.Equal(t, formulaErrorVALUE, result)
+	assert.EqualError(t, err, "RAND function is not allowed in pure calculation")
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=SUM(A1:B1)"))
+	result, err = f.CalcCellValue("Sheet1", "C1", Options{PureCalc: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "3", result)
+	_, err = f.EvalFormula("Sheet1", "C1", "=NOW()", Options{PureCalc: true})
+	assert.EqualError(t, err, "NOW function is not allowed in pure calculation")
+}
+
+func TestCalcUnsupportedFunctionError(t *testing.T) {
+	f := prepareCalcData([][]interface{}{{1, 2}})
+	assert.NoError(t, f.RegisterFunction("MYADD", func(args []FormulaArgument) (FormulaResult, error) {
+		return FormulaResult{Type: ArgNumber, Number: 1}, nil
+	}))
+	// Test calculate formula with unsupported function in legacy mode
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "=UNSUPPORT(A1)"))
+	result, err := f.CalcCellValue("Sheet1", "C1")
+	assert.Equal(t, formulaErrorVALUE, result)
+	assert.EqualError(t, err, "not support UNSUPPORT function")
+	// Test calculate formula with unsupported function in strict mode
+	for formula, expected := range map[string]string{
+		"=UNSUPPORT(A1)":            "UNSUPPORT",
+		"=SUM(1,UNSUPPORT(A1))":     "UNSUPPORT",
+		"=_xlfn.UNSUPPORT.DIST(A1)": "UNSUPPORT.DIST",
+		"=UNSUPPORT(SQRT(-1))":      "UNSUPPORT",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1", Options{UnsupportedFunctionError: true})
+		assert.Equal(t, formulaErrorVALUE, result, formula)
+		assert.Equal(t, ErrUnsupportedFunction{FuncName: expected}, err, formula)
+		assert.EqualError(t, err, fmt.Sprintf("unsupported formula function %s", expected), formula)
+	}
+	for formula, expected := range map[string]string{
+		"=SUM(A1:B1)":                  "3",
+		"=_xlfn.NORM.S.DIST(0.8,TRUE)": "0.788144601416603",
+		"=MYADD(A1)+1":                 "2",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1", Options{UnsupportedFunctionError: true})
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	// Test evaluate formula with unsupported function in strict mode
+	_, err = f.EvalFormula("Sheet1", "C1", "=UNSUPPORT(A1)", Options{UnsupportedFunctionError: true})
+	assert.Equal(t, ErrUnsupportedFunction{FuncName: "UNSUPPORT"}, err)
+}
+
+func TestCheckFormulaArgsCount(t *testing.T) {
+	newArgs := func(n int) *list.List {
+		args := list.New()
+		for i := 0; i < n; i++ {
+			args.PushBack(newNumberFormulaArg(1))
+		}
+		return args
+	}
+	for _, c := range []struct {
+		name     string
+		argsLen  int
+		expected string
+	}{
+		{"CHAR", 0, "CHAR requires 1 argument"},
+		{"COUNTIF", 3, "COUNTIF requires 2 arguments"},
+		{"ACCRINTM", 3, "ACCRINTM requires 4 or 5 arguments"},
+		{"ACCRINTM", 6, "ACCRINTM requires 4 or 5 arguments"},
+		{"AVERAGEIFS", 2, "AVERAGEIFS requires at least 3 arguments"},
+		{"AVEDEV", 0, "AVEDEV requires at least 1 argument"},
+		{"ACCRINT", 9, "ACCRINT allows at most 8 arguments"},
+	} {
+		arg := checkFormulaArgsCount(c.name, newArgs(c.argsLen))
+		assert.Equal(t, ArgError, arg.Type, c.name)
+		assert.Equal(t, formulaErrorVALUE, arg.String, c.name)
+		assert.Equal(t, c.expected, arg.Error, c.name)
+	}
+	assert.Equal(t, ArgEmpty, checkFormulaArgsCount("CHAR", newArgs(1)).Type)
+	assert.Equal(t, ArgEmpty, checkFormulaArgsCount("AVEDEV", newArgs(300)).Type)
+	assert.Equal(t, ArgEmpty, checkFormulaArgsCount("UNKNOWN", newArgs(0)).Type)
+	// Test all functions with arity metadata returns the uniform error message
+	// for under-supplied and over-supplied arguments
+	for name, arity := range formulaFuncsArity {
+		method := strings.NewReplacer("_xlfn.", "", ".", "dot").Replace(name)
+		for _, argsLen := range []int{arity[0] - 1, arity[1] + 1} {
+			if argsLen < 0 || arity[1] == -1 && argsLen == 0 {
+				continue
+			}
+			args := newArgs(argsLen)
+			expected := checkFormulaArgsCount(name, args)
+			assert.Equal(t, ArgError, expected.Type, name)
+			assert.Equal(t, expected, callFuncByName(&formulaFuncs{}, method, []reflect.Value{reflect.ValueOf(args)}), name)
+		}
+	}
+}
+
+func TestFormulaFuncsTable(t *testing.T) {
+	typ := reflect.TypeOf(&formulaFuncs{})
+	for i := 0; i < typ.NumMethod(); i++ {
+		_, ok := formulaFuncsTable[typ.Method(i).Name]
+		assert.True(t, ok, typ.Method(i).Name)
+	}
+	assert.Len(t, formulaFuncsTable, typ.NumMethod())
+	// Test table dispatch returns identical results with the reflection
+	for name, args := range map[string][]formulaArg{
+		"ABS":         {newNumberFormulaArg(-2)},
+		"CONCAT":      {newStringFormulaArg("a"), newNumberFormulaArg(1)},
+		"IF":          {newBoolFormulaArg(false), newNumberFormulaArg(1), newStringFormulaArg("x")},
+		"MAX":         {newNumberFormulaArg(1), newNumberFormulaArg(3), newNumberFormulaArg(2)},
+		"SQRT":        {newNumberFormulaArg(-1)},
+		"SUM":         {newNumberFormulaArg(1), newErrorFormulaArg(formulaErrorNA, formulaErrorNA)},
+		"VLOOKUP":     {newNumberFormulaArg(1)},
+		"IFS":         {newBoolFormulaArg(true)},
+		"NOTEXISTING": {newNumberFormulaArg(1)},
+	} {
+		argsList := list.New()
+		for _, arg := range args {
+			argsList.PushBack(arg)
+		}
+		params := []reflect.Value{reflect.ValueOf(argsList)}
+		expected := newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("not support %s function", name))
+		if method := reflect.ValueOf(&formulaFuncs{}).MethodByName(name); method.IsValid() {
+			expected = method.Call(params)[0].Interface().(formulaArg)
+		}
+		assert.Equal(t, expected, callFuncByName(&formulaFuncs{}, name, params), name)
+	}
+}
+
+func BenchmarkCallFuncByName(b *testing.B) {
+	argsList := list.New()
+	for i := 0; i < 10; i++ {
+		argsList.PushBack(newNumberFormulaArg(float64(i)))
+	}
+	params := []reflect.Value{reflect.ValueOf(argsList)}
+	b.Run("reflection", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			reflect.ValueOf(&formulaFuncs{}).MethodByName("SUM").Call(params)
+		}
+	})
+	b.Run("table", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			callFuncByName(&formulaFuncs{}, "SUM", params)
+		}
+	})
+}
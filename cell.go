@@ -1,5 +1,4 @@
 
-
 package excelize
 
 import (
@@ -1000,6 +999,46 @@ func (f *File) GetCellRichText(sheet, cell string) (runs []RichTextRun, err erro
 	return
 }
 
+// getCellPhonetic provides a function to get the concatenated phonetic
+// (furigana) text runs of the string cell by given worksheet name and cell
+// reference, the ok will be false if the cell has no phonetic text runs.
+func (f *File) getCellPhonetic(sheet, cell string) (phonetic string, ok bool, err error) {
+	phonetic, err = f.getCellStringFunc(sheet, cell, func(x *xlsxWorksheet, c *xlsxC) (string, bool, error) {
+		var si *xlsxSI
+		switch c.T {
+		case "inlineStr":
+			si = c.IS
+		case "s":
+			siIdx, err := strconv.Atoi(strings.TrimSpace(c.V))
+			if err != nil {
+				return "", true, nil
+			}
+			sst, err := f.sharedStringsReader()
+			if err != nil {
+				return "", true, err
+			}
+			sst.mu.Lock()
+			defer sst.mu.Unlock()
+			if len(sst.SI) <= siIdx || siIdx < 0 {
+				return "", true, nil
+			}
+			si = &sst.SI[siIdx]
+		}
+		if si == nil {
+			return "", true, nil
+		}
+		var val string
+		for _, run := range si.RPh {
+			if run != nil {
+				val += run.T
+				ok = true
+			}
+		}
+		return val, true, nil
+	})
+	return
+}
+
 // newRpr create run properties for the rich text by given font format.
 func newRpr(fnt *Font) *xlsxRPr {
 	rpr := xlsxRPr{}